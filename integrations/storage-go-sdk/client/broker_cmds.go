@@ -9,50 +9,36 @@ import (
 
 // BrokerSubscribe implements the client side of the protocol.ServerCommandBrokerSubscribe command.
 func (c *Client) BrokerSubscribe(db, coll string, subject string, fn func(msg []byte)) error {
-	totalLen := 2 + len(subject)
-	payload := make([]byte, totalLen)
-
-	// Subject
-	binary.BigEndian.PutUint16(payload[0:2], uint16(len(subject)))
-	copy(payload[2:2+len(subject)], []byte(subject))
-
-	resp, err := c.SendCmd(&protocol.Command{
-		ID:             protocol.ServerCommandBrokerSubscribe,
-		DatabaseName:   db,
-		CollectionName: coll,
-		Payload:        payload,
-	})
-	if err != nil {
-		return err
-	}
-
-	if resp.Code != protocol.StatusOK {
-		return ErrUnexpectedStatusCode
-	}
-
-	c.brokerSubjectListenersMu.Lock()
-	c.brokerSubjectListeners[db+"."+coll+"."+subject] = append(c.brokerSubjectListeners[db+"."+coll+"."+subject], fn)
-	c.brokerSubjectListenersMu.Unlock()
-
-	return nil
+	return c.BrokerSubscribeWithOptions(db, coll, subject, "", BrokerSubscribeOptions{}, fn)
 }
 
 // BrokerSubscribeQueue implements the client side of the protocol.ServerCommandBrokerSubscribeQueue command.
 func (c *Client) BrokerSubscribeQueue(db, coll string, subject, queue string, fn func(msg []byte)) error {
-	totalLen := 2 + len(subject) + 2 + len(queue)
-	payload := make([]byte, totalLen)
+	return c.BrokerSubscribeWithOptions(db, coll, subject, queue, BrokerSubscribeOptions{}, fn)
+}
 
-	// Subject
-	binary.BigEndian.PutUint16(payload[0:2], uint16(len(subject)))
-	copy(payload[2:2+len(subject)], []byte(subject))
+// BrokerSubscribeWithOptions subscribes to the subject like BrokerSubscribe, or like BrokerSubscribeQueue if queue is not empty,
+// and configures how the server handles the subscription if the client does not keep up with the publishers.
+// See OnBrokerSlowConsumer to get notified when messages are dropped.
+func (c *Client) BrokerSubscribeWithOptions(db, coll string, subject, queue string, opts BrokerSubscribeOptions, fn func(msg []byte)) error {
+	cmdID := protocol.ServerCommandBrokerSubscribe
+	payload := make([]byte, 0, 2+len(subject)+2+len(queue)+1+4)
+
+	// Subject and queue group
+	payload = appendKey(payload, subject)
+	if queue != "" {
+		cmdID = protocol.ServerCommandBrokerSubscribeQueue
+		payload = appendKey(payload, queue)
+	}
 
-	// Queue
-	queueOffset := 2 + len(subject)
-	binary.BigEndian.PutUint16(payload[queueOffset:queueOffset+2], uint16(len(queue)))
-	copy(payload[queueOffset+2:queueOffset+2+len(queue)], []byte(queue))
+	// Slow consumer policy, the server applies its defaults if it is missing
+	if opts != (BrokerSubscribeOptions{}) {
+		payload = append(payload, byte(opts.Policy))
+		payload = binary.BigEndian.AppendUint32(payload, uint32(opts.BlockTimeout.Milliseconds()))
+	}
 
 	resp, err := c.SendCmd(&protocol.Command{
-		ID:             protocol.ServerCommandBrokerSubscribeQueue,
+		ID:             cmdID,
 		DatabaseName:   db,
 		CollectionName: coll,
 		Payload:        payload,
@@ -64,6 +50,11 @@ func (c *Client) BrokerSubscribeQueue(db, coll string, subject, queue string, fn
 	if resp.Code != protocol.StatusOK {
 		return ErrUnexpectedStatusCode
 	}
+
+	c.brokerSubjectListenersMu.Lock()
+	c.brokerSubjectListeners[db+"."+coll+"."+subject] = append(c.brokerSubjectListeners[db+"."+coll+"."+subject], fn)
+	c.brokerSubjectListenersMu.Unlock()
+
 	return nil
 }
 
@@ -94,11 +85,23 @@ func (c *Client) BrokerUnsubscribe(db, coll string, subject string) error {
 	delete(c.brokerSubjectListeners, db+"."+coll+"."+subject)
 	c.brokerSubjectListenersMu.Unlock()
 
+	c.brokerHeaderListenersMu.Lock()
+	delete(c.brokerHeaderListeners, db+"."+coll+"."+subject)
+	c.brokerHeaderListenersMu.Unlock()
+
+	removeBrokerStreamListeners(&c.brokerStreamListenersMu, c.brokerStreamListeners, db+"."+coll+"."+subject)
+
 	return nil
 }
 
 // BrokerPublish implements the client side of the protocol.ServerCommandBrokerPublish command.
 func (c *Client) BrokerPublish(db, coll string, subject string, msg []byte) error {
+	_, err := c.BrokerPublishSequenced(db, coll, subject, msg)
+	return err
+}
+
+// BrokerPublishSequenced is like BrokerPublish, but returns the sequence number of the message if the collection is a stream, otherwise 0.
+func (c *Client) BrokerPublishSequenced(db, coll string, subject string, msg []byte) (uint64, error) {
 	payload := make([]byte, 2+len(subject))
 
 	// Subject
@@ -115,11 +118,16 @@ func (c *Client) BrokerPublish(db, coll string, subject string, msg []byte) erro
 		Payload:        payload,
 	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if resp.Code != protocol.StatusOK {
-		return ErrUnexpectedStatusCode
+		return 0, ErrUnexpectedStatusCode
 	}
-	return nil
+
+	if len(resp.Payload) < 8 {
+		return 0, nil
+	}
+
+	return binary.BigEndian.Uint64(resp.Payload[0:8]), nil
 }
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// BrokerSubscribeConsumer implements the client side of the protocol.ServerCommandBrokerSubscribeConsumer command.
+// Returns ErrNotStream if the collection is no stream.
+func (c *Client) BrokerSubscribeConsumer(db, coll string, cfg BrokerConsumerConfig, fn func(msg *BrokerConsumerMessage)) error {
+	payload := make([]byte, 0, 2+len(cfg.Name)+2+len(cfg.Subject)+1+8+8+4+2+len(cfg.DeadLetterSubject))
+
+	// Name and subject
+	payload = appendKey(payload, cfg.Name)
+	payload = appendKey(payload, cfg.Subject)
+
+	// Start position
+	payload = append(payload, byte(cfg.Start.Policy))
+	switch cfg.Start.Policy {
+	case BrokerStartSequence:
+		payload = binary.BigEndian.AppendUint64(payload, cfg.Start.Sequence)
+	case BrokerStartTime:
+		payload = binary.BigEndian.AppendUint64(payload, uint64(cfg.Start.Time.UnixMilli()))
+	default:
+		payload = binary.BigEndian.AppendUint64(payload, 0)
+	}
+
+	// Redelivery
+	payload = binary.BigEndian.AppendUint64(payload, uint64(cfg.AckWait.Milliseconds()))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(cfg.MaxDeliveries))
+	payload = appendKey(payload, cfg.DeadLetterSubject)
+
+	// Register the listener first, the server starts delivering pending messages right away
+	key := db + "." + coll + "." + cfg.Name
+	listener := newBrokerStreamListener(fn)
+	addBrokerStreamListener(&c.brokerConsumerListenersMu, c.brokerConsumerListeners, key, listener)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandBrokerSubscribeConsumer,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err == nil {
+		switch resp.Code {
+		case protocol.StatusOK:
+			return nil
+		case protocol.StatusCollectionNotFound:
+			err = ErrCollectionNotFound
+		case protocol.StatusCommandNotAllowed:
+			err = ErrNotStream
+		default:
+			err = ErrUnexpectedStatusCode
+		}
+	}
+
+	removeBrokerStreamListener(&c.brokerConsumerListenersMu, c.brokerConsumerListeners, key, listener)
+	return err
+}
+
+// BrokerUnsubscribeConsumer implements the client side of the protocol.ServerCommandBrokerUnsubscribeConsumer command.
+// The consumer is kept on the server, subscribing to it again continues where it left off.
+func (c *Client) BrokerUnsubscribeConsumer(db, coll string, name string) error {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandBrokerUnsubscribeConsumer,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        appendKey(nil, name),
+	})
+	if err != nil {
+		return err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+	case protocol.StatusNotFound:
+		return ErrConsumerNotFound
+	default:
+		return ErrUnexpectedStatusCode
+	}
+
+	removeBrokerStreamListeners(&c.brokerConsumerListenersMu, c.brokerConsumerListeners, db+"."+coll+"."+name)
+
+	return nil
+}
+
+// BrokerAck implements the client side of the protocol.ServerCommandBrokerAck command.
+// Returns ErrNotPending if the message is not waiting for an acknowledgement, e.g. because it was delivered again in the meantime.
+func (c *Client) BrokerAck(db, coll string, consumer string, seq uint64) error {
+	return c.sendBrokerPendingCmd(protocol.ServerCommandBrokerAck, db, coll, consumer, seq)
+}
+
+// BrokerNak implements the client side of the protocol.ServerCommandBrokerNak command.
+// Returns ErrNotPending if the message is not waiting for an acknowledgement.
+func (c *Client) BrokerNak(db, coll string, consumer string, seq uint64) error {
+	return c.sendBrokerPendingCmd(protocol.ServerCommandBrokerNak, db, coll, consumer, seq)
+}
+
+// BrokerInProgress implements the client side of the protocol.ServerCommandBrokerInProgress command.
+// Returns ErrNotPending if the message is not waiting for an acknowledgement.
+func (c *Client) BrokerInProgress(db, coll string, consumer string, seq uint64) error {
+	return c.sendBrokerPendingCmd(protocol.ServerCommandBrokerInProgress, db, coll, consumer, seq)
+}
+
+// sendBrokerPendingCmd sends a command that addresses a pending message of a durable consumer.
+// Payload format: | name length (2 bytes) | name (variable) | sequence (8 bytes) |
+func (c *Client) sendBrokerPendingCmd(id uint16, db, coll string, consumer string, seq uint64) error {
+	payload := appendKey(make([]byte, 0, 2+len(consumer)+8), consumer)
+	payload = binary.BigEndian.AppendUint64(payload, seq)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             id,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+		return nil
+	case protocol.StatusCollectionNotFound:
+		return ErrCollectionNotFound
+	case protocol.StatusCommandNotAllowed:
+		return ErrNotStream
+	case protocol.StatusNotFound:
+		return ErrNotPending
+	default:
+		return ErrUnexpectedStatusCode
+	}
+}
+
+// dispatchBrokerConsumerMessages passes the messages of a protocol.ClientCommandBrokerConsumerMessage command to the listeners of the consumer.
+// Each message is encoded as | sequence (8 bytes) | timestamp (8 bytes) | deliveries (4 bytes) | subject length (2 bytes) | subject (variable) |
+// ID length (2 bytes) | ID (variable) | header count (2 bytes) | header entries (variable) | data (variable) |
+func (c *Client) dispatchBrokerConsumerMessages(db, coll, consumer string, values []*codex.Value) {
+	msgs := make([]*BrokerConsumerMessage, 0, len(values))
+	for _, value := range values {
+		data := value.AsBinary()
+		if len(data) < 8+8+4 {
+			continue
+		}
+
+		msg := BrokerConsumerMessage{
+			BrokerStreamMessage: BrokerStreamMessage{
+				Sequence:  binary.BigEndian.Uint64(data[0:8]),
+				Timestamp: int64(binary.BigEndian.Uint64(data[8:16])),
+			},
+			Consumer:   consumer,
+			Deliveries: int(binary.BigEndian.Uint32(data[16:20])),
+		}
+		if !decodeBrokerStreamFields(&msg.BrokerStreamMessage, data[20:]) {
+			continue
+		}
+
+		msgs = append(msgs, &msg)
+	}
+
+	dispatchToBrokerStreamListeners(&c.brokerConsumerListenersMu, c.brokerConsumerListeners, db+"."+coll+"."+consumer, msgs)
+}
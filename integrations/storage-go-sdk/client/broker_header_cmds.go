@@ -0,0 +1,183 @@
+package client
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// BrokerPublishWithHeader implements the client side of the protocol.ServerCommandBrokerPublishWithHeader command.
+// It returns the published message with the ID, timestamp and sequence number the server assigned to it.
+func (c *Client) BrokerPublishWithHeader(db, coll string, subject string, header map[string]string, msg []byte) (*BrokerMessage, error) {
+	payload := make([]byte, 0, 2+len(subject)+2+len(msg)+8)
+
+	// Subject and header
+	payload = appendKey(payload, subject)
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(header)))
+	for k, v := range header {
+		payload = appendKey(payload, k)
+		payload = appendKey(payload, v)
+	}
+
+	// Message
+	payload = append(payload, codex.EncodeBinary(msg)...)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandBrokerPublishWithHeader,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+	case protocol.StatusCollectionNotFound:
+		return nil, ErrCollectionNotFound
+	default:
+		return nil, ErrUnexpectedStatusCode
+	}
+
+	data := resp.Payload
+	if len(data) < 2 || len(data) < 2+int(binary.BigEndian.Uint16(data[0:2]))+8+8 {
+		return nil, ErrInvalidPayloadLength
+	}
+	idLen := int(binary.BigEndian.Uint16(data[0:2]))
+
+	return &BrokerMessage{
+		ID:        string(data[2 : 2+idLen]),
+		Timestamp: int64(binary.BigEndian.Uint64(data[2+idLen : 2+idLen+8])),
+		Sequence:  binary.BigEndian.Uint64(data[2+idLen+8 : 2+idLen+16]),
+		Subject:   subject,
+		Header:    header,
+		Data:      msg,
+	}, nil
+}
+
+// BrokerSubscribeWithHeader subscribes like BrokerSubscribeWithOptions, but messages are delivered together with their ID, timestamp and header.
+func (c *Client) BrokerSubscribeWithHeader(db, coll string, subject, queue string, opts BrokerSubscribeOptions, fn func(msg *BrokerMessage)) error {
+	cmdID := protocol.ServerCommandBrokerSubscribe
+	payload := make([]byte, 0, 2+len(subject)+2+len(queue)+1+4+1)
+
+	// Subject and queue group
+	payload = appendKey(payload, subject)
+	if queue != "" {
+		cmdID = protocol.ServerCommandBrokerSubscribeQueue
+		payload = appendKey(payload, queue)
+	}
+
+	// Options
+	payload = append(payload, byte(opts.Policy))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(opts.BlockTimeout.Milliseconds()))
+	payload = append(payload, 1) // with header
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             cmdID,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return ErrUnexpectedStatusCode
+	}
+
+	key := db + "." + coll + "." + subject
+	c.brokerHeaderListenersMu.Lock()
+	c.brokerHeaderListeners[key] = append(c.brokerHeaderListeners[key], fn)
+	c.brokerHeaderListenersMu.Unlock()
+
+	return nil
+}
+
+// dispatchBrokerHeaderMessages passes the messages of a protocol.ClientCommandBrokerHeaderMessage command to the listeners of the subscription.
+// Each message is encoded as | ID length (2 bytes) | ID (variable) | timestamp (8 bytes) | sequence (8 bytes) | subject length (2 bytes) | subject (variable) |
+// header count (2 bytes) | header entries (variable) | data (variable) |, each header entry as | key length (2 bytes) | key (variable) | value length (2 bytes) | value (variable) |
+func (c *Client) dispatchBrokerHeaderMessages(key string, values []*codex.Value) {
+	c.brokerHeaderListenersMu.Lock()
+	listeners := c.brokerHeaderListeners[key]
+	c.brokerHeaderListenersMu.Unlock()
+
+	for _, value := range values {
+		msg, ok := decodeBrokerMessage(value.AsBinary())
+		if !ok {
+			continue
+		}
+
+		for _, listener := range listeners {
+			go listener(msg)
+		}
+	}
+}
+
+// decodeBrokerMessage decodes a message encoded as described at dispatchBrokerHeaderMessages, it returns false if data is too short.
+func decodeBrokerMessage(data []byte) (*BrokerMessage, bool) {
+	var msg BrokerMessage
+	var ok bool
+
+	if msg.ID, data, ok = readBrokerString(data); !ok || len(data) < 8+8 {
+		return nil, false
+	}
+	msg.Timestamp = int64(binary.BigEndian.Uint64(data[0:8]))
+	msg.Sequence = binary.BigEndian.Uint64(data[8:16])
+	data = data[16:]
+
+	if msg.Subject, data, ok = readBrokerString(data); !ok {
+		return nil, false
+	}
+	if msg.Header, data, ok = readBrokerHeader(data); !ok {
+		return nil, false
+	}
+
+	msg.Data = data
+	return &msg, true
+}
+
+// readBrokerHeader reads a message header encoded as | header count (2 bytes) | header entries (variable) | from the start of data
+// and returns it with the rest of data. The header is nil if it has no entries.
+func readBrokerHeader(data []byte) (map[string]string, []byte, bool) {
+	if len(data) < 2 {
+		return nil, nil, false
+	}
+
+	count := int(binary.BigEndian.Uint16(data[0:2]))
+	data = data[2:]
+
+	var header map[string]string
+	if count > 0 {
+		header = make(map[string]string, count)
+	}
+	for range count {
+		var k, v string
+		var ok bool
+		if k, data, ok = readBrokerString(data); !ok {
+			return nil, nil, false
+		}
+		if v, data, ok = readBrokerString(data); !ok {
+			return nil, nil, false
+		}
+		header[k] = v
+	}
+
+	return header, data, true
+}
+
+// readBrokerString reads a string encoded as | length (2 bytes) | string (variable) | from the start of data and returns it with the rest of data.
+func readBrokerString(data []byte) (string, []byte, bool) {
+	if len(data) < 2 {
+		return "", nil, false
+	}
+
+	n := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+n {
+		return "", nil, false
+	}
+
+	return string(data[2 : 2+n]), data[2+n:], true
+}
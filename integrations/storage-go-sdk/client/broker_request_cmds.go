@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// DefaultBrokerRequestTimeout is how long BrokerRequest waits for a reply if the context has no deadline.
+const DefaultBrokerRequestTimeout = 5 * time.Second
+
+// BrokerInbox implements the client side of the protocol.ServerCommandBrokerInbox command.
+// The inbox prefix belongs to the connection and the collection, so it is fetched once per collection and cached until the client reconnects.
+func (c *Client) BrokerInbox(db, coll string) (string, error) {
+	c.brokerInboxesMu.Lock()
+	defer c.brokerInboxesMu.Unlock()
+
+	key := db + "." + coll
+	if inbox, ok := c.brokerInboxes[key]; ok {
+		return inbox, nil
+	}
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandBrokerInbox,
+		DatabaseName:   db,
+		CollectionName: coll,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+		inbox := string(resp.Payload)
+		c.brokerInboxes[key] = inbox
+		return inbox, nil
+	case protocol.StatusCollectionNotFound:
+		return "", ErrCollectionNotFound
+	default:
+		return "", ErrUnexpectedStatusCode
+	}
+}
+
+// BrokerRequest implements the client side of the protocol.ServerCommandBrokerRequest command.
+// It publishes the payload to the subject and waits for the first reply, until ctx is done.
+// If ctx has no deadline, DefaultBrokerRequestTimeout applies.
+// Returns ErrNoResponders if no subscriber matches the subject.
+func (c *Client) BrokerRequest(ctx context.Context, db, coll string, subject string, payload []byte) ([]byte, error) {
+	prefix, err := c.BrokerInbox(db, coll)
+	if err != nil {
+		return nil, err
+	}
+	reply := prefix + "." + strconv.FormatUint(atomic.AddUint64(&c.brokerRequestCounter, 1), 10)
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultBrokerRequestTimeout)
+		defer cancel()
+	}
+
+	// Register the listener first, the reply may arrive before the response to the request
+	replyCh := make(chan []byte, 1)
+	key := db + "." + coll + "." + reply
+	c.brokerSubjectListenersMu.Lock()
+	c.brokerSubjectListeners[key] = []func([]byte){func(msg []byte) {
+		select {
+		case replyCh <- msg:
+		default: // only the first reply is used
+		}
+	}}
+	c.brokerSubjectListenersMu.Unlock()
+
+	cmdPayload := make([]byte, 0, 2+len(subject)+2+len(reply)+len(payload)+8)
+	cmdPayload = appendKey(cmdPayload, subject)
+	cmdPayload = appendKey(cmdPayload, reply)
+	cmdPayload = append(cmdPayload, codex.EncodeBinary(payload)...)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandBrokerRequest,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        cmdPayload,
+	})
+	if err == nil && resp.Code != protocol.StatusOK {
+		switch resp.Code {
+		case protocol.StatusNoResponders:
+			err = ErrNoResponders
+		case protocol.StatusCollectionNotFound:
+			err = ErrCollectionNotFound
+		default:
+			err = ErrUnexpectedStatusCode
+		}
+	}
+	if err != nil {
+		c.brokerSubjectListenersMu.Lock()
+		delete(c.brokerSubjectListeners, key)
+		c.brokerSubjectListenersMu.Unlock()
+		return nil, err
+	}
+
+	defer c.BrokerUnsubscribe(db, coll, reply)
+
+	select {
+	case msg := <-replyCh:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// BrokerHandleRequests subscribes to the subject and replies to every request with the result of fn.
+// If queue is not empty, the subscription is part of that queue group, so each request is handled by only one member.
+// Messages that are published without a reply subject are ignored.
+func (c *Client) BrokerHandleRequests(db, coll string, subject, queue string, fn func(req []byte) []byte) error {
+	key := db + "." + coll + "." + subject
+	c.brokerRequestHandlersMu.Lock()
+	c.brokerRequestHandlers[key] = append(c.brokerRequestHandlers[key], fn)
+	c.brokerRequestHandlersMu.Unlock()
+
+	var err error
+	if queue == "" {
+		err = c.BrokerSubscribe(db, coll, subject, func([]byte) {})
+	} else {
+		err = c.BrokerSubscribeQueue(db, coll, subject, queue, func([]byte) {})
+	}
+	if err != nil {
+		c.brokerRequestHandlersMu.Lock()
+		delete(c.brokerRequestHandlers, key)
+		c.brokerRequestHandlersMu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// dispatchBrokerRequests passes the requests of a protocol.ClientCommandBrokerRequestMessage command to the request handlers
+// and publishes their replies, subscribers without request handlers receive the request data like a normal message.
+// Each request is encoded as | Reply Length (2 bytes) | Reply (variable) | Data (variable) |
+func (c *Client) dispatchBrokerRequests(db, coll, subject string, msgs []*codex.Value) {
+	key := db + "." + coll + "." + subject
+
+	c.brokerRequestHandlersMu.Lock()
+	handlers := c.brokerRequestHandlers[key]
+	c.brokerRequestHandlersMu.Unlock()
+
+	c.brokerSubjectListenersMu.Lock()
+	listeners := c.brokerSubjectListeners[key]
+	c.brokerSubjectListenersMu.Unlock()
+
+	for _, msg := range msgs {
+		buf := msg.AsBinary()
+		if len(buf) < 2 || len(buf) < 2+int(binary.BigEndian.Uint16(buf[0:2])) {
+			continue
+		}
+		replyLen := int(binary.BigEndian.Uint16(buf[0:2]))
+		reply := string(buf[2 : 2+replyLen])
+		data := buf[2+replyLen:]
+
+		for _, handler := range handlers {
+			go func() {
+				if err := c.BrokerPublish(db, coll, reply, handler(data)); err != nil {
+					slog.Warn("Failed to publish broker reply", slog.String("subject", subject), slog.Any("error", err))
+				}
+			}()
+		}
+		if len(handlers) > 0 {
+			continue
+		}
+		for _, listener := range listeners {
+			go listener(data)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"encoding/binary"
+	"log/slog"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// OnBrokerSlowConsumer sets the function that is called when the server starts dropping messages for a subscription of the client.
+// It is called again if messages are dropped after the subscription caught up in between.
+// With BrokerDisconnect, the server closes the connection right after the notice.
+func (c *Client) OnBrokerSlowConsumer(fn func(notice *BrokerSlowConsumerNotice)) {
+	c.brokerSlowConsumerMu.Lock()
+	c.brokerSlowConsumer = fn
+	c.brokerSlowConsumerMu.Unlock()
+}
+
+// BrokerSubscriptionStats implements the client side of the protocol.ServerCommandBrokerSubscriptionStats command.
+// It returns the subscriptions of the client in the collection and the number of messages dropped for them.
+func (c *Client) BrokerSubscriptionStats(db, coll string) ([]*BrokerSubscriptionStats, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandBrokerSubscriptionStats,
+		DatabaseName:   db,
+		CollectionName: coll,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+	case protocol.StatusCollectionNotFound:
+		return nil, ErrCollectionNotFound
+	default:
+		return nil, ErrUnexpectedStatusCode
+	}
+
+	values, err := codex.DecodeList(resp.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*BrokerSubscriptionStats, 0, len(values))
+	for _, value := range values {
+		var s BrokerSubscriptionStats
+		if err := codex.Unmarshal(value.AsBinary(), &s); err != nil {
+			return nil, err
+		}
+		stats = append(stats, &s)
+	}
+
+	return stats, nil
+}
+
+// handleBrokerSlowConsumer passes the notice of a protocol.ClientCommandBrokerSlowConsumer command to the function set with OnBrokerSlowConsumer.
+// The notice is encoded as | subject length (2 bytes) | subject (variable) | queue length (2 bytes) | queue (variable) | policy (1 byte) | dropped (8 bytes) |
+func (c *Client) handleBrokerSlowConsumer(cmd *protocol.Command) {
+	data := cmd.Payload
+	if len(data) < 2 || len(data) < 2+int(binary.BigEndian.Uint16(data[0:2]))+2 {
+		slog.Warn("Received slow consumer notice with invalid payload")
+		return
+	}
+	subjectLen := int(binary.BigEndian.Uint16(data[0:2]))
+	subject := string(data[2 : 2+subjectLen])
+	data = data[2+subjectLen:]
+
+	queueLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+queueLen+1+8 {
+		slog.Warn("Received slow consumer notice with invalid payload")
+		return
+	}
+	queue := string(data[2 : 2+queueLen])
+	data = data[2+queueLen:]
+
+	notice := &BrokerSlowConsumerNotice{
+		Database:   cmd.DatabaseName,
+		Collection: cmd.CollectionName,
+		Subject:    subject,
+		Queue:      queue,
+		Policy:     BrokerSlowConsumerPolicy(data[0]),
+		Dropped:    binary.BigEndian.Uint64(data[1:9]),
+	}
+
+	slog.Warn("Server drops broker messages for a slow subscription",
+		slog.String("database", notice.Database),
+		slog.String("collection", notice.Collection),
+		slog.String("subject", notice.Subject),
+		slog.Uint64("dropped", notice.Dropped),
+	)
+
+	c.brokerSlowConsumerMu.Lock()
+	fn := c.brokerSlowConsumer
+	c.brokerSlowConsumerMu.Unlock()
+
+	if fn != nil {
+		go fn(notice)
+	}
+}
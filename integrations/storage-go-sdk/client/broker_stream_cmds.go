@@ -0,0 +1,218 @@
+package client
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// brokerStreamListener calls fn for the messages of a stream subscription or durable consumer one after another, in the order they were delivered.
+type brokerStreamListener[M any] struct {
+	fn   func(msg *M)
+	msgs chan []*M
+	done chan struct{}
+}
+
+// newBrokerStreamListener creates a listener and starts calling fn for its messages.
+func newBrokerStreamListener[M any](fn func(msg *M)) *brokerStreamListener[M] {
+	l := &brokerStreamListener[M]{
+		fn:   fn,
+		msgs: make(chan []*M, 64),
+		done: make(chan struct{}),
+	}
+	go l.run()
+
+	return l
+}
+
+// BrokerSubscribeStream implements the client side of the protocol.ServerCommandBrokerSubscribeStream command.
+// Returns ErrNotStream if the collection is no stream.
+func (c *Client) BrokerSubscribeStream(db, coll string, subject string, start BrokerStartPosition, fn func(msg *BrokerStreamMessage)) error {
+	payload := make([]byte, 2+len(subject), 2+len(subject)+1+8)
+
+	// Subject
+	binary.BigEndian.PutUint16(payload[0:2], uint16(len(subject)))
+	copy(payload[2:], subject)
+
+	// Start position
+	payload = append(payload, byte(start.Policy))
+	switch start.Policy {
+	case BrokerStartSequence:
+		payload = binary.BigEndian.AppendUint64(payload, start.Sequence)
+	case BrokerStartTime:
+		payload = binary.BigEndian.AppendUint64(payload, uint64(start.Time.UnixMilli()))
+	default:
+		payload = binary.BigEndian.AppendUint64(payload, 0)
+	}
+
+	// Register the listener first, the server starts delivering stored messages right away
+	key := db + "." + coll + "." + subject
+	listener := newBrokerStreamListener(fn)
+	addBrokerStreamListener(&c.brokerStreamListenersMu, c.brokerStreamListeners, key, listener)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandBrokerSubscribeStream,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err == nil {
+		switch resp.Code {
+		case protocol.StatusOK:
+			return nil
+		case protocol.StatusCollectionNotFound:
+			err = ErrCollectionNotFound
+		case protocol.StatusCommandNotAllowed:
+			err = ErrNotStream
+		default:
+			err = ErrUnexpectedStatusCode
+		}
+	}
+
+	removeBrokerStreamListener(&c.brokerStreamListenersMu, c.brokerStreamListeners, key, listener)
+	return err
+}
+
+// BrokerStreamInfo implements the client side of the protocol.ServerCommandBrokerStreamInfo command.
+// Returns ErrNotStream if the collection is no stream.
+func (c *Client) BrokerStreamInfo(db, coll string) (*BrokerStreamInfo, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandBrokerStreamInfo,
+		DatabaseName:   db,
+		CollectionName: coll,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+	case protocol.StatusCollectionNotFound:
+		return nil, ErrCollectionNotFound
+	case protocol.StatusCommandNotAllowed:
+		return nil, ErrNotStream
+	default:
+		return nil, ErrUnexpectedStatusCode
+	}
+
+	data, err := codex.DecodeValue(resp.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var info BrokerStreamInfo
+	if err := codex.Unmarshal(data.AsBinary(), &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// dispatchBrokerStreamMessages passes the messages of a protocol.ClientCommandBrokerStreamMessage command to the listeners of the subscription.
+// Each message is encoded as | sequence (8 bytes) | timestamp (8 bytes) | subject length (2 bytes) | subject (variable) | ID length (2 bytes) | ID (variable) |
+// header count (2 bytes) | header entries (variable) | data (variable) |, header entries are encoded like in dispatchBrokerHeaderMessages.
+func (c *Client) dispatchBrokerStreamMessages(key string, values []*codex.Value) {
+	msgs := make([]*BrokerStreamMessage, 0, len(values))
+	for _, value := range values {
+		data := value.AsBinary()
+		if len(data) < 8+8 {
+			continue
+		}
+
+		msg := BrokerStreamMessage{
+			Sequence:  binary.BigEndian.Uint64(data[0:8]),
+			Timestamp: int64(binary.BigEndian.Uint64(data[8:16])),
+		}
+		if !decodeBrokerStreamFields(&msg, data[16:]) {
+			continue
+		}
+
+		msgs = append(msgs, &msg)
+	}
+
+	dispatchToBrokerStreamListeners(&c.brokerStreamListenersMu, c.brokerStreamListeners, key, msgs)
+}
+
+// decodeBrokerStreamFields decodes | subject | ID | header | data | of a stream or consumer message into msg, it returns false if data is too short.
+func decodeBrokerStreamFields(msg *BrokerStreamMessage, data []byte) bool {
+	var ok bool
+	if msg.Subject, data, ok = readBrokerString(data); !ok {
+		return false
+	}
+	if msg.ID, data, ok = readBrokerString(data); !ok {
+		return false
+	}
+	if msg.Header, data, ok = readBrokerHeader(data); !ok {
+		return false
+	}
+
+	msg.Data = data
+	return true
+}
+
+// dispatchToBrokerStreamListeners passes decoded messages to the listeners with the given key.
+func dispatchToBrokerStreamListeners[M any](mu *sync.Mutex, listeners map[string][]*brokerStreamListener[M], key string, msgs []*M) {
+	mu.Lock()
+	keyListeners := listeners[key]
+	mu.Unlock()
+
+	for _, listener := range keyListeners {
+		select {
+		case listener.msgs <- msgs:
+		case <-listener.done:
+		}
+	}
+}
+
+// addBrokerStreamListener registers a listener under the given key.
+func addBrokerStreamListener[M any](mu *sync.Mutex, listeners map[string][]*brokerStreamListener[M], key string, listener *brokerStreamListener[M]) {
+	mu.Lock()
+	listeners[key] = append(listeners[key], listener)
+	mu.Unlock()
+}
+
+// removeBrokerStreamListeners stops all listeners with the given key.
+func removeBrokerStreamListeners[M any](mu *sync.Mutex, listeners map[string][]*brokerStreamListener[M], key string) {
+	mu.Lock()
+	keyListeners := listeners[key]
+	delete(listeners, key)
+	mu.Unlock()
+
+	for _, listener := range keyListeners {
+		close(listener.done)
+	}
+}
+
+// removeBrokerStreamListener stops a single listener with the given key.
+func removeBrokerStreamListener[M any](mu *sync.Mutex, listeners map[string][]*brokerStreamListener[M], key string, listener *brokerStreamListener[M]) {
+	mu.Lock()
+	keyListeners := listeners[key]
+	for i, l := range keyListeners {
+		if l == listener {
+			listeners[key] = append(keyListeners[:i:i], keyListeners[i+1:]...)
+			break
+		}
+	}
+	if len(listeners[key]) == 0 {
+		delete(listeners, key)
+	}
+	mu.Unlock()
+
+	close(listener.done)
+}
+
+// run calls the listener function for every delivered message until the listener is stopped.
+func (l *brokerStreamListener[M]) run() {
+	for {
+		select {
+		case <-l.done:
+			return
+		case msgs := <-l.msgs:
+			for _, msg := range msgs {
+				l.fn(msg)
+			}
+		}
+	}
+}
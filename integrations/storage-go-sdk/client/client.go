@@ -21,6 +21,26 @@ type Client struct {
 
 	brokerSubjectListeners   map[string][]func([]byte)
 	brokerSubjectListenersMu sync.Mutex
+
+	brokerHeaderListeners   map[string][]func(*BrokerMessage)
+	brokerHeaderListenersMu sync.Mutex
+
+	brokerStreamListeners   map[string][]*brokerStreamListener[BrokerStreamMessage]
+	brokerStreamListenersMu sync.Mutex
+
+	brokerConsumerListeners   map[string][]*brokerStreamListener[BrokerConsumerMessage]
+	brokerConsumerListenersMu sync.Mutex
+
+	brokerRequestHandlers   map[string][]func([]byte) []byte
+	brokerRequestHandlersMu sync.Mutex
+
+	brokerSlowConsumer   func(notice *BrokerSlowConsumerNotice)
+	brokerSlowConsumerMu sync.Mutex
+
+	// brokerInboxes are the inbox prefixes of the connection by db+"."+coll, see BrokerInbox
+	brokerInboxes        map[string]string
+	brokerInboxesMu      sync.Mutex
+	brokerRequestCounter uint64
 }
 
 // Configuration holds the necessary parameters for connecting to the storage server and authenticating.
@@ -57,7 +77,12 @@ func NewClient(cfg Configuration) (*Client, error) {
 		requestIDCounter: 0,
 		pendingCmds:      make(map[uint32]chan *protocol.Response),
 
-		brokerSubjectListeners: make(map[string][]func([]byte)),
+		brokerSubjectListeners:  make(map[string][]func([]byte)),
+		brokerHeaderListeners:   make(map[string][]func(*BrokerMessage)),
+		brokerStreamListeners:   make(map[string][]*brokerStreamListener[BrokerStreamMessage]),
+		brokerConsumerListeners: make(map[string][]*brokerStreamListener[BrokerConsumerMessage]),
+		brokerRequestHandlers:   make(map[string][]func([]byte) []byte),
+		brokerInboxes:           make(map[string]string),
 	}
 
 	go c.startResponseListener()
@@ -107,6 +132,11 @@ func (c *Client) Reconnect() {
 	c.pendingCmds = make(map[uint32]chan *protocol.Response)
 	c.pendingCmdsMu.Unlock()
 
+	// The inbox prefixes belong to the old connection
+	c.brokerInboxesMu.Lock()
+	c.brokerInboxes = make(map[string]string)
+	c.brokerInboxesMu.Unlock()
+
 	go c.startResponseListener()
 
 	if err := c.Ping(); err != nil {
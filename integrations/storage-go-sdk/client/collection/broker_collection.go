@@ -1,6 +1,10 @@
 package collection
 
-import "github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/client"
+import (
+	"context"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/client"
+)
 
 // MessageBrokerCollection represents a collection that provides message broker functionality in the storage system.
 type MessageBrokerCollection struct {
@@ -53,6 +57,23 @@ func (coll *MessageBrokerCollection) SubscribeQueue(subject, queue string, fn fu
 	return coll.client.BrokerSubscribeQueue(coll.database, coll.name, subject, queue, fn)
 }
 
+// SubscribeWithOptions subscribes like Subscribe, or like SubscribeQueue if queue is not empty.
+// The options decide what the server does with messages while the client does not keep up with the publishers,
+// see client.Client.OnBrokerSlowConsumer to get notified when messages are dropped.
+func (coll *MessageBrokerCollection) SubscribeWithOptions(subject, queue string, opts client.BrokerSubscribeOptions, fn func(msg []byte)) error {
+	return coll.client.BrokerSubscribeWithOptions(coll.database, coll.name, subject, queue, opts, fn)
+}
+
+// SubscribeWithHeader subscribes like SubscribeWithOptions, but messages are delivered together with their ID, timestamp and header.
+func (coll *MessageBrokerCollection) SubscribeWithHeader(subject, queue string, opts client.BrokerSubscribeOptions, fn func(msg *client.BrokerMessage)) error {
+	return coll.client.BrokerSubscribeWithHeader(coll.database, coll.name, subject, queue, opts, fn)
+}
+
+// SubscriptionStats returns the subscriptions of the client in the collection and the number of messages dropped for each of them.
+func (coll *MessageBrokerCollection) SubscriptionStats() ([]*client.BrokerSubscriptionStats, error) {
+	return coll.client.BrokerSubscriptionStats(coll.database, coll.name)
+}
+
 // Unsubscribe unsubscribes the client from a subject in the collection.
 func (coll *MessageBrokerCollection) Unsubscribe(subject string) error {
 	return coll.client.BrokerUnsubscribe(coll.database, coll.name, subject)
@@ -62,3 +83,79 @@ func (coll *MessageBrokerCollection) Unsubscribe(subject string) error {
 func (coll *MessageBrokerCollection) Publish(subject string, msg []byte) error {
 	return coll.client.BrokerPublish(coll.database, coll.name, subject, msg)
 }
+
+// PublishWithHeader publishes a message with a header to a subject in the collection, e.g. to propagate trace IDs or content types.
+// Only subscribers that subscribed with SubscribeWithHeader receive the header.
+// It returns the published message with the ID, timestamp and sequence number the server assigned to it.
+func (coll *MessageBrokerCollection) PublishWithHeader(subject string, header map[string]string, msg []byte) (*client.BrokerMessage, error) {
+	return coll.client.BrokerPublishWithHeader(coll.database, coll.name, subject, header, msg)
+}
+
+// PublishSequenced publishes a message to a subject in the collection and returns its sequence number in the stream.
+// If the collection is no stream, the message is published like with Publish and the sequence number is 0.
+func (coll *MessageBrokerCollection) PublishSequenced(subject string, msg []byte) (uint64, error) {
+	return coll.client.BrokerPublishSequenced(coll.database, coll.name, subject, msg)
+}
+
+// SubscribeStream subscribes the client to a subject in a stream collection, starting at a position in the stream.
+//
+// The stored messages from the start position on are delivered first, followed by new messages as they are published.
+// Messages are passed to fn one after another in the order of their sequence numbers. Unlike with Subscribe, no messages are dropped
+// if the client falls behind, only messages removed by the retention limits of the stream are skipped.
+//
+// The wildcards "*" and ">" can be used in the subject like with Subscribe. Returns client.ErrNotStream if the collection is no stream.
+func (coll *MessageBrokerCollection) SubscribeStream(subject string, start client.BrokerStartPosition, fn func(msg *client.BrokerStreamMessage)) error {
+	return coll.client.BrokerSubscribeStream(coll.database, coll.name, subject, start, fn)
+}
+
+// StreamInfo returns the number of stored messages, their sequence numbers and the retention limits of the stream.
+// Returns client.ErrNotStream if the collection is no stream.
+func (coll *MessageBrokerCollection) StreamInfo() (*client.BrokerStreamInfo, error) {
+	return coll.client.BrokerStreamInfo(coll.database, coll.name)
+}
+
+// SubscribeConsumer subscribes the client to a durable consumer of a stream collection, creating the consumer if it does not exist yet.
+//
+// The server keeps track of the messages the consumer acknowledged, so it continues where it left off after the client or the server restarts.
+// Each message is delivered to one subscriber of the consumer at a time and must be acknowledged with Ack. Messages that are not acknowledged
+// within cfg.AckWait, that are rejected with Nak or whose subscriber disconnects are delivered again, up to cfg.MaxDeliveries times.
+// Afterward they are published to cfg.DeadLetterSubject, if set.
+//
+// Messages are passed to fn one after another. Returns client.ErrNotStream if the collection is no stream.
+func (coll *MessageBrokerCollection) SubscribeConsumer(cfg client.BrokerConsumerConfig, fn func(msg *client.BrokerConsumerMessage)) error {
+	return coll.client.BrokerSubscribeConsumer(coll.database, coll.name, cfg, fn)
+}
+
+// UnsubscribeConsumer unsubscribes the client from a durable consumer. Its unacknowledged messages are delivered to the other subscribers.
+func (coll *MessageBrokerCollection) UnsubscribeConsumer(name string) error {
+	return coll.client.BrokerUnsubscribeConsumer(coll.database, coll.name, name)
+}
+
+// Ack acknowledges a message of a durable consumer, so it is not delivered again.
+// Returns client.ErrNotPending if the message is no longer waiting for an acknowledgement.
+func (coll *MessageBrokerCollection) Ack(msg *client.BrokerConsumerMessage) error {
+	return coll.client.BrokerAck(coll.database, coll.name, msg.Consumer, msg.Sequence)
+}
+
+// Nak rejects a message of a durable consumer, so it is delivered again right away.
+func (coll *MessageBrokerCollection) Nak(msg *client.BrokerConsumerMessage) error {
+	return coll.client.BrokerNak(coll.database, coll.name, msg.Consumer, msg.Sequence)
+}
+
+// InProgress tells the server that a message of a durable consumer is still being processed, which restarts its ack wait.
+func (coll *MessageBrokerCollection) InProgress(msg *client.BrokerConsumerMessage) error {
+	return coll.client.BrokerInProgress(coll.database, coll.name, msg.Consumer, msg.Sequence)
+}
+
+// Request publishes a request to the subject and returns the first reply.
+// It waits until ctx is done, or client.DefaultBrokerRequestTimeout if ctx has no deadline.
+// Returns client.ErrNoResponders if no subscriber matches the subject.
+func (coll *MessageBrokerCollection) Request(ctx context.Context, subject string, payload []byte) ([]byte, error) {
+	return coll.client.BrokerRequest(ctx, coll.database, coll.name, subject, payload)
+}
+
+// HandleRequests subscribes to the subject and replies to every request with the result of fn.
+// If queue is not empty, each request is handled by only one member of the queue group.
+func (coll *MessageBrokerCollection) HandleRequests(subject, queue string, fn func(req []byte) []byte) error {
+	return coll.client.BrokerHandleRequests(coll.database, coll.name, subject, queue, fn)
+}
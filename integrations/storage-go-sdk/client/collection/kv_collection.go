@@ -1,6 +1,7 @@
 package collection
 
 import (
+	"iter"
 	"time"
 
 	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/client"
@@ -38,6 +39,12 @@ func (coll *KeyValueCollection) Get(key string) (*codex.Value, error) {
 	return coll.client.KVGet(coll.database, coll.name, key)
 }
 
+// GetWithRevision retrieves the value and the current revision associated with the specified key from the collection.
+// The revision can be passed to CompareAndSet or CompareAndDelete for optimistic concurrency control.
+func (coll *KeyValueCollection) GetWithRevision(key string) (*codex.Value, uint64, error) {
+	return coll.client.KVGetWithRevision(coll.database, coll.name, key)
+}
+
 // GetStruct retrieves the value associated with the specified key and tries to unmarshal it into the provided destination struct.
 func (coll *KeyValueCollection) GetStruct(key string, dest any) error {
 	val, err := coll.Get(key)
@@ -227,6 +234,36 @@ func (coll *KeyValueCollection) SetStructWithTTL(key string, value any, ttl time
 	return coll.SetWithTTL(key, data, ttl)
 }
 
+// CompareAndSet sets the value for key only if the current revision of the key equals expectedRevision.
+// An expectedRevision of 0 requires that the key does not exist yet.
+// It returns the new revision, or the current revision together with client.ErrRevisionMismatch.
+func (coll *KeyValueCollection) CompareAndSet(key string, expectedRevision uint64, value any) (uint64, error) {
+	cval, err := codex.NewValue(value)
+	if err != nil {
+		return 0, err
+	}
+
+	return coll.client.KVCompareAndSet(coll.database, coll.name, key, expectedRevision, cval, 0)
+}
+
+// CompareAndSetWithTTL is like CompareAndSet, but the key expires after the given time-to-live (TTL) duration.
+func (coll *KeyValueCollection) CompareAndSetWithTTL(key string, expectedRevision uint64, value any, ttl time.Duration) (uint64, error) {
+	cval, err := codex.NewValue(value)
+	if err != nil {
+		return 0, err
+	}
+
+	expiresAt := uint64(time.Now().Add(ttl).UnixNano())
+
+	return coll.client.KVCompareAndSet(coll.database, coll.name, key, expectedRevision, cval, expiresAt)
+}
+
+// CompareAndDelete deletes key only if its current revision equals expectedRevision.
+// It returns the current revision together with client.ErrRevisionMismatch if the key was not deleted.
+func (coll *KeyValueCollection) CompareAndDelete(key string, expectedRevision uint64) (uint64, error) {
+	return coll.client.KVCompareAndDelete(coll.database, coll.name, key, expectedRevision)
+}
+
 // Delete removes the key-value pair associated with the specified key from the collection.
 func (coll *KeyValueCollection) Delete(key string) error {
 	return coll.client.KVDelete(coll.database, coll.name, key)
@@ -261,3 +298,239 @@ func (coll *KeyValueCollection) Count() (uint32, error) {
 func (coll *KeyValueCollection) Size() (uint64, error) {
 	return coll.client.KVSize(coll.database, coll.name)
 }
+
+// Stats retrieves the usage, limits and eviction counters of the collection.
+func (coll *KeyValueCollection) Stats() (*client.KVStats, error) {
+	return coll.client.KVStats(coll.database, coll.name)
+}
+
+// Scan iterates over all keys matching the glob pattern ("" matches every key), fetching pageSize keys per request.
+// Supported syntax: '*' matches any sequence, '?' a single byte, '[a-z]' and '[!a-z]' byte classes, '\' escapes.
+// Keys that exist for the whole scan are returned exactly once, keys added or removed during the scan may or may not be returned.
+// Iteration stops at the first error, which is yielded with an empty key.
+func (coll *KeyValueCollection) Scan(pattern string, pageSize int) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		var cursor []byte
+		for {
+			keys, next, err := coll.client.KVScan(coll.database, coll.name, cursor, uint32(pageSize), pattern)
+			if err != nil {
+				yield("", err)
+				return
+			}
+
+			for _, key := range keys {
+				if !yield(key, nil) {
+					return
+				}
+			}
+
+			if len(next) == 0 {
+				return
+			}
+			cursor = next
+		}
+	}
+}
+
+// Increment atomically adds delta to the numeric value stored under key and returns the new value.
+// A missing key is treated as zero of delta's type. The delta can be of any numeric type supported by codex.ValueType.
+func (coll *KeyValueCollection) Increment(key string, delta any) (*codex.Value, error) {
+	return coll.numOp(coll.client.KVNumIncrement, key, delta)
+}
+
+// Decrement atomically subtracts delta from the numeric value stored under key and returns the new value.
+func (coll *KeyValueCollection) Decrement(key string, delta any) (*codex.Value, error) {
+	return coll.numOp(coll.client.KVNumDecrement, key, delta)
+}
+
+// Multiply atomically multiplies the numeric value stored under key by factor and returns the new value.
+func (coll *KeyValueCollection) Multiply(key string, factor any) (*codex.Value, error) {
+	return coll.numOp(coll.client.KVNumMultiply, key, factor)
+}
+
+// Divide atomically divides the numeric value stored under key by divisor and returns the new value.
+func (coll *KeyValueCollection) Divide(key string, divisor any) (*codex.Value, error) {
+	return coll.numOp(coll.client.KVNumDivide, key, divisor)
+}
+
+// Modulo atomically replaces the numeric value stored under key with the remainder of dividing it by divisor.
+func (coll *KeyValueCollection) Modulo(key string, divisor any) (*codex.Value, error) {
+	return coll.numOp(coll.client.KVNumModulo, key, divisor)
+}
+
+// LeftShift atomically shifts the integer stored under key left by the given number of bits.
+func (coll *KeyValueCollection) LeftShift(key string, bits any) (*codex.Value, error) {
+	return coll.numOp(coll.client.KVNumLeftShift, key, bits)
+}
+
+// RightShift atomically shifts the integer stored under key right by the given number of bits.
+func (coll *KeyValueCollection) RightShift(key string, bits any) (*codex.Value, error) {
+	return coll.numOp(coll.client.KVNumRightShift, key, bits)
+}
+
+// BitwiseAnd atomically replaces the integer stored under key with the bitwise AND of it and mask.
+func (coll *KeyValueCollection) BitwiseAnd(key string, mask any) (*codex.Value, error) {
+	return coll.numOp(coll.client.KVNumBitwiseAnd, key, mask)
+}
+
+// BitwiseOr atomically replaces the integer stored under key with the bitwise OR of it and mask.
+func (coll *KeyValueCollection) BitwiseOr(key string, mask any) (*codex.Value, error) {
+	return coll.numOp(coll.client.KVNumBitwiseOr, key, mask)
+}
+
+// BitwiseXor atomically replaces the integer stored under key with the bitwise XOR of it and mask.
+func (coll *KeyValueCollection) BitwiseXor(key string, mask any) (*codex.Value, error) {
+	return coll.numOp(coll.client.KVNumBitwiseXor, key, mask)
+}
+
+// BitwiseNot atomically inverts all bits of the integer stored under key.
+func (coll *KeyValueCollection) BitwiseNot(key string) (*codex.Value, error) {
+	return coll.client.KVNumBitwiseNot(coll.database, coll.name, key)
+}
+
+func (coll *KeyValueCollection) numOp(fn func(db, coll string, key string, operand *codex.Value) (*codex.Value, error), key string, operand any) (*codex.Value, error) {
+	cval, err := codex.NewValue(operand)
+	if err != nil {
+		return nil, err
+	}
+
+	return fn(coll.database, coll.name, key, cval)
+}
+
+// ListLength returns the number of items in the list stored under key (0 if the key does not exist).
+func (coll *KeyValueCollection) ListLength(key string) (uint32, error) {
+	return coll.client.KVListLength(coll.database, coll.name, key)
+}
+
+// ListGet returns the item at index in the list stored under key.
+// Negative indexes count from the end of the list (-1 is the last item).
+func (coll *KeyValueCollection) ListGet(key string, index int) (*codex.Value, error) {
+	return coll.client.KVListGet(coll.database, coll.name, key, int32(index))
+}
+
+// ListSet replaces the item at index in the list stored under key.
+// Negative indexes count from the end of the list (-1 is the last item).
+func (coll *KeyValueCollection) ListSet(key string, index int, value any) error {
+	cval, err := codex.NewValue(value)
+	if err != nil {
+		return err
+	}
+
+	return coll.client.KVListSet(coll.database, coll.name, key, int32(index), cval)
+}
+
+// ListRemove removes the item at index from the list stored under key and returns it.
+// Negative indexes count from the end of the list (-1 is the last item).
+func (coll *KeyValueCollection) ListRemove(key string, index int) (*codex.Value, error) {
+	return coll.client.KVListRemove(coll.database, coll.name, key, int32(index))
+}
+
+// ListLeftPush inserts values at the head of the list stored under key and returns the new length of the list.
+// The values are inserted one after another, so the last value ends up first.
+func (coll *KeyValueCollection) ListLeftPush(key string, values ...any) (uint32, error) {
+	cvals, err := newValues(values)
+	if err != nil {
+		return 0, err
+	}
+
+	return coll.client.KVListLeftPush(coll.database, coll.name, key, cvals)
+}
+
+// ListRightPush appends values to the tail of the list stored under key and returns the new length of the list.
+func (coll *KeyValueCollection) ListRightPush(key string, values ...any) (uint32, error) {
+	cvals, err := newValues(values)
+	if err != nil {
+		return 0, err
+	}
+
+	return coll.client.KVListRightPush(coll.database, coll.name, key, cvals)
+}
+
+// ListLeftPop removes and returns the first item of the list stored under key.
+// Returns client.ErrKeyNotFound if the list is empty.
+func (coll *KeyValueCollection) ListLeftPop(key string) (*codex.Value, error) {
+	return coll.client.KVListLeftPop(coll.database, coll.name, key)
+}
+
+// ListRightPop removes and returns the last item of the list stored under key.
+// Returns client.ErrKeyNotFound if the list is empty.
+func (coll *KeyValueCollection) ListRightPop(key string) (*codex.Value, error) {
+	return coll.client.KVListRightPop(coll.database, coll.name, key)
+}
+
+// MapLength returns the number of fields in the map stored under key (0 if the key does not exist).
+func (coll *KeyValueCollection) MapLength(key string) (uint32, error) {
+	return coll.client.KVMapLength(coll.database, coll.name, key)
+}
+
+// MapSet sets field to value in the map stored under key, creating the map if needed.
+func (coll *KeyValueCollection) MapSet(key, field string, value any) error {
+	cval, err := codex.NewValue(value)
+	if err != nil {
+		return err
+	}
+
+	return coll.client.KVMapSet(coll.database, coll.name, key, field, cval)
+}
+
+// MapGet returns the value of field in the map stored under key.
+// Returns client.ErrKeyNotFound if the key or the field does not exist.
+func (coll *KeyValueCollection) MapGet(key, field string) (*codex.Value, error) {
+	return coll.client.KVMapGet(coll.database, coll.name, key, field)
+}
+
+// MapDelete removes field from the map stored under key.
+func (coll *KeyValueCollection) MapDelete(key, field string) error {
+	return coll.client.KVMapDelete(coll.database, coll.name, key, field)
+}
+
+// MapExists checks if field exists in the map stored under key.
+func (coll *KeyValueCollection) MapExists(key, field string) (bool, error) {
+	return coll.client.KVMapExists(coll.database, coll.name, key, field)
+}
+
+// MapKeys returns the fields of the map stored under key.
+func (coll *KeyValueCollection) MapKeys(key string) ([]string, error) {
+	return coll.client.KVMapKeys(coll.database, coll.name, key)
+}
+
+// MapValues returns the values of the map stored under key.
+func (coll *KeyValueCollection) MapValues(key string) ([]*codex.Value, error) {
+	return coll.client.KVMapValues(coll.database, coll.name, key)
+}
+
+func newValues(values []any) ([]*codex.Value, error) {
+	cvals := make([]*codex.Value, len(values))
+	for i, v := range values {
+		cval, err := codex.NewValue(v)
+		if err != nil {
+			return nil, err
+		}
+		cvals[i] = cval
+	}
+
+	return cvals, nil
+}
+
+// Append atomically appends suffix to the string stored under key and returns the new length in bytes.
+// The key is created if it does not exist.
+func (coll *KeyValueCollection) Append(key, suffix string) (uint32, error) {
+	return coll.client.StringAppend(coll.database, coll.name, key, suffix)
+}
+
+// Prepend atomically prepends prefix to the string stored under key and returns the new length in bytes.
+// The key is created if it does not exist.
+func (coll *KeyValueCollection) Prepend(key, prefix string) (uint32, error) {
+	return coll.client.StringPrepend(coll.database, coll.name, key, prefix)
+}
+
+// StringLength returns the length in bytes of the string stored under key (0 if the key does not exist).
+func (coll *KeyValueCollection) StringLength(key string) (uint32, error) {
+	return coll.client.StringLength(coll.database, coll.name, key)
+}
+
+// Substring returns the bytes [start, end) of the string stored under key.
+// Negative offsets count from the end of the string, offsets outside the string are clamped to its bounds.
+func (coll *KeyValueCollection) Substring(key string, start, end int) (string, error) {
+	return coll.client.StringSubstring(coll.database, coll.name, key, int32(start), int32(end))
+}
@@ -0,0 +1,104 @@
+package collection
+
+import (
+	"time"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/client"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+)
+
+// Txn collects checks and writes on keys of a key-value collection and commits them atomically.
+// Create one with KeyValueCollection.Txn; the builder methods return the transaction so calls can be chained.
+//
+//	revisions, err := coll.Txn().
+//		Check("balance:alice", aliceRev).
+//		Set("balance:alice", 90).
+//		Set("balance:bob", 110).
+//		Commit()
+type Txn struct {
+	coll *KeyValueCollection
+	ops  []client.TxnOp
+	err  error
+}
+
+// Txn starts a new transaction on the collection.
+func (coll *KeyValueCollection) Txn() *Txn {
+	return &Txn{coll: coll}
+}
+
+// Check requires that key has the expected revision when the transaction is committed.
+// An expectedRevision of 0 requires that the key does not exist.
+func (txn *Txn) Check(key string, expectedRevision uint64) *Txn {
+	txn.ops = append(txn.ops, client.TxnOp{Type: client.TxnOpCheck, Key: key, Revision: expectedRevision})
+	return txn
+}
+
+// Set sets key to value.
+// The value can be of any type supported by codex.ValueType.
+func (txn *Txn) Set(key string, value any) *Txn {
+	return txn.set(client.TxnOpSet, key, 0, value, 0)
+}
+
+// SetWithTTL sets key to value, the key expires after the given time-to-live (TTL) duration.
+func (txn *Txn) SetWithTTL(key string, value any, ttl time.Duration) *Txn {
+	return txn.set(client.TxnOpSet, key, 0, value, ttl)
+}
+
+// Delete deletes key.
+func (txn *Txn) Delete(key string) *Txn {
+	txn.ops = append(txn.ops, client.TxnOp{Type: client.TxnOpDelete, Key: key})
+	return txn
+}
+
+// CompareAndSet sets key to value if the key has the expected revision.
+// An expectedRevision of 0 requires that the key does not exist yet.
+func (txn *Txn) CompareAndSet(key string, expectedRevision uint64, value any) *Txn {
+	return txn.set(client.TxnOpCompareAndSet, key, expectedRevision, value, 0)
+}
+
+// CompareAndSetWithTTL is like CompareAndSet, but the key expires after the given time-to-live (TTL) duration.
+func (txn *Txn) CompareAndSetWithTTL(key string, expectedRevision uint64, value any, ttl time.Duration) *Txn {
+	return txn.set(client.TxnOpCompareAndSet, key, expectedRevision, value, ttl)
+}
+
+// CompareAndDelete deletes key if it has the expected revision.
+func (txn *Txn) CompareAndDelete(key string, expectedRevision uint64) *Txn {
+	txn.ops = append(txn.ops, client.TxnOp{Type: client.TxnOpCompareAndDelete, Key: key, Revision: expectedRevision})
+	return txn
+}
+
+func (txn *Txn) set(opType client.TxnOpType, key string, expectedRevision uint64, value any, ttl time.Duration) *Txn {
+	cval, err := codex.NewValue(value)
+	if err != nil {
+		if txn.err == nil {
+			txn.err = err
+		}
+		return txn
+	}
+
+	var expiresAt uint64
+	if ttl > 0 {
+		expiresAt = uint64(time.Now().Add(ttl).UnixNano())
+	}
+
+	txn.ops = append(txn.ops, client.TxnOp{
+		Type:      opType,
+		Key:       key,
+		Revision:  expectedRevision,
+		Value:     cval,
+		ExpiresAt: expiresAt,
+	})
+	return txn
+}
+
+// Commit applies all operations of the transaction atomically: either all of them or none.
+// Conditions are evaluated against the state before the transaction, writes are applied in the order they were added.
+// It returns the new revision of every set operation in the order they were added (0 for the other operations),
+// or a *client.TxnConflictError (wrapping client.ErrRevisionMismatch) if a condition does not hold.
+func (txn *Txn) Commit() ([]uint64, error) {
+	if txn.err != nil {
+		return nil, txn.err
+	}
+
+	return txn.coll.client.KVTxn(txn.coll.database, txn.coll.name, txn.ops)
+}
@@ -1,6 +1,10 @@
 package collection
 
-import "github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/client"
+import (
+	"io"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/client"
+)
 
 // ObjectCollection represents a collection that stores objects in the storage system.
 type ObjectCollection struct {
@@ -38,16 +42,114 @@ func (coll *ObjectCollection) Get(key string) ([]byte, error) {
 	return coll.client.ObjGet(coll.database, coll.name, key)
 }
 
+// PutWithMetadata stores the given binary data with a content type and user-defined metadata under the specified key.
+func (coll *ObjectCollection) PutWithMetadata(key string, data []byte, contentType string, metadata map[string]string) error {
+	return coll.client.ObjPutWithMetadata(coll.database, coll.name, key, data, contentType, metadata)
+}
+
+// PutVersioned is like PutWithMetadata, but returns the version ID of the stored object, which is 0 if versioning is disabled for the collection.
+func (coll *ObjectCollection) PutVersioned(key string, data []byte, contentType string, metadata map[string]string) (uint64, error) {
+	return coll.client.ObjPutVersioned(coll.database, coll.name, key, data, contentType, metadata)
+}
+
+// GetVersion retrieves the binary data of a version of the object associated with the specified key.
+func (coll *ObjectCollection) GetVersion(key string, versionID uint64) ([]byte, error) {
+	return coll.client.ObjGetVersion(coll.database, coll.name, key, versionID)
+}
+
+// PutStream stores the data read from r until io.EOF under the specified key, uploading it in chunks.
+// Use it for objects that are too large to hold in memory or to send in a single command.
+func (coll *ObjectCollection) PutStream(key string, r io.Reader) error {
+	return coll.client.ObjPutStream(coll.database, coll.name, key, r)
+}
+
+// PutStreamWithMetadata is like PutStream, but stores the object with a content type and user-defined metadata.
+func (coll *ObjectCollection) PutStreamWithMetadata(key string, r io.Reader, contentType string, metadata map[string]string) error {
+	return coll.client.ObjPutStreamWithMetadata(coll.database, coll.name, key, r, contentType, metadata)
+}
+
+// GetStream writes the object associated with the specified key to w, downloading it in chunks.
+// It returns the number of bytes written.
+func (coll *ObjectCollection) GetStream(key string, w io.Writer) (int64, error) {
+	return coll.client.ObjGetStream(coll.database, coll.name, key, w)
+}
+
+// GetRange retrieves up to length bytes of the object associated with the specified key, starting at offset.
+func (coll *ObjectCollection) GetRange(key string, offset uint64, length uint32) ([]byte, error) {
+	return coll.client.ObjGetRange(coll.database, coll.name, key, offset, length)
+}
+
 // GetMetadata retrieves the metadata associated with the specified key from the collection.
 func (coll *ObjectCollection) GetMetadata(key string) (*client.ObjectMetadata, error) {
 	return coll.client.ObjGetMetadata(coll.database, coll.name, key)
 }
 
+// GetMetadataVersion retrieves the metadata of a version of the object associated with the specified key, which may be a delete marker.
+func (coll *ObjectCollection) GetMetadataVersion(key string, versionID uint64) (*client.ObjectMetadata, error) {
+	return coll.client.ObjGetMetadataVersion(coll.database, coll.name, key, versionID)
+}
+
+// ListVersions returns the versions of the object associated with the specified key, including delete markers, newest first.
+func (coll *ObjectCollection) ListVersions(key string) ([]client.ObjectMetadata, error) {
+	return coll.client.ObjListVersions(coll.database, coll.name, key)
+}
+
+// List returns a page of the objects in the collection, ordered lexicographically by their keys.
+// Pass the NextToken of a truncated result as opts.Token to list the next page.
+func (coll *ObjectCollection) List(opts client.ObjListOptions) (*client.ObjListResult, error) {
+	return coll.client.ObjList(coll.database, coll.name, opts)
+}
+
+// Exists checks whether an object is stored under the specified key.
+func (coll *ObjectCollection) Exists(key string) (bool, error) {
+	return coll.client.ObjExists(coll.database, coll.name, key)
+}
+
+// Copy copies the object stored under srcKey to dstKey within the collection, keeping its creation time.
+func (coll *ObjectCollection) Copy(srcKey, dstKey string) error {
+	return coll.client.ObjCopy(coll.database, coll.name, srcKey, "", dstKey)
+}
+
+// CopyTo copies the object stored under srcKey to dstKey in dst, which must belong to the same database.
+func (coll *ObjectCollection) CopyTo(srcKey string, dst *ObjectCollection, dstKey string) error {
+	if dst.database != coll.database {
+		return client.ErrDifferentDatabase
+	}
+
+	return coll.client.ObjCopy(coll.database, coll.name, srcKey, dst.name, dstKey)
+}
+
+// Move moves the object stored under srcKey to dstKey within the collection, keeping its creation time.
+func (coll *ObjectCollection) Move(srcKey, dstKey string) error {
+	return coll.client.ObjMove(coll.database, coll.name, srcKey, "", dstKey)
+}
+
+// MoveTo moves the object stored under srcKey to dstKey in dst, which must belong to the same database.
+func (coll *ObjectCollection) MoveTo(srcKey string, dst *ObjectCollection, dstKey string) error {
+	if dst.database != coll.database {
+		return client.ErrDifferentDatabase
+	}
+
+	return coll.client.ObjMove(coll.database, coll.name, srcKey, dst.name, dstKey)
+}
+
+// Rename moves the object stored under oldKey to newKey within the collection.
+func (coll *ObjectCollection) Rename(oldKey, newKey string) error {
+	return coll.client.ObjRename(coll.database, coll.name, oldKey, newKey)
+}
+
 // Delete removes the object associated with the specified key from the collection.
+// In versioned collections, a delete marker is created instead and the previous versions are kept.
 func (coll *ObjectCollection) Delete(key string) error {
 	return coll.client.ObjDelete(coll.database, coll.name, key)
 }
 
+// DeleteVersion permanently removes a version of the object associated with the specified key.
+// If it is the current version, the previous version becomes current.
+func (coll *ObjectCollection) DeleteVersion(key string, versionID uint64) error {
+	return coll.client.ObjDeleteVersion(coll.database, coll.name, key, versionID)
+}
+
 // Count returns the total number of objects stored in the collection.
 func (coll *ObjectCollection) Count() (uint32, error) {
 	return coll.client.ObjCount(coll.database, coll.name)
@@ -57,3 +159,19 @@ func (coll *ObjectCollection) Count() (uint32, error) {
 func (coll *ObjectCollection) Size() (uint64, error) {
 	return coll.client.ObjSize(coll.database, coll.name)
 }
+
+// Stats returns the disk usage and the compaction counters of the collection.
+func (coll *ObjectCollection) Stats() (*client.ObjStats, error) {
+	return coll.client.ObjStats(coll.database, coll.name)
+}
+
+// LifecycleReport returns the objects that the lifecycle rules of the collection expire right now, without deleting them.
+func (coll *ObjectCollection) LifecycleReport() (*client.ObjLifecycleReport, error) {
+	return coll.client.ObjLifecycleReport(coll.database, coll.name)
+}
+
+// Compact schedules the compaction of the collection, which removes replaced and deleted objects from disk.
+// It returns once the compaction is scheduled and requires admin permission on the database.
+func (coll *ObjectCollection) Compact() error {
+	return coll.client.ObjCompact(coll.database, coll.name)
+}
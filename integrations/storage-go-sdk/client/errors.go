@@ -1,6 +1,11 @@
 package client
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
 
 var (
 	ErrProtocolVersionNotSupported = errors.New("protocol version not supported")
@@ -14,4 +19,42 @@ var (
 	ErrCollectionNotFound          = errors.New("collection not found")
 	ErrKeyNotFound                 = errors.New("key not found")
 	ErrInvalidEngine               = errors.New("invalid engine")
+	ErrBadRequest                  = errors.New("bad request")
+	ErrTypeMismatch                = errors.New("value type does not support this operation")
+	ErrOverflow                    = errors.New("numeric overflow")
+	ErrOutOfRange                  = errors.New("index out of range")
+	ErrRevisionMismatch            = errors.New("revision mismatch")
+	ErrLimitExceeded               = errors.New("memory or entry limit of the collection reached")
+	ErrUploadNotFound              = errors.New("upload not found")
+	ErrObjectTooLarge              = errors.New("object exceeds the maximum object size")
+	ErrMetadataTooLarge            = errors.New("content type and metadata exceed the maximum metadata size")
+	ErrObjectChanged               = errors.New("object changed during download")
+	ErrForbidden                   = errors.New("permission denied")
+	ErrDifferentDatabase           = errors.New("objects can only be copied or moved within a database")
+	ErrNotStream                   = errors.New("broker collection is not a stream")
+	ErrConsumerNotFound            = errors.New("consumer not found")
+	ErrNotPending                  = errors.New("message is not pending or consumer not found")
+	ErrNoResponders                = errors.New("no subscriber matches the request subject")
 )
+
+// kvStatusError maps the status code of a failed key-value command to an error.
+func kvStatusError(resp *protocol.Response) error {
+	switch resp.Code {
+	case protocol.StatusNotFound:
+		return ErrKeyNotFound
+	case protocol.StatusTypeMismatch:
+		return ErrTypeMismatch
+	case protocol.StatusOverflow:
+		return ErrOverflow
+	case protocol.StatusOutOfRange:
+		return ErrOutOfRange
+	case protocol.StatusConflict:
+		return ErrRevisionMismatch
+	case protocol.StatusLimitExceeded:
+		return ErrLimitExceeded
+	case protocol.StatusBadRequest:
+		return fmt.Errorf("%w: %s", ErrBadRequest, string(resp.Payload))
+	default:
+		return ErrUnexpectedStatusCode
+	}
+}
@@ -33,7 +33,7 @@ func (c *Client) KVSet(db, coll string, key string, value *codex.Value) error {
 	}
 
 	if resp.Code != protocol.StatusOK {
-		return ErrUnexpectedStatusCode
+		return kvStatusError(resp)
 	}
 
 	return nil
@@ -67,7 +67,7 @@ func (c *Client) KVSetTTL(db, coll string, key string, value *codex.Value, expir
 	}
 
 	if resp.Code != protocol.StatusOK {
-		return ErrUnexpectedStatusCode
+		return kvStatusError(resp)
 	}
 
 	return nil
@@ -203,6 +203,46 @@ func (c *Client) KVGet(db, coll string, key string) (*codex.Value, error) {
 	return val, nil
 }
 
+// KVGetWithRevision implements the client side of the protocol.ServerCommandKVGetWithRevision command.
+func (c *Client) KVGetWithRevision(db, coll string, key string) (*codex.Value, uint64, error) {
+	totalLen := 2 + len(key)
+	payload := make([]byte, totalLen)
+
+	// Key
+	binary.BigEndian.PutUint16(payload[0:2], uint16(len(key)))
+	copy(payload[2:2+len(key)], []byte(key))
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandKVGetWithRevision,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		if resp.Code == protocol.StatusNotFound {
+			return nil, 0, ErrKeyNotFound
+		}
+
+		return nil, 0, ErrUnexpectedStatusCode
+	}
+
+	if len(resp.Payload) < 8 {
+		return nil, 0, ErrInvalidPayloadLength
+	}
+	revision := binary.BigEndian.Uint64(resp.Payload[0:8])
+
+	val, err := codex.DecodeValue(resp.Payload[8:])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return val, revision, nil
+}
+
 // KVGetMultiple implements the client side of the protocol.ServerCommandKVGetMultiple command.
 func (c *Client) KVGetMultiple(db, coll string, keys []string) (map[string]*codex.Value, error) {
 	keyVals := codex.NewStringListValue(keys)
@@ -449,3 +489,38 @@ func (c *Client) KVSize(db, coll string) (uint64, error) {
 
 	return size, nil
 }
+
+// KVStats implements the client side of the protocol.ServerCommandKVStats command.
+func (c *Client) KVStats(db, coll string) (*KVStats, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandKVStats,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        *commonresponses.EmptyPayload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return nil, ErrUnexpectedStatusCode
+	}
+
+	data, err := codex.DecodeValue(resp.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats KVStats
+	if err := codex.Unmarshal(data.AsBinary(), &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// appendKey appends a length-prefixed key (| Key Length (2 bytes) | Key (variable) |) to dst.
+func appendKey(dst []byte, key string) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(key)))
+	return append(dst, key...)
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// KVListLength implements the client side of the protocol.ServerCommandKVListLength command.
+func (c *Client) KVListLength(db, coll string, key string) (uint32, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandKVListLength,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        appendKey(nil, key),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return 0, kvStatusError(resp)
+	}
+
+	if len(resp.Payload) < 4 {
+		return 0, ErrInvalidPayloadLength
+	}
+
+	return binary.BigEndian.Uint32(resp.Payload[0:4]), nil
+}
+
+// KVListGet implements the client side of the protocol.ServerCommandKVListGet command.
+func (c *Client) KVListGet(db, coll string, key string, index int32) (*codex.Value, error) {
+	payload := appendKey(nil, key)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(index))
+
+	return c.kvListValueCmd(protocol.ServerCommandKVListGet, db, coll, payload)
+}
+
+// KVListSet implements the client side of the protocol.ServerCommandKVListSet command.
+func (c *Client) KVListSet(db, coll string, key string, index int32, value *codex.Value) error {
+	payload := appendKey(nil, key)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(index))
+	payload = append(payload, codex.EncodeValue(value)...)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandKVListSet,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return kvStatusError(resp)
+	}
+
+	return nil
+}
+
+// KVListRemove implements the client side of the protocol.ServerCommandKVListRemove command.
+func (c *Client) KVListRemove(db, coll string, key string, index int32) (*codex.Value, error) {
+	payload := appendKey(nil, key)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(index))
+
+	return c.kvListValueCmd(protocol.ServerCommandKVListRemove, db, coll, payload)
+}
+
+// KVListLeftPush implements the client side of the protocol.ServerCommandKVListLeftPush command.
+func (c *Client) KVListLeftPush(db, coll string, key string, values []*codex.Value) (uint32, error) {
+	return c.kvListPush(protocol.ServerCommandKVListLeftPush, db, coll, key, values)
+}
+
+// KVListRightPush implements the client side of the protocol.ServerCommandKVListRightPush command.
+func (c *Client) KVListRightPush(db, coll string, key string, values []*codex.Value) (uint32, error) {
+	return c.kvListPush(protocol.ServerCommandKVListRightPush, db, coll, key, values)
+}
+
+// KVListLeftPop implements the client side of the protocol.ServerCommandKVListLeftPop command.
+func (c *Client) KVListLeftPop(db, coll string, key string) (*codex.Value, error) {
+	return c.kvListValueCmd(protocol.ServerCommandKVListLeftPop, db, coll, appendKey(nil, key))
+}
+
+// KVListRightPop implements the client side of the protocol.ServerCommandKVListRightPop command.
+func (c *Client) KVListRightPop(db, coll string, key string) (*codex.Value, error) {
+	return c.kvListValueCmd(protocol.ServerCommandKVListRightPop, db, coll, appendKey(nil, key))
+}
+
+func (c *Client) kvListPush(cmdID uint16, db, coll string, key string, values []*codex.Value) (uint32, error) {
+	payload := appendKey(nil, key)
+	payload = append(payload, codex.EncodeList(values)...)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             cmdID,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return 0, kvStatusError(resp)
+	}
+
+	if len(resp.Payload) < 4 {
+		return 0, ErrInvalidPayloadLength
+	}
+
+	return binary.BigEndian.Uint32(resp.Payload[0:4]), nil
+}
+
+// kvListValueCmd sends a list command whose successful response is a single codex-encoded value.
+func (c *Client) kvListValueCmd(cmdID uint16, db, coll string, payload []byte) (*codex.Value, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             cmdID,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return nil, kvStatusError(resp)
+	}
+
+	return codex.DecodeValue(resp.Payload)
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// KVMapLength implements the client side of the protocol.ServerCommandKVMapLength command.
+func (c *Client) KVMapLength(db, coll string, key string) (uint32, error) {
+	resp, err := c.kvMapCmd(protocol.ServerCommandKVMapLength, db, coll, appendKey(nil, key))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(resp.Payload) < 4 {
+		return 0, ErrInvalidPayloadLength
+	}
+
+	return binary.BigEndian.Uint32(resp.Payload[0:4]), nil
+}
+
+// KVMapSet implements the client side of the protocol.ServerCommandKVMapSet command.
+func (c *Client) KVMapSet(db, coll string, key, field string, value *codex.Value) error {
+	payload := appendKey(appendKey(nil, key), field)
+	payload = append(payload, codex.EncodeValue(value)...)
+
+	_, err := c.kvMapCmd(protocol.ServerCommandKVMapSet, db, coll, payload)
+	return err
+}
+
+// KVMapGet implements the client side of the protocol.ServerCommandKVMapGet command.
+func (c *Client) KVMapGet(db, coll string, key, field string) (*codex.Value, error) {
+	resp, err := c.kvMapCmd(protocol.ServerCommandKVMapGet, db, coll, appendKey(appendKey(nil, key), field))
+	if err != nil {
+		return nil, err
+	}
+
+	return codex.DecodeValue(resp.Payload)
+}
+
+// KVMapDelete implements the client side of the protocol.ServerCommandKVMapDelete command.
+func (c *Client) KVMapDelete(db, coll string, key, field string) error {
+	_, err := c.kvMapCmd(protocol.ServerCommandKVMapDelete, db, coll, appendKey(appendKey(nil, key), field))
+	return err
+}
+
+// KVMapExists implements the client side of the protocol.ServerCommandKVMapExists command.
+func (c *Client) KVMapExists(db, coll string, key, field string) (bool, error) {
+	_, err := c.kvMapCmd(protocol.ServerCommandKVMapExists, db, coll, appendKey(appendKey(nil, key), field))
+	if errors.Is(err, ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// KVMapKeys implements the client side of the protocol.ServerCommandKVMapKeys command.
+func (c *Client) KVMapKeys(db, coll string, key string) ([]string, error) {
+	resp, err := c.kvMapCmd(protocol.ServerCommandKVMapKeys, db, coll, appendKey(nil, key))
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := codex.DecodeList(resp.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(items))
+	for i, item := range items {
+		if item.Type != codex.TypeString {
+			return nil, ErrUnexpectedDataType
+		}
+		keys[i] = item.AsString()
+	}
+
+	return keys, nil
+}
+
+// KVMapValues implements the client side of the protocol.ServerCommandKVMapValues command.
+func (c *Client) KVMapValues(db, coll string, key string) ([]*codex.Value, error) {
+	resp, err := c.kvMapCmd(protocol.ServerCommandKVMapValues, db, coll, appendKey(nil, key))
+	if err != nil {
+		return nil, err
+	}
+
+	return codex.DecodeList(resp.Payload)
+}
+
+// kvMapCmd sends a map command and returns the response if it was successful.
+func (c *Client) kvMapCmd(cmdID uint16, db, coll string, payload []byte) (*protocol.Response, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             cmdID,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return nil, kvStatusError(resp)
+	}
+
+	return resp, nil
+}
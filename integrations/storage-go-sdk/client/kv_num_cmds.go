@@ -0,0 +1,97 @@
+package client
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// KVNumIncrement implements the client side of the protocol.ServerCommandKVNumIncrement command.
+func (c *Client) KVNumIncrement(db, coll string, key string, delta *codex.Value) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumIncrement, db, coll, key, delta)
+}
+
+// KVNumDecrement implements the client side of the protocol.ServerCommandKVNumDecrement command.
+func (c *Client) KVNumDecrement(db, coll string, key string, delta *codex.Value) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumDecrement, db, coll, key, delta)
+}
+
+// KVNumMultiply implements the client side of the protocol.ServerCommandKVNumMultiply command.
+func (c *Client) KVNumMultiply(db, coll string, key string, factor *codex.Value) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumMultiply, db, coll, key, factor)
+}
+
+// KVNumDivide implements the client side of the protocol.ServerCommandKVNumDivide command.
+func (c *Client) KVNumDivide(db, coll string, key string, divisor *codex.Value) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumDivide, db, coll, key, divisor)
+}
+
+// KVNumModulo implements the client side of the protocol.ServerCommandKVNumModulo command.
+func (c *Client) KVNumModulo(db, coll string, key string, divisor *codex.Value) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumModulo, db, coll, key, divisor)
+}
+
+// KVNumLeftShift implements the client side of the protocol.ServerCommandKVNumLeftShift command.
+func (c *Client) KVNumLeftShift(db, coll string, key string, bits *codex.Value) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumLeftShift, db, coll, key, bits)
+}
+
+// KVNumRightShift implements the client side of the protocol.ServerCommandKVNumRightShift command.
+func (c *Client) KVNumRightShift(db, coll string, key string, bits *codex.Value) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumRightShift, db, coll, key, bits)
+}
+
+// KVNumBitwiseAnd implements the client side of the protocol.ServerCommandKVNumBitwiseAnd command.
+func (c *Client) KVNumBitwiseAnd(db, coll string, key string, mask *codex.Value) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumBitwiseAnd, db, coll, key, mask)
+}
+
+// KVNumBitwiseOr implements the client side of the protocol.ServerCommandKVNumBitwiseOr command.
+func (c *Client) KVNumBitwiseOr(db, coll string, key string, mask *codex.Value) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumBitwiseOr, db, coll, key, mask)
+}
+
+// KVNumBitwiseXor implements the client side of the protocol.ServerCommandKVNumBitwiseXor command.
+func (c *Client) KVNumBitwiseXor(db, coll string, key string, mask *codex.Value) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumBitwiseXor, db, coll, key, mask)
+}
+
+// KVNumBitwiseNot implements the client side of the protocol.ServerCommandKVNumBitwiseNot command.
+func (c *Client) KVNumBitwiseNot(db, coll string, key string) (*codex.Value, error) {
+	return c.kvNumOp(protocol.ServerCommandKVNumBitwiseNot, db, coll, key, nil)
+}
+
+// kvNumOp sends one of the protocol.ServerCommandKVNum* commands and returns the new value of the key.
+func (c *Client) kvNumOp(cmdID uint16, db, coll string, key string, operand *codex.Value) (*codex.Value, error) {
+	var data []byte
+	if operand != nil {
+		data = codex.EncodeValue(operand)
+	}
+
+	totalLen := 2 + len(key) + len(data)
+	payload := make([]byte, totalLen)
+
+	// Key
+	binary.BigEndian.PutUint16(payload[0:2], uint16(len(key)))
+	copy(payload[2:2+len(key)], []byte(key))
+
+	// Operand
+	copy(payload[2+len(key):], data)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             cmdID,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return nil, kvStatusError(resp)
+	}
+
+	return codex.DecodeValue(resp.Payload)
+}
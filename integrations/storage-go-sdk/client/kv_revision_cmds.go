@@ -0,0 +1,57 @@
+package client
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// KVCompareAndSet implements the client side of the protocol.ServerCommandKVCompareAndSet command.
+// It returns the new revision of the key, or the current revision together with ErrRevisionMismatch.
+// An expectedRevision of 0 requires that the key does not exist, an expiresAt of 0 stores the value without expiration.
+func (c *Client) KVCompareAndSet(db, coll string, key string, expectedRevision uint64, value *codex.Value, expiresAt uint64) (uint64, error) {
+	payload := appendKey(nil, key)
+	payload = binary.BigEndian.AppendUint64(payload, expectedRevision)
+	payload = binary.BigEndian.AppendUint64(payload, expiresAt)
+	payload = append(payload, codex.EncodeValue(value)...)
+
+	return c.kvRevisionCmd(protocol.ServerCommandKVCompareAndSet, db, coll, payload)
+}
+
+// KVCompareAndDelete implements the client side of the protocol.ServerCommandKVCompareAndDelete command.
+// It returns the current revision together with ErrRevisionMismatch if the key was not deleted.
+func (c *Client) KVCompareAndDelete(db, coll string, key string, expectedRevision uint64) (uint64, error) {
+	payload := appendKey(nil, key)
+	payload = binary.BigEndian.AppendUint64(payload, expectedRevision)
+
+	return c.kvRevisionCmd(protocol.ServerCommandKVCompareAndDelete, db, coll, payload)
+}
+
+// kvRevisionCmd sends a conditional write and returns the revision contained in the response.
+func (c *Client) kvRevisionCmd(cmdID uint16, db, coll string, payload []byte) (uint64, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             cmdID,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Code != protocol.StatusOK && resp.Code != protocol.StatusConflict {
+		return 0, kvStatusError(resp)
+	}
+
+	if len(resp.Payload) < 8 {
+		return 0, ErrInvalidPayloadLength
+	}
+	revision := binary.BigEndian.Uint64(resp.Payload[0:8])
+
+	if resp.Code == protocol.StatusConflict {
+		return revision, ErrRevisionMismatch
+	}
+
+	return revision, nil
+}
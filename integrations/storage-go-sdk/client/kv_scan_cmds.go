@@ -0,0 +1,57 @@
+package client
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// KVScan implements the client side of the protocol.ServerCommandKVScan command.
+// Pass a nil cursor to start a new scan and the returned cursor to fetch the next page.
+// The scan is finished when the returned cursor is empty.
+func (c *Client) KVScan(db, coll string, cursor []byte, count uint32, pattern string) ([]string, []byte, error) {
+	payload := binary.BigEndian.AppendUint32(nil, uint32(len(cursor)))
+	payload = append(payload, cursor...)
+	payload = binary.BigEndian.AppendUint32(payload, count)
+	payload = appendKey(payload, pattern)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandKVScan,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return nil, nil, kvStatusError(resp)
+	}
+
+	data := resp.Payload
+	if len(data) < 4 {
+		return nil, nil, ErrInvalidPayloadLength
+	}
+	cursorLen := int(binary.BigEndian.Uint32(data[0:4]))
+	if cursorLen > len(data)-4 {
+		return nil, nil, ErrInvalidPayloadLength
+	}
+	next := data[4 : 4+cursorLen]
+
+	items, err := codex.DecodeList(data[4+cursorLen:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := make([]string, len(items))
+	for i, item := range items {
+		if item.Type != codex.TypeString {
+			return nil, nil, ErrUnexpectedDataType
+		}
+		keys[i] = item.AsString()
+	}
+
+	return keys, next, nil
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// StringAppend implements the client side of the protocol.ServerCommandStringAppend command.
+func (c *Client) StringAppend(db, coll string, key string, suffix string) (uint32, error) {
+	return c.stringConcat(protocol.ServerCommandStringAppend, db, coll, key, suffix)
+}
+
+// StringPrepend implements the client side of the protocol.ServerCommandStringPrepend command.
+func (c *Client) StringPrepend(db, coll string, key string, prefix string) (uint32, error) {
+	return c.stringConcat(protocol.ServerCommandStringPrepend, db, coll, key, prefix)
+}
+
+// StringLength implements the client side of the protocol.ServerCommandStringLength command.
+func (c *Client) StringLength(db, coll string, key string) (uint32, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandStringLength,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        appendKey(nil, key),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return 0, kvStatusError(resp)
+	}
+
+	if len(resp.Payload) < 4 {
+		return 0, ErrInvalidPayloadLength
+	}
+
+	return binary.BigEndian.Uint32(resp.Payload[0:4]), nil
+}
+
+// StringSubstring implements the client side of the protocol.ServerCommandStringSubstring command.
+func (c *Client) StringSubstring(db, coll string, key string, start, end int32) (string, error) {
+	payload := appendKey(nil, key)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(start))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(end))
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandStringSubstring,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return "", kvStatusError(resp)
+	}
+
+	return codex.DecodeString(resp.Payload)
+}
+
+func (c *Client) stringConcat(cmdID uint16, db, coll string, key string, part string) (uint32, error) {
+	payload := appendKey(nil, key)
+	payload = append(payload, codex.EncodeString(part)...)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             cmdID,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return 0, kvStatusError(resp)
+	}
+
+	if len(resp.Payload) < 4 {
+		return 0, ErrInvalidPayloadLength
+	}
+
+	return binary.BigEndian.Uint32(resp.Payload[0:4]), nil
+}
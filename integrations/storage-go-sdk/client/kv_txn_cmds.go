@@ -0,0 +1,109 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// TxnOpType is the kind of operation in a transaction.
+type TxnOpType uint8
+
+const (
+	// TxnOpCheck requires that the key has the expected revision, without writing it.
+	TxnOpCheck TxnOpType = iota + 1
+	// TxnOpSet stores the value for the key.
+	TxnOpSet
+	// TxnOpDelete deletes the key.
+	TxnOpDelete
+	// TxnOpCompareAndSet stores the value for the key if it has the expected revision.
+	TxnOpCompareAndSet
+	// TxnOpCompareAndDelete deletes the key if it has the expected revision.
+	TxnOpCompareAndDelete
+)
+
+// TxnOp is a single operation of a transaction.
+// Revision is the expected revision of conditional operations (0 means the key must not exist),
+// Value and ExpiresAt (unix nanoseconds, 0 for no expiration) are only used by set operations.
+type TxnOp struct {
+	Type      TxnOpType
+	Key       string
+	Revision  uint64
+	Value     *codex.Value
+	ExpiresAt uint64
+}
+
+// TxnConflictError is returned by KVTxn if a condition of the transaction does not hold.
+// It wraps ErrRevisionMismatch.
+type TxnConflictError struct {
+	// Op is the index of the first operation whose condition failed.
+	Op int
+	// Revision is the current revision of the key of that operation (0 if it does not exist).
+	Revision uint64
+}
+
+func (e *TxnConflictError) Error() string {
+	return fmt.Sprintf("transaction operation %d: %s (current revision %d)", e.Op, ErrRevisionMismatch, e.Revision)
+}
+
+func (e *TxnConflictError) Unwrap() error {
+	return ErrRevisionMismatch
+}
+
+// KVTxn implements the client side of the protocol.ServerCommandKVTxn command.
+// The operations are applied atomically: either all of them or none.
+// It returns the new revision of every set operation (0 for the other operations),
+// or a *TxnConflictError if a condition does not hold.
+func (c *Client) KVTxn(db, coll string, ops []TxnOp) ([]uint64, error) {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(len(ops)))
+	for _, op := range ops {
+		payload = append(payload, byte(op.Type))
+		payload = appendKey(payload, op.Key)
+		payload = binary.BigEndian.AppendUint64(payload, op.Revision)
+		payload = binary.BigEndian.AppendUint64(payload, op.ExpiresAt)
+
+		if op.Value == nil {
+			payload = binary.BigEndian.AppendUint32(payload, 0)
+			continue
+		}
+		value := codex.EncodeValue(op.Value)
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(value)))
+		payload = append(payload, value...)
+	}
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandKVTxn,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+		if len(resp.Payload) != len(ops)*8 {
+			return nil, ErrInvalidPayloadLength
+		}
+
+		revisions := make([]uint64, len(ops))
+		for i := range revisions {
+			revisions[i] = binary.BigEndian.Uint64(resp.Payload[i*8 : i*8+8])
+		}
+		return revisions, nil
+	case protocol.StatusConflict:
+		if len(resp.Payload) < 10 {
+			return nil, ErrInvalidPayloadLength
+		}
+
+		return nil, &TxnConflictError{
+			Op:       int(binary.BigEndian.Uint16(resp.Payload[0:2])),
+			Revision: binary.BigEndian.Uint64(resp.Payload[2:10]),
+		}
+	default:
+		return nil, kvStatusError(resp)
+	}
+}
@@ -8,6 +8,24 @@ type DatabaseDatabase struct {
 	Users     map[string]string `json:"users"`
 }
 
+// KVStats contains the usage, limits and eviction counters of a key-value collection.
+type KVStats struct {
+	Entries        uint64 `json:"entries"`
+	MemoryBytes    uint64 `json:"memory_bytes"`
+	MaxEntries     uint64 `json:"max_entries"`      // 0 = unlimited
+	MaxMemoryBytes uint64 `json:"max_memory_bytes"` // 0 = unlimited
+	EvictionPolicy string `json:"eviction_policy"`
+
+	// Evictions is the number of entries evicted to stay within the limits.
+	Evictions uint64 `json:"evictions"`
+	// RejectedWrites is the number of writes rejected because of the limits.
+	RejectedWrites uint64 `json:"rejected_writes"`
+	// Expirations is the number of expired entries removed.
+	Expirations uint64 `json:"expirations"`
+	// DroppedEvents is the number of keyspace notifications dropped, because they could not be published fast enough.
+	DroppedEvents uint64 `json:"dropped_events"`
+}
+
 type DatabaseCollection struct {
 	Database  string    `json:"database"`
 	Name      string    `json:"name"`
@@ -30,4 +48,244 @@ type ObjectMetadata struct {
 
 	// ModifiedAt is the timestamp (in unix milliseconds) when the object was last modified
 	ModifiedAt int64
+
+	// ContentType is the media type of the object (e.g. "image/png"), empty if unknown
+	ContentType string
+
+	// Metadata is user-defined metadata of the object, nil if there is none
+	Metadata map[string]string
+
+	// VersionID identifies the version of the object, it is 0 for objects stored while versioning was disabled
+	VersionID uint64
+
+	// DeleteMarker is set for the versions created by deleting an object in a versioned collection
+	DeleteMarker bool
+}
+
+// ObjStats contains the disk usage and the compaction counters of an object collection.
+type ObjStats struct {
+	Objects uint64 `json:"objects"`
+	// Versions is the number of older versions and delete markers kept by versioning.
+	Versions uint64 `json:"versions"`
+
+	// FileBytes is the size of all shard files of the collection.
+	FileBytes uint64 `json:"file_bytes"`
+	// GarbageBytes is the part of FileBytes taken up by replaced and deleted objects, which is reclaimed by compaction.
+	GarbageBytes uint64 `json:"garbage_bytes"`
+
+	// Compactions is the number of compacted shards since the server started.
+	Compactions uint64 `json:"compactions"`
+	// ReclaimedBytes is the number of bytes reclaimed by compaction since the server started.
+	ReclaimedBytes uint64 `json:"reclaimed_bytes"`
+	// LastCompaction is the time (in unix milliseconds) the last shard was compacted, 0 if none was compacted yet.
+	LastCompaction int64 `json:"last_compaction"`
+	// Compacting reports whether a shard is being compacted right now.
+	Compacting bool `json:"compacting"`
+
+	// Expirations is the number of objects deleted by the lifecycle rules since the server started.
+	Expirations uint64 `json:"expirations"`
+}
+
+// ObjLifecycleReport describes the objects that the lifecycle rules of an object collection expire.
+type ObjLifecycleReport struct {
+	// Objects is the number of expired objects.
+	Objects uint64 `json:"objects"`
+	// Bytes is the total size of the expired objects.
+	Bytes uint64 `json:"bytes"`
+	// Keys are the keys of the expired objects in lexicographic order, at most 1000.
+	Keys []string `json:"keys"`
+	// Truncated reports whether more objects expire than Keys contains.
+	Truncated bool `json:"truncated"`
+}
+
+// ObjListOptions controls which objects ObjList returns.
+type ObjListOptions struct {
+	// Prefix restricts the listing to keys that start with it.
+	Prefix string
+
+	// Delimiter groups keys that contain it after the prefix into a single common prefix, which ends with the first occurrence of the delimiter.
+	// With "/" as delimiter, keys behave like paths and common prefixes like directories.
+	Delimiter string
+
+	// Token continues a listing after the previous page, see ObjListResult.NextToken.
+	Token string
+
+	// MaxKeys is the maximum number of objects and common prefixes to return, capped at 1000 by the server (0 = 1000).
+	MaxKeys uint16
+}
+
+// ObjListResult is a page of an object listing.
+type ObjListResult struct {
+	// Objects are the metadata of the listed objects in lexicographic order of their keys.
+	Objects []ObjectMetadata
+
+	// CommonPrefixes are the common prefixes of the keys grouped by the delimiter in lexicographic order.
+	CommonPrefixes []string
+
+	// Truncated reports whether there are more objects or common prefixes, which can be listed by passing NextToken as ObjListOptions.Token.
+	Truncated bool
+	NextToken string
+}
+
+// BrokerStartPolicy decides where a stream subscription starts.
+type BrokerStartPolicy byte
+
+const (
+	// BrokerStartNew delivers only messages that are published after subscribing.
+	BrokerStartNew BrokerStartPolicy = 0
+	// BrokerStartAll delivers all messages that are stored in the stream.
+	BrokerStartAll BrokerStartPolicy = 1
+	// BrokerStartSequence delivers the messages starting at BrokerStartPosition.Sequence.
+	BrokerStartSequence BrokerStartPolicy = 2
+	// BrokerStartTime delivers the messages that were published at or after BrokerStartPosition.Time.
+	BrokerStartTime BrokerStartPolicy = 3
+)
+
+// BrokerStartPosition is the position in a broker stream at which a stream subscription starts.
+// To continue after a restart, subscribe with BrokerStartSequence and the sequence number after the last processed message.
+type BrokerStartPosition struct {
+	Policy   BrokerStartPolicy
+	Sequence uint64
+	Time     time.Time
+}
+
+// BrokerStreamMessage is a message delivered by a stream subscription.
+type BrokerStreamMessage struct {
+	// Sequence is the position of the message in the stream, it increases with every published message
+	Sequence uint64
+
+	// Timestamp is the time (in unix milliseconds) the message was published
+	Timestamp int64
+
+	// Subject is the subject the message was published to
+	Subject string
+
+	// ID is assigned by the server when the message is published, dead letters keep the ID of the original message
+	ID string
+
+	// Header carries metadata of the publisher, it is stored in the stream together with the message
+	Header map[string]string
+
+	Data []byte
+}
+
+// BrokerStreamInfo describes the messages stored in a broker stream and its retention limits.
+type BrokerStreamInfo struct {
+	Messages uint64 `json:"messages"`
+	// Bytes is the size of the stored messages on disk.
+	Bytes uint64 `json:"bytes"`
+
+	// FirstSequence is the sequence number of the oldest stored message, LastSequence the one of the newest.
+	// If no message is stored, FirstSequence is the sequence number of the next message and LastSequence the one before.
+	FirstSequence uint64 `json:"first_sequence"`
+	LastSequence  uint64 `json:"last_sequence"`
+	// FirstTimestamp and LastTimestamp are the publish times (in unix milliseconds) of the oldest and the newest message, 0 if no message is stored.
+	FirstTimestamp int64 `json:"first_timestamp"`
+	LastTimestamp  int64 `json:"last_timestamp"`
+
+	MaxMessages   uint64 `json:"max_messages"`    // 0 = unlimited
+	MaxBytes      uint64 `json:"max_bytes"`       // 0 = unlimited
+	MaxAgeSeconds int64  `json:"max_age_seconds"` // 0 = unlimited
+}
+
+// BrokerConsumerConfig configures a durable consumer of a broker stream.
+type BrokerConsumerConfig struct {
+	// Name identifies the consumer. It consists of at most 64 letters, digits, '_' and '-'.
+	Name string
+
+	// Subject is the subject the consumer receives the messages of, including its wildcards
+	Subject string
+
+	// Start is the position in the stream at which the consumer starts, it is only used when the consumer is created
+	Start BrokerStartPosition
+
+	// AckWait is the time after which a delivered message that was not acknowledged is delivered again (0 = 30 seconds)
+	AckWait time.Duration
+
+	// MaxDeliveries is the number of times a message is delivered before it is given up on (0 = unlimited)
+	MaxDeliveries int
+
+	// DeadLetterSubject is the subject messages are published to once they were delivered MaxDeliveries times without being acknowledged.
+	// If it is empty, these messages are dropped. It must not match Subject, otherwise the consumer would receive its dead letters again.
+	DeadLetterSubject string
+}
+
+// BrokerConsumerMessage is a message delivered to a durable consumer. It must be acknowledged, otherwise it is delivered again.
+type BrokerConsumerMessage struct {
+	BrokerStreamMessage
+
+	// Consumer is the name of the consumer the message was delivered to
+	Consumer string
+
+	// Deliveries is the number of times the message was delivered to the consumer, including this delivery
+	Deliveries int
+}
+
+// BrokerSlowConsumerPolicy decides what the server does with messages for a subscription that does not keep up with the publishers.
+type BrokerSlowConsumerPolicy byte
+
+const (
+	// BrokerDropNewest drops new messages while the queue of the subscription is full. This is the default.
+	BrokerDropNewest BrokerSlowConsumerPolicy = 0
+	// BrokerDropOldest drops the oldest queued message to make room for a new one.
+	BrokerDropOldest BrokerSlowConsumerPolicy = 1
+	// BrokerBlock makes publishers wait up to BrokerSubscribeOptions.BlockTimeout for room in the queue, the message is dropped afterward.
+	BrokerBlock BrokerSlowConsumerPolicy = 2
+	// BrokerDisconnect disconnects the client as slow consumer once a message has to be dropped.
+	BrokerDisconnect BrokerSlowConsumerPolicy = 3
+)
+
+// BrokerSubscribeOptions configures how the server handles a subscription that does not keep up with the publishers.
+type BrokerSubscribeOptions struct {
+	Policy BrokerSlowConsumerPolicy
+
+	// BlockTimeout is how long publishers wait for room with BrokerBlock, the server default (100ms) applies if it is 0.
+	// The server rejects timeouts longer than 1s with ErrBadRequest.
+	BlockTimeout time.Duration
+}
+
+// BrokerSlowConsumerNotice is sent by the server when messages start being dropped for a subscription.
+type BrokerSlowConsumerNotice struct {
+	Database   string
+	Collection string
+
+	// Subject and Queue identify the subscription
+	Subject string
+	Queue   string
+
+	Policy BrokerSlowConsumerPolicy
+
+	// Dropped is the number of messages dropped for the subscription so far
+	Dropped uint64
+}
+
+// BrokerSubscriptionStats describes a subscription of the client and the messages dropped for it.
+type BrokerSubscriptionStats struct {
+	Subject string                   `json:"subject"`
+	Queue   string                   `json:"queue"`
+	Policy  BrokerSlowConsumerPolicy `json:"policy"`
+
+	// Pending is the number of messages waiting in the queue of the subscription on the server
+	Pending uint32 `json:"pending"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// BrokerMessage is a message delivered together with its metadata, see BrokerSubscribeWithHeader.
+type BrokerMessage struct {
+	// ID is assigned by the server when the message is published
+	ID string
+
+	// Timestamp is the time (in unix milliseconds) the message was published
+	Timestamp int64
+
+	// Sequence is the position of the message in the stream, 0 if the collection is no stream
+	Sequence uint64
+
+	// Subject is the subject the message was published to
+	Subject string
+
+	// Header carries metadata of the publisher, e.g. trace IDs, content types or deduplication keys
+	Header map[string]string
+
+	Data []byte
 }
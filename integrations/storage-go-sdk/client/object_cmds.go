@@ -10,17 +10,23 @@ import (
 
 // ObjPut implements the client side of the protocol.ServerCommandObjectPut command.
 func (c *Client) ObjPut(db, coll string, key string, data []byte) error {
-	encodedData := codex.EncodeBinary(data)
-
-	totalLen := 2 + len(key) + len(encodedData)
-	payload := make([]byte, totalLen)
+	return c.ObjPutWithMetadata(db, coll, key, data, "", nil)
+}
 
-	// Key
-	binary.BigEndian.PutUint16(payload[0:2], uint16(len(key)))
-	copy(payload[2:2+len(key)], []byte(key))
+// ObjPutWithMetadata implements the client side of the protocol.ServerCommandObjectPut command with a content type and user-defined metadata.
+// The content type and the names and values of the metadata may have at most 8 KB combined.
+func (c *Client) ObjPutWithMetadata(db, coll string, key string, data []byte, contentType string, metadata map[string]string) error {
+	_, err := c.ObjPutVersioned(db, coll, key, data, contentType, metadata)
+	return err
+}
 
-	// Value
-	copy(payload[2+len(key):], encodedData)
+// ObjPutVersioned is like ObjPutWithMetadata, but returns the version ID of the stored object, which is 0 if versioning is disabled for the collection.
+func (c *Client) ObjPutVersioned(db, coll string, key string, data []byte, contentType string, metadata map[string]string) (uint64, error) {
+	payload := appendKey(nil, key)
+	payload = append(payload, codex.EncodeBinary(data)...)
+	if contentType != "" || len(metadata) > 0 {
+		payload = appendObjAttributes(payload, contentType, metadata)
+	}
 
 	resp, err := c.SendCmd(&protocol.Command{
 		ID:             protocol.ServerCommandObjectPut,
@@ -29,22 +35,37 @@ func (c *Client) ObjPut(db, coll string, key string, data []byte) error {
 		Payload:        payload,
 	})
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if resp.Code == protocol.StatusLimitExceeded {
+		return 0, ErrMetadataTooLarge
 	}
 
 	if resp.Code != protocol.StatusOK {
-		return ErrUnexpectedStatusCode
+		return 0, ErrUnexpectedStatusCode
 	}
 
-	return nil
+	// Servers without versioning respond without payload
+	if len(resp.Payload) < 8 {
+		return 0, nil
+	}
+
+	return binary.BigEndian.Uint64(resp.Payload[0:8]), nil
 }
 
 // ObjGet implements the client side of the protocol.ServerCommandObjectGet command.
 func (c *Client) ObjGet(db, coll string, key string) ([]byte, error) {
-	payload := make([]byte, 2+len(key))
-	binary.BigEndian.PutUint16(payload[0:2], uint16(len(key)))
-	copy(payload[2:], []byte(key))
+	return c.objGet(db, coll, appendKey(nil, key))
+}
+
+// ObjGetVersion implements the client side of the protocol.ServerCommandObjectGet command for a version of an object.
+// Returns ErrKeyNotFound if the version does not exist or is a delete marker.
+func (c *Client) ObjGetVersion(db, coll string, key string, versionID uint64) ([]byte, error) {
+	return c.objGet(db, coll, binary.BigEndian.AppendUint64(appendKey(nil, key), versionID))
+}
 
+func (c *Client) objGet(db, coll string, payload []byte) ([]byte, error) {
 	resp, err := c.SendCmd(&protocol.Command{
 		ID:             protocol.ServerCommandObjectGet,
 		DatabaseName:   db,
@@ -68,10 +89,15 @@ func (c *Client) ObjGet(db, coll string, key string) ([]byte, error) {
 
 // ObjGetMetadata implements the client side of the protocol.ServerCommandObjectGetMetadata command.
 func (c *Client) ObjGetMetadata(db, coll string, key string) (*ObjectMetadata, error) {
-	payload := make([]byte, 2+len(key))
-	binary.BigEndian.PutUint16(payload[0:2], uint16(len(key)))
-	copy(payload[2:], []byte(key))
+	return c.objGetMetadata(db, coll, key, appendKey(nil, key))
+}
+
+// ObjGetMetadataVersion implements the client side of the protocol.ServerCommandObjectGetMetadata command for a version of an object, which may be a delete marker.
+func (c *Client) ObjGetMetadataVersion(db, coll string, key string, versionID uint64) (*ObjectMetadata, error) {
+	return c.objGetMetadata(db, coll, key, binary.BigEndian.AppendUint64(appendKey(nil, key), versionID))
+}
 
+func (c *Client) objGetMetadata(db, coll string, key string, payload []byte) (*ObjectMetadata, error) {
 	resp, err := c.SendCmd(&protocol.Command{
 		ID:             protocol.ServerCommandObjectGetMetadata,
 		DatabaseName:   db,
@@ -91,26 +117,50 @@ func (c *Client) ObjGetMetadata(db, coll string, key string) (*ObjectMetadata, e
 	}
 
 	data := resp.Payload
-	if len(data) != 8+4+8+8 {
-		fmt.Printf("invalid metadata payload length: expected 12, got %d\n", len(data))
+	if len(data) < 8+4+8+8 {
+		fmt.Printf("invalid metadata payload length: expected at least 28, got %d\n", len(data))
 		return nil, ErrInvalidPayloadLength
 	}
 
-	return &ObjectMetadata{
+	meta := &ObjectMetadata{
 		Key:        key,
 		Size:       uint32(binary.BigEndian.Uint64(data[0:8])),
 		Checksum:   binary.BigEndian.Uint32(data[8:12]),
 		CreatedAt:  int64(binary.BigEndian.Uint64(data[12:20])),
 		ModifiedAt: int64(binary.BigEndian.Uint64(data[20:28])),
-	}, nil
+	}
+
+	// Servers without content types and user metadata end the payload here
+	if len(data) == 28 {
+		return meta, nil
+	}
+
+	meta.ContentType, meta.Metadata, data, err = readObjAttributes(data[28:])
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers without versioning end the payload here
+	if len(data) >= 8+1 {
+		meta.VersionID = binary.BigEndian.Uint64(data[0:8])
+		meta.DeleteMarker = data[8] == 1
+	}
+
+	return meta, nil
 }
 
 // ObjDelete implements the client side of the protocol.ServerCommandObjectDelete command.
+// In versioned collections, a delete marker becomes the current version of the object and the previous versions are kept.
 func (c *Client) ObjDelete(db, coll string, key string) error {
-	payload := make([]byte, 2+len(key))
-	binary.BigEndian.PutUint16(payload[0:2], uint16(len(key)))
-	copy(payload[2:], []byte(key))
+	return c.objDelete(db, coll, appendKey(nil, key))
+}
 
+// ObjDeleteVersion implements the client side of the protocol.ServerCommandObjectDelete command for a version of an object, which is removed permanently.
+func (c *Client) ObjDeleteVersion(db, coll string, key string, versionID uint64) error {
+	return c.objDelete(db, coll, binary.BigEndian.AppendUint64(appendKey(nil, key), versionID))
+}
+
+func (c *Client) objDelete(db, coll string, payload []byte) error {
 	resp, err := c.SendCmd(&protocol.Command{
 		ID:             protocol.ServerCommandObjectDelete,
 		DatabaseName:   db,
@@ -132,6 +182,80 @@ func (c *Client) ObjDelete(db, coll string, key string) error {
 	return nil
 }
 
+// ObjExists implements the client side of the protocol.ServerCommandObjectExists command.
+func (c *Client) ObjExists(db, coll string, key string) (bool, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandObjectExists,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        appendKey(nil, key),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+		return true, nil
+	case protocol.StatusNotFound:
+		return false, nil
+	default:
+		return false, ErrUnexpectedStatusCode
+	}
+}
+
+// ObjCopy implements the client side of the protocol.ServerCommandObjectCopy command.
+// dstColl must be an object collection in the same database, an empty dstColl refers to coll.
+func (c *Client) ObjCopy(db, coll string, srcKey, dstColl, dstKey string) error {
+	return c.sendObjTransferCmd(protocol.ServerCommandObjectCopy, db, coll, srcKey, dstColl, dstKey)
+}
+
+// ObjMove implements the client side of the protocol.ServerCommandObjectMove command.
+// dstColl must be an object collection in the same database, an empty dstColl refers to coll.
+func (c *Client) ObjMove(db, coll string, srcKey, dstColl, dstKey string) error {
+	return c.sendObjTransferCmd(protocol.ServerCommandObjectMove, db, coll, srcKey, dstColl, dstKey)
+}
+
+// ObjRename implements the client side of the protocol.ServerCommandObjectRename command.
+func (c *Client) ObjRename(db, coll string, oldKey, newKey string) error {
+	payload := appendKey(nil, oldKey)
+	payload = appendKey(payload, newKey)
+
+	return c.sendObjKeyCmd(protocol.ServerCommandObjectRename, db, coll, payload)
+}
+
+func (c *Client) sendObjTransferCmd(id uint16, db, coll string, srcKey, dstColl, dstKey string) error {
+	payload := appendKey(nil, srcKey)
+	payload = appendKey(payload, dstColl)
+	payload = appendKey(payload, dstKey)
+
+	return c.sendObjKeyCmd(id, db, coll, payload)
+}
+
+// sendObjKeyCmd sends an object command that only responds with a status, protocol.StatusNotFound is mapped to ErrKeyNotFound.
+func (c *Client) sendObjKeyCmd(id uint16, db, coll string, payload []byte) error {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             id,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+		return nil
+	case protocol.StatusNotFound:
+		return ErrKeyNotFound
+	case protocol.StatusCollectionNotFound:
+		return ErrCollectionNotFound
+	default:
+		return ErrUnexpectedStatusCode
+	}
+}
+
 // ObjCount implements the client side of the protocol.ServerCommandObjectCount command.
 func (c *Client) ObjCount(db, coll string) (uint32, error) {
 	resp, err := c.SendCmd(&protocol.Command{
@@ -177,3 +301,280 @@ func (c *Client) ObjSize(db, coll string) (uint64, error) {
 	size := binary.BigEndian.Uint64(resp.Payload[0:8])
 	return size, nil
 }
+
+// ObjStats implements the client side of the protocol.ServerCommandObjectStats command.
+func (c *Client) ObjStats(db, coll string) (*ObjStats, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandObjectStats,
+		DatabaseName:   db,
+		CollectionName: coll,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+	case protocol.StatusCollectionNotFound:
+		return nil, ErrCollectionNotFound
+	default:
+		return nil, ErrUnexpectedStatusCode
+	}
+
+	data, err := codex.DecodeValue(resp.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats ObjStats
+	if err := codex.Unmarshal(data.AsBinary(), &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// ObjLifecycleReport implements the client side of the protocol.ServerCommandObjectLifecycleReport command.
+// It reports the objects that the lifecycle rules expire right now, without deleting them.
+func (c *Client) ObjLifecycleReport(db, coll string) (*ObjLifecycleReport, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandObjectLifecycleReport,
+		DatabaseName:   db,
+		CollectionName: coll,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+	case protocol.StatusCollectionNotFound:
+		return nil, ErrCollectionNotFound
+	default:
+		return nil, ErrUnexpectedStatusCode
+	}
+
+	data, err := codex.DecodeValue(resp.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var report ObjLifecycleReport
+	if err := codex.Unmarshal(data.AsBinary(), &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// ObjCompact implements the client side of the protocol.ServerCommandObjectCompact command.
+// It only schedules the compaction, the progress can be observed with ObjStats.
+func (c *Client) ObjCompact(db, coll string) error {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandObjectCompact,
+		DatabaseName:   db,
+		CollectionName: coll,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+		return nil
+	case protocol.StatusForbidden:
+		return ErrForbidden
+	case protocol.StatusDatabaseNotFound:
+		return ErrDatabaseNotFound
+	case protocol.StatusCollectionNotFound:
+		return ErrCollectionNotFound
+	default:
+		return ErrUnexpectedStatusCode
+	}
+}
+
+// ObjListVersions implements the client side of the protocol.ServerCommandObjectListVersions command.
+// It returns the versions of an object including delete markers, newest first.
+func (c *Client) ObjListVersions(db, coll string, key string) ([]ObjectMetadata, error) {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandObjectListVersions,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        appendKey(nil, key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Code {
+	case protocol.StatusOK:
+	case protocol.StatusNotFound:
+		return nil, ErrKeyNotFound
+	case protocol.StatusCollectionNotFound:
+		return nil, ErrCollectionNotFound
+	default:
+		return nil, ErrUnexpectedStatusCode
+	}
+
+	data := resp.Payload
+	if len(data) < 2 {
+		return nil, ErrInvalidPayloadLength
+	}
+	count := int(binary.BigEndian.Uint16(data[0:2]))
+	data = data[2:]
+
+	versions := make([]ObjectMetadata, 0, count)
+	for range count {
+		if len(data) < 8+1+8+4+8+8 {
+			return nil, ErrInvalidPayloadLength
+		}
+		versions = append(versions, ObjectMetadata{
+			Key:          key,
+			VersionID:    binary.BigEndian.Uint64(data[0:8]),
+			DeleteMarker: data[8] == 1,
+			Size:         uint32(binary.BigEndian.Uint64(data[9:17])),
+			Checksum:     binary.BigEndian.Uint32(data[17:21]),
+			CreatedAt:    int64(binary.BigEndian.Uint64(data[21:29])),
+			ModifiedAt:   int64(binary.BigEndian.Uint64(data[29:37])),
+		})
+		data = data[37:]
+	}
+
+	return versions, nil
+}
+
+// ObjList implements the client side of the protocol.ServerCommandObjectList command.
+func (c *Client) ObjList(db, coll string, opts ObjListOptions) (*ObjListResult, error) {
+	payload := appendKey(nil, opts.Prefix)
+	payload = appendKey(payload, opts.Delimiter)
+	payload = appendKey(payload, opts.Token)
+	payload = binary.BigEndian.AppendUint16(payload, opts.MaxKeys)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandObjectList,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return nil, ErrUnexpectedStatusCode
+	}
+
+	data := resp.Payload
+	if len(data) < 1 {
+		return nil, ErrInvalidPayloadLength
+	}
+	res := &ObjListResult{Truncated: data[0] == 1}
+
+	res.NextToken, data, err = readString(data[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 2 {
+		return nil, ErrInvalidPayloadLength
+	}
+	objectCount := int(binary.BigEndian.Uint16(data[0:2]))
+	data = data[2:]
+
+	res.Objects = make([]ObjectMetadata, 0, objectCount)
+	for range objectCount {
+		var key string
+		key, data, err = readString(data)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) < 8+4+8+8 {
+			return nil, ErrInvalidPayloadLength
+		}
+		res.Objects = append(res.Objects, ObjectMetadata{
+			Key:        key,
+			Size:       uint32(binary.BigEndian.Uint64(data[0:8])),
+			Checksum:   binary.BigEndian.Uint32(data[8:12]),
+			CreatedAt:  int64(binary.BigEndian.Uint64(data[12:20])),
+			ModifiedAt: int64(binary.BigEndian.Uint64(data[20:28])),
+		})
+		data = data[28:]
+	}
+
+	if len(data) < 2 {
+		return nil, ErrInvalidPayloadLength
+	}
+	prefixCount := int(binary.BigEndian.Uint16(data[0:2]))
+	data = data[2:]
+
+	res.CommonPrefixes = make([]string, 0, prefixCount)
+	for range prefixCount {
+		var prefix string
+		prefix, data, err = readString(data)
+		if err != nil {
+			return nil, err
+		}
+		res.CommonPrefixes = append(res.CommonPrefixes, prefix)
+	}
+
+	return res, nil
+}
+
+// readString reads a string with a 2-byte length prefix from the start of data and returns it with the rest of data.
+func readString(data []byte) (string, []byte, error) {
+	if len(data) < 2 {
+		return "", nil, ErrInvalidPayloadLength
+	}
+
+	n := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+n {
+		return "", nil, ErrInvalidPayloadLength
+	}
+
+	return string(data[2 : 2+n]), data[2+n:], nil
+}
+
+// appendObjAttributes appends the content type and user metadata of an object to dst.
+// Format: | Content Type Length (2 bytes) | Content Type | Metadata Count (2 bytes) | (| Name Length (2 bytes) | Name | Value Length (2 bytes) | Value |)* |
+func appendObjAttributes(dst []byte, contentType string, metadata map[string]string) []byte {
+	dst = appendKey(dst, contentType)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(metadata)))
+	for name, value := range metadata {
+		dst = appendKey(dst, name)
+		dst = appendKey(dst, value)
+	}
+	return dst
+}
+
+// readObjAttributes reads the content type and user metadata of an object in the format of appendObjAttributes and returns the rest of data.
+func readObjAttributes(data []byte) (string, map[string]string, []byte, error) {
+	contentType, data, err := readString(data)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	if len(data) < 2 {
+		return "", nil, nil, ErrInvalidPayloadLength
+	}
+	count := int(binary.BigEndian.Uint16(data[0:2]))
+	data = data[2:]
+
+	if count == 0 {
+		return contentType, nil, data, nil
+	}
+
+	metadata := make(map[string]string, count)
+	for range count {
+		var name, value string
+		if name, data, err = readString(data); err != nil {
+			return "", nil, nil, err
+		}
+		if value, data, err = readString(data); err != nil {
+			return "", nil, nil, err
+		}
+		metadata[name] = value
+	}
+
+	return contentType, metadata, data, nil
+}
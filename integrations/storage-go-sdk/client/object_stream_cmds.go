@@ -0,0 +1,195 @@
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+)
+
+// ObjStreamChunkSize is the size of the chunks ObjPutStream uploads and ObjGetStream downloads.
+const ObjStreamChunkSize = 4 * 1024 * 1024
+
+// ObjPutStream stores the data read from r until io.EOF under key, uploading it in chunks of ObjStreamChunkSize.
+// The object is replaced atomically once all data was uploaded; if reading or uploading fails, the upload is aborted.
+func (c *Client) ObjPutStream(db, coll string, key string, r io.Reader) error {
+	return c.ObjPutStreamWithMetadata(db, coll, key, r, "", nil)
+}
+
+// ObjPutStreamWithMetadata is like ObjPutStream, but stores the object with a content type and user-defined metadata.
+func (c *Client) ObjPutStreamWithMetadata(db, coll string, key string, r io.Reader, contentType string, metadata map[string]string) error {
+	id, err := c.ObjUploadBegin(db, coll, key, contentType, metadata)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, ObjStreamChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := c.ObjUploadAppend(db, coll, id, buf[:n]); err != nil {
+				_ = c.ObjUploadAbort(db, coll, id)
+				return err
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			_ = c.ObjUploadAbort(db, coll, id)
+			return readErr
+		}
+	}
+
+	return c.ObjUploadCommit(db, coll, id)
+}
+
+// ObjGetStream writes the object stored under key to w, downloading it in chunks of ObjStreamChunkSize.
+// It returns the number of bytes written. The checksum of the downloaded data is verified against the metadata of the object,
+// ErrObjectChanged is returned if the object was replaced during the download.
+func (c *Client) ObjGetStream(db, coll string, key string, w io.Writer) (int64, error) {
+	meta, err := c.ObjGetMetadata(db, coll, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var written int64
+	var checksum uint32
+	for written < int64(meta.Size) {
+		chunk, err := c.ObjGetRange(db, coll, key, uint64(written), ObjStreamChunkSize)
+		if err != nil {
+			return written, err
+		}
+		if len(chunk) == 0 {
+			return written, ErrObjectChanged
+		}
+
+		checksum = crc32.Update(checksum, crc32.IEEETable, chunk)
+
+		n, err := w.Write(chunk)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	if checksum != meta.Checksum {
+		return written, ErrObjectChanged
+	}
+
+	return written, nil
+}
+
+// ObjUploadBegin implements the client side of the protocol.ServerCommandObjectUploadBegin command.
+// The object is stored with the given content type and user-defined metadata, which may both be empty. It returns the ID of the new upload.
+func (c *Client) ObjUploadBegin(db, coll string, key string, contentType string, metadata map[string]string) (uint64, error) {
+	payload := appendKey(nil, key)
+	if contentType != "" || len(metadata) > 0 {
+		payload = appendObjAttributes(payload, contentType, metadata)
+	}
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandObjectUploadBegin,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Code == protocol.StatusLimitExceeded {
+		return 0, ErrMetadataTooLarge
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return 0, objUploadStatusError(resp)
+	}
+
+	if len(resp.Payload) != 8 {
+		return 0, ErrInvalidPayloadLength
+	}
+
+	return binary.BigEndian.Uint64(resp.Payload), nil
+}
+
+// ObjUploadAppend implements the client side of the protocol.ServerCommandObjectUploadAppend command.
+func (c *Client) ObjUploadAppend(db, coll string, uploadID uint64, chunk []byte) error {
+	payload := make([]byte, 8+5+len(chunk))
+	binary.BigEndian.PutUint64(payload[0:8], uploadID)
+	codex.EncodeBinaryInto(chunk, payload[8:])
+
+	return c.sendUploadCmd(protocol.ServerCommandObjectUploadAppend, db, coll, payload)
+}
+
+// ObjUploadCommit implements the client side of the protocol.ServerCommandObjectUploadCommit command.
+func (c *Client) ObjUploadCommit(db, coll string, uploadID uint64) error {
+	return c.sendUploadCmd(protocol.ServerCommandObjectUploadCommit, db, coll, binary.BigEndian.AppendUint64(nil, uploadID))
+}
+
+// ObjUploadAbort implements the client side of the protocol.ServerCommandObjectUploadAbort command.
+func (c *Client) ObjUploadAbort(db, coll string, uploadID uint64) error {
+	return c.sendUploadCmd(protocol.ServerCommandObjectUploadAbort, db, coll, binary.BigEndian.AppendUint64(nil, uploadID))
+}
+
+// ObjGetRange implements the client side of the protocol.ServerCommandObjectGetRange command.
+// The server returns at most 8 MB per call, less if the object ends before and nothing if offset lies behind its end.
+func (c *Client) ObjGetRange(db, coll string, key string, offset uint64, length uint32) ([]byte, error) {
+	payload := appendKey(make([]byte, 0, 2+len(key)+8+4), key)
+	payload = binary.BigEndian.AppendUint64(payload, offset)
+	payload = binary.BigEndian.AppendUint32(payload, length)
+
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             protocol.ServerCommandObjectGetRange,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Code == protocol.StatusNotFound {
+		return nil, ErrKeyNotFound
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return nil, ErrUnexpectedStatusCode
+	}
+
+	return codex.DecodeBinary(resp.Payload)
+}
+
+func (c *Client) sendUploadCmd(id uint16, db, coll string, payload []byte) error {
+	resp, err := c.SendCmd(&protocol.Command{
+		ID:             id,
+		DatabaseName:   db,
+		CollectionName: coll,
+		Payload:        payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	if resp.Code != protocol.StatusOK {
+		return objUploadStatusError(resp)
+	}
+
+	return nil
+}
+
+// objUploadStatusError maps the status code of a failed upload command to an error.
+func objUploadStatusError(resp *protocol.Response) error {
+	switch resp.Code {
+	case protocol.StatusNotFound:
+		return ErrUploadNotFound
+	case protocol.StatusLimitExceeded:
+		return ErrObjectTooLarge
+	default:
+		return ErrUnexpectedStatusCode
+	}
+}
@@ -76,8 +76,13 @@ func (c *Client) startResponseListener() {
 				slog.String("payload_size", strconv.Itoa(len(cmd.Payload))),
 			)
 
+			if cmd.ID == protocol.ClientCommandBrokerSlowConsumer {
+				c.handleBrokerSlowConsumer(cmd)
+				continue
+			}
+
 			// Handle broker messages
-			if cmd.ID == protocol.ClientCommandBrokerMessage {
+			if cmd.ID == protocol.ClientCommandBrokerMessage || cmd.ID == protocol.ClientCommandBrokerStreamMessage || cmd.ID == protocol.ClientCommandBrokerConsumerMessage || cmd.ID == protocol.ClientCommandBrokerRequestMessage || cmd.ID == protocol.ClientCommandBrokerHeaderMessage {
 				payload := cmd.Payload
 				if len(payload) < 2+8 { // subject length (2 bytes) + at least 8 bytes for list
 					slog.Warn("Received broker message with invalid payload", slog.String("payload_size", strconv.Itoa(len(payload))))
@@ -101,6 +106,24 @@ func (c *Client) startResponseListener() {
 					continue
 				}
 
+				if cmd.ID == protocol.ClientCommandBrokerStreamMessage {
+					c.dispatchBrokerStreamMessages(cmd.DatabaseName+"."+cmd.CollectionName+"."+subject, msgs)
+					continue
+				}
+				if cmd.ID == protocol.ClientCommandBrokerConsumerMessage {
+					// The subject is the name of the consumer
+					c.dispatchBrokerConsumerMessages(cmd.DatabaseName, cmd.CollectionName, subject, msgs)
+					continue
+				}
+				if cmd.ID == protocol.ClientCommandBrokerHeaderMessage {
+					c.dispatchBrokerHeaderMessages(cmd.DatabaseName+"."+cmd.CollectionName+"."+subject, msgs)
+					continue
+				}
+				if cmd.ID == protocol.ClientCommandBrokerRequestMessage {
+					c.dispatchBrokerRequests(cmd.DatabaseName, cmd.CollectionName, subject, msgs)
+					continue
+				}
+
 				c.brokerSubjectListenersMu.Lock()
 				listeners := c.brokerSubjectListeners[cmd.DatabaseName+"."+cmd.CollectionName+"."+subject]
 				c.brokerSubjectListenersMu.Unlock()
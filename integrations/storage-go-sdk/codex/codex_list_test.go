@@ -182,3 +182,23 @@ func TestDecodeList(t *testing.T) {
 		})
 	}
 }
+
+func TestNewValueList(t *testing.T) {
+	val, err := NewValue([]any{"a", "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	list := val.AsList()
+	if len(list) != 2 || list[0].AsString() != "a" || list[1].AsString() != "b" {
+		t.Fatalf("unexpected list: %v", list)
+	}
+
+	decoded, err := DecodeValue(EncodeValue(val))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := decoded.AsList(); len(got) != 2 || got[1].AsString() != "b" {
+		t.Fatalf("unexpected decoded list: %v", got)
+	}
+}
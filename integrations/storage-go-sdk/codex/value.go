@@ -199,7 +199,7 @@ func NewValue(data any) (*Value, error) {
 		return &Value{Type: TypeString, data: v}, nil
 	case []any:
 		itemType := TypeEmpty
-		values := make([]Value, len(v))
+		values := make([]*Value, len(v))
 		for i, item := range v {
 			value, err := NewValue(item)
 			if err != nil {
@@ -213,7 +213,7 @@ func NewValue(data any) (*Value, error) {
 				return nil, ErrInvalidType
 			}
 
-			values[i] = *value
+			values[i] = value
 		}
 		return &Value{Type: TypeList, data: values}, nil
 	case map[string]any:
@@ -236,6 +236,14 @@ func NewEmptyValue() *Value {
 	return &Value{Type: TypeEmpty, data: nil}
 }
 
+func NewListValue(items []*Value) *Value {
+	return &Value{Type: TypeList, data: items}
+}
+
+func NewMapValue(items map[string]*Value) *Value {
+	return &Value{Type: TypeMap, data: items}
+}
+
 func NewStringListValue(items []string) *Value {
 	values := make([]*Value, len(items))
 	for i, item := range items {
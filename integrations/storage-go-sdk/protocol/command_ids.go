@@ -38,21 +38,28 @@ const (
 	ServerCommandKVSetIfExistsTTL    uint16 = 2005
 	ServerCommandKVSetIfNotExists    uint16 = 2006
 	ServerCommandKVSetIfNotExistsTTL uint16 = 2007
+	ServerCommandKVCompareAndSet     uint16 = 2008
 
-	ServerCommandKVDelete         uint16 = 2020
-	ServerCommandKVDeleteMultiple uint16 = 2021
-	ServerCommandKVDeleteAll      uint16 = 2022
+	ServerCommandKVDelete           uint16 = 2020
+	ServerCommandKVDeleteMultiple   uint16 = 2021
+	ServerCommandKVDeleteAll        uint16 = 2022
+	ServerCommandKVCompareAndDelete uint16 = 2023
 
-	ServerCommandKVExists         uint16 = 2030
-	ServerCommandKVGet            uint16 = 2031
-	ServerCommandKVGetMultiple    uint16 = 2032
-	ServerCommandKVGetAll         uint16 = 2033
-	ServerCommandKVGetTTL         uint16 = 2034
-	ServerCommandKVGetMultipleTTL uint16 = 2035
-	ServerCommandKVGetAllTTL      uint16 = 2036
-	ServerCommandKVKeys           uint16 = 2037
-	ServerCommandKVCount          uint16 = 2038
-	ServerCommandKVSize           uint16 = 2039
+	ServerCommandKVExists          uint16 = 2030
+	ServerCommandKVGet             uint16 = 2031
+	ServerCommandKVGetMultiple     uint16 = 2032
+	ServerCommandKVGetAll          uint16 = 2033
+	ServerCommandKVGetTTL          uint16 = 2034
+	ServerCommandKVGetMultipleTTL  uint16 = 2035
+	ServerCommandKVGetAllTTL       uint16 = 2036
+	ServerCommandKVKeys            uint16 = 2037
+	ServerCommandKVCount           uint16 = 2038
+	ServerCommandKVSize            uint16 = 2039
+	ServerCommandKVScan            uint16 = 2040
+	ServerCommandKVStats           uint16 = 2041
+	ServerCommandKVGetWithRevision uint16 = 2042
+
+	ServerCommandKVTxn uint16 = 2050
 
 	ServerCommandKVNumIncrement  uint16 = 2520
 	ServerCommandKVNumDecrement  uint16 = 2521
@@ -91,24 +98,49 @@ const (
 
 // Object engine command IDs
 const (
-	ServerCommandObjectPut         uint16 = 4000
-	ServerCommandObjectGet         uint16 = 4001
-	ServerCommandObjectGetMetadata uint16 = 4002
-	ServerCommandObjectDelete      uint16 = 4003
-	ServerCommandObjectExists      uint16 = 4004
-	ServerCommandObjectList        uint16 = 4005
-	ServerCommandObjectCopy        uint16 = 4006
-	ServerCommandObjectMove        uint16 = 4007
-	ServerCommandObjectRename      uint16 = 4008
-	ServerCommandObjectCount       uint16 = 4009
-	ServerCommandObjectSize        uint16 = 4010
+	ServerCommandObjectPut             uint16 = 4000
+	ServerCommandObjectGet             uint16 = 4001
+	ServerCommandObjectGetMetadata     uint16 = 4002
+	ServerCommandObjectDelete          uint16 = 4003
+	ServerCommandObjectExists          uint16 = 4004
+	ServerCommandObjectList            uint16 = 4005
+	ServerCommandObjectCopy            uint16 = 4006
+	ServerCommandObjectMove            uint16 = 4007
+	ServerCommandObjectRename          uint16 = 4008
+	ServerCommandObjectCount           uint16 = 4009
+	ServerCommandObjectSize            uint16 = 4010
+	ServerCommandObjectUploadBegin     uint16 = 4011
+	ServerCommandObjectUploadAppend    uint16 = 4012
+	ServerCommandObjectUploadCommit    uint16 = 4013
+	ServerCommandObjectUploadAbort     uint16 = 4014
+	ServerCommandObjectGetRange        uint16 = 4015
+	ServerCommandObjectStats           uint16 = 4016
+	ServerCommandObjectCompact         uint16 = 4017
+	ServerCommandObjectListVersions    uint16 = 4018
+	ServerCommandObjectLifecycleReport uint16 = 4019
 )
 
 // Broker engine command IDs
 const (
-	ServerCommandBrokerSubscribe      uint16 = 6000
-	ServerCommandBrokerSubscribeQueue uint16 = 6001
-	ServerCommandBrokerUnsubscribe    uint16 = 6002
-	ServerCommandBrokerPublish        uint16 = 6003
-	ClientCommandBrokerMessage        uint16 = 6004
+	ServerCommandBrokerSubscribe           uint16 = 6000
+	ServerCommandBrokerSubscribeQueue      uint16 = 6001
+	ServerCommandBrokerUnsubscribe         uint16 = 6002
+	ServerCommandBrokerPublish             uint16 = 6003
+	ClientCommandBrokerMessage             uint16 = 6004
+	ServerCommandBrokerSubscribeStream     uint16 = 6005
+	ClientCommandBrokerStreamMessage       uint16 = 6006
+	ServerCommandBrokerStreamInfo          uint16 = 6007
+	ServerCommandBrokerSubscribeConsumer   uint16 = 6008
+	ServerCommandBrokerUnsubscribeConsumer uint16 = 6009
+	ServerCommandBrokerAck                 uint16 = 6010
+	ServerCommandBrokerNak                 uint16 = 6011
+	ServerCommandBrokerInProgress          uint16 = 6012
+	ClientCommandBrokerConsumerMessage     uint16 = 6013
+	ServerCommandBrokerInbox               uint16 = 6014
+	ServerCommandBrokerRequest             uint16 = 6015
+	ClientCommandBrokerRequestMessage      uint16 = 6016
+	ClientCommandBrokerSlowConsumer        uint16 = 6017
+	ServerCommandBrokerSubscriptionStats   uint16 = 6018
+	ServerCommandBrokerPublishWithHeader   uint16 = 6019
+	ClientCommandBrokerHeaderMessage       uint16 = 6020
 )
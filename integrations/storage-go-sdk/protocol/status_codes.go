@@ -38,6 +38,24 @@ const (
 	// StatusForbidden indicates that the client does not have permission to access the requested resource.
 	StatusForbidden uint16 = 1009
 
+	// StatusTypeMismatch indicates that the stored value has a type that does not support the requested operation.
+	StatusTypeMismatch uint16 = 1010
+
+	// StatusOverflow indicates that the result of a numeric operation does not fit into the type of the stored value.
+	StatusOverflow uint16 = 1011
+
+	// StatusOutOfRange indicates that an index lies outside the bounds of the stored value.
+	StatusOutOfRange uint16 = 1012
+
+	// StatusConflict indicates that a conditional write failed, because the stored revision differs from the expected one.
+	StatusConflict uint16 = 1013
+
+	// StatusLimitExceeded indicates that a write was rejected, because it would exceed the memory or entry limit of the collection.
+	StatusLimitExceeded uint16 = 1014
+
+	// StatusNoResponders indicates that a broker request was not delivered, because no subscriber matches its subject.
+	StatusNoResponders uint16 = 1015
+
 	// StatusInternalServerError indicates that an internal server error occurred.
 	StatusInternalServerError uint16 = 2000
 )
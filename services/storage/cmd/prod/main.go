@@ -50,7 +50,7 @@ func main() {
 	serverPort := "8091"
 	mux := http.NewServeMux()
 
-	srv := app.Start(app.Configuration{
+	srv, engineService := app.Start(app.Configuration{
 		Mux:        mux,
 		ServerPort: serverPort,
 	})
@@ -81,10 +81,12 @@ func main() {
 	// Wait for a signal to exit
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-	switch <-sig {
-	case os.Interrupt:
-		slog.Info("Received interrupt signal, shutting down...")
+	<-sig
+	slog.Info("Received shutdown signal, shutting down...")
 
-		slog.Info("Shutdown complete")
+	if err := engineService.Close(); err != nil {
+		slog.Error("Could not close engines", sloki.WrapError(err))
 	}
+
+	slog.Info("Shutdown complete")
 }
@@ -25,7 +25,9 @@ type Configuration struct {
 	ServerPort string
 }
 
-func Start(cfg Configuration) *server.Server {
+// Start wires up all services and returns the tcp server together with the engine service,
+// which must be closed on shutdown.
+func Start(cfg Configuration) (*server.Server, *engine.Service) {
 	// database
 	databaseDB := fakeDatabaseDB.NewDatabaseDB()
 	databaseStore := database.NewService(database.Configuration{
@@ -51,6 +53,11 @@ func Start(cfg Configuration) *server.Server {
 	engineService := engine.NewService(engine.Configuration{
 		DatabaseStore:       databaseStore,
 		SendBrokerMessage:   srv.SendBrokerMessage,
+		SendStreamMessage:   srv.SendStreamMessage,
+		SendHeaderMessage:   srv.SendHeaderMessage,
+		SendConsumerMessage: srv.SendConsumerMessage,
+		SendRequestMessage:  srv.SendRequestMessage,
+		SendSlowConsumer:    srv.SendSlowConsumer,
 		IsConnectionHealthy: srv.IsConnectionHealthy,
 	})
 	if err := engineService.LoadEngines(); err != nil {
@@ -80,8 +87,10 @@ func Start(cfg Configuration) *server.Server {
 	objectCommands := objectcmds.New(objectcmds.Configuration{
 		DatabaseStore: databaseStore,
 		EngineService: engineService,
+		UserFromCtx:   auth.UserFromContext,
 	})
 	cmdService.RegisterHandlers(objectCommands.Get())
+	objectCommands.RegisterHTTP(apiPrefix, cfg.Mux)
 
 	brokerCommands := brokercmds.New(brokercmds.Configuration{
 		DatabaseStore: databaseStore,
@@ -91,5 +100,5 @@ func Start(cfg Configuration) *server.Server {
 
 	srv.SetCommandService(cmdService)
 
-	return srv
+	return srv, engineService
 }
@@ -143,6 +143,18 @@ func (s *Store) UpdateCollectionKVSettings(ctx context.Context, coll *Collection
 	return s.db.UpdateCollection(ctx, *coll)
 }
 
+func (s *Store) UpdateCollectionObjectSettings(ctx context.Context, coll *Collection, newSettings ObjectSettings) error {
+	coll.ObjectSettings = &newSettings
+
+	return s.db.UpdateCollection(ctx, *coll)
+}
+
+func (s *Store) UpdateCollectionBrokerSettings(ctx context.Context, coll *Collection, newSettings BrokerSettings) error {
+	coll.BrokerSettings = &newSettings
+
+	return s.db.UpdateCollection(ctx, *coll)
+}
+
 func (s *Store) DeleteCollection(ctx context.Context, db *Database, name string) error {
 	_, err := s.db.GetCollection(ctx, db.Name, name)
 	if err != nil {
@@ -45,10 +45,86 @@ type Collection struct {
 	CreatedAt  time.Time   `json:"created_at"`
 	Engine     Engine      `json:"engine"`
 	KVSettings *KVSettings `json:"kv_settings,omitempty"`
+
+	ObjectSettings *ObjectSettings `json:"object_settings,omitempty"`
+
+	BrokerSettings *BrokerSettings `json:"broker_settings,omitempty"`
 }
 
 type KVSettings struct {
 	DisableTTL bool `json:"disable_ttl"`
+
+	// Persistent enables writing every mutation to an append-only log and periodic snapshots, so the data survives restarts.
+	Persistent bool `json:"persistent"`
+
+	// SnapshotIntervalSeconds is the interval in which a persistent collection is snapshotted to disk (default: 300).
+	SnapshotIntervalSeconds int `json:"snapshot_interval_seconds,omitempty"`
+
+	// MaxMemoryBytes limits the total size of all keys and values of the collection (0 = unlimited).
+	MaxMemoryBytes uint64 `json:"max_memory_bytes,omitempty"`
+
+	// MaxEntries limits the number of entries of the collection (0 = unlimited).
+	MaxEntries uint64 `json:"max_entries,omitempty"`
+
+	// EvictionPolicy decides what happens when a limit is reached:
+	// "reject" (default) rejects writes, "lru", "lfu", "random" and "volatile-ttl" evict entries.
+	EvictionPolicy string `json:"eviction_policy,omitempty"`
+
+	// NotificationsCollection is the name of a broker collection in the same database to which changes of keys are published (empty = disabled).
+	// Subjects have the form kv.{db}.{coll}.{event}.{key} with the events set, delete, expire and evict.
+	NotificationsCollection string `json:"notifications_collection,omitempty"`
+}
+
+type ObjectSettings struct {
+	// SyncPolicy decides when writes are flushed to disk:
+	// "interval" (default) flushes every second, "always" before every write is acknowledged and "never" leaves it to the operating system.
+	SyncPolicy string `json:"sync_policy,omitempty"`
+
+	// Versioning keeps the previous versions of objects when they are replaced or deleted.
+	Versioning bool `json:"versioning,omitempty"`
+
+	// MaxVersions is the number of older versions kept per object (0 = unlimited).
+	MaxVersions int `json:"max_versions,omitempty"`
+
+	// VersionRetentionDays is the number of days older versions are kept after they were replaced (0 = unlimited).
+	VersionRetentionDays int `json:"version_retention_days,omitempty"`
+
+	// CompactionIntervalSeconds is the interval in which shards are checked for compaction (0 = 5 minutes).
+	CompactionIntervalSeconds int `json:"compaction_interval_seconds,omitempty"`
+
+	// CompactionGarbageRatio is the share of a shard file that must be taken up by replaced or deleted objects before the shard is compacted (0 = 0.5).
+	CompactionGarbageRatio float64 `json:"compaction_garbage_ratio,omitempty"`
+
+	// CompactionRateLimitBytes limits the bytes per second compaction copies, so it does not starve other disk I/O (0 = unlimited).
+	CompactionRateLimitBytes uint64 `json:"compaction_rate_limit_bytes,omitempty"`
+
+	// LifecycleRules delete objects once they are older than the max age of a rule whose prefix matches their key.
+	LifecycleRules []ObjectLifecycleRule `json:"lifecycle_rules,omitempty"`
+}
+
+type ObjectLifecycleRule struct {
+	// Prefix restricts the rule to keys that start with it (empty = all keys).
+	Prefix string `json:"prefix"`
+
+	// MaxAgeSeconds is the age after which objects are deleted.
+	MaxAgeSeconds int64 `json:"max_age_seconds"`
+
+	// Basis is the timestamp the age is measured from: "modified" (default) or "created".
+	Basis string `json:"basis,omitempty"`
+}
+
+type BrokerSettings struct {
+	// Stream stores published messages on disk, so subscribers can replay them from a sequence number or a point in time.
+	Stream bool `json:"stream"`
+
+	// MaxMessages is the number of messages the stream keeps (0 = unlimited).
+	MaxMessages uint64 `json:"max_messages,omitempty"`
+
+	// MaxBytes is the size of the messages the stream keeps on disk (0 = unlimited).
+	MaxBytes uint64 `json:"max_bytes,omitempty"`
+
+	// MaxAgeSeconds is the number of seconds the stream keeps messages after they were published (0 = unlimited).
+	MaxAgeSeconds int `json:"max_age_seconds,omitempty"`
 }
 
 type Engine string
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/brokerengine"
+)
+
+// encodeStreamMessages encodes messages of a broker stream for delivery to clients.
+// Each message is encoded as | Sequence (8 bytes) | Timestamp (8 bytes, unix millis) | Subject Length (2 bytes) | Subject (variable) | ID Length (2 bytes) | ID (variable) |
+// Header Count (2 bytes) | Header Entries (variable) | Data (variable) |, each header entry as | Key Length (2 bytes) | Key (variable) | Value Length (2 bytes) | Value (variable) |
+func encodeStreamMessages(msgs []brokerengine.Message) [][]byte {
+	encoded := make([][]byte, len(msgs))
+	for i, msg := range msgs {
+		buf := make([]byte, 0, 8+8+2+len(msg.Subject)+2+len(msg.ID)+headerSize(msg.Header)+len(msg.Data))
+		buf = binary.BigEndian.AppendUint64(buf, msg.Sequence)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(msg.Timestamp))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(msg.Subject)))
+		buf = append(buf, msg.Subject...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(msg.ID)))
+		buf = append(buf, msg.ID...)
+		buf = appendHeader(buf, msg.Header)
+		buf = append(buf, msg.Data...)
+		encoded[i] = buf
+	}
+
+	return encoded
+}
+
+// encodeHeaderMessages encodes messages together with their metadata for delivery to clients that subscribed with header.
+// Each message is encoded as | ID Length (2 bytes) | ID (variable) | Timestamp (8 bytes, unix millis) | Sequence (8 bytes) | Subject Length (2 bytes) | Subject (variable) |
+// Header Count (2 bytes) | Header Entries (variable) | Data (variable) |, each header entry as | Key Length (2 bytes) | Key (variable) | Value Length (2 bytes) | Value (variable) |
+func encodeHeaderMessages(msgs []brokerengine.Message) [][]byte {
+	encoded := make([][]byte, len(msgs))
+	for i, msg := range msgs {
+		buf := make([]byte, 0, 2+len(msg.ID)+8+8+2+len(msg.Subject)+headerSize(msg.Header)+len(msg.Data))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(msg.ID)))
+		buf = append(buf, msg.ID...)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(msg.Timestamp))
+		buf = binary.BigEndian.AppendUint64(buf, msg.Sequence)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(msg.Subject)))
+		buf = append(buf, msg.Subject...)
+		buf = appendHeader(buf, msg.Header)
+		buf = append(buf, msg.Data...)
+		encoded[i] = buf
+	}
+
+	return encoded
+}
+
+// headerSize returns the encoded size of a message header.
+func headerSize(header map[string]string) int {
+	size := 2
+	for k, v := range header {
+		size += 2 + len(k) + 2 + len(v)
+	}
+
+	return size
+}
+
+// appendHeader appends a message header encoded as | Header Count (2 bytes) | Header Entries (variable) |.
+func appendHeader(buf []byte, header map[string]string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(header)))
+	for k, v := range header {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(k)))
+		buf = append(buf, k...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(v)))
+		buf = append(buf, v...)
+	}
+
+	return buf
+}
+
+// encodeRequests encodes broker requests for delivery to clients.
+// Each request is encoded as | Reply Length (2 bytes) | Reply (variable) | Data (variable) |
+func encodeRequests(reqs []brokerengine.Request) [][]byte {
+	encoded := make([][]byte, len(reqs))
+	for i, req := range reqs {
+		buf := make([]byte, 0, 2+len(req.Reply)+len(req.Data))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(req.Reply)))
+		buf = append(buf, req.Reply...)
+		buf = append(buf, req.Data...)
+		encoded[i] = buf
+	}
+
+	return encoded
+}
+
+// encodeSlowConsumerNotice encodes the notice that messages are dropped for a subscription.
+// The notice is encoded as | Subject Length (2 bytes) | Subject (variable) | Queue Length (2 bytes) | Queue (variable) | Policy (1 byte) | Dropped (8 bytes) |
+func encodeSlowConsumerNotice(sub *brokerengine.Subscriber, subject string, dropped uint64) []byte {
+	buf := make([]byte, 0, 2+len(subject)+2+len(sub.Queue)+1+8)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(subject)))
+	buf = append(buf, subject...)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(sub.Queue)))
+	buf = append(buf, sub.Queue...)
+	buf = append(buf, byte(sub.Policy))
+	buf = binary.BigEndian.AppendUint64(buf, dropped)
+
+	return buf
+}
+
+// encodeConsumerMessages encodes messages of a durable broker consumer for delivery to clients.
+// Each message is encoded as | Sequence (8 bytes) | Timestamp (8 bytes, unix millis) | Deliveries (4 bytes) | Subject Length (2 bytes) | Subject (variable) |
+// ID Length (2 bytes) | ID (variable) | Header Count (2 bytes) | Header Entries (variable) | Data (variable) |, header entries are encoded like in encodeStreamMessages.
+func encodeConsumerMessages(msgs []brokerengine.ConsumerMessage) [][]byte {
+	encoded := make([][]byte, len(msgs))
+	for i, msg := range msgs {
+		buf := make([]byte, 0, 8+8+4+2+len(msg.Subject)+2+len(msg.ID)+headerSize(msg.Header)+len(msg.Data))
+		buf = binary.BigEndian.AppendUint64(buf, msg.Sequence)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(msg.Timestamp))
+		buf = binary.BigEndian.AppendUint32(buf, uint32(msg.Deliveries))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(msg.Subject)))
+		buf = append(buf, msg.Subject...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(msg.ID)))
+		buf = append(buf, msg.ID...)
+		buf = appendHeader(buf, msg.Header)
+		buf = append(buf, msg.Data...)
+		encoded[i] = buf
+	}
+
+	return encoded
+}
@@ -0,0 +1,74 @@
+package brokercmds
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/brokerengine"
+)
+
+// handleAck handles the protocol.ServerCommandBrokerAck command, which acknowledges a message of a durable consumer, so it is not delivered again.
+// Payload format: | name Length (2 bytes) | name (variable) | sequence (8 bytes) |
+// If the consumer does not exist or the message is not waiting for an acknowledgement, a protocol.StatusNotFound response is returned.
+func (c *Commands) handleAck(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	return c.handlePendingMessage(cmd, (*brokerengine.Broker).Ack), nil
+}
+
+// handleNak handles the protocol.ServerCommandBrokerNak command, which rejects a message of a durable consumer, so it is delivered again right away.
+// Payload format and responses are the same as for handleAck.
+func (c *Commands) handleNak(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	return c.handlePendingMessage(cmd, (*brokerengine.Broker).Nak), nil
+}
+
+// handleInProgress handles the protocol.ServerCommandBrokerInProgress command, which resets the ack wait of a message of a durable consumer.
+// Payload format and responses are the same as for handleAck.
+func (c *Commands) handleInProgress(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	return c.handlePendingMessage(cmd, (*brokerengine.Broker).InProgress), nil
+}
+
+// handlePendingMessage reads the consumer name and sequence number of a pending message from the payload and applies fn to the message.
+func (c *Commands) handlePendingMessage(cmd *protocol.Command, fn func(b *brokerengine.Broker, name string, seq uint64) error) *protocol.Response {
+	be, resp := c.brokerEngine(cmd)
+	if resp != nil {
+		return resp
+	}
+
+	name, data, resp := readSubject(cmd.Payload)
+	if resp != nil {
+		return resp
+	}
+
+	if len(data) != 8 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for sequence"),
+		}
+	}
+
+	if err := fn(be, name, binary.BigEndian.Uint64(data[0:8])); err != nil {
+		return consumerErrorResponse(err)
+	}
+
+	return commonresponses.OK
+}
+
+// consumerErrorResponse is the response to errors of durable consumer operations.
+func consumerErrorResponse(err error) *protocol.Response {
+	switch {
+	case errors.Is(err, brokerengine.ErrNotStream):
+		return commonresponses.CommandNotAllowed
+	case errors.Is(err, brokerengine.ErrConsumerNotFound), errors.Is(err, brokerengine.ErrNotPending):
+		return &protocol.Response{
+			Code:    protocol.StatusNotFound,
+			Payload: []byte(err.Error()),
+		}
+	default:
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte(err.Error()),
+		}
+	}
+}
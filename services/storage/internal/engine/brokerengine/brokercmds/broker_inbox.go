@@ -0,0 +1,26 @@
+package brokercmds
+
+import (
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// inboxPrefix returns the prefix of the reply subjects the connection with the given ID may use for requests.
+func inboxPrefix(connID string) string {
+	return "_INBOX." + connID
+}
+
+// handleInbox handles the protocol.ServerCommandBrokerInbox command, which returns the inbox prefix of the client.
+// The client appends a token that is unique for each request to the prefix to get the reply subject of the request (see handleRequest).
+// Payload format: empty
+// Response payload: | prefix (variable) |
+func (c *Commands) handleInbox(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	if _, resp := c.brokerEngine(cmd); resp != nil {
+		return resp, nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: []byte(inboxPrefix(ctx.ID)),
+	}, nil
+}
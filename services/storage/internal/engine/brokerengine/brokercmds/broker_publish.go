@@ -13,8 +13,9 @@ import (
 	"github.com/fancyinnovations/fancyspaces/storage/internal/database"
 )
 
-// handlePublish handles the protocol.ServerCommandBrokerSubscribe command, which subscribes the client to a given subject on the broker engine.
+// handlePublish handles the protocol.ServerCommandBrokerPublish command, which publishes a message to a given subject on the broker engine.
 // Payload format: | subject Length (2 bytes) | subject (variable) | data (codex.TypeBinary) |
+// Response payload: | sequence (8 bytes) | if the collection is a stream, else empty
 func (c *Commands) handlePublish(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
 	e, err := c.engineService.GetEngine(cmd.DatabaseName, cmd.CollectionName)
 	if err != nil {
@@ -61,7 +62,22 @@ func (c *Commands) handlePublish(ctx *command.ConnCtx, _ *protocol.Message, cmd
 		}, nil
 	}
 
-	be.Publish(subject, data)
+	seq, err := be.Publish(subject, data)
+	if err != nil {
+		slog.Error("Failed to store message in broker stream",
+			slog.String("database", cmd.DatabaseName),
+			slog.String("collection", cmd.CollectionName),
+			sloki.WrapError(err),
+		)
+		return commonresponses.InternalServerError, nil
+	}
+
+	if seq == 0 {
+		return commonresponses.OK, nil
+	}
 
-	return commonresponses.OK, nil
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: binary.BigEndian.AppendUint64(nil, seq),
+	}, nil
 }
@@ -0,0 +1,83 @@
+package brokercmds
+
+import (
+	"encoding/binary"
+	"log/slog"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// handlePublishWithHeader handles the protocol.ServerCommandBrokerPublishWithHeader command, which publishes a message with a header to a given subject on the broker engine.
+// Subscribers that subscribed with header receive the header together with the ID and timestamp the broker assigned to the message,
+// all other subscribers receive the data only.
+// Payload format: | subject Length (2 bytes) | subject (variable) | header count (2 bytes) | header entries (variable) | data (codex.TypeBinary) |
+// Each header entry is encoded as | key Length (2 bytes) | key (variable) | value Length (2 bytes) | value (variable) |
+// Response payload: | ID Length (2 bytes) | ID (variable) | timestamp (8 bytes, unix millis) | sequence (8 bytes, 0 if the collection is no stream) |
+func (c *Commands) handlePublishWithHeader(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	be, resp := c.brokerEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	subject, rest, resp := readSubject(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if len(rest) < 2 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for header count"),
+		}, nil
+	}
+	count := int(binary.BigEndian.Uint16(rest[0:2]))
+	rest = rest[2:]
+
+	var header map[string]string
+	if count > 0 {
+		header = make(map[string]string, count)
+	}
+	for range count {
+		var key, value string
+		if key, rest, resp = readSubject(rest); resp != nil {
+			return resp, nil
+		}
+		if value, rest, resp = readSubject(rest); resp != nil {
+			return resp, nil
+		}
+		header[key] = value
+	}
+
+	data, err := codex.DecodeBinary(rest)
+	if err != nil {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid codex binary data"),
+		}, nil
+	}
+
+	msg, err := be.PublishWithHeader(subject, header, data)
+	if err != nil {
+		slog.Error("Failed to store message in broker stream",
+			slog.String("database", cmd.DatabaseName),
+			slog.String("collection", cmd.CollectionName),
+			sloki.WrapError(err),
+		)
+		return commonresponses.InternalServerError, nil
+	}
+
+	payload := make([]byte, 0, 2+len(msg.ID)+8+8)
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(msg.ID)))
+	payload = append(payload, msg.ID...)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(msg.Timestamp))
+	payload = binary.BigEndian.AppendUint64(payload, msg.Sequence)
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: payload,
+	}, nil
+}
@@ -0,0 +1,62 @@
+package brokercmds
+
+import (
+	"strings"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/brokerengine"
+)
+
+// handleRequest handles the protocol.ServerCommandBrokerRequest command, which publishes a request to a given subject on the broker engine.
+// The client is subscribed to the reply subject before the request is published, so no reply is missed.
+// Replies are delivered as protocol.ClientCommandBrokerMessage, the client has to unsubscribe from the reply subject once it is done.
+// Payload format: | subject Length (2 bytes) | subject (variable) | reply Length (2 bytes) | reply (variable) | data (codex.TypeBinary) |
+// The reply subject must start with the inbox prefix of the client (see handleInbox), followed by a dot.
+// If no subscriber matches the subject, a protocol.StatusNoResponders response is returned.
+func (c *Commands) handleRequest(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	be, resp := c.brokerEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	subject, rest, resp := readSubject(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	reply, rest, resp := readSubject(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if !strings.HasPrefix(reply, inboxPrefix(ctx.ID)+".") || strings.ContainsAny(reply, "*>") {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("reply subject is not in the inbox of the client"),
+		}, nil
+	}
+
+	data, err := codex.DecodeBinary(rest)
+	if err != nil {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid codex binary data"),
+		}, nil
+	}
+
+	be.SubscribeInbox(reply, &brokerengine.Subscriber{ID: ctx.ID})
+
+	// A responder that drops the request because it is slow still exists, the requester runs into its timeout then
+	if responders, _ := be.PublishRequest(subject, reply, data); responders == 0 {
+		be.Unsubscribe(reply, ctx.ID)
+		return &protocol.Response{
+			Code:    protocol.StatusNoResponders,
+			Payload: []byte("no responders"),
+		}, nil
+	}
+
+	return commonresponses.OK, nil
+}
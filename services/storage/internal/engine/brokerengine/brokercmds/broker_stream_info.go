@@ -0,0 +1,44 @@
+package brokercmds
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/brokerengine"
+)
+
+// handleStreamInfo handles the protocol.ServerCommandBrokerStreamInfo command, which returns the stored messages and the retention limits of a broker stream.
+// Payload format: empty
+// Response payload: codex-encoded binary value containing the marshaled brokerengine.StreamInfo
+// If the collection is no stream, a protocol.StatusCommandNotAllowed response is returned.
+func (c *Commands) handleStreamInfo(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	be, resp := c.brokerEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	info, err := be.StreamInfo()
+	if errors.Is(err, brokerengine.ErrNotStream) {
+		return commonresponses.CommandNotAllowed, nil
+	}
+
+	data, err := codex.Marshal(info)
+	if err != nil {
+		slog.Error("Failed to marshal stream info",
+			slog.String("database", cmd.DatabaseName),
+			slog.String("collection", cmd.CollectionName),
+			sloki.WrapError(err),
+		)
+		return commonresponses.InternalServerError, nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeBinary(data),
+	}, nil
+}
@@ -14,7 +14,8 @@ import (
 )
 
 // handleSubscribe handles the protocol.ServerCommandBrokerSubscribe command, which subscribes the client to a given subject on the broker engine.
-// Payload format: | subject Length (2 bytes) | subject (variable) |
+// Payload format: | subject Length (2 bytes) | subject (variable) | [ policy (1 byte) | block timeout in ms (4 bytes) | [ with header (1 byte) ] ] |
+// The subscription options are optional, see readSubscribeOptions.
 func (c *Commands) handleSubscribe(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
 	e, err := c.engineService.GetEngine(cmd.DatabaseName, cmd.CollectionName)
 	if err != nil {
@@ -53,10 +54,15 @@ func (c *Commands) handleSubscribe(ctx *command.ConnCtx, _ *protocol.Message, cm
 
 	subject := string(data[2 : 2+subjectLen])
 
-	be.Subscribe(subject, &brokerengine.Subscriber{
+	sub := &brokerengine.Subscriber{
 		ID:    ctx.ID,
 		Queue: "",
-	})
+	}
+	if resp := readSubscribeOptions(data[2+subjectLen:], sub); resp != nil {
+		return resp, nil
+	}
+
+	be.Subscribe(subject, sub)
 
 	return commonresponses.OK, nil
 }
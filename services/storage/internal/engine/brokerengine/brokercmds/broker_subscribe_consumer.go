@@ -0,0 +1,72 @@
+package brokercmds
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/brokerengine"
+)
+
+// handleSubscribeConsumer handles the protocol.ServerCommandBrokerSubscribeConsumer command, which subscribes the client to a durable consumer
+// of a broker stream, creating the consumer if it does not exist. The messages are delivered with the protocol.ClientCommandBrokerConsumerMessage command.
+// Payload format: | name Length (2 bytes) | name (variable) | subject Length (2 bytes) | subject (variable) | start policy (1 byte) | start value (8 bytes) |
+// ack wait in milliseconds (8 bytes) | max deliveries (4 bytes) | dead letter subject Length (2 bytes) | dead letter subject (variable) |
+// If the collection is no stream, a protocol.StatusCommandNotAllowed response is returned.
+func (c *Commands) handleSubscribeConsumer(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	be, resp := c.brokerEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	name, data, resp := readSubject(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+	subject, data, resp := readSubject(data)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if len(data) < 1+8+8+4 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for consumer configuration"),
+		}, nil
+	}
+
+	cfg := brokerengine.ConsumerConfig{
+		Name:          name,
+		Subject:       subject,
+		Start:         brokerengine.StartPosition{Policy: brokerengine.StartPolicy(data[0])},
+		AckWait:       time.Duration(binary.BigEndian.Uint64(data[9:17])) * time.Millisecond,
+		MaxDeliveries: int(binary.BigEndian.Uint32(data[17:21])),
+	}
+	switch cfg.Start.Policy {
+	case brokerengine.StartSequence:
+		cfg.Start.Sequence = binary.BigEndian.Uint64(data[1:9])
+	case brokerengine.StartTime:
+		cfg.Start.Time = int64(binary.BigEndian.Uint64(data[1:9]))
+	}
+
+	cfg.DeadLetterSubject, _, resp = readSubject(data[21:])
+	if resp != nil {
+		return resp, nil
+	}
+
+	err := be.SubscribeConsumer(cfg, &brokerengine.Subscriber{ID: ctx.ID})
+	if errors.Is(err, brokerengine.ErrNotStream) {
+		return commonresponses.CommandNotAllowed, nil
+	}
+	if err != nil {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte(err.Error()),
+		}, nil
+	}
+
+	return commonresponses.OK, nil
+}
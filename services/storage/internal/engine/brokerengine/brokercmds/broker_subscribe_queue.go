@@ -14,7 +14,8 @@ import (
 )
 
 // handleSubscribeQueue handles the protocol.ServerCommandBrokerSubscribeQueue command, which subscribes the client to a given subject on the broker engine with a queue group.
-// Payload format: | subject Length (2 bytes) | subject (variable) | queue group Length (2 bytes) | queue group (variable) |
+// Payload format: | subject Length (2 bytes) | subject (variable) | queue group Length (2 bytes) | queue group (variable) | [ policy (1 byte) | block timeout in ms (4 bytes) | [ with header (1 byte) ] ] |
+// The subscription options are optional, see readSubscribeOptions.
 func (c *Commands) handleSubscribeQueue(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
 	e, err := c.engineService.GetEngine(cmd.DatabaseName, cmd.CollectionName)
 	if err != nil {
@@ -70,10 +71,15 @@ func (c *Commands) handleSubscribeQueue(ctx *command.ConnCtx, _ *protocol.Messag
 
 	subject := string(data[2 : 2+subjectLen])
 
-	be.Subscribe(subject, &brokerengine.Subscriber{
+	sub := &brokerengine.Subscriber{
 		ID:    ctx.ID,
 		Queue: queueGroup,
-	})
+	}
+	if resp := readSubscribeOptions(data[2+subjectLen+2+queueGroupLen:], sub); resp != nil {
+		return resp, nil
+	}
+
+	be.Subscribe(subject, sub)
 
 	return commonresponses.OK, nil
 }
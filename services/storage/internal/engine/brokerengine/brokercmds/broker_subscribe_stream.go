@@ -0,0 +1,56 @@
+package brokercmds
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/brokerengine"
+)
+
+// handleSubscribeStream handles the protocol.ServerCommandBrokerSubscribeStream command, which subscribes the client to a given subject on a broker stream,
+// starting at a position in the stream. The messages are delivered with the protocol.ClientCommandBrokerStreamMessage command.
+// Payload format: | subject Length (2 bytes) | subject (variable) | start policy (1 byte) | start value (8 bytes) |
+// The start value is the sequence number for brokerengine.StartSequence and the time in unix milliseconds for brokerengine.StartTime, it is ignored otherwise.
+// If the collection is no stream, a protocol.StatusCommandNotAllowed response is returned.
+func (c *Commands) handleSubscribeStream(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	be, resp := c.brokerEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	subject, data, resp := readSubject(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if len(data) < 1+8 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for start position"),
+		}, nil
+	}
+
+	start := brokerengine.StartPosition{Policy: brokerengine.StartPolicy(data[0])}
+	switch start.Policy {
+	case brokerengine.StartSequence:
+		start.Sequence = binary.BigEndian.Uint64(data[1:9])
+	case brokerengine.StartTime:
+		start.Time = int64(binary.BigEndian.Uint64(data[1:9]))
+	}
+
+	err := be.SubscribeStream(subject, &brokerengine.Subscriber{ID: ctx.ID}, start)
+	if errors.Is(err, brokerengine.ErrNotStream) {
+		return commonresponses.CommandNotAllowed, nil
+	}
+	if err != nil {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte(err.Error()),
+		}, nil
+	}
+
+	return commonresponses.OK, nil
+}
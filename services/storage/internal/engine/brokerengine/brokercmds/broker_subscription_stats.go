@@ -0,0 +1,42 @@
+package brokercmds
+
+import (
+	"log/slog"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// handleSubscriptionStats handles the protocol.ServerCommandBrokerSubscriptionStats command, which returns the subscriptions of the client
+// and the number of messages dropped for each of them, because the client did not keep up with the publishers.
+// Payload format: empty
+// Response payload: codex-encoded list of binary values, each containing a marshaled brokerengine.SubscriptionStats
+func (c *Commands) handleSubscriptionStats(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	be, resp := c.brokerEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	subs := be.Subscriptions(ctx.ID)
+	encoded := make([][]byte, len(subs))
+	for i, sub := range subs {
+		data, err := codex.Marshal(sub)
+		if err != nil {
+			slog.Error("Failed to marshal subscription stats",
+				slog.String("database", cmd.DatabaseName),
+				slog.String("collection", cmd.CollectionName),
+				sloki.WrapError(err),
+			)
+			return commonresponses.InternalServerError, nil
+		}
+		encoded[i] = data
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeValue(codex.NewBinaryListValue(encoded)),
+	}, nil
+}
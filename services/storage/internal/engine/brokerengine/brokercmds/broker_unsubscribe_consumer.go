@@ -0,0 +1,29 @@
+package brokercmds
+
+import (
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// handleUnsubscribeConsumer handles the protocol.ServerCommandBrokerUnsubscribeConsumer command, which unsubscribes the client from a durable consumer.
+// The messages pending for the client are delivered to the other subscribers of the consumer.
+// Payload format: | name Length (2 bytes) | name (variable) |
+// If the consumer does not exist, a protocol.StatusNotFound response is returned.
+func (c *Commands) handleUnsubscribeConsumer(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	be, resp := c.brokerEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	name, _, resp := readSubject(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if err := be.UnsubscribeConsumer(name, ctx.ID); err != nil {
+		return consumerErrorResponse(err), nil
+	}
+
+	return commonresponses.OK, nil
+}
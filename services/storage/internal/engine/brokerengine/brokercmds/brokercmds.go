@@ -1,10 +1,18 @@
 package brokercmds
 
 import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
 	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/database"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/engine"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/brokerengine"
 )
 
 type Commands struct {
@@ -26,9 +34,99 @@ func New(cfg Configuration) *Commands {
 
 func (c *Commands) Get() map[uint16]command.Handler {
 	return map[uint16]command.Handler{
-		protocol.ServerCommandBrokerSubscribe:      c.handleSubscribe,
-		protocol.ServerCommandBrokerSubscribeQueue: c.handleSubscribeQueue,
-		protocol.ServerCommandBrokerUnsubscribe:    c.handleUnsubscribe,
-		protocol.ServerCommandBrokerPublish:        c.handlePublish,
+		protocol.ServerCommandBrokerSubscribe:           c.handleSubscribe,
+		protocol.ServerCommandBrokerSubscribeQueue:      c.handleSubscribeQueue,
+		protocol.ServerCommandBrokerUnsubscribe:         c.handleUnsubscribe,
+		protocol.ServerCommandBrokerPublish:             c.handlePublish,
+		protocol.ServerCommandBrokerSubscribeStream:     c.handleSubscribeStream,
+		protocol.ServerCommandBrokerStreamInfo:          c.handleStreamInfo,
+		protocol.ServerCommandBrokerSubscribeConsumer:   c.handleSubscribeConsumer,
+		protocol.ServerCommandBrokerUnsubscribeConsumer: c.handleUnsubscribeConsumer,
+		protocol.ServerCommandBrokerAck:                 c.handleAck,
+		protocol.ServerCommandBrokerNak:                 c.handleNak,
+		protocol.ServerCommandBrokerInProgress:          c.handleInProgress,
+		protocol.ServerCommandBrokerInbox:               c.handleInbox,
+		protocol.ServerCommandBrokerRequest:             c.handleRequest,
+		protocol.ServerCommandBrokerSubscriptionStats:   c.handleSubscriptionStats,
+		protocol.ServerCommandBrokerPublishWithHeader:   c.handlePublishWithHeader,
+	}
+}
+
+// brokerEngine returns the broker engine of the collection addressed by cmd, or the response to send if there is none.
+func (c *Commands) brokerEngine(cmd *protocol.Command) (*brokerengine.Broker, *protocol.Response) {
+	e, err := c.engineService.GetEngine(cmd.DatabaseName, cmd.CollectionName)
+	if err != nil {
+		if errors.Is(err, database.ErrCollectionNotFound) {
+			return nil, commonresponses.CollectionNotFound
+		}
+
+		slog.Error("Failed to get engine",
+			slog.String("database", cmd.DatabaseName),
+			slog.String("collection", cmd.CollectionName),
+			sloki.WrapError(err),
+		)
+		return nil, commonresponses.InternalServerError
+	}
+
+	if e.Type != database.EngineBroker {
+		return nil, commonresponses.CommandNotAllowed
+	}
+
+	return e.AsBrokerEngine(), nil
+}
+
+// readSubject reads a length-prefixed subject (| subject Length (2 bytes) | subject (variable) |) from the start of data.
+// It returns the subject and the rest of data, or the response to send if data is too short.
+func readSubject(data []byte) (string, []byte, *protocol.Response) {
+	if len(data) < 2 {
+		return "", nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length"),
+		}
 	}
+
+	subjectLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+subjectLen {
+		return "", nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for subject"),
+		}
+	}
+
+	return string(data[2 : 2+subjectLen]), data[2+subjectLen:], nil
+}
+
+// readSubscribeOptions reads the optional options (| policy (1 byte) | block timeout in ms (4 bytes) | [ with header (1 byte) ] |) of a subscription into sub.
+// If data is empty, the default policy brokerengine.DropNewest applies and messages are delivered without their metadata.
+func readSubscribeOptions(data []byte, sub *brokerengine.Subscriber) *protocol.Response {
+	if len(data) == 0 {
+		return nil
+	}
+	if len(data) < 1+4 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for slow consumer policy"),
+		}
+	}
+
+	policy := brokerengine.SlowConsumerPolicy(data[0])
+	if policy > brokerengine.Disconnect {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid slow consumer policy"),
+		}
+	}
+
+	blockTimeout := time.Duration(binary.BigEndian.Uint32(data[1:5])) * time.Millisecond
+	if blockTimeout > brokerengine.MaxBlockTimeout {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("block timeout too large"),
+		}
+	}
+
+	sub.Policy = policy
+	sub.BlockTimeout = blockTimeout
+	sub.WithHeader = len(data) > 5 && data[5] == 1
+	return nil
 }
@@ -1,26 +1,101 @@
 package brokerengine
 
 import (
+	"errors"
+	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/OliverSchlueter/goutils/idgen"
 )
 
 type PublishCallback func(sub *Subscriber, subject string, msgs [][]byte)
 
+// StreamCallback is invoked to deliver stored messages to stream subscriptions, subject is the subject the subscriber subscribed to.
+type StreamCallback func(sub *Subscriber, subject string, msgs []Message)
+
+// HeaderCallback is invoked to deliver messages together with their metadata to subscribers with Subscriber.WithHeader set,
+// subject is the subject the subscriber subscribed to.
+type HeaderCallback func(sub *Subscriber, subject string, msgs []Message)
+
+// RequestCallback is invoked to deliver requests to subscribers, subject is the subject the subscriber subscribed to.
+type RequestCallback func(sub *Subscriber, subject string, reqs []Request)
+
+// SlowConsumerCallback is invoked when a subscriber starts dropping messages, dropped is the number of messages dropped for it so far.
+// It is invoked again if the subscriber drops messages after it caught up in between.
+// All subscribers of a broker are reported from a single goroutine, so the callback must not block for long.
+type SlowConsumerCallback func(sub *Subscriber, subject string, dropped uint64)
+
+// ConsumerCallback is invoked to deliver messages of a durable consumer to one of its subscribers.
+type ConsumerCallback func(sub *Subscriber, consumer string, msgs []ConsumerMessage)
+
+const (
+	// messageIDLength is the length of the IDs the broker assigns to published messages.
+	messageIDLength = 16
+
+	// slowNoticeQueueSize is the number of slow consumer notices that are buffered for the SlowConsumerCallback.
+	slowNoticeQueueSize = 256
+)
+
+// slowNotice reports that a subscriber started dropping messages, see SlowConsumerCallback.
+type slowNotice struct {
+	sub     *Subscriber
+	dropped uint64
+}
+
 type Broker struct {
 	root            *Node
 	pubCallback     PublishCallback
+	headerCallback  HeaderCallback
+	requestCallback RequestCallback
+	slowCallback    SlowConsumerCallback
+	slowNotices     chan slowNotice
 	isClientHealthy func(id string) bool
 	batchSize       int
 	batchTimeout    time.Duration
+
+	stream         *stream // nil if the broker is no stream
+	streamCallback StreamCallback
+	streamSubsMu   sync.Mutex
+	streamSubs     map[string][]*streamSubscription // by subscriber ID
+
+	consumerCallback ConsumerCallback
+	consumersMu      sync.Mutex
+	consumers        map[string]*consumer // by name
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup // Background jobs and stream deliveries
 }
 
 type Configuration struct {
 	PublishCallback PublishCallback
-	IsClientHealthy func(id string) bool
-	BatchSize       int
-	BatchTimeout    time.Duration
+	// HeaderCallback delivers messages with their metadata, see Subscriber.WithHeader.
+	HeaderCallback HeaderCallback
+	// RequestCallback delivers requests, see PublishRequest.
+	RequestCallback RequestCallback
+	// SlowConsumerCallback reports subscribers that start dropping messages, see SlowConsumerPolicy.
+	SlowConsumerCallback SlowConsumerCallback
+	IsClientHealthy      func(id string) bool
+	BatchSize            int
+	BatchTimeout         time.Duration
+
+	// The following fields are only used by NewStreamBroker.
+
+	Database   string
+	Collection string
+	// StreamCallback delivers the messages of stream subscriptions, see SubscribeStream.
+	StreamCallback StreamCallback
+	// ConsumerCallback delivers the messages of durable consumers, see SubscribeConsumer.
+	ConsumerCallback ConsumerCallback
+	// MaxMessages is the number of messages the stream keeps (0 = unlimited).
+	MaxMessages uint64
+	// MaxBytes is the size of the messages on disk the stream keeps (0 = unlimited).
+	MaxBytes uint64
+	// MaxAge is the time the stream keeps messages after they were published (0 = unlimited).
+	MaxAge time.Duration
 }
 
 // NewBroker creates a new broker with a callback invoked for delivery
@@ -34,59 +109,139 @@ func NewBroker(cfg Configuration) *Broker {
 	if cfg.IsClientHealthy == nil {
 		cfg.IsClientHealthy = func(id string) bool { return true }
 	}
+	if cfg.HeaderCallback == nil {
+		cfg.HeaderCallback = func(sub *Subscriber, subject string, msgs []Message) {}
+	}
+	if cfg.RequestCallback == nil {
+		cfg.RequestCallback = func(sub *Subscriber, subject string, reqs []Request) {}
+	}
+	if cfg.SlowConsumerCallback == nil {
+		cfg.SlowConsumerCallback = func(sub *Subscriber, subject string, dropped uint64) {}
+	}
 
 	b := &Broker{
 		root: &Node{
 			children: make(map[string]*Node),
 		},
-		pubCallback:     cfg.PublishCallback,
-		isClientHealthy: cfg.IsClientHealthy,
-		batchSize:       cfg.BatchSize,
-		batchTimeout:    cfg.BatchTimeout,
+		pubCallback:      cfg.PublishCallback,
+		headerCallback:   cfg.HeaderCallback,
+		requestCallback:  cfg.RequestCallback,
+		slowCallback:     cfg.SlowConsumerCallback,
+		slowNotices:      make(chan slowNotice, slowNoticeQueueSize),
+		isClientHealthy:  cfg.IsClientHealthy,
+		batchSize:        cfg.BatchSize,
+		batchTimeout:     cfg.BatchTimeout,
+		streamCallback:   cfg.StreamCallback,
+		streamSubs:       make(map[string][]*streamSubscription),
+		consumerCallback: cfg.ConsumerCallback,
+		consumers:        make(map[string]*consumer),
+		stop:             make(chan struct{}),
 	}
 
 	b.startCleanupUnhealthyClients()
+	b.startSlowConsumerNotices()
 
 	return b
 }
 
+// NewStreamBroker creates a broker that additionally appends every published message to a stream on disk, within the retention limits of cfg.
+// Stream subscriptions replay the stored messages from a start position, so subscribers that restart do not miss messages.
+// Durable consumers are loaded from the same directory.
+// Path structure: data/{database}/{collection}/segment_{first sequence}.log and data/{database}/{collection}/consumer_{name}.json
+func NewStreamBroker(cfg Configuration) (*Broker, error) {
+	st, err := openStream(filepath.Join("data", cfg.Database, cfg.Collection), cfg.MaxMessages, cfg.MaxBytes, cfg.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	b := NewBroker(cfg)
+	b.stream = st
+	if err := b.loadConsumers(); err != nil {
+		b.Close()
+		return nil, err
+	}
+	b.startStreamJob()
+
+	return b, nil
+}
+
+// Close stops the background jobs, the stream subscriptions and the durable consumers of the broker, persists the consumers and closes the stream.
+// The broker must not be used afterward.
+func (b *Broker) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.stop)
+	})
+	b.wg.Wait()
+
+	if b.stream == nil {
+		return nil
+	}
+
+	return errors.Join(b.persistConsumers(), b.stream.close())
+}
+
 // Subscribe adds a subscriber to a subject and starts delivery goroutine
 func (b *Broker) Subscribe(subject string, sub *Subscriber) {
-	sub.msgCh = make(chan []byte, 1024)
+	sub.subject = subject
+	sub.msgCh = make(chan delivery, 1024)
 
-	b.startDelivery(sub, subject, b.pubCallback)
+	b.startDelivery(sub, subject)
 
 	tokens := strings.Split(subject, ".")
 	b.insert(b.root, tokens, sub)
 }
 
-// startDelivery starts the subscriber goroutine that batches messages
-func (b *Broker) startDelivery(sub *Subscriber, subject string, callback PublishCallback) {
+// SubscribeInbox subscribes to the inbox subject that replies to requests of the subscriber are published to, see PublishRequest.
+// Unlike with Subscribe, messages are delivered right away instead of in batches.
+func (b *Broker) SubscribeInbox(inbox string, sub *Subscriber) {
+	sub.immediate = true
+	b.Subscribe(inbox, sub)
+}
+
+// startDelivery starts the subscriber goroutine that batches messages.
+// Messages go to the PublishCallback, or to the HeaderCallback if the subscriber wants their metadata.
+// Requests are delivered right away, after the messages that were published before them.
+func (b *Broker) startDelivery(sub *Subscriber, subject string) {
 	go func() {
 		batch := make([][]byte, 0, b.batchSize)
+		var msgs []Message // batch of subscribers with WithHeader set
 		timer := time.NewTimer(b.batchTimeout)
 		defer timer.Stop()
 
+		flush := func() {
+			if len(batch) > 0 {
+				b.pubCallback(sub, subject, batch)
+				batch = batch[:0]
+			}
+			if len(msgs) > 0 {
+				b.headerCallback(sub, subject, msgs)
+				msgs = msgs[:0]
+			}
+		}
+
 		for {
 			select {
-			case msg, ok := <-sub.msgCh:
+			case d, ok := <-sub.msgCh:
 				if !ok {
-					if len(batch) > 0 {
-						callback(sub, subject, batch)
-					}
+					flush()
 					return
 				}
-				batch = append(batch, msg)
-				if len(batch) >= b.batchSize {
-					callback(sub, subject, batch)
-					batch = batch[:0]
+				if d.reply != "" {
+					flush()
+					b.requestCallback(sub, subject, []Request{{Reply: d.reply, Data: d.msg.Data}})
+					continue
+				}
+				if sub.WithHeader {
+					msgs = append(msgs, *d.msg)
+				} else {
+					batch = append(batch, d.msg.Data)
+				}
+				if len(batch)+len(msgs) >= b.batchSize || sub.immediate {
+					flush()
 					timer.Reset(b.batchTimeout)
 				}
 			case <-timer.C:
-				if len(batch) > 0 {
-					callback(sub, subject, batch)
-					batch = batch[:0]
-				}
+				flush()
 				timer.Reset(b.batchTimeout)
 			}
 		}
@@ -129,10 +284,11 @@ func (b *Broker) insert(node *Node, tokens []string, sub *Subscriber) {
 	b.insert(child, tokens[1:], sub)
 }
 
-// Unsubscribe removes a subscriber from a subject
+// Unsubscribe removes a subscriber from a subject, including its stream subscriptions of the subject
 func (b *Broker) Unsubscribe(subject string, subID string) {
 	tokens := strings.Split(subject, ".")
 	b.remove(b.root, tokens, subID)
+	b.removeStreamSubscriptions(subID, subject)
 }
 
 // remove recursively removes subscriber
@@ -148,7 +304,7 @@ func (b *Broker) remove(node *Node, tokens []string, subID string) {
 			if s.ID != subID {
 				filtered = append(filtered, s)
 			} else {
-				close(s.msgCh) // stop delivery
+				s.close() // stop delivery
 			}
 		}
 		node.subs = filtered
@@ -170,16 +326,55 @@ func (b *Broker) remove(node *Node, tokens []string, subID string) {
 	}
 }
 
-// Publish sends a message to all matching subscribers
-func (b *Broker) Publish(subject string, msg []byte) {
+// Publish sends a message to all matching subscribers.
+// If the broker is a stream, the message is stored first and its sequence number is returned, otherwise the sequence number is 0.
+func (b *Broker) Publish(subject string, msg []byte) (uint64, error) {
+	published, err := b.PublishWithHeader(subject, nil, msg)
+	return published.Sequence, err
+}
+
+// PublishWithHeader is like Publish, but the message carries a header, which is delivered to subscribers with Subscriber.WithHeader set.
+// The broker assigns an ID and a timestamp to the message, the returned message contains them.
+func (b *Broker) PublishWithHeader(subject string, header map[string]string, data []byte) (Message, error) {
+	return b.publishMessage(Message{
+		ID:        idgen.GenerateID(messageIDLength),
+		Timestamp: time.Now().UnixMilli(),
+		Subject:   subject,
+		Header:    header,
+		Data:      data,
+	})
+}
+
+// publishMessage stores msg in the stream, if the broker is one, and sends it to all subscribers matching its subject.
+func (b *Broker) publishMessage(msg Message) (Message, error) {
+	if b.stream != nil {
+		stored, err := b.stream.append(msg)
+		if err != nil {
+			return Message{}, err
+		}
+		msg = stored
+	}
+
+	tokens := strings.Split(msg.Subject, ".")
+	b.publish(b.root, tokens, msg.Subject, delivery{msg: &msg})
+
+	return msg, nil
+}
+
+// PublishRequest sends a request to all matching subscribers, they are expected to publish their replies to the reply subject.
+// It returns the number of healthy subscribers that matched the request, 0 means there are no responders,
+// and the number of them the request was handed to, which is lower if the queues of some of them are full (see SlowConsumerPolicy).
+// Requests are only delivered to subscribers that are connected right now, so they are not stored in streams.
+func (b *Broker) PublishRequest(subject, reply string, msg []byte) (responders, delivered int) {
 	tokens := strings.Split(subject, ".")
-	b.publish(b.root, tokens, subject, msg)
+	return b.publish(b.root, tokens, subject, delivery{msg: &Message{Subject: subject, Data: msg}, reply: reply})
 }
 
-// publish walks the trie and delivers messages
-func (b *Broker) publish(node *Node, tokens []string, subject string, msg []byte) {
+// publish walks the trie and delivers messages.
+// It returns the number of healthy subscribers that matched the message and the number of them the message was handed to.
+func (b *Broker) publish(node *Node, tokens []string, subject string, msg delivery) (matched, delivered int) {
 	if node == nil {
-		return
+		return 0, 0
 	}
 
 	// Collect subscribers under lock, then release
@@ -207,9 +402,9 @@ func (b *Broker) publish(node *Node, tokens []string, subject string, msg []byte
 			sub := group[idx%uint32(len(group))]
 
 			if b.isClientHealthy(sub.ID) {
-				select {
-				case sub.msgCh <- msg:
-				default: // drop if full
+				matched++
+				if b.enqueue(sub, msg) {
+					delivered++
 				}
 				break
 			}
@@ -227,41 +422,180 @@ func (b *Broker) publish(node *Node, tokens []string, subject string, msg []byte
 			continue
 		}
 
-		select {
-		case sub.msgCh <- msg:
-		default:
+		matched++
+		if b.enqueue(sub, msg) {
+			delivered++
 		}
 	}
 
 	// recursion for next token
+	add := func(m, d int) {
+		matched += m
+		delivered += d
+	}
 	if len(tokens) == 0 {
 		if node.greater != nil {
-			b.publish(node.greater, nil, subject, msg)
+			add(b.publish(node.greater, nil, subject, msg))
 		}
-		return
+		return matched, delivered
 	}
 
 	token := tokens[0]
 
 	// exact match
 	if child, ok := node.children[token]; ok {
-		b.publish(child, tokens[1:], subject, msg)
+		add(b.publish(child, tokens[1:], subject, msg))
 	}
 	// single-level wildcard *
 	if node.star != nil {
-		b.publish(node.star, tokens[1:], subject, msg)
+		add(b.publish(node.star, tokens[1:], subject, msg))
 	}
 	// tail wildcard >
 	if node.greater != nil {
-		b.publish(node.greater, nil, subject, msg)
+		add(b.publish(node.greater, nil, subject, msg))
 	}
+
+	return matched, delivered
+}
+
+// enqueue hands a message to the delivery goroutine of the subscriber, the slow consumer policy of the subscriber applies if its queue is full.
+// It returns false if the message was dropped.
+func (b *Broker) enqueue(sub *Subscriber, msg delivery) bool {
+	sub.mu.RLock()
+	defer sub.mu.RUnlock()
+
+	if sub.closed {
+		return false
+	}
+
+	select {
+	case sub.msgCh <- msg:
+		sub.dropping.Store(false)
+		return true
+	default:
+	}
+
+	switch sub.Policy {
+	case DropOldest:
+		for {
+			select {
+			case <-sub.msgCh:
+				b.drop(sub)
+			default: // the delivery goroutine made room in the meantime
+			}
+
+			select {
+			case sub.msgCh <- msg:
+				return true
+			default: // another publisher took the room, try again
+			}
+		}
+	case Block:
+		timeout := min(sub.BlockTimeout, MaxBlockTimeout)
+		if timeout <= 0 {
+			timeout = DefaultBlockTimeout
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case sub.msgCh <- msg:
+			return true
+		case <-timer.C:
+		}
+	}
+
+	b.drop(sub)
+	return false
+}
+
+// drop counts a message that was dropped for the subscriber, and reports the subscriber if it just started dropping messages.
+// The report is queued for startSlowConsumerNotices, so publishers are never held up by the SlowConsumerCallback.
+func (b *Broker) drop(sub *Subscriber) {
+	dropped := sub.dropped.Add(1)
+	if !sub.dropping.CompareAndSwap(false, true) {
+		return
+	}
+
+	select {
+	case b.slowNotices <- slowNotice{sub: sub, dropped: dropped}:
+	default:
+		// the queue is full, the subscriber is reported with its next dropped message
+		sub.dropping.Store(false)
+	}
+}
+
+// startSlowConsumerNotices starts the goroutine that passes the queued slow consumer notices to the SlowConsumerCallback.
+// It is separate from the delivery goroutines, so subscribers are reported even while their delivery is stuck.
+func (b *Broker) startSlowConsumerNotices() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case n := <-b.slowNotices:
+				b.slowCallback(n.sub, n.sub.subject, n.dropped)
+			}
+		}
+	}()
+}
+
+// Subscriptions returns the subscriptions of the subscriber with the given ID and the messages dropped for them.
+func (b *Broker) Subscriptions(subID string) []SubscriptionStats {
+	var stats []SubscriptionStats
+	b.collectSubscriptions(b.root, subID, &stats)
+	return stats
+}
+
+// collectSubscriptions recursively appends the subscriptions of the subscriber to stats
+func (b *Broker) collectSubscriptions(node *Node, subID string, stats *[]SubscriptionStats) {
+	if node == nil {
+		return
+	}
+
+	node.RLock()
+	defer node.RUnlock()
+
+	for _, sub := range node.subs {
+		if sub.ID != subID {
+			continue
+		}
+		*stats = append(*stats, SubscriptionStats{
+			Subject: sub.subject,
+			Queue:   sub.Queue,
+			Policy:  sub.Policy,
+			Pending: uint32(len(sub.msgCh)),
+			Dropped: sub.Dropped(),
+		})
+	}
+
+	for _, child := range node.children {
+		b.collectSubscriptions(child, subID, stats)
+	}
+	b.collectSubscriptions(node.star, subID, stats)
+	b.collectSubscriptions(node.greater, subID, stats)
 }
 
 func (b *Broker) startCleanupUnhealthyClients() {
-	ticker := time.NewTicker(1 * time.Minute)
+	b.wg.Add(1)
 	go func() {
-		for range ticker.C {
-			b.cleanupNode(b.root)
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				b.cleanupNode(b.root)
+				b.cleanupStreamSubscriptions()
+				b.cleanupConsumers()
+			}
 		}
 	}()
 }
@@ -276,7 +610,7 @@ func (b *Broker) cleanupNode(node *Node) {
 		if b.isClientHealthy(sub.ID) {
 			filtered = append(filtered, sub)
 		} else {
-			close(sub.msgCh)
+			sub.close()
 		}
 	}
 	node.subs = filtered
@@ -1,6 +1,9 @@
 package brokerengine
 
 import (
+	"errors"
+	"os"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -221,3 +224,624 @@ func TestMultipleSubscribersAllReceive(t *testing.T) {
 		t.Fatalf("expected 2 deliveries, got %d", count)
 	}
 }
+
+func TestStreamReplay(t *testing.T) {
+	received := make(chan Message, 16)
+
+	cfg := Configuration{
+		Database:   "testdb",
+		Collection: "teststream",
+		StreamCallback: func(sub *Subscriber, subject string, msgs []Message) {
+			for _, msg := range msgs {
+				received <- msg
+			}
+		},
+	}
+	b, err := NewStreamBroker(cfg)
+	if err != nil {
+		t.Fatalf("NewStreamBroker error: %v", err)
+	}
+	defer func() { deleteStreamForTest(t, b) }()
+
+	var first Message
+	for i, subject := range []string{"orders.created", "orders.paid", "users.created"} {
+		published, err := b.PublishWithHeader(subject, map[string]string{"index": strconv.Itoa(i)}, []byte(subject))
+		if err != nil {
+			t.Fatalf("PublishWithHeader error: %v", err)
+		}
+		if published.Sequence != uint64(i+1) {
+			t.Fatalf("PublishWithHeader sequence = %d, want %d", published.Sequence, i+1)
+		}
+		if i == 0 {
+			first = published
+		}
+	}
+
+	// the stream survives a restart
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if b, err = NewStreamBroker(cfg); err != nil {
+		t.Fatalf("NewStreamBroker error: %v", err)
+	}
+
+	if err := b.SubscribeStream("orders.*", &Subscriber{ID: "1"}, StartPosition{Policy: StartAll}); err != nil {
+		t.Fatalf("SubscribeStream error: %v", err)
+	}
+	if _, err := b.Publish("orders.shipped", []byte("orders.shipped")); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+
+	for _, want := range []Message{
+		{Sequence: 1, Subject: "orders.created"},
+		{Sequence: 2, Subject: "orders.paid"},
+		{Sequence: 4, Subject: "orders.shipped"},
+	} {
+		select {
+		case msg := <-received:
+			if msg.Sequence != want.Sequence || msg.Subject != want.Subject || string(msg.Data) != want.Subject {
+				t.Fatalf("received %+v, want sequence %d of %s", msg, want.Sequence, want.Subject)
+			}
+			// the ID, timestamp and header are stored in the stream as well
+			if msg.Sequence == 1 && (msg.ID != first.ID || msg.Timestamp != first.Timestamp || msg.Header["index"] != "0") {
+				t.Fatalf("replayed %+v, want the metadata of %+v", msg, first)
+			}
+		case <-time.After(200 * time.Millisecond):
+			t.Fatalf("timeout waiting for message %d", want.Sequence)
+		}
+	}
+
+	if err := b.SubscribeStream(">", &Subscriber{ID: "2"}, StartPosition{Policy: StartSequence, Sequence: 3}); err != nil {
+		t.Fatalf("SubscribeStream error: %v", err)
+	}
+	for _, want := range []uint64{3, 4} {
+		select {
+		case msg := <-received:
+			if msg.Sequence != want {
+				t.Fatalf("received sequence %d, want %d", msg.Sequence, want)
+			}
+		case <-time.After(200 * time.Millisecond):
+			t.Fatalf("timeout waiting for message %d", want)
+		}
+	}
+
+	b.Unsubscribe("orders.*", "1")
+	b.Unsubscribe(">", "2")
+	if err := b.SubscribeStream(">", &Subscriber{ID: "3"}, StartPosition{Policy: StartNew}); err != nil {
+		t.Fatalf("SubscribeStream error: %v", err)
+	}
+	select {
+	case msg := <-received:
+		t.Fatalf("received %+v before a new message was published", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestStreamRetention(t *testing.T) {
+	b, err := NewStreamBroker(Configuration{
+		Database:    "testdb",
+		Collection:  "teststreamretention",
+		MaxMessages: 3,
+		MaxAge:      time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewStreamBroker error: %v", err)
+	}
+	defer func() { deleteStreamForTest(t, b) }()
+
+	for i := 0; i < 5; i++ {
+		if _, err := b.Publish("events", []byte{byte(i)}); err != nil {
+			t.Fatalf("Publish error: %v", err)
+		}
+	}
+
+	info, err := b.StreamInfo()
+	if err != nil {
+		t.Fatalf("StreamInfo error: %v", err)
+	}
+	if info.Messages != 3 || info.FirstSequence != 3 || info.LastSequence != 5 {
+		t.Fatalf("StreamInfo = %+v, want sequences 3 to 5", info)
+	}
+
+	// older messages than the max age are removed, the sequence numbers continue
+	if err := b.stream.maintain(time.Now().Add(2 * time.Hour).UnixMilli()); err != nil {
+		t.Fatalf("maintain error: %v", err)
+	}
+	if info := b.stream.info(); info.Messages != 0 || info.FirstSequence != 6 || info.Bytes != 0 {
+		t.Fatalf("StreamInfo after max age = %+v, want no messages", info)
+	}
+	if seq, err := b.Publish("events", nil); err != nil || seq != 6 {
+		t.Fatalf("Publish = %d, %v, want sequence 6", seq, err)
+	}
+
+	if _, err := NewBroker(Configuration{}).StreamInfo(); !errors.Is(err, ErrNotStream) {
+		t.Fatalf("StreamInfo of broker without stream error = %v, want ErrNotStream", err)
+	}
+}
+
+func TestStreamTornTail(t *testing.T) {
+	cfg := Configuration{Database: "testdb", Collection: "teststreamtorn"}
+	b, err := NewStreamBroker(cfg)
+	if err != nil {
+		t.Fatalf("NewStreamBroker error: %v", err)
+	}
+	defer func() { deleteStreamForTest(t, b) }()
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.Publish("events", []byte("message")); err != nil {
+			t.Fatalf("Publish error: %v", err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	// cut the last record in half, like a crash during a write
+	path := b.stream.segmentPath(1)
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	if err := os.Truncate(path, stat.Size()-5); err != nil {
+		t.Fatalf("Truncate error: %v", err)
+	}
+
+	if b, err = NewStreamBroker(cfg); err != nil {
+		t.Fatalf("NewStreamBroker error: %v", err)
+	}
+	if info, _ := b.StreamInfo(); info.Messages != 2 || info.LastSequence != 2 {
+		t.Fatalf("StreamInfo after torn tail = %+v, want 2 messages", info)
+	}
+	if seq, err := b.Publish("events", []byte("message")); err != nil || seq != 3 {
+		t.Fatalf("Publish = %d, %v, want sequence 3", seq, err)
+	}
+}
+
+func TestConsumerRedelivery(t *testing.T) {
+	received := make(chan ConsumerMessage, 16)
+
+	cfg := Configuration{
+		Database:   "testdb",
+		Collection: "testconsumer",
+		ConsumerCallback: func(sub *Subscriber, consumer string, msgs []ConsumerMessage) {
+			for _, msg := range msgs {
+				received <- msg
+			}
+		},
+	}
+	b, err := NewStreamBroker(cfg)
+	if err != nil {
+		t.Fatalf("NewStreamBroker error: %v", err)
+	}
+	defer func() { deleteStreamForTest(t, b) }()
+
+	for _, subject := range []string{"jobs.a", "other", "jobs.b"} {
+		if _, err := b.Publish(subject, []byte(subject)); err != nil {
+			t.Fatalf("Publish error: %v", err)
+		}
+	}
+
+	consumerCfg := ConsumerConfig{
+		Name:    "workers",
+		Subject: "jobs.*",
+		Start:   StartPosition{Policy: StartAll},
+		AckWait: 50 * time.Millisecond,
+	}
+	if err := b.SubscribeConsumer(consumerCfg, &Subscriber{ID: "1"}); err != nil {
+		t.Fatalf("SubscribeConsumer error: %v", err)
+	}
+
+	receive := func(wantSeq uint64, wantDeliveries int) {
+		t.Helper()
+		select {
+		case msg := <-received:
+			if msg.Sequence != wantSeq || msg.Deliveries != wantDeliveries {
+				t.Fatalf("received sequence %d with %d deliveries, want sequence %d with %d", msg.Sequence, msg.Deliveries, wantSeq, wantDeliveries)
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("timeout waiting for sequence %d", wantSeq)
+		}
+	}
+
+	receive(1, 1)
+	receive(3, 1)
+	if err := b.Ack("workers", 1); err != nil {
+		t.Fatalf("Ack error: %v", err)
+	}
+	if err := b.Ack("workers", 1); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("second Ack error = %v, want ErrNotPending", err)
+	}
+
+	// the unacknowledged message is delivered again after the ack wait
+	receive(3, 2)
+	if err := b.Nak("workers", 3); err != nil {
+		t.Fatalf("Nak error: %v", err)
+	}
+	receive(3, 3)
+	if err := b.InProgress("workers", 3); err != nil {
+		t.Fatalf("InProgress error: %v", err)
+	}
+	if err := b.Ack("workers", 3); err != nil {
+		t.Fatalf("Ack error: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		t.Fatalf("received %+v after all messages were acknowledged", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := b.SubscribeConsumer(ConsumerConfig{Name: "workers", Subject: "other"}, &Subscriber{ID: "2"}); !errors.Is(err, ErrConsumerSubject) {
+		t.Fatalf("SubscribeConsumer with another subject error = %v, want ErrConsumerSubject", err)
+	}
+	if err := b.SubscribeConsumer(ConsumerConfig{Name: "../workers", Subject: "jobs.*"}, &Subscriber{ID: "2"}); !errors.Is(err, ErrInvalidConsumer) {
+		t.Fatalf("SubscribeConsumer with invalid name error = %v, want ErrInvalidConsumer", err)
+	}
+	if err := b.SubscribeConsumer(ConsumerConfig{Name: "looping", Subject: "jobs.*", DeadLetterSubject: "jobs.dead"}, &Subscriber{ID: "2"}); !errors.Is(err, ErrInvalidConsumer) {
+		t.Fatalf("SubscribeConsumer with a matching dead letter subject error = %v, want ErrInvalidConsumer", err)
+	}
+	if err := b.Ack("unknown", 1); !errors.Is(err, ErrConsumerNotFound) {
+		t.Fatalf("Ack of unknown consumer error = %v, want ErrConsumerNotFound", err)
+	}
+}
+
+func TestConsumerDeadLetter(t *testing.T) {
+	received := make(chan ConsumerMessage, 16)
+	deadLetters := make(chan Message, 16)
+
+	b, err := NewStreamBroker(Configuration{
+		Database:   "testdb",
+		Collection: "testconsumerdeadletter",
+		ConsumerCallback: func(sub *Subscriber, consumer string, msgs []ConsumerMessage) {
+			for _, msg := range msgs {
+				received <- msg
+			}
+		},
+		StreamCallback: func(sub *Subscriber, subject string, msgs []Message) {
+			for _, msg := range msgs {
+				deadLetters <- msg
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewStreamBroker error: %v", err)
+	}
+	defer func() { deleteStreamForTest(t, b) }()
+
+	if err := b.SubscribeStream("dead", &Subscriber{ID: "dlq"}, StartPosition{Policy: StartNew}); err != nil {
+		t.Fatalf("SubscribeStream error: %v", err)
+	}
+	if err := b.SubscribeConsumer(ConsumerConfig{
+		Name:              "workers",
+		Subject:           "jobs",
+		AckWait:           20 * time.Millisecond,
+		MaxDeliveries:     2,
+		DeadLetterSubject: "dead",
+	}, &Subscriber{ID: "1"}); err != nil {
+		t.Fatalf("SubscribeConsumer error: %v", err)
+	}
+	published, err := b.PublishWithHeader("jobs", map[string]string{"trace": "abc"}, []byte("poison"))
+	if err != nil {
+		t.Fatalf("PublishWithHeader error: %v", err)
+	}
+
+	for want := 1; want <= 2; want++ {
+		select {
+		case msg := <-received:
+			if msg.Deliveries != want {
+				t.Fatalf("received message with %d deliveries, want %d", msg.Deliveries, want)
+			}
+			if msg.ID != published.ID || msg.Header["trace"] != "abc" {
+				t.Fatalf("received %+v, want the ID and header of the published message", msg)
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("timeout waiting for delivery %d", want)
+		}
+	}
+
+	select {
+	case msg := <-deadLetters:
+		if msg.Subject != "dead" || string(msg.Data) != "poison" {
+			t.Fatalf("dead letter = %+v, want poison on subject dead", msg)
+		}
+		if msg.ID != published.ID || msg.Header["trace"] != "abc" {
+			t.Fatalf("dead letter = %+v, want the ID and header of the published message", msg)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("timeout waiting for dead letter")
+	}
+
+	if err := b.Ack("workers", 1); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("Ack of dead letter error = %v, want ErrNotPending", err)
+	}
+}
+
+func TestConsumerPersistence(t *testing.T) {
+	received := make(chan ConsumerMessage, 16)
+
+	cfg := Configuration{
+		Database:   "testdb",
+		Collection: "testconsumerpersistence",
+		ConsumerCallback: func(sub *Subscriber, consumer string, msgs []ConsumerMessage) {
+			for _, msg := range msgs {
+				received <- msg
+			}
+		},
+	}
+	b, err := NewStreamBroker(cfg)
+	if err != nil {
+		t.Fatalf("NewStreamBroker error: %v", err)
+	}
+	defer func() { deleteStreamForTest(t, b) }()
+
+	consumerCfg := ConsumerConfig{Name: "workers", Subject: "jobs", Start: StartPosition{Policy: StartAll}}
+	if err := b.SubscribeConsumer(consumerCfg, &Subscriber{ID: "1"}); err != nil {
+		t.Fatalf("SubscribeConsumer error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := b.Publish("jobs", []byte{byte(i)}); err != nil {
+			t.Fatalf("Publish error: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("timeout waiting for message %d", i+1)
+		}
+	}
+	if err := b.Ack("workers", 1); err != nil {
+		t.Fatalf("Ack error: %v", err)
+	}
+
+	// after a restart only the unacknowledged message is delivered again, followed by new messages
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if b, err = NewStreamBroker(cfg); err != nil {
+		t.Fatalf("NewStreamBroker error: %v", err)
+	}
+	if _, err := b.Publish("jobs", []byte{2}); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+	if err := b.SubscribeConsumer(consumerCfg, &Subscriber{ID: "2"}); err != nil {
+		t.Fatalf("SubscribeConsumer error: %v", err)
+	}
+
+	for _, want := range []ConsumerMessage{
+		{Message: Message{Sequence: 2}, Deliveries: 2},
+		{Message: Message{Sequence: 3}, Deliveries: 1},
+	} {
+		select {
+		case msg := <-received:
+			if msg.Sequence != want.Sequence || msg.Deliveries != want.Deliveries {
+				t.Fatalf("received sequence %d with %d deliveries, want sequence %d with %d", msg.Sequence, msg.Deliveries, want.Sequence, want.Deliveries)
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("timeout waiting for sequence %d", want.Sequence)
+		}
+	}
+}
+
+func deleteStreamForTest(t *testing.T, b *Broker) {
+	if err := b.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+
+	if err := os.RemoveAll(b.stream.dir); err != nil {
+		t.Errorf("failed to remove stream: %v", err)
+	}
+}
+
+func TestRequestReply(t *testing.T) {
+	replies := make(chan [][]byte, 1)
+
+	var b *Broker
+	b = NewBroker(Configuration{
+		BatchSize:    10,
+		BatchTimeout: time.Second,
+		PublishCallback: func(sub *Subscriber, subject string, msgs [][]byte) {
+			if sub.ID == "requester" && subject == "_INBOX.requester.1" {
+				replies <- msgs
+			}
+		},
+		RequestCallback: func(sub *Subscriber, subject string, reqs []Request) {
+			for _, req := range reqs {
+				b.Publish(req.Reply, append([]byte("re: "), req.Data...))
+			}
+		},
+	})
+
+	b.Subscribe("svc.echo", &Subscriber{ID: "responder"})
+	b.SubscribeInbox("_INBOX.requester.1", &Subscriber{ID: "requester"})
+
+	if responders, delivered := b.PublishRequest("svc.echo", "_INBOX.requester.1", []byte("hello")); responders != 1 || delivered != 1 {
+		t.Fatalf("expected request to be handed to 1 subscriber, got %d responders and %d deliveries", responders, delivered)
+	}
+
+	// Neither the request nor the reply may wait for the batch timeout
+	select {
+	case msgs := <-replies:
+		if len(msgs) != 1 || string(msgs[0]) != "re: hello" {
+			t.Fatalf("unexpected reply: %q", msgs)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("timeout waiting for reply")
+	}
+
+	if responders, _ := b.PublishRequest("svc.unknown", "_INBOX.requester.1", []byte("hello")); responders != 0 {
+		t.Fatalf("expected no responders, got %d", responders)
+	}
+}
+
+func TestRequestSlowResponder(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	b := NewBroker(Configuration{
+		BatchSize: 1,
+		RequestCallback: func(sub *Subscriber, subject string, reqs []Request) {
+			<-release
+		},
+	})
+	defer b.Close()
+
+	b.Subscribe("svc.slow", &Subscriber{ID: "responder", Policy: DropNewest})
+
+	// fill the queue of the responder until it drops requests
+	for i := 0; ; i++ {
+		if i > 2048 {
+			t.Fatal("expected the queue of the responder to fill up")
+		}
+
+		responders, delivered := b.PublishRequest("svc.slow", "_INBOX.requester.1", []byte("hello"))
+		if responders != 1 {
+			t.Fatalf("expected the slow responder to count as responder, got %d responders", responders)
+		}
+		if delivered == 0 {
+			break
+		}
+	}
+}
+
+func TestSlowConsumerPolicy(t *testing.T) {
+	for _, policy := range []SlowConsumerPolicy{DropNewest, DropOldest} {
+		release := make(chan struct{})
+		notices := make(chan uint64, 10)
+		var mu sync.Mutex
+		var last string
+
+		b := NewBroker(Configuration{
+			BatchSize: 1,
+			PublishCallback: func(sub *Subscriber, subject string, msgs [][]byte) {
+				<-release
+				mu.Lock()
+				last = string(msgs[len(msgs)-1])
+				mu.Unlock()
+			},
+			SlowConsumerCallback: func(sub *Subscriber, subject string, dropped uint64) {
+				notices <- dropped
+			},
+		})
+
+		sub := &Subscriber{ID: "1", Policy: policy}
+		b.Subscribe("slow", sub)
+
+		// The delivery goroutine holds at most one message, the queue holds 1024
+		for i := 0; i < 1100; i++ {
+			b.Publish("slow", []byte(strconv.Itoa(i)))
+		}
+
+		if d := sub.Dropped(); d < 75 || d > 76 {
+			t.Fatalf("policy %d: expected 75 or 76 dropped messages, got %d", policy, d)
+		}
+		// the notice is sent while the delivery is still stuck
+		select {
+		case <-notices:
+		case <-time.After(time.Second):
+			t.Fatalf("policy %d: expected a slow consumer notice", policy)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if len(notices) != 0 {
+			t.Fatalf("policy %d: expected one slow consumer notice, got %d more", policy, len(notices))
+		}
+		stats := b.Subscriptions("1")
+		if len(stats) != 1 || stats[0].Subject != "slow" || stats[0].Dropped != sub.Dropped() {
+			t.Fatalf("policy %d: unexpected subscription stats: %+v", policy, stats)
+		}
+
+		close(release)
+		deadline := time.Now().Add(time.Second)
+		for len(sub.msgCh) > 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		got := last
+		mu.Unlock()
+		if policy == DropOldest && got != "1099" {
+			t.Fatalf("drop oldest: expected the newest message to be delivered last, got %q", got)
+		}
+		if policy == DropNewest && got != "1023" && got != "1024" {
+			t.Fatalf("drop newest: expected the newest messages to be dropped, last delivered %q", got)
+		}
+	}
+}
+
+func TestSlowConsumerBlock(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	b := NewBroker(Configuration{
+		BatchSize: 1,
+		PublishCallback: func(sub *Subscriber, subject string, msgs [][]byte) {
+			<-release
+		},
+	})
+
+	sub := &Subscriber{ID: "1", Policy: Block, BlockTimeout: 20 * time.Millisecond}
+	b.Subscribe("slow", sub)
+
+	for i := 0; i < 1025; i++ {
+		b.Publish("slow", []byte("x"))
+	}
+
+	start := time.Now()
+	b.Publish("slow", []byte("x"))
+	if elapsed := time.Since(start); elapsed < sub.BlockTimeout {
+		t.Fatalf("expected publish to block for the block timeout, took %s", elapsed)
+	}
+	if d := sub.Dropped(); d < 1 || d > 2 {
+		t.Fatalf("expected the message to be dropped after the block timeout, dropped %d", d)
+	}
+}
+
+func TestPublishWithHeader(t *testing.T) {
+	plain := make(chan [][]byte, 1)
+	withHeader := make(chan []Message, 1)
+
+	b := NewBroker(Configuration{
+		BatchSize: 1,
+		PublishCallback: func(sub *Subscriber, subject string, msgs [][]byte) {
+			plain <- msgs
+		},
+		HeaderCallback: func(sub *Subscriber, subject string, msgs []Message) {
+			withHeader <- msgs
+		},
+	})
+
+	b.Subscribe("orders.*", &Subscriber{ID: "1"})
+	b.Subscribe("orders.*", &Subscriber{ID: "2", WithHeader: true})
+
+	published, err := b.PublishWithHeader("orders.created", map[string]string{"trace-id": "abc"}, []byte("hello"))
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if published.ID == "" || published.Timestamp == 0 {
+		t.Fatalf("expected ID and timestamp to be assigned, got %+v", published)
+	}
+
+	select {
+	case msgs := <-withHeader:
+		if len(msgs) != 1 {
+			t.Fatalf("expected 1 msg, got %d", len(msgs))
+		}
+		msg := msgs[0]
+		if msg.ID != published.ID || msg.Timestamp != published.Timestamp || msg.Subject != "orders.created" ||
+			msg.Header["trace-id"] != "abc" || string(msg.Data) != "hello" {
+			t.Fatalf("unexpected msg: %+v", msg)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("timeout waiting for message with header")
+	}
+
+	select {
+	case msgs := <-plain:
+		if len(msgs) != 1 || string(msgs[0]) != "hello" {
+			t.Fatalf("unexpected msgs: %v", msgs)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("timeout waiting for message")
+	}
+}
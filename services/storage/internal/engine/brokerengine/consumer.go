@@ -0,0 +1,598 @@
+package brokerengine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+)
+
+const (
+	consumerFilePrefix = "consumer_"
+	consumerFileSuffix = ".json"
+
+	// DefaultAckWait is the ack wait of consumers that do not configure one.
+	DefaultAckWait = 30 * time.Second
+
+	// maxAckPending bounds the number of delivered but unacknowledged messages of a consumer.
+	// No new messages are delivered to the consumer while it is reached, only redeliveries.
+	maxAckPending = 1000
+)
+
+// consumerNamePattern restricts consumer names, they are part of the name of the file the consumer is persisted in.
+var consumerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ConsumerConfig configures a durable consumer, see SubscribeConsumer.
+type ConsumerConfig struct {
+	// Name identifies the consumer. It consists of at most 64 letters, digits, '_' and '-'.
+	Name string
+	// Subject is the subject the consumer receives the messages of, including its wildcards.
+	Subject string
+	// Start is the position in the stream at which the consumer starts. It is only used when the consumer is created.
+	Start StartPosition
+	// AckWait is the time after which a delivered message that was not acknowledged is delivered again. Defaults to DefaultAckWait.
+	AckWait time.Duration
+	// MaxDeliveries is the number of times a message is delivered before it is given up on (0 = unlimited).
+	MaxDeliveries int
+	// DeadLetterSubject is the subject messages are published to once they were delivered MaxDeliveries times without being acknowledged.
+	// If it is empty, these messages are dropped. It must not match Subject, otherwise the consumer would receive its dead letters again.
+	DeadLetterSubject string
+}
+
+// ConsumerMessage is a message delivered to a durable consumer.
+type ConsumerMessage struct {
+	Message
+	// Deliveries is the number of times the message was delivered to the consumer, including this delivery.
+	Deliveries int
+}
+
+// pendingMessage is a message that was delivered to a consumer but was not acknowledged yet.
+type pendingMessage struct {
+	deliveries int
+	deadline   int64  // Unix milliseconds at which the message is delivered again
+	subID      string // Subscriber the message was delivered to last
+}
+
+// consumer tracks which messages of a stream a durable consumer acknowledged and delivers the others to its subscribers in turn.
+// Every message before next that is not pending was acknowledged, given up on or does not match the subject.
+type consumer struct {
+	cfg    ConsumerConfig
+	tokens []string
+	path   string
+
+	mu      sync.Mutex
+	next    uint64 // Sequence number of the next new message
+	pending map[uint64]*pendingMessage
+	subs    []*Subscriber
+	turn    int
+	dirty   bool          // Whether the state changed since it was persisted
+	wake    chan struct{} // Signaled when messages may be deliverable again
+}
+
+// consumerState is the state of a consumer that is persisted in data/{database}/{collection}/consumer_{name}.json
+type consumerState struct {
+	Subject           string         `json:"subject"`
+	AckWaitMillis     int64          `json:"ack_wait_ms"`
+	MaxDeliveries     int            `json:"max_deliveries"`
+	DeadLetterSubject string         `json:"dead_letter_subject"`
+	Next              uint64         `json:"next"`
+	Pending           map[uint64]int `json:"pending"` // Deliveries by sequence number
+}
+
+// SubscribeConsumer subscribes to the messages of a durable consumer, creating the consumer if it does not exist yet.
+//
+// The consumer keeps track of the acknowledged messages on disk, so it continues where it left off after subscribers or the server restart.
+// Every message is delivered to one subscriber of the consumer at a time, through the ConsumerCallback of the broker.
+// Messages that are not acknowledged within the ack wait, are not acknowledged (see Nak) or whose subscriber disconnects are delivered again,
+// up to MaxDeliveries times. The ack wait, max deliveries and dead letter subject of an existing consumer are replaced by the ones of cfg.
+//
+// Returns ErrNotStream if the broker is no stream, ErrInvalidConsumer if cfg is invalid and ErrConsumerSubject if the consumer exists with another subject.
+func (b *Broker) SubscribeConsumer(cfg ConsumerConfig, sub *Subscriber) error {
+	if b.stream == nil {
+		return ErrNotStream
+	}
+	if !consumerNamePattern.MatchString(cfg.Name) {
+		return fmt.Errorf("%w: name must consist of 1 to 64 letters, digits, '_' and '-'", ErrInvalidConsumer)
+	}
+	if cfg.Subject == "" {
+		return fmt.Errorf("%w: subject must not be empty", ErrInvalidConsumer)
+	}
+	if cfg.DeadLetterSubject != "" && matchTokens(strings.Split(cfg.Subject, "."), cfg.DeadLetterSubject) {
+		return fmt.Errorf("%w: dead letter subject must not match the subject", ErrInvalidConsumer)
+	}
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = DefaultAckWait
+	}
+
+	b.consumersMu.Lock()
+	defer b.consumersMu.Unlock()
+
+	c, ok := b.consumers[cfg.Name]
+	if !ok {
+		next, err := b.stream.startSeq(cfg.Start)
+		if err != nil {
+			return err
+		}
+
+		c = newConsumer(cfg, filepath.Join(b.stream.dir, consumerFilePrefix+cfg.Name+consumerFileSuffix), next)
+		c.dirty = true
+		b.consumers[cfg.Name] = c
+		b.startConsumerDelivery(c)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cfg.Subject != cfg.Subject {
+		return ErrConsumerSubject
+	}
+
+	if c.cfg.AckWait != cfg.AckWait || c.cfg.MaxDeliveries != cfg.MaxDeliveries || c.cfg.DeadLetterSubject != cfg.DeadLetterSubject {
+		c.cfg.AckWait = cfg.AckWait
+		c.cfg.MaxDeliveries = cfg.MaxDeliveries
+		c.cfg.DeadLetterSubject = cfg.DeadLetterSubject
+		c.dirty = true
+	}
+
+	c.subs = slices.DeleteFunc(c.subs, func(s *Subscriber) bool { return s.ID == sub.ID })
+	c.subs = append(c.subs, sub)
+	c.signal()
+
+	return nil
+}
+
+// UnsubscribeConsumer removes a subscriber from a durable consumer. The messages pending for the subscriber are delivered to the other subscribers.
+// The consumer itself is kept, new subscribers continue where it left off.
+// Returns ErrConsumerNotFound if there is no consumer with the name.
+func (b *Broker) UnsubscribeConsumer(name string, subID string) error {
+	c, err := b.consumer(name)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.unbind(subID)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Ack acknowledges a message of a durable consumer, so it is not delivered again.
+// Returns ErrConsumerNotFound if there is no consumer with the name and ErrNotPending if the message is not waiting for an acknowledgement.
+func (b *Broker) Ack(name string, seq uint64) error {
+	return b.updatePending(name, seq, func(c *consumer, p *pendingMessage) {
+		delete(c.pending, seq)
+	})
+}
+
+// Nak rejects a message of a durable consumer, so it is delivered again right away.
+// Returns ErrConsumerNotFound if there is no consumer with the name and ErrNotPending if the message is not waiting for an acknowledgement.
+func (b *Broker) Nak(name string, seq uint64) error {
+	return b.updatePending(name, seq, func(c *consumer, p *pendingMessage) {
+		p.deadline = 0
+	})
+}
+
+// InProgress resets the ack wait of a message of a durable consumer, for subscribers that need longer to process it.
+// Returns ErrConsumerNotFound if there is no consumer with the name and ErrNotPending if the message is not waiting for an acknowledgement.
+func (b *Broker) InProgress(name string, seq uint64) error {
+	return b.updatePending(name, seq, func(c *consumer, p *pendingMessage) {
+		p.deadline = time.Now().UnixMilli() + c.cfg.AckWait.Milliseconds()
+	})
+}
+
+// updatePending calls fn with the pending message seq of the consumer and wakes up its delivery.
+func (b *Broker) updatePending(name string, seq uint64, fn func(c *consumer, p *pendingMessage)) error {
+	c, err := b.consumer(name)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.pending[seq]
+	if !ok {
+		return ErrNotPending
+	}
+
+	fn(c, p)
+	c.dirty = true
+	c.signal()
+
+	return nil
+}
+
+// consumer returns the durable consumer with the name.
+func (b *Broker) consumer(name string) (*consumer, error) {
+	if b.stream == nil {
+		return nil, ErrNotStream
+	}
+
+	b.consumersMu.Lock()
+	defer b.consumersMu.Unlock()
+
+	c, ok := b.consumers[name]
+	if !ok {
+		return nil, ErrConsumerNotFound
+	}
+
+	return c, nil
+}
+
+func newConsumer(cfg ConsumerConfig, path string, next uint64) *consumer {
+	return &consumer{
+		cfg:     cfg,
+		tokens:  strings.Split(cfg.Subject, "."),
+		path:    path,
+		next:    next,
+		pending: make(map[uint64]*pendingMessage),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// startConsumerDelivery starts the goroutine that delivers the messages of the consumer while it has subscribers.
+func (b *Broker) startConsumerDelivery(c *consumer) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			default:
+			}
+
+			progress, appended, due := b.deliverConsumer(c)
+			if progress {
+				continue
+			}
+
+			var timeout <-chan time.Time
+			if due > 0 {
+				timer.Reset(due)
+				timeout = timer.C
+			}
+
+			select {
+			case <-b.stop:
+				return
+			case <-c.wake:
+			case <-appended:
+			case <-timeout:
+			}
+		}
+	}()
+}
+
+// deliverConsumer delivers a batch of due redeliveries and new messages to the next subscriber of the consumer.
+// It reports whether it made progress, otherwise it returns the channel that is closed when the next message is appended (nil if new messages
+// are not awaited) and the time until the next pending message is due (0 if none is pending).
+func (b *Broker) deliverConsumer(c *consumer) (bool, <-chan struct{}, time.Duration) {
+	now := time.Now().UnixMilli()
+
+	c.mu.Lock()
+	sub := c.nextSubscriber(b.isClientHealthy)
+	if sub == nil {
+		c.mu.Unlock()
+		return false, nil, 0
+	}
+	due, wait := c.due(now, b.batchSize)
+	free := maxAckPending - len(c.pending)
+	from := c.next
+	c.mu.Unlock()
+
+	batch := make([]ConsumerMessage, 0, b.batchSize)
+	for _, seq := range due {
+		if msg, ok := b.redeliver(c, seq, sub.ID, now); ok {
+			batch = append(batch, msg)
+		}
+	}
+
+	progress := len(due) > 0
+	var appended <-chan struct{}
+	if free > 0 && len(batch) < b.batchSize {
+		msgs, next, ch, err := b.stream.read(from, c.matches, min(free, b.batchSize-len(batch)))
+		if err != nil {
+			slog.Error("Failed to read message from broker stream, skipping it",
+				slog.String("consumer", c.cfg.Name),
+				slog.Uint64("sequence", next),
+				sloki.WrapError(err),
+			)
+			next++
+		}
+		progress = progress || ch == nil
+		appended = ch
+
+		c.mu.Lock()
+		for _, msg := range msgs {
+			c.pending[msg.Sequence] = &pendingMessage{deliveries: 1, deadline: now + c.cfg.AckWait.Milliseconds(), subID: sub.ID}
+			batch = append(batch, ConsumerMessage{Message: msg, Deliveries: 1})
+		}
+		c.dirty = c.dirty || next != c.next
+		c.next = next
+		c.mu.Unlock()
+	}
+
+	if len(batch) == 0 {
+		return progress, appended, wait
+	}
+
+	b.consumerCallback(sub, c.cfg.Name, batch)
+	return true, nil, 0
+}
+
+// redeliver prepares the redelivery of the pending message seq to the subscriber subID.
+// Messages that were delivered MaxDeliveries times are published to the dead letter subject instead and, like messages that were removed
+// by the retention limits of the stream, are no longer pending afterward.
+func (b *Broker) redeliver(c *consumer, seq uint64, subID string, now int64) (ConsumerMessage, bool) {
+	msg, stored, err := b.stream.get(seq)
+	if err != nil {
+		slog.Error("Failed to read message from broker stream, skipping it",
+			slog.String("consumer", c.cfg.Name),
+			slog.Uint64("sequence", seq),
+			sloki.WrapError(err),
+		)
+	}
+
+	c.mu.Lock()
+	p, ok := c.pending[seq]
+	if !ok || p.deadline > now {
+		// Acknowledged or touched in the meantime
+		c.mu.Unlock()
+		return ConsumerMessage{}, false
+	}
+
+	if !stored {
+		delete(c.pending, seq)
+		c.dirty = true
+		c.mu.Unlock()
+		return ConsumerMessage{}, false
+	}
+
+	if c.cfg.MaxDeliveries > 0 && p.deliveries >= c.cfg.MaxDeliveries {
+		delete(c.pending, seq)
+		c.dirty = true
+		deadLetterSubject := c.cfg.DeadLetterSubject
+		c.mu.Unlock()
+
+		if deadLetterSubject != "" {
+			// The dead letter keeps the ID and the header of the message
+			deadLetter := Message{
+				ID:        msg.ID,
+				Timestamp: time.Now().UnixMilli(),
+				Subject:   deadLetterSubject,
+				Header:    msg.Header,
+				Data:      msg.Data,
+			}
+			if _, err := b.publishMessage(deadLetter); err != nil {
+				slog.Error("Failed to publish message to dead letter subject",
+					slog.String("consumer", c.cfg.Name),
+					slog.Uint64("sequence", seq),
+					sloki.WrapError(err),
+				)
+			}
+		}
+		return ConsumerMessage{}, false
+	}
+
+	p.deliveries++
+	p.deadline = now + c.cfg.AckWait.Milliseconds()
+	p.subID = subID
+	c.dirty = true
+	c.mu.Unlock()
+
+	return ConsumerMessage{Message: msg, Deliveries: p.deliveries}, true
+}
+
+// matches reports whether the subject of a message matches the subject of the consumer, including its wildcards.
+func (c *consumer) matches(subject string) bool {
+	return matchTokens(c.tokens, subject)
+}
+
+// nextSubscriber returns the healthy subscriber whose turn it is, unhealthy subscribers are removed on the way. It returns nil if there is none.
+// The caller must hold c.mu.
+func (c *consumer) nextSubscriber(isClientHealthy func(id string) bool) *Subscriber {
+	for len(c.subs) > 0 {
+		sub := c.subs[c.turn%len(c.subs)]
+		if isClientHealthy(sub.ID) {
+			c.turn++
+			return sub
+		}
+
+		c.unbind(sub.ID)
+	}
+
+	return nil
+}
+
+// due returns up to limit pending messages that are due at now in the order of their sequence numbers,
+// and the time until the next one that is not due yet (0 if there is none).
+// The caller must hold c.mu.
+func (c *consumer) due(now int64, limit int) ([]uint64, time.Duration) {
+	var due []uint64
+	next := int64(0)
+	for seq, p := range c.pending {
+		if p.deadline <= now {
+			due = append(due, seq)
+		} else if next == 0 || p.deadline < next {
+			next = p.deadline
+		}
+	}
+
+	slices.Sort(due)
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	if next == 0 {
+		return due, 0
+	}
+	return due, time.Duration(next-now) * time.Millisecond
+}
+
+// unbind removes the subscriber and makes the messages pending for it due right away.
+// The caller must hold c.mu.
+func (c *consumer) unbind(subID string) {
+	c.subs = slices.DeleteFunc(c.subs, func(s *Subscriber) bool { return s.ID == subID })
+
+	for _, p := range c.pending {
+		if p.subID == subID {
+			p.deadline = 0
+		}
+	}
+	c.signal()
+}
+
+// signal wakes up the delivery of the consumer.
+func (c *consumer) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// cleanupConsumers removes unhealthy subscribers from the durable consumers.
+func (b *Broker) cleanupConsumers() {
+	b.consumersMu.Lock()
+	defer b.consumersMu.Unlock()
+
+	for _, c := range b.consumers {
+		c.mu.Lock()
+		for _, sub := range slices.Clone(c.subs) {
+			if !b.isClientHealthy(sub.ID) {
+				c.unbind(sub.ID)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// loadConsumers loads the durable consumers persisted in the stream directory and starts their delivery.
+// Messages that were pending are delivered again as soon as the consumers have subscribers.
+func (b *Broker) loadConsumers() error {
+	paths, err := filepath.Glob(filepath.Join(b.stream.dir, consumerFilePrefix+"*"+consumerFileSuffix))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var state consumerState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("failed to decode consumer %s: %w", path, err)
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), consumerFilePrefix), consumerFileSuffix)
+		c := newConsumer(ConsumerConfig{
+			Name:              name,
+			Subject:           state.Subject,
+			AckWait:           time.Duration(state.AckWaitMillis) * time.Millisecond,
+			MaxDeliveries:     state.MaxDeliveries,
+			DeadLetterSubject: state.DeadLetterSubject,
+		}, path, state.Next)
+		for seq, deliveries := range state.Pending {
+			c.pending[seq] = &pendingMessage{deliveries: deliveries}
+		}
+
+		b.consumers[name] = c
+		b.startConsumerDelivery(c)
+	}
+
+	return nil
+}
+
+// persistConsumers writes the state of the durable consumers that changed since they were persisted last.
+func (b *Broker) persistConsumers() error {
+	b.consumersMu.Lock()
+	consumers := make([]*consumer, 0, len(b.consumers))
+	for _, c := range b.consumers {
+		consumers = append(consumers, c)
+	}
+	b.consumersMu.Unlock()
+
+	var errs []error
+	for _, c := range consumers {
+		if err := c.persist(); err != nil {
+			errs = append(errs, fmt.Errorf("consumer %s: %w", c.cfg.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// persist writes the state of the consumer to its file if it changed, the file is replaced atomically.
+func (c *consumer) persist() error {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+
+	state := consumerState{
+		Subject:           c.cfg.Subject,
+		AckWaitMillis:     c.cfg.AckWait.Milliseconds(),
+		MaxDeliveries:     c.cfg.MaxDeliveries,
+		DeadLetterSubject: c.cfg.DeadLetterSubject,
+		Next:              c.next,
+		Pending:           make(map[uint64]int, len(c.pending)),
+	}
+	for seq, p := range c.pending {
+		state.Pending[seq] = p.deliveries
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	err := writeFileAtomic(c.path, state)
+	if err != nil {
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+	}
+
+	return err
+}
+
+// writeFileAtomic writes v as JSON to path by replacing the file with a synced temporary file.
+func writeFileAtomic(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
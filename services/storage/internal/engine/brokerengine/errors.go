@@ -0,0 +1,13 @@
+package brokerengine
+
+import "errors"
+
+var (
+	ErrNotStream            = errors.New("broker is not a stream")
+	ErrInvalidStartPosition = errors.New("invalid start position")
+	ErrInvalidConsumer      = errors.New("invalid consumer")
+	ErrConsumerNotFound     = errors.New("consumer not found")
+	ErrConsumerSubject      = errors.New("consumer exists with a different subject")
+	ErrNotPending           = errors.New("message is not pending")
+	errCorruptRecord        = errors.New("corrupt record")
+)
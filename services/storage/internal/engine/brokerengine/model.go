@@ -2,6 +2,8 @@ package brokerengine
 
 import (
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // queueGroupCounter is a global counter for assigning unique IDs to queue subscribers
@@ -11,7 +13,80 @@ var queueGroupCounter uint32
 type Subscriber struct {
 	ID    string
 	Queue string
-	msgCh chan []byte
+	// Policy decides what happens to messages for the subscriber while its queue is full.
+	Policy SlowConsumerPolicy
+	// BlockTimeout is how long publishers wait for room in the queue with the Block policy, DefaultBlockTimeout if 0, at most MaxBlockTimeout.
+	BlockTimeout time.Duration
+	// WithHeader delivers messages together with their metadata to the HeaderCallback instead of the PublishCallback.
+	WithHeader bool
+
+	subject   string
+	msgCh     chan delivery
+	immediate bool // Deliver messages right away instead of batching them, see SubscribeInbox
+
+	mu       sync.RWMutex // Guards sends to msgCh against closing it
+	closed   bool
+	dropped  atomic.Uint64
+	dropping atomic.Bool // Set once a message is dropped, cleared once a message fits into the queue again
+}
+
+// Dropped returns the number of messages that were dropped, because the queue of the subscriber was full.
+func (s *Subscriber) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// close stops the delivery goroutine of the subscriber, it waits for publishers that are blocked on the queue.
+func (s *Subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.msgCh)
+	}
+}
+
+// SlowConsumerPolicy decides what happens to messages for a subscriber that does not keep up with the publishers, so its queue is full.
+type SlowConsumerPolicy byte
+
+const (
+	// DropNewest drops the message that does not fit into the queue.
+	DropNewest SlowConsumerPolicy = 0
+	// DropOldest drops the oldest queued message to make room for the new one.
+	DropOldest SlowConsumerPolicy = 1
+	// Block makes the publisher wait up to Subscriber.BlockTimeout for room in the queue, the message is dropped afterward.
+	Block SlowConsumerPolicy = 2
+	// Disconnect drops the message, the subscriber is expected to be disconnected as slow consumer (see SlowConsumerCallback).
+	Disconnect SlowConsumerPolicy = 3
+)
+
+const (
+	// DefaultBlockTimeout is the block timeout of subscribers with the Block policy that do not configure one.
+	DefaultBlockTimeout = 100 * time.Millisecond
+	// MaxBlockTimeout is the longest block timeout a subscriber may configure, so that a slow subscriber cannot hold up publishers for long.
+	MaxBlockTimeout = 1 * time.Second
+)
+
+// SubscriptionStats describes a subscription and the messages dropped for it.
+type SubscriptionStats struct {
+	Subject string             `json:"subject"`
+	Queue   string             `json:"queue"`
+	Policy  SlowConsumerPolicy `json:"policy"`
+	// Pending is the number of messages in the queue of the subscriber.
+	Pending uint32 `json:"pending"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// delivery is a message on its way to a subscriber, reply is set if the message is a request.
+type delivery struct {
+	msg   *Message // Shared by all subscribers, must not be modified
+	reply string
+}
+
+// Request is a message that expects a reply, which the receiver publishes to the Reply subject.
+type Request struct {
+	Reply string
+	Data  []byte
 }
 
 // Node represents a trie node for subject routing
@@ -22,3 +97,43 @@ type Node struct {
 	star     *Node // *
 	greater  *Node // >
 }
+
+// StartPolicy decides where a stream subscription starts.
+type StartPolicy byte
+
+const (
+	// StartNew delivers only messages that are published after subscribing.
+	StartNew StartPolicy = 0
+	// StartAll delivers all messages that are stored in the stream.
+	StartAll StartPolicy = 1
+	// StartSequence delivers the messages starting at StartPosition.Sequence.
+	StartSequence StartPolicy = 2
+	// StartTime delivers the messages that were published at or after StartPosition.Time.
+	StartTime StartPolicy = 3
+)
+
+// StartPosition is the position in the stream at which a stream subscription starts.
+type StartPosition struct {
+	Policy   StartPolicy
+	Sequence uint64
+	Time     int64 // Unix milliseconds
+}
+
+// StreamInfo describes the messages stored in a stream and its retention limits.
+type StreamInfo struct {
+	Messages uint64 `json:"messages"`
+	// Bytes is the size of the stored messages on disk.
+	Bytes uint64 `json:"bytes"`
+
+	// FirstSequence is the sequence number of the oldest stored message, LastSequence the one of the newest.
+	// If no message is stored, FirstSequence is the sequence number of the next message and LastSequence the one before.
+	FirstSequence uint64 `json:"first_sequence"`
+	LastSequence  uint64 `json:"last_sequence"`
+	// FirstTimestamp and LastTimestamp are the publish times (in unix milliseconds) of the oldest and the newest message, 0 if no message is stored.
+	FirstTimestamp int64 `json:"first_timestamp"`
+	LastTimestamp  int64 `json:"last_timestamp"`
+
+	MaxMessages   uint64 `json:"max_messages"`
+	MaxBytes      uint64 `json:"max_bytes"`
+	MaxAgeSeconds int64  `json:"max_age_seconds"`
+}
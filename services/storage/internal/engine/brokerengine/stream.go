@@ -0,0 +1,545 @@
+package brokerengine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+)
+
+const (
+	segmentFilePrefix = "segment_"
+	segmentFileSuffix = ".log"
+
+	// maxSegmentSize is the size after which a new segment file is started.
+	// Retention only removes whole segment files, so removed messages take up at most this much disk space.
+	maxSegmentSize = 64 * 1024 * 1024
+
+	// recordHeaderSize is the size of the header in front of every record: | payload length (4 bytes) | crc32 of payload (4 bytes) |
+	recordHeaderSize = 4 + 4
+
+	// recordFixedSize is the size of the fixed fields of a record payload: | sequence (8 bytes) | timestamp (8 bytes) | subject length (2 bytes) | ID length (2 bytes) | header count (2 bytes) |
+	recordFixedSize = 8 + 8 + 2 + 2 + 2
+
+	// maxRecordSize limits the payload length of a single record, anything larger is treated as corruption.
+	maxRecordSize = 64 * 1024 * 1024
+
+	// maxReadScan bounds the number of messages a single read visits, so the stream lock is only held briefly.
+	maxReadScan = 1024
+
+	// streamJobInterval is the interval in which the stream is synced to disk and messages that exceed MaxAge are removed.
+	streamJobInterval = 1 * time.Second
+)
+
+// Message is a published message together with its metadata, see PublishWithHeader.
+// Streams store all fields, so messages that are replayed from a stream are the same as the published ones.
+type Message struct {
+	Sequence  uint64 // 0 if the broker is no stream
+	ID        string
+	Timestamp int64 // Unix milliseconds
+	Subject   string
+	Header    map[string]string
+	Data      []byte
+}
+
+// segment is a file of the stream log. Its name contains the sequence number of its first message.
+type segment struct {
+	file     *os.File
+	firstSeq uint64
+	size     int64
+}
+
+// streamEntry locates a message in the segment files.
+type streamEntry struct {
+	segment   *segment
+	offset    int64
+	size      int64 // Size of the record including its header
+	timestamp int64
+}
+
+// stream appends published messages to segment files and keeps an index of their positions, so they can be read by sequence number.
+// Path structure: data/{database}/{collection}/segment_{first sequence}.log
+type stream struct {
+	dir         string
+	maxMessages uint64
+	maxBytes    uint64
+	maxAge      time.Duration
+
+	mu       sync.RWMutex
+	segments []*segment    // Ordered by sequence, the last one is appended to
+	entries  []streamEntry // The entry of a message with sequence n is entries[n-firstSeq]
+	firstSeq uint64
+	bytes    uint64
+	appended chan struct{} // Closed and replaced whenever a message is appended
+	unsynced bool
+	buf      []byte // Scratch buffer for encoding records, guarded by mu
+}
+
+// openStream opens the stream in dir and loads the index from its segment files.
+// A torn record at the end of the last segment (e.g. after a crash) is truncated.
+func openStream(dir string, maxMessages, maxBytes uint64, maxAge time.Duration) (*stream, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &stream{
+		dir:         dir,
+		maxMessages: maxMessages,
+		maxBytes:    maxBytes,
+		maxAge:      maxAge,
+		firstSeq:    1,
+		appended:    make(chan struct{}),
+	}
+
+	seqs, err := s.segmentSequences()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, seq := range seqs {
+		seg, err := s.loadSegment(seq, i == len(seqs)-1)
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	if len(s.segments) == 0 {
+		seg, err := s.createSegment(1)
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	s.enforceLimits(time.Now().UnixMilli())
+
+	return s, nil
+}
+
+// loadSegment opens the segment file starting with sequence number seq and adds its messages to the index.
+// If last is true, a torn or corrupt tail is truncated instead of failing.
+func (s *stream) loadSegment(seq uint64, last bool) (*segment, error) {
+	path := s.segmentPath(seq)
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	seg := &segment{file: f, firstSeq: seq}
+
+	if len(s.entries) == 0 {
+		s.firstSeq = seq
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		msg, n, err := readRecord(r)
+		if err == nil && msg.Sequence != s.nextSeq() {
+			err = fmt.Errorf("%w: sequence %d, expected %d", errCorruptRecord, msg.Sequence, s.nextSeq())
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !last {
+				f.Close()
+				return nil, fmt.Errorf("failed to read %s at offset %d: %w", path, seg.size, err)
+			}
+
+			slog.Warn("Truncating torn tail of broker stream",
+				slog.String("path", path),
+				slog.Int64("offset", seg.size),
+				sloki.WrapError(err),
+			)
+			if err := f.Truncate(seg.size); err != nil {
+				f.Close()
+				return nil, err
+			}
+			break
+		}
+
+		s.entries = append(s.entries, streamEntry{segment: seg, offset: seg.size, size: int64(n), timestamp: msg.Timestamp})
+		s.bytes += uint64(n)
+		seg.size += int64(n)
+	}
+
+	return seg, nil
+}
+
+// nextSeq returns the sequence number of the next appended message.
+// The caller must hold one of the locks.
+func (s *stream) nextSeq() uint64 {
+	return s.firstSeq + uint64(len(s.entries))
+}
+
+// append stores msg with the next sequence number and wakes up all readers waiting for it.
+// It returns msg with its sequence number set.
+func (s *stream) append(msg Message) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg.Sequence = s.nextSeq()
+
+	seg := s.segments[len(s.segments)-1]
+	if seg.size >= maxSegmentSize {
+		next, err := s.createSegment(msg.Sequence)
+		if err != nil {
+			return Message{}, err
+		}
+		if err := seg.file.Sync(); err != nil {
+			slog.Warn("Failed to sync broker stream segment before rotating", slog.String("path", seg.file.Name()), sloki.WrapError(err))
+		}
+
+		s.segments = append(s.segments, next)
+		seg = next
+	}
+
+	s.buf = encodeRecord(s.buf[:0], msg)
+	if _, err := seg.file.WriteAt(s.buf, seg.size); err != nil {
+		// Remove a partially written record, so the next one is not appended behind it
+		if truncErr := seg.file.Truncate(seg.size); truncErr != nil {
+			return Message{}, errors.Join(err, truncErr)
+		}
+		return Message{}, err
+	}
+
+	s.entries = append(s.entries, streamEntry{segment: seg, offset: seg.size, size: int64(len(s.buf)), timestamp: msg.Timestamp})
+	s.bytes += uint64(len(s.buf))
+	seg.size += int64(len(s.buf))
+	s.unsynced = true
+
+	s.enforceLimits(msg.Timestamp)
+
+	close(s.appended)
+	s.appended = make(chan struct{})
+
+	return msg, nil
+}
+
+// read returns up to limit messages starting at sequence number from whose subject matches, and the sequence number to continue with.
+// Messages that were already removed by retention are skipped.
+// Once all messages were read, the returned channel is closed when the next message is appended, otherwise it is nil.
+func (s *stream) read(from uint64, match func(subject string) bool, limit int) ([]Message, uint64, <-chan struct{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	next := max(from, s.firstSeq)
+	end := min(s.nextSeq(), next+maxReadScan)
+
+	var msgs []Message
+	for ; next < end && len(msgs) < limit; next++ {
+		entry := s.entries[next-s.firstSeq]
+
+		msg, err := entry.read()
+		if err != nil {
+			return msgs, next, nil, err
+		}
+
+		if match(msg.Subject) {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	if next < s.nextSeq() {
+		return msgs, next, nil, nil
+	}
+
+	return msgs, next, s.appended, nil
+}
+
+// get returns the message with sequence number seq, or false if it was removed by retention or was not published yet.
+func (s *stream) get(seq uint64) (Message, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if seq < s.firstSeq || seq >= s.nextSeq() {
+		return Message{}, false, nil
+	}
+
+	msg, err := s.entries[seq-s.firstSeq].read()
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	return msg, true, nil
+}
+
+// read reads the message described by the entry.
+// The caller must hold one of the stream locks.
+func (e streamEntry) read() (Message, error) {
+	msg, _, err := readRecord(io.NewSectionReader(e.segment.file, e.offset, e.size))
+	return msg, err
+}
+
+// startSeq returns the sequence number of the first message at the start position.
+func (s *stream) startSeq(start StartPosition) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch start.Policy {
+	case StartNew:
+		return s.nextSeq(), nil
+	case StartAll:
+		return s.firstSeq, nil
+	case StartSequence:
+		return max(start.Sequence, s.firstSeq), nil
+	case StartTime:
+		i := sort.Search(len(s.entries), func(i int) bool {
+			return s.entries[i].timestamp >= start.Time
+		})
+		return s.firstSeq + uint64(i), nil
+	default:
+		return 0, ErrInvalidStartPosition
+	}
+}
+
+// enforceLimits removes the oldest messages until the stream is within MaxMessages and MaxBytes and no message is older than MaxAge at now.
+// Segment files are removed once none of their messages is left, except for the last one.
+// The caller must hold the write lock.
+func (s *stream) enforceLimits(now int64) {
+	removed := 0
+	for removed < len(s.entries) {
+		entry := s.entries[removed]
+		count := uint64(len(s.entries) - removed)
+
+		if (s.maxMessages > 0 && count > s.maxMessages) ||
+			(s.maxBytes > 0 && s.bytes > s.maxBytes) ||
+			(s.maxAge > 0 && now-entry.timestamp > s.maxAge.Milliseconds()) {
+			s.bytes -= uint64(entry.size)
+			removed++
+			continue
+		}
+		break
+	}
+
+	if removed == 0 {
+		return
+	}
+
+	s.entries = s.entries[removed:]
+	s.firstSeq += uint64(removed)
+
+	for len(s.segments) > 1 && (len(s.entries) == 0 || s.entries[0].segment != s.segments[0]) {
+		seg := s.segments[0]
+		s.segments = s.segments[1:]
+
+		if err := seg.file.Close(); err != nil {
+			slog.Warn("Failed to close broker stream segment", slog.String("path", seg.file.Name()), sloki.WrapError(err))
+		}
+		if err := os.Remove(seg.file.Name()); err != nil {
+			slog.Error("Failed to remove broker stream segment", slog.String("path", seg.file.Name()), sloki.WrapError(err))
+		}
+	}
+}
+
+// info returns the current state of the stream.
+func (s *stream) info() StreamInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info := StreamInfo{
+		Messages:      uint64(len(s.entries)),
+		Bytes:         s.bytes,
+		FirstSequence: s.firstSeq,
+		LastSequence:  s.nextSeq() - 1,
+		MaxMessages:   s.maxMessages,
+		MaxBytes:      s.maxBytes,
+		MaxAgeSeconds: int64(s.maxAge.Seconds()),
+	}
+	if len(s.entries) > 0 {
+		info.FirstTimestamp = s.entries[0].timestamp
+		info.LastTimestamp = s.entries[len(s.entries)-1].timestamp
+	}
+
+	return info
+}
+
+// maintain syncs the last segment to disk if it was written to and removes the messages that exceed MaxAge.
+func (s *stream) maintain(now int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxAge > 0 {
+		s.enforceLimits(now)
+	}
+
+	if !s.unsynced {
+		return nil
+	}
+	s.unsynced = false
+
+	return s.segments[len(s.segments)-1].file.Sync()
+}
+
+// close syncs and closes all segment files.
+func (s *stream) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, seg := range s.segments {
+		errs = append(errs, seg.file.Sync(), seg.file.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *stream) createSegment(firstSeq uint64) (*segment, error) {
+	f, err := os.OpenFile(s.segmentPath(firstSeq), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &segment{file: f, firstSeq: firstSeq}, nil
+}
+
+func (s *stream) segmentPath(firstSeq uint64) string {
+	return filepath.Join(s.dir, segmentFilePrefix+strconv.FormatUint(firstSeq, 10)+segmentFileSuffix)
+}
+
+// segmentSequences returns the first sequence numbers of all segment files in the directory in ascending order.
+func (s *stream) segmentSequences() ([]uint64, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seqs := make([]uint64, 0)
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, segmentFilePrefix) || !strings.HasSuffix(name, segmentFileSuffix) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, segmentFilePrefix), segmentFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	return seqs, nil
+}
+
+// encodeRecord appends a record of the message to dst.
+// Format: | payload length (4 bytes) | crc32 of payload (4 bytes) | payload (variable) |
+// Payload format: | sequence (8 bytes) | timestamp (8 bytes, unix millis) | subject length (2 bytes) | subject (variable) | ID length (2 bytes) | ID (variable) |
+// | header count (2 bytes) | header entries (variable) | data (variable) |, each header entry as | key length (2 bytes) | key (variable) | value length (2 bytes) | value (variable) |
+func encodeRecord(dst []byte, msg Message) []byte {
+	start := len(dst)
+	dst = append(dst, make([]byte, recordHeaderSize)...)
+
+	dst = binary.LittleEndian.AppendUint64(dst, msg.Sequence)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(msg.Timestamp))
+	dst = appendRecordString(dst, msg.Subject)
+	dst = appendRecordString(dst, msg.ID)
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(len(msg.Header)))
+	for k, v := range msg.Header {
+		dst = appendRecordString(dst, k)
+		dst = appendRecordString(dst, v)
+	}
+	dst = append(dst, msg.Data...)
+
+	payload := dst[start+recordHeaderSize:]
+	binary.LittleEndian.PutUint32(dst[start:start+4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(dst[start+4:start+8], crc32.ChecksumIEEE(payload))
+
+	return dst
+}
+
+// readRecord reads the next record from r and returns its message together with the number of bytes consumed.
+// It returns io.EOF if r is exhausted at a record boundary, and io.ErrUnexpectedEOF or errCorruptRecord for torn or corrupt records.
+func readRecord(r io.Reader) (Message, int, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Message{}, 0, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+	if length < recordFixedSize || length > maxRecordSize {
+		return Message{}, 0, errCorruptRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Message{}, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return Message{}, 0, errCorruptRecord
+	}
+
+	msg := Message{
+		Sequence:  binary.LittleEndian.Uint64(payload[0:8]),
+		Timestamp: int64(binary.LittleEndian.Uint64(payload[8:16])),
+	}
+	rest := payload[16:]
+
+	var ok bool
+	if msg.Subject, rest, ok = readRecordString(rest); !ok {
+		return Message{}, 0, errCorruptRecord
+	}
+	if msg.ID, rest, ok = readRecordString(rest); !ok {
+		return Message{}, 0, errCorruptRecord
+	}
+
+	if len(rest) < 2 {
+		return Message{}, 0, errCorruptRecord
+	}
+	count := int(binary.LittleEndian.Uint16(rest[0:2]))
+	rest = rest[2:]
+	if count > 0 {
+		msg.Header = make(map[string]string, count)
+	}
+	for range count {
+		var k, v string
+		if k, rest, ok = readRecordString(rest); !ok {
+			return Message{}, 0, errCorruptRecord
+		}
+		if v, rest, ok = readRecordString(rest); !ok {
+			return Message{}, 0, errCorruptRecord
+		}
+		msg.Header[k] = v
+	}
+	msg.Data = rest
+
+	return msg, recordHeaderSize + int(length), nil
+}
+
+// appendRecordString appends s with a 2-byte length prefix to dst.
+func appendRecordString(dst []byte, s string) []byte {
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(len(s)))
+	return append(dst, s...)
+}
+
+// readRecordString reads a string with a 2-byte length prefix from data and returns it with the rest of data.
+// ok is false if data is too short.
+func readRecordString(data []byte) (s string, rest []byte, ok bool) {
+	if len(data) < 2 {
+		return "", nil, false
+	}
+	n := int(binary.LittleEndian.Uint16(data[0:2]))
+	if len(data) < 2+n {
+		return "", nil, false
+	}
+
+	return string(data[2 : 2+n]), data[2+n:], true
+}
@@ -0,0 +1,196 @@
+package brokerengine
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+)
+
+// streamSubscription delivers the messages of a stream to a subscriber, starting at a position in the stream.
+// It reads the messages from the stream instead of receiving them from Publish, so it never drops messages, no matter how far it falls behind.
+// Only messages removed by the retention limits of the stream are skipped.
+type streamSubscription struct {
+	sub     *Subscriber
+	subject string
+	tokens  []string
+	next    uint64 // Sequence number of the next message to deliver
+	stop    chan struct{}
+}
+
+// SubscribeStream subscribes to a subject and delivers the stored messages from the start position on, followed by new messages as they are published.
+// Messages are delivered in the order of their sequence numbers through the StreamCallback of the broker.
+// Returns ErrNotStream if the broker is no stream and ErrInvalidStartPosition if the start policy is unknown.
+func (b *Broker) SubscribeStream(subject string, sub *Subscriber, start StartPosition) error {
+	if b.stream == nil {
+		return ErrNotStream
+	}
+
+	next, err := b.stream.startSeq(start)
+	if err != nil {
+		return err
+	}
+
+	ss := &streamSubscription{
+		sub:     sub,
+		subject: subject,
+		tokens:  strings.Split(subject, "."),
+		next:    next,
+		stop:    make(chan struct{}),
+	}
+
+	b.streamSubsMu.Lock()
+	b.streamSubs[sub.ID] = append(b.streamSubs[sub.ID], ss)
+	b.streamSubsMu.Unlock()
+
+	b.startStreamDelivery(ss)
+
+	return nil
+}
+
+// StreamInfo returns the number of stored messages, their sequence numbers and the retention limits of the stream.
+// Returns ErrNotStream if the broker is no stream.
+func (b *Broker) StreamInfo() (StreamInfo, error) {
+	if b.stream == nil {
+		return StreamInfo{}, ErrNotStream
+	}
+
+	return b.stream.info(), nil
+}
+
+// startStreamDelivery starts the goroutine that reads the messages of the subscription from the stream and delivers them in batches.
+func (b *Broker) startStreamDelivery(ss *streamSubscription) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ss.stop:
+				return
+			default:
+			}
+
+			msgs, next, appended, err := b.stream.read(ss.next, ss.matches, b.batchSize)
+			ss.next = next
+			if err != nil {
+				slog.Error("Failed to read message from broker stream, skipping it",
+					slog.String("subscriber", ss.sub.ID),
+					slog.Uint64("sequence", next),
+					sloki.WrapError(err),
+				)
+				ss.next++
+			}
+
+			if len(msgs) > 0 {
+				if !b.isClientHealthy(ss.sub.ID) {
+					b.removeStreamSubscriptions(ss.sub.ID, ss.subject)
+					return
+				}
+
+				b.streamCallback(ss.sub, ss.subject, msgs)
+				continue
+			}
+			if appended == nil {
+				continue // Not all messages were read yet
+			}
+
+			select {
+			case <-b.stop:
+				return
+			case <-ss.stop:
+				return
+			case <-appended:
+			}
+		}
+	}()
+}
+
+// matches reports whether the subject of a message matches the subject of the subscription, including its wildcards.
+func (ss *streamSubscription) matches(subject string) bool {
+	return matchTokens(ss.tokens, subject)
+}
+
+// matchTokens reports whether subject matches the tokens of a subscribed subject, including its wildcards.
+// Like in the subscription trie, ">" matches any number of remaining tokens.
+func matchTokens(subTokens []string, subject string) bool {
+	tokens := strings.Split(subject, ".")
+
+	for i, token := range subTokens {
+		if token == ">" {
+			return true
+		}
+		if i >= len(tokens) || (token != "*" && token != tokens[i]) {
+			return false
+		}
+	}
+
+	return len(tokens) == len(subTokens)
+}
+
+// removeStreamSubscriptions stops the stream subscriptions of a subscriber to a subject.
+func (b *Broker) removeStreamSubscriptions(subID, subject string) {
+	b.streamSubsMu.Lock()
+	defer b.streamSubsMu.Unlock()
+
+	subs := slices.DeleteFunc(b.streamSubs[subID], func(ss *streamSubscription) bool {
+		if ss.subject != subject {
+			return false
+		}
+
+		close(ss.stop)
+		return true
+	})
+
+	if len(subs) == 0 {
+		delete(b.streamSubs, subID)
+	} else {
+		b.streamSubs[subID] = subs
+	}
+}
+
+// cleanupStreamSubscriptions stops the stream subscriptions of unhealthy subscribers.
+func (b *Broker) cleanupStreamSubscriptions() {
+	b.streamSubsMu.Lock()
+	defer b.streamSubsMu.Unlock()
+
+	for subID, subs := range b.streamSubs {
+		if b.isClientHealthy(subID) {
+			continue
+		}
+
+		for _, ss := range subs {
+			close(ss.stop)
+		}
+		delete(b.streamSubs, subID)
+	}
+}
+
+// startStreamJob starts the background job that syncs the stream to disk, removes messages that exceed the max age and persists the durable consumers.
+func (b *Broker) startStreamJob() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(streamJobInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				if err := b.stream.maintain(time.Now().UnixMilli()); err != nil {
+					slog.Error("Failed to sync broker stream", slog.String("dir", b.stream.dir), sloki.WrapError(err))
+				}
+				if err := b.persistConsumers(); err != nil {
+					slog.Error("Failed to persist broker consumers", slog.String("dir", b.stream.dir), sloki.WrapError(err))
+				}
+			}
+		}
+	}()
+}
@@ -2,8 +2,11 @@ package engine
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/fancyinnovations/fancyspaces/storage/internal/database"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/brokerengine"
@@ -17,12 +20,28 @@ type Service struct {
 	engines             map[string]*Entry
 	enginesMu           sync.RWMutex
 	sendBrokerMessage   func(db, coll, connID, subject string, msgs [][]byte)
+	sendStreamMessage   func(db, coll, connID, subject string, msgs [][]byte)
+	sendHeaderMessage   func(db, coll, connID, subject string, msgs [][]byte)
+	sendConsumerMessage func(db, coll, connID, consumer string, msgs [][]byte)
+	sendRequestMessage  func(db, coll, connID, subject string, msgs [][]byte)
+	sendSlowConsumer    func(db, coll, connID string, notice []byte, disconnect bool)
 	isConnectionHealthy func(connID string) bool
 }
 
 type Configuration struct {
-	DatabaseStore       *database.Store
-	SendBrokerMessage   func(db, coll, connID, subject string, msgs [][]byte)
+	DatabaseStore     *database.Store
+	SendBrokerMessage func(db, coll, connID, subject string, msgs [][]byte)
+	// SendStreamMessage sends messages of broker streams to subscribed clients, each message is encoded by encodeStreamMessages.
+	SendStreamMessage func(db, coll, connID, subject string, msgs [][]byte)
+	// SendHeaderMessage sends messages together with their metadata to clients that subscribed with header, each message is encoded by encodeHeaderMessages.
+	SendHeaderMessage func(db, coll, connID, subject string, msgs [][]byte)
+	// SendConsumerMessage sends messages of durable broker consumers to subscribed clients, each message is encoded by encodeConsumerMessages.
+	SendConsumerMessage func(db, coll, connID, consumer string, msgs [][]byte)
+	// SendRequestMessage sends broker requests to subscribed clients, each request is encoded by encodeRequests.
+	SendRequestMessage func(db, coll, connID, subject string, msgs [][]byte)
+	// SendSlowConsumer notifies a client that messages are dropped for one of its subscriptions, the notice is encoded by encodeSlowConsumerNotice.
+	// If disconnect is set, the client is disconnected afterward.
+	SendSlowConsumer    func(db, coll, connID string, notice []byte, disconnect bool)
 	IsConnectionHealthy func(connID string) bool
 }
 
@@ -31,6 +50,11 @@ func NewService(cfg Configuration) *Service {
 		dbStore:             cfg.DatabaseStore,
 		engines:             make(map[string]*Entry),
 		sendBrokerMessage:   cfg.SendBrokerMessage,
+		sendStreamMessage:   cfg.SendStreamMessage,
+		sendHeaderMessage:   cfg.SendHeaderMessage,
+		sendConsumerMessage: cfg.SendConsumerMessage,
+		sendRequestMessage:  cfg.SendRequestMessage,
+		sendSlowConsumer:    cfg.SendSlowConsumer,
 		isConnectionHealthy: cfg.IsConnectionHealthy,
 	}
 }
@@ -59,14 +83,97 @@ func (s *Service) LoadEngines() error {
 
 		switch coll.Engine {
 		case database.EngineKeyValue:
-			e = kvengine.NewEngine(kvengine.Configuration{
-				DisableTTL: coll.KVSettings != nil && coll.KVSettings.DisableTTL,
-			})
+			if coll.KVSettings == nil {
+				e = kvengine.NewEngine(kvengine.Configuration{})
+				break
+			}
+
+			policy, err := kvengine.ParseEvictionPolicy(coll.KVSettings.EvictionPolicy)
+			if err != nil {
+				slog.Warn(
+					"Invalid eviction policy for key-value collection, rejecting writes over the limits instead",
+					slog.String("database", coll.Database),
+					slog.String("collection", coll.Name),
+					slog.Any("error", err),
+				)
+				policy = kvengine.EvictionReject
+			}
+
+			cfg := kvengine.Configuration{
+				DisableTTL:     coll.KVSettings.DisableTTL,
+				MaxMemoryBytes: coll.KVSettings.MaxMemoryBytes,
+				MaxEntries:     coll.KVSettings.MaxEntries,
+				EvictionPolicy: policy,
+			}
+			if coll.KVSettings.NotificationsCollection != "" {
+				cfg.OnEvent = s.kvEventHandler(coll.Database, coll.Name, coll.KVSettings.NotificationsCollection)
+			}
+
+			if !coll.KVSettings.Persistent {
+				e = kvengine.NewEngine(cfg)
+				break
+			}
+
+			cfg.Database = coll.Database
+			cfg.Collection = coll.Name
+			cfg.SnapshotInterval = time.Duration(coll.KVSettings.SnapshotIntervalSeconds) * time.Second
+
+			e, err = kvengine.NewPersistentEngine(cfg)
+			if err != nil {
+				slog.Error(
+					"Failed to initialize persistent key-value engine for collection",
+					slog.String("database", coll.Database),
+					slog.String("collection", coll.Name),
+					slog.Any("error", err),
+				)
+				continue
+			}
 		case database.EngineObject:
-			e, err = objectengine.NewBucket(objectengine.Configuration{
+			cfg := objectengine.Configuration{
 				Database:   coll.Database,
 				Collection: coll.Name,
-			})
+			}
+			if coll.ObjectSettings != nil {
+				policy, err := objectengine.ParseSyncPolicy(coll.ObjectSettings.SyncPolicy)
+				if err != nil {
+					slog.Warn(
+						"Invalid sync policy for object collection, using the default instead",
+						slog.String("database", coll.Database),
+						slog.String("collection", coll.Name),
+						slog.Any("error", err),
+					)
+				}
+				cfg.SyncPolicy = policy
+				cfg.Versioning = coll.ObjectSettings.Versioning
+				cfg.MaxVersions = coll.ObjectSettings.MaxVersions
+				cfg.VersionRetention = time.Duration(coll.ObjectSettings.VersionRetentionDays) * 24 * time.Hour
+				cfg.CompactionInterval = time.Duration(coll.ObjectSettings.CompactionIntervalSeconds) * time.Second
+				cfg.CompactionGarbageRatio = coll.ObjectSettings.CompactionGarbageRatio
+				cfg.CompactionRateLimit = coll.ObjectSettings.CompactionRateLimitBytes
+
+				for _, rule := range coll.ObjectSettings.LifecycleRules {
+					basis, err := objectengine.ParseLifecycleBasis(rule.Basis)
+					if err != nil || rule.MaxAgeSeconds <= 0 {
+						slog.Warn(
+							"Invalid lifecycle rule for object collection, ignoring it",
+							slog.String("database", coll.Database),
+							slog.String("collection", coll.Name),
+							slog.String("prefix", rule.Prefix),
+							slog.Int64("max_age_seconds", rule.MaxAgeSeconds),
+							slog.Any("error", err),
+						)
+						continue
+					}
+
+					cfg.LifecycleRules = append(cfg.LifecycleRules, objectengine.LifecycleRule{
+						Prefix: rule.Prefix,
+						MaxAge: time.Duration(rule.MaxAgeSeconds) * time.Second,
+						Basis:  basis,
+					})
+				}
+			}
+
+			e, err = objectengine.NewBucket(cfg)
 			if err != nil {
 				slog.Error(
 					"Failed to initialize object engine for collection",
@@ -77,12 +184,48 @@ func (s *Service) LoadEngines() error {
 				continue
 			}
 		case database.EngineBroker:
-			e = brokerengine.NewBroker(brokerengine.Configuration{
+			cfg := brokerengine.Configuration{
 				PublishCallback: func(sub *brokerengine.Subscriber, subject string, msgs [][]byte) {
 					s.sendBrokerMessage(coll.Database, coll.Name, sub.ID, subject, msgs)
 				},
+				HeaderCallback: func(sub *brokerengine.Subscriber, subject string, msgs []brokerengine.Message) {
+					s.sendHeaderMessage(coll.Database, coll.Name, sub.ID, subject, encodeHeaderMessages(msgs))
+				},
+				RequestCallback: func(sub *brokerengine.Subscriber, subject string, reqs []brokerengine.Request) {
+					s.sendRequestMessage(coll.Database, coll.Name, sub.ID, subject, encodeRequests(reqs))
+				},
+				SlowConsumerCallback: func(sub *brokerengine.Subscriber, subject string, dropped uint64) {
+					s.sendSlowConsumer(coll.Database, coll.Name, sub.ID, encodeSlowConsumerNotice(sub, subject, dropped), sub.Policy == brokerengine.Disconnect)
+				},
 				IsClientHealthy: s.isConnectionHealthy,
-			})
+			}
+			if coll.BrokerSettings == nil || !coll.BrokerSettings.Stream {
+				e = brokerengine.NewBroker(cfg)
+				break
+			}
+
+			cfg.Database = coll.Database
+			cfg.Collection = coll.Name
+			cfg.StreamCallback = func(sub *brokerengine.Subscriber, subject string, msgs []brokerengine.Message) {
+				s.sendStreamMessage(coll.Database, coll.Name, sub.ID, subject, encodeStreamMessages(msgs))
+			}
+			cfg.ConsumerCallback = func(sub *brokerengine.Subscriber, consumer string, msgs []brokerengine.ConsumerMessage) {
+				s.sendConsumerMessage(coll.Database, coll.Name, sub.ID, consumer, encodeConsumerMessages(msgs))
+			}
+			cfg.MaxMessages = coll.BrokerSettings.MaxMessages
+			cfg.MaxBytes = coll.BrokerSettings.MaxBytes
+			cfg.MaxAge = time.Duration(coll.BrokerSettings.MaxAgeSeconds) * time.Second
+
+			e, err = brokerengine.NewStreamBroker(cfg)
+			if err != nil {
+				slog.Error(
+					"Failed to initialize broker stream for collection",
+					slog.String("database", coll.Database),
+					slog.String("collection", coll.Name),
+					slog.Any("error", err),
+				)
+				continue
+			}
 		}
 
 		if e == nil {
@@ -121,6 +264,31 @@ func (s *Service) GetEngine(db, coll string) (*Entry, error) {
 	return entry, nil
 }
 
+// Close closes all loaded engines, so that pending data is flushed to disk and background jobs are stopped.
+func (s *Service) Close() error {
+	s.enginesMu.Lock()
+	defer s.enginesMu.Unlock()
+
+	var errs []error
+	for key, entry := range s.engines {
+		var err error
+		switch entry.Type {
+		case database.EngineKeyValue:
+			err = entry.AsKeyValueEngine().Close()
+		case database.EngineObject:
+			err = entry.AsObjectEngine().Close()
+		case database.EngineBroker:
+			err = entry.AsBrokerEngine().Close()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("close engine %s.%s: %w", entry.Database, entry.Collection, err))
+		}
+		delete(s.engines, key)
+	}
+
+	return errors.Join(errs...)
+}
+
 func toKey(database, collection string) string {
 	return database + "_" + collection
 }
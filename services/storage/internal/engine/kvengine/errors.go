@@ -0,0 +1,15 @@
+package kvengine
+
+import "errors"
+
+var (
+	ErrKeyNotFound    = errors.New("key not found")
+	ErrTypeMismatch   = errors.New("value type does not support this operation")
+	ErrOverflow       = errors.New("numeric overflow")
+	ErrDivisionByZero = errors.New("division by zero")
+	ErrInvalidOperand = errors.New("invalid operand")
+	ErrOutOfRange     = errors.New("index out of range")
+
+	ErrRevisionMismatch = errors.New("revision mismatch")
+	ErrLimitExceeded    = errors.New("memory or entry limit of the collection reached")
+)
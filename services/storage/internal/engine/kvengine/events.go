@@ -0,0 +1,81 @@
+package kvengine
+
+import (
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+)
+
+// EventType is the kind of change an Event describes.
+type EventType string
+
+const (
+	// EventSet is emitted whenever a key is written, including list, map, string and numeric operations.
+	EventSet EventType = "set"
+	// EventDelete is emitted when a key is deleted by a command.
+	EventDelete EventType = "delete"
+	// EventExpire is emitted when an expired key is removed by the cleanup job.
+	EventExpire EventType = "expire"
+	// EventEvict is emitted when a key is evicted to stay within the limits of the engine.
+	EventEvict EventType = "evict"
+)
+
+// Event describes a change of a single key.
+type Event struct {
+	Type EventType
+	Key  string
+
+	// Value and Revision are the new value and revision for EventSet, nil and 0 otherwise.
+	Value    *codex.Value
+	Revision uint64
+}
+
+// eventQueueSize is the number of events that are buffered for the event handler, before further events are dropped.
+const eventQueueSize = 4096
+
+// EventHandler receives the events of an engine, see Configuration.OnEvent.
+// It is called from a single goroutine of the engine in the order of the changes, so slow handlers delay later events.
+// If the handler does not keep up, events are dropped and counted in Stats.DroppedEvents.
+type EventHandler func(event Event)
+
+// notify queues an event for the event handler of the engine, if there is one.
+// The caller must hold the shard write lock, so that events of a key are queued in the order of the changes.
+// It never blocks: if the queue is full, the event is dropped.
+func (e *Engine) notify(eventType EventType, key string, en *entry) {
+	if e.events == nil {
+		return
+	}
+
+	event := Event{
+		Type: eventType,
+		Key:  key,
+	}
+	if eventType == EventSet {
+		event.Value = en.value
+		event.Revision = en.revision
+	}
+
+	select {
+	case e.events <- event:
+	default:
+		e.droppedEvents.Add(1)
+	}
+}
+
+// dispatchEvents passes the queued events to the event handler until the engine is closed.
+// Events that are still queued when the engine is closed are delivered before it returns.
+func (e *Engine) dispatchEvents() {
+	for {
+		select {
+		case event := <-e.events:
+			e.onEvent(event)
+		case <-e.stop:
+			for {
+				select {
+				case event := <-e.events:
+					e.onEvent(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package kvengine
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+)
+
+// EvictionPolicy decides what happens when a write would exceed the memory or entry limit of an engine.
+type EvictionPolicy string
+
+const (
+	// EvictionReject rejects writes that would exceed a limit with ErrLimitExceeded. This is the default.
+	EvictionReject EvictionPolicy = "reject"
+	// EvictionLRU evicts the least recently used entries.
+	EvictionLRU EvictionPolicy = "lru"
+	// EvictionLFU evicts the least frequently used entries.
+	EvictionLFU EvictionPolicy = "lfu"
+	// EvictionRandom evicts random entries.
+	EvictionRandom EvictionPolicy = "random"
+	// EvictionVolatileTTL evicts the entries that expire first. Entries without expiration are only evicted if no entry with one is found.
+	EvictionVolatileTTL EvictionPolicy = "volatile-ttl"
+)
+
+const (
+	// evictionSamples is the number of keys sampled for every evicted entry.
+	// Shards are visited until enough keys are sampled, so small engines are searched completely.
+	evictionSamples = 20
+	// evictionSampleKeys is the maximum number of keys sampled per shard.
+	evictionSampleKeys = 5
+	// maxEvictionsPerWrite bounds the work a single write spends on eviction; later writes continue where it stopped.
+	maxEvictionsPerWrite = 64
+)
+
+// ParseEvictionPolicy parses the name of an eviction policy. An empty name is EvictionReject.
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	switch policy := EvictionPolicy(name); policy {
+	case "":
+		return EvictionReject, nil
+	case EvictionReject, EvictionLRU, EvictionLFU, EvictionRandom, EvictionVolatileTTL:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown eviction policy %q", name)
+	}
+}
+
+// Stats is a snapshot of the usage and the limits of an engine.
+type Stats struct {
+	Entries        uint64 `json:"entries"`
+	MemoryBytes    uint64 `json:"memory_bytes"`
+	MaxEntries     uint64 `json:"max_entries"`
+	MaxMemoryBytes uint64 `json:"max_memory_bytes"`
+	EvictionPolicy string `json:"eviction_policy"`
+
+	// Evictions is the number of entries evicted to stay within the limits.
+	Evictions uint64 `json:"evictions"`
+	// RejectedWrites is the number of writes rejected with ErrLimitExceeded.
+	RejectedWrites uint64 `json:"rejected_writes"`
+	// Expirations is the number of expired entries removed by the cleanup job.
+	Expirations uint64 `json:"expirations"`
+	// DroppedEvents is the number of events dropped, because the event handler did not keep up.
+	DroppedEvents uint64 `json:"dropped_events"`
+}
+
+// Stats returns the current usage, limits and eviction counters of the engine.
+// Memory is accounted as the size of keys and encoded values, see Size.
+func (e *Engine) Stats() Stats {
+	return Stats{
+		Entries:        uint64(max(e.entries.Load(), 0)),
+		MemoryBytes:    uint64(max(e.memory.Load(), 0)),
+		MaxEntries:     uint64(e.maxEntries),
+		MaxMemoryBytes: uint64(e.maxMemory),
+		EvictionPolicy: string(e.evictionPolicy),
+		Evictions:      e.evictions.Load(),
+		RejectedWrites: e.rejectedWrites.Load(),
+		Expirations:    e.expirations.Load(),
+		DroppedEvents:  e.droppedEvents.Load(),
+	}
+}
+
+// limited reports whether the engine has a memory or entry limit.
+func (e *Engine) limited() bool {
+	return e.maxMemory > 0 || e.maxEntries > 0
+}
+
+// overLimit reports whether the engine currently exceeds one of its limits.
+func (e *Engine) overLimit() bool {
+	return (e.maxMemory > 0 && e.memory.Load() > e.maxMemory) ||
+		(e.maxEntries > 0 && e.entries.Load() > e.maxEntries)
+}
+
+// admit checks whether a write of an entry with the given size, which changes the accounted memory and the number of entries by the given deltas, is allowed.
+// With EvictionReject, writes that would exceed a limit are rejected. With the other policies, only entries that can never fit are rejected,
+// the engine evicts other entries after the write instead.
+// The check is not atomic with writes to other shards, so concurrent writes may exceed a limit by a few entries.
+func (e *Engine) admit(size, memoryDelta, entriesDelta int64) error {
+	if !e.limited() {
+		return nil
+	}
+
+	exceeds := e.maxMemory > 0 && size > e.maxMemory
+	if e.evictionPolicy == EvictionReject {
+		exceeds = exceeds ||
+			(e.maxMemory > 0 && memoryDelta > 0 && e.memory.Load()+memoryDelta > e.maxMemory) ||
+			(e.maxEntries > 0 && entriesDelta > 0 && e.entries.Load()+entriesDelta > e.maxEntries)
+	}
+
+	if exceeds {
+		e.rejectedWrites.Add(1)
+		return ErrLimitExceeded
+	}
+
+	return nil
+}
+
+// evict removes entries chosen by the eviction policy until the engine is within its limits again.
+// locked is the shard held by the caller (may be nil) and keep the key that was just written, which is never evicted.
+// Other shards are only locked if they are not busy, so eviction never waits for other writers and cannot deadlock,
+// shards held by the caller (e.g. in a transaction) are skipped.
+func (e *Engine) evict(locked *shard, keep string) {
+	if !e.limited() || e.evictionPolicy == EvictionReject {
+		return
+	}
+
+	for i := 0; i < maxEvictionsPerWrite && e.overLimit(); i++ {
+		if !e.evictOne(locked, keep) {
+			return
+		}
+	}
+}
+
+// evictOne samples entries from a few random shards and evicts the best candidate of the policy.
+// Returns false if no candidate was found or it could not be removed.
+func (e *Engine) evictOne(locked *shard, keep string) bool {
+	var victim *shard
+	var victimKey string
+	var victimEntry *entry
+	victimScore := int64(math.MaxInt64)
+
+	sampled := 0
+	start := rand.IntN(ShardCount)
+	for i := 0; i < ShardCount && sampled < evictionSamples; i++ {
+		s := &e.shards[(start+i)%ShardCount]
+		if s != locked && !s.mu.TryLock() {
+			continue
+		}
+
+		key, en, score, n := e.sampleShard(s, keep)
+		sampled += n
+		if en != nil && (victimEntry == nil || score < victimScore) {
+			victim, victimKey, victimEntry, victimScore = s, key, en, score
+		}
+
+		if s != locked {
+			s.mu.Unlock()
+		}
+	}
+
+	if victimEntry == nil {
+		return false
+	}
+
+	if victim != locked {
+		if !victim.mu.TryLock() {
+			return true
+		}
+		defer victim.mu.Unlock()
+	}
+
+	// the candidate may have been replaced while the shard was not locked
+	if victim.data[victimKey] != victimEntry {
+		return true
+	}
+
+	if err := e.remove(victim, victimKey, EventEvict); err != nil {
+		slog.Error("Failed to evict key-value entry", slog.String("key", victimKey), sloki.WrapError(err))
+		return false
+	}
+	e.evictions.Add(1)
+
+	return true
+}
+
+// sampleShard returns the best eviction candidate among a few keys of s (lower scores are evicted first) and the number of sampled keys.
+// The caller must hold the shard write lock.
+func (e *Engine) sampleShard(s *shard, keep string) (string, *entry, int64, int) {
+	var bestKey string
+	var best *entry
+	bestScore := int64(math.MaxInt64)
+
+	n := 0
+	// map iteration order is random, so the first keys are a random sample
+	for key, en := range s.data {
+		if n == evictionSampleKeys {
+			break
+		}
+		if key == keep {
+			continue
+		}
+		n++
+
+		score := e.evictionScore(en)
+		if best == nil || score < bestScore {
+			bestKey, best, bestScore = key, en, score
+		}
+	}
+
+	return bestKey, best, bestScore, n
+}
+
+// evictionScore ranks an entry for eviction according to the policy, entries with lower scores are evicted first.
+func (e *Engine) evictionScore(en *entry) int64 {
+	switch e.evictionPolicy {
+	case EvictionLRU:
+		return en.lastAccess.Load()
+	case EvictionLFU:
+		return int64(en.hits.Load())
+	case EvictionVolatileTTL:
+		if en.expires == 0 {
+			return math.MaxInt64
+		}
+		return en.expires
+	default:
+		return 0
+	}
+}
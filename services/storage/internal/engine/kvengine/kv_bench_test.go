@@ -171,3 +171,107 @@ func BenchmarkEngine_ParallelSet(b *testing.B) {
 		}
 	})
 }
+
+// newCleanupBenchEngine returns an engine with many entries without expiration and a few with one.
+func newCleanupBenchEngine(b *testing.B) *Engine {
+	b.Helper()
+
+	e := newTestEngine()
+	e.disableTTL = false // keep expiration times, but do not start the cleanup job
+	val := testValue()
+	expires := time.Now().Add(time.Hour).UnixNano()
+
+	for i := 0; i < 1_000_000; i++ {
+		e.Set(fmt.Sprintf("key-%d", i), val)
+	}
+	for i := 0; i < 1_000; i++ {
+		e.SetWithTTL(fmt.Sprintf("ttl-key-%d", i), val, expires)
+	}
+
+	return e
+}
+
+// fullSweep is the previous cleanup, which locked every shard and visited every entry. It is kept as a baseline.
+func fullSweep(e *Engine) {
+	for i := 0; i < ShardCount; i++ {
+		s := &e.shards[i]
+		s.mu.Lock()
+		now := time.Now().UnixNano()
+		for key, en := range s.data {
+			if en.expires > 0 && en.expires <= now {
+				e.drop(s, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func BenchmarkEngine_CleanupFullSweep(b *testing.B) {
+	e := newCleanupBenchEngine(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fullSweep(e)
+	}
+}
+
+func BenchmarkEngine_Cleanup(b *testing.B) {
+	e := newCleanupBenchEngine(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.cleanup()
+	}
+}
+
+func BenchmarkEngine_CleanupExpiring(b *testing.B) {
+	e := newCleanupBenchEngine(b)
+	val := testValue()
+	expired := time.Now().Add(-time.Second).UnixNano()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.SetWithTTL(fmt.Sprintf("expired-key-%d", i), val, expired)
+		e.cleanup()
+	}
+}
+
+func BenchmarkEngine_ParallelGetDuringFullSweep(b *testing.B) {
+	benchmarkParallelGetDuring(b, fullSweep)
+}
+
+func BenchmarkEngine_ParallelGetDuringCleanup(b *testing.B) {
+	benchmarkParallelGetDuring(b, (*Engine).cleanup)
+}
+
+// benchmarkParallelGetDuring measures reads while cleanup runs continuously in the background.
+func benchmarkParallelGetDuring(b *testing.B, cleanup func(e *Engine)) {
+	e := newCleanupBenchEngine(b)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				cleanup(e)
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_ = e.Get(fmt.Sprintf("key-%d", i%1_000_000))
+			i++
+		}
+	})
+	b.StopTimer()
+
+	close(stop)
+	<-done
+}
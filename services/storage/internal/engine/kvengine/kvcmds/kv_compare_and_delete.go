@@ -0,0 +1,33 @@
+package kvcmds
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// handleCompareAndDelete implements the server side of the protocol.ServerCommandKVCompareAndDelete command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Expected Revision (8 bytes) |
+// Response payload: | Revision (8 bytes) | (0 on success, the current revision on conflict)
+func (c *Commands) handleCompareAndDelete(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if len(rest) < 8 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for revision"),
+		}, nil
+	}
+
+	revision, err := kve.CompareAndDelete(key, binary.BigEndian.Uint64(rest[0:8]))
+	return revisionResponse(revision, err), nil
+}
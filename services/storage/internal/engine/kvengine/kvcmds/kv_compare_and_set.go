@@ -0,0 +1,63 @@
+package kvcmds
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/kvengine"
+)
+
+// handleCompareAndSet implements the server side of the protocol.ServerCommandKVCompareAndSet command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Expected Revision (8 bytes) | Expires At (8 bytes, 0 = no expiration) | Value (codex-encoded) |
+// Response payload: | Revision (8 bytes) | (the new revision on success, the current revision on conflict)
+func (c *Commands) handleCompareAndSet(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if len(rest) < 16 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for revision and expiration"),
+		}, nil
+	}
+
+	expected := binary.BigEndian.Uint64(rest[0:8])
+	expiresAt := int64(binary.BigEndian.Uint64(rest[8:16]))
+
+	value, err := codex.DecodeValue(rest[16:])
+	if err != nil {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid value encoding: " + err.Error()),
+		}, nil
+	}
+
+	revision, err := kve.CompareAndSetTTL(key, expected, value, expiresAt)
+	return revisionResponse(revision, err), nil
+}
+
+// revisionResponse builds the response of a conditional write from the revision and error returned by the engine.
+func revisionResponse(revision uint64, err error) *protocol.Response {
+	code := protocol.StatusOK
+	if err != nil {
+		if !errors.Is(err, kvengine.ErrRevisionMismatch) {
+			return errorResponse(err)
+		}
+		code = protocol.StatusConflict
+	}
+
+	return &protocol.Response{
+		Code:    code,
+		Payload: binary.BigEndian.AppendUint64(nil, revision),
+	}
+}
@@ -56,7 +56,9 @@ func (c *Commands) handleDelete(_ *command.ConnCtx, _ *protocol.Message, cmd *pr
 
 	key := string(data[2 : 2+keyLen])
 
-	kve.Delete(key)
+	if err := kve.Delete(key); err != nil {
+		return errorResponse(err), nil
+	}
 
 	return &protocol.Response{
 		Code:    protocol.StatusOK,
@@ -78,7 +80,11 @@ func (c *Commands) handleDeleteHTTP(w http.ResponseWriter, r *http.Request, _ *d
 		return
 	}
 
-	kve.Delete(req.Key)
+	if err := kve.Delete(req.Key); err != nil {
+		slog.Error("Failed to delete key", sloki.WrapError(err))
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/OliverSchlueter/goutils/problems"
 	"github.com/OliverSchlueter/goutils/sloki"
 	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
 	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
@@ -35,14 +36,20 @@ func (c *Commands) handleDeleteAll(_ *command.ConnCtx, _ *protocol.Message, cmd
 	}
 	kve := e.AsKeyValueEngine()
 
-	kve.DeleteAll()
+	if err := kve.DeleteAll(); err != nil {
+		return errorResponse(err), nil
+	}
 
 	return commonresponses.OK, nil
 }
 
 // handleDeleteAllHTTP implements the server side of the protocol.ServerCommandKVDeleteAll command over HTTP.
 func (c *Commands) handleDeleteAllHTTP(w http.ResponseWriter, _ *http.Request, _ *database.Database, _ *database.Collection, kve *kvengine.Engine) {
-	kve.DeleteAll()
+	if err := kve.DeleteAll(); err != nil {
+		slog.Error("Failed to delete all keys", sloki.WrapError(err))
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -58,7 +58,9 @@ func (c *Commands) handleDeleteMultiple(_ *command.ConnCtx, _ *protocol.Message,
 		keys[i] = kv.AsString()
 	}
 
-	kve.DeleteMultiple(keys)
+	if err := kve.DeleteMultiple(keys); err != nil {
+		return errorResponse(err), nil
+	}
 
 	return commonresponses.OK, nil
 }
@@ -82,7 +84,11 @@ func (c *Commands) handleDeleteMultipleHTTP(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	kve.DeleteMultiple(req.Keys)
+	if err := kve.DeleteMultiple(req.Keys); err != nil {
+		slog.Error("Failed to delete keys", sloki.WrapError(err))
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
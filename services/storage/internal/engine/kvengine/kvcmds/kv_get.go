@@ -78,7 +78,8 @@ type getRequestHTTP struct {
 
 // getResponseHTTP is the response format for the get command over HTTP.
 type getResponseHTTP struct {
-	Value any `json:"value"`
+	Value    any    `json:"value"`
+	Revision uint64 `json:"revision"`
 }
 
 // handleGetHTTP implements the server side of the protocol.ServerCommandKVGet command over HTTP.
@@ -90,7 +91,7 @@ func (c *Commands) handleGetHTTP(w http.ResponseWriter, r *http.Request, _ *data
 		return
 	}
 
-	val := kve.Get(req.Key)
+	val, revision := kve.GetWithRevision(req.Key)
 	if val == nil {
 		problems.NotFound("key", "Key not found").WriteToHTTP(w)
 		return
@@ -104,7 +105,8 @@ func (c *Commands) handleGetHTTP(w http.ResponseWriter, r *http.Request, _ *data
 	}
 
 	resp := getResponseHTTP{
-		Value: data,
+		Value:    data,
+		Revision: revision,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
@@ -0,0 +1,41 @@
+package kvcmds
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// handleGetWithRevision implements the server side of the protocol.ServerCommandKVGetWithRevision command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) |
+// Response payload: | Revision (8 bytes) | Value (codex-encoded) |
+func (c *Commands) handleGetWithRevision(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, _, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	val, revision := kve.GetWithRevision(key)
+	if val == nil {
+		return &protocol.Response{
+			Code:    protocol.StatusNotFound,
+			Payload: *commonresponses.EmptyPayload,
+		}, nil
+	}
+
+	payload := binary.BigEndian.AppendUint64(make([]byte, 0, 8+codex.SizeOfValue(val)), revision)
+	payload = append(payload, codex.EncodeValue(val)...)
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: payload,
+	}, nil
+}
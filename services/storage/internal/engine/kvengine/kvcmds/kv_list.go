@@ -0,0 +1,219 @@
+package kvcmds
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// readIndex reads a signed list index (| Index (4 bytes) |) from the start of data.
+func readIndex(data []byte) (int, []byte, *protocol.Response) {
+	if len(data) < 4 {
+		return 0, nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for index"),
+		}
+	}
+
+	return int(int32(binary.BigEndian.Uint32(data[0:4]))), data[4:], nil
+}
+
+// handleListLength implements the server side of the protocol.ServerCommandKVListLength command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) |
+// Response payload: | Length (4 bytes) |
+func (c *Commands) handleListLength(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, _, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	length, err := kve.ListLength(key)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, length)
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: payload,
+	}, nil
+}
+
+// handleListGet implements the server side of the protocol.ServerCommandKVListGet command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Index (4 bytes, signed) |
+// Response payload: | Item (codex-encoded) |
+func (c *Commands) handleListGet(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	index, _, resp := readIndex(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	val, err := kve.ListGet(key, index)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeValue(val),
+	}, nil
+}
+
+// handleListSet implements the server side of the protocol.ServerCommandKVListSet command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Index (4 bytes, signed) | Item (codex-encoded) |
+func (c *Commands) handleListSet(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	index, rest, resp := readIndex(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	value, err := codex.DecodeValue(rest)
+	if err != nil {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid value encoding: " + err.Error()),
+		}, nil
+	}
+
+	if err := kve.ListSet(key, index, value); err != nil {
+		return errorResponse(err), nil
+	}
+
+	return commonresponses.OK, nil
+}
+
+// handleListRemove implements the server side of the protocol.ServerCommandKVListRemove command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Index (4 bytes, signed) |
+// Response payload: | Removed Item (codex-encoded) |
+func (c *Commands) handleListRemove(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	index, _, resp := readIndex(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	val, err := kve.ListRemove(key, index)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeValue(val),
+	}, nil
+}
+
+// handleListPush returns the handler for the protocol.ServerCommandKVListLeftPush and protocol.ServerCommandKVListRightPush commands.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Items (codex-encoded list) |
+// Response payload: | New Length (4 bytes) |
+func (c *Commands) handleListPush(left bool) command.Handler {
+	return func(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+		kve, resp := c.keyValueEngine(cmd)
+		if resp != nil {
+			return resp, nil
+		}
+
+		key, rest, resp := readKey(cmd.Payload)
+		if resp != nil {
+			return resp, nil
+		}
+
+		values, err := codex.DecodeList(rest)
+		if err != nil {
+			return &protocol.Response{
+				Code:    protocol.StatusBadRequest,
+				Payload: []byte("invalid items encoding: " + err.Error()),
+			}, nil
+		}
+
+		var length uint32
+		if left {
+			length, err = kve.ListLeftPush(key, values)
+		} else {
+			length, err = kve.ListRightPush(key, values)
+		}
+		if err != nil {
+			return errorResponse(err), nil
+		}
+
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, length)
+
+		return &protocol.Response{
+			Code:    protocol.StatusOK,
+			Payload: payload,
+		}, nil
+	}
+}
+
+// handleListPop returns the handler for the protocol.ServerCommandKVListLeftPop and protocol.ServerCommandKVListRightPop commands.
+// Payload format: | Key Length (2 bytes) | Key (variable) |
+// Response payload: | Popped Item (codex-encoded) |
+func (c *Commands) handleListPop(left bool) command.Handler {
+	return func(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+		kve, resp := c.keyValueEngine(cmd)
+		if resp != nil {
+			return resp, nil
+		}
+
+		key, _, resp := readKey(cmd.Payload)
+		if resp != nil {
+			return resp, nil
+		}
+
+		var val *codex.Value
+		var err error
+		if left {
+			val, err = kve.ListLeftPop(key)
+		} else {
+			val, err = kve.ListRightPop(key)
+		}
+		if err != nil {
+			return errorResponse(err), nil
+		}
+
+		return &protocol.Response{
+			Code:    protocol.StatusOK,
+			Payload: codex.EncodeValue(val),
+		}, nil
+	}
+}
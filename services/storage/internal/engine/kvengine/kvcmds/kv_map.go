@@ -0,0 +1,229 @@
+package kvcmds
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// readField reads a length-prefixed map field (| Field Length (2 bytes) | Field (variable) |) from the start of data.
+func readField(data []byte) (string, []byte, *protocol.Response) {
+	if len(data) < 2 {
+		return "", nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length"),
+		}
+	}
+
+	fieldLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+fieldLen {
+		return "", nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for field"),
+		}
+	}
+
+	return string(data[2 : 2+fieldLen]), data[2+fieldLen:], nil
+}
+
+// handleMapLength implements the server side of the protocol.ServerCommandKVMapLength command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) |
+// Response payload: | Length (4 bytes) |
+func (c *Commands) handleMapLength(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, _, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	length, err := kve.MapLength(key)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, length)
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: payload,
+	}, nil
+}
+
+// handleMapSet implements the server side of the protocol.ServerCommandKVMapSet command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Field Length (2 bytes) | Field (variable) | Value (codex-encoded) |
+func (c *Commands) handleMapSet(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	field, rest, resp := readField(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	value, err := codex.DecodeValue(rest)
+	if err != nil {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid value encoding: " + err.Error()),
+		}, nil
+	}
+
+	if err := kve.MapSet(key, field, value); err != nil {
+		return errorResponse(err), nil
+	}
+
+	return commonresponses.OK, nil
+}
+
+// handleMapGet implements the server side of the protocol.ServerCommandKVMapGet command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Field Length (2 bytes) | Field (variable) |
+// Response payload: | Value (codex-encoded) |
+func (c *Commands) handleMapGet(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	field, _, resp := readField(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	val, err := kve.MapGet(key, field)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeValue(val),
+	}, nil
+}
+
+// handleMapDelete implements the server side of the protocol.ServerCommandKVMapDelete command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Field Length (2 bytes) | Field (variable) |
+func (c *Commands) handleMapDelete(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	field, _, resp := readField(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if _, err := kve.MapDelete(key, field); err != nil {
+		return errorResponse(err), nil
+	}
+
+	return commonresponses.OK, nil
+}
+
+// handleMapExists implements the server side of the protocol.ServerCommandKVMapExists command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Field Length (2 bytes) | Field (variable) |
+func (c *Commands) handleMapExists(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	field, _, resp := readField(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	exists, err := kve.MapExists(key, field)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	if !exists {
+		return &protocol.Response{
+			Code:    protocol.StatusNotFound,
+			Payload: *commonresponses.EmptyPayload,
+		}, nil
+	}
+
+	return commonresponses.OK, nil
+}
+
+// handleMapKeys implements the server side of the protocol.ServerCommandKVMapKeys command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) |
+// Response payload: | Fields (codex-encoded list of strings) |
+func (c *Commands) handleMapKeys(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, _, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	keys, err := kve.MapKeys(key)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeValue(codex.NewStringListValue(keys)),
+	}, nil
+}
+
+// handleMapValues implements the server side of the protocol.ServerCommandKVMapValues command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) |
+// Response payload: | Values (codex-encoded list) |
+func (c *Commands) handleMapValues(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, _, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	values, err := kve.MapValues(key)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeList(values),
+	}, nil
+}
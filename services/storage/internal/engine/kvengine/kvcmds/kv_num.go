@@ -0,0 +1,47 @@
+package kvcmds
+
+import (
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/kvengine"
+)
+
+// handleNumOp returns the handler for the protocol.ServerCommandKVNum* commands, which atomically apply op to a numeric value.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Operand (codex-encoded, omitted for bitwise not) |
+// Response payload: | New Value (codex-encoded) |
+func (c *Commands) handleNumOp(op kvengine.NumOp) command.Handler {
+	return func(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+		kve, resp := c.keyValueEngine(cmd)
+		if resp != nil {
+			return resp, nil
+		}
+
+		key, rest, resp := readKey(cmd.Payload)
+		if resp != nil {
+			return resp, nil
+		}
+
+		var operand *codex.Value
+		if op != kvengine.NumOpBitwiseNot {
+			var err error
+			operand, err = codex.DecodeValue(rest)
+			if err != nil {
+				return &protocol.Response{
+					Code:    protocol.StatusBadRequest,
+					Payload: []byte("invalid operand encoding: " + err.Error()),
+				}, nil
+			}
+		}
+
+		val, err := kve.ApplyNumOp(key, op, operand)
+		if err != nil {
+			return errorResponse(err), nil
+		}
+
+		return &protocol.Response{
+			Code:    protocol.StatusOK,
+			Payload: codex.EncodeValue(val),
+		}, nil
+	}
+}
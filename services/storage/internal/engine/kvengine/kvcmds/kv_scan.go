@@ -0,0 +1,113 @@
+package kvcmds
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/kvengine"
+)
+
+// handleScan implements the server side of the protocol.ServerCommandKVScan command over TCP.
+// Payload format: | Cursor Length (4 bytes) | Cursor (variable) | Count (4 bytes) | Pattern Length (2 bytes) | Pattern (variable) |
+// Response payload: | Next Cursor Length (4 bytes) | Next Cursor (variable) | Keys (codex-encoded list of strings) |
+// The cursor length has 4 bytes, because cursors contain a key and are longer than the longest key.
+func (c *Commands) handleScan(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	data := cmd.Payload
+	if len(data) < 4 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length"),
+		}, nil
+	}
+	cursorLen := int(binary.BigEndian.Uint32(data[0:4]))
+	if cursorLen > len(data)-4 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for cursor"),
+		}, nil
+	}
+	rest := data[4+cursorLen:]
+
+	cursor, ok := decodeScanCursor(data[4 : 4+cursorLen])
+	if !ok {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid cursor"),
+		}, nil
+	}
+
+	if len(rest) < 4 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for count"),
+		}, nil
+	}
+	count := int(binary.BigEndian.Uint32(rest[0:4]))
+
+	pattern, _, resp := readKey(rest[4:])
+	if resp != nil {
+		return resp, nil
+	}
+
+	keys, next := kve.Scan(cursor, count, pattern)
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: encodeScanResponse(keys, next),
+	}, nil
+}
+
+// encodeScanResponse encodes the response payload of a scan page, see handleScan.
+func encodeScanResponse(keys []string, next kvengine.ScanCursor) []byte {
+	nextCursor := encodeScanCursor(next)
+	payload := binary.BigEndian.AppendUint32(nil, uint32(len(nextCursor)))
+	payload = append(payload, nextCursor...)
+
+	return append(payload, codex.EncodeValue(codex.NewStringListValue(keys))...)
+}
+
+// encodeScanCursor encodes a scan cursor for the client.
+// Format: | Shard Index (2 bytes) | Has Last Key (1 byte) | Last Key (variable) |
+// A finished scan is encoded as an empty cursor.
+func encodeScanCursor(cursor kvengine.ScanCursor) []byte {
+	if cursor.Done() {
+		return nil
+	}
+
+	data := binary.BigEndian.AppendUint16(nil, uint16(cursor.Shard))
+	if cursor.HasLast {
+		data = append(data, 1)
+	} else {
+		data = append(data, 0)
+	}
+
+	return append(data, cursor.LastKey...)
+}
+
+// decodeScanCursor decodes a cursor returned by encodeScanCursor. An empty cursor starts a new scan.
+func decodeScanCursor(data []byte) (kvengine.ScanCursor, bool) {
+	if len(data) == 0 {
+		return kvengine.ScanCursor{}, true
+	}
+	if len(data) < 3 {
+		return kvengine.ScanCursor{}, false
+	}
+
+	shard := int(binary.BigEndian.Uint16(data[0:2]))
+	if shard >= kvengine.ShardCount {
+		return kvengine.ScanCursor{}, false
+	}
+
+	return kvengine.ScanCursor{
+		Shard:   shard,
+		HasLast: data[2] == 1,
+		LastKey: string(data[3:]),
+	}, true
+}
@@ -0,0 +1,42 @@
+package kvcmds
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/kvengine"
+)
+
+func TestScanResponseWithMaxLengthKey(t *testing.T) {
+	key := strings.Repeat("k", math.MaxUint16)
+	next := kvengine.ScanCursor{Shard: 3, HasLast: true, LastKey: key}
+
+	payload := encodeScanResponse([]string{key}, next)
+
+	if len(payload) < 4 {
+		t.Fatalf("payload too short: %d bytes", len(payload))
+	}
+	cursorLen := int(binary.BigEndian.Uint32(payload[0:4]))
+	if cursorLen != 3+len(key) || len(payload) < 4+cursorLen {
+		t.Fatalf("cursor length = %d, want %d", cursorLen, 3+len(key))
+	}
+
+	cursor, ok := decodeScanCursor(payload[4 : 4+cursorLen])
+	if !ok {
+		t.Fatalf("failed to decode cursor")
+	}
+	if cursor != next {
+		t.Fatalf("cursor = {%d %v %d bytes}, want {%d %v %d bytes}", cursor.Shard, cursor.HasLast, len(cursor.LastKey), next.Shard, next.HasLast, len(next.LastKey))
+	}
+
+	keys, err := codex.DecodeList(payload[4+cursorLen:])
+	if err != nil {
+		t.Fatalf("DecodeList error: %v", err)
+	}
+	if len(keys) != 1 || keys[0].AsString() != key {
+		t.Fatalf("expected the key to follow the cursor")
+	}
+}
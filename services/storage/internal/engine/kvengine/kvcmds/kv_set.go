@@ -61,7 +61,9 @@ func (c *Commands) handleSet(ctx *command.ConnCtx, _ *protocol.Message, cmd *pro
 		}, nil
 	}
 
-	kve.Set(key, value)
+	if err := kve.Set(key, value); err != nil {
+		return errorResponse(err), nil
+	}
 
 	return &protocol.Response{
 		Code:    protocol.StatusOK,
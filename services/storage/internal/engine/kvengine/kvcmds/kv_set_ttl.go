@@ -70,7 +70,9 @@ func (c *Commands) handleSetTTL(ctx *command.ConnCtx, _ *protocol.Message, cmd *
 		}, nil
 	}
 
-	kve.SetWithTTL(key, value, expiresAt)
+	if err := kve.SetWithTTL(key, value, expiresAt); err != nil {
+		return errorResponse(err), nil
+	}
 
 	return &protocol.Response{
 		Code:    protocol.StatusOK,
@@ -0,0 +1,47 @@
+package kvcmds
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/database"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/kvengine"
+)
+
+// handleStats implements the server side of the protocol.ServerCommandKVStats command over TCP.
+// Payload format: empty
+// Response payload: codex-encoded binary value containing the marshaled kvengine.Stats
+func (c *Commands) handleStats(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	data, err := codex.Marshal(kve.Stats())
+	if err != nil {
+		slog.Error("Failed to marshal key-value stats",
+			slog.String("database", cmd.DatabaseName),
+			slog.String("collection", cmd.CollectionName),
+			sloki.WrapError(err),
+		)
+		return commonresponses.InternalServerError, nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeBinary(data),
+	}, nil
+}
+
+// handleStatsHTTP implements the server side of the protocol.ServerCommandKVStats command over HTTP.
+func (c *Commands) handleStatsHTTP(w http.ResponseWriter, _ *http.Request, _ *database.Database, _ *database.Collection, kve *kvengine.Engine) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(kve.Stats())
+}
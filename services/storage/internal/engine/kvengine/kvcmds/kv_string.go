@@ -0,0 +1,115 @@
+package kvcmds
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// handleStringConcat returns the handler for the protocol.ServerCommandStringAppend and protocol.ServerCommandStringPrepend commands.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Part (codex-encoded string) |
+// Response payload: | New Length (4 bytes) |
+func (c *Commands) handleStringConcat(prepend bool) command.Handler {
+	return func(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+		kve, resp := c.keyValueEngine(cmd)
+		if resp != nil {
+			return resp, nil
+		}
+
+		key, rest, resp := readKey(cmd.Payload)
+		if resp != nil {
+			return resp, nil
+		}
+
+		part, err := codex.DecodeString(rest)
+		if err != nil {
+			return &protocol.Response{
+				Code:    protocol.StatusBadRequest,
+				Payload: []byte("invalid string encoding: " + err.Error()),
+			}, nil
+		}
+
+		var length uint32
+		if prepend {
+			length, err = kve.StringPrepend(key, part)
+		} else {
+			length, err = kve.StringAppend(key, part)
+		}
+		if err != nil {
+			return errorResponse(err), nil
+		}
+
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, length)
+
+		return &protocol.Response{
+			Code:    protocol.StatusOK,
+			Payload: payload,
+		}, nil
+	}
+}
+
+// handleStringLength implements the server side of the protocol.ServerCommandStringLength command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) |
+// Response payload: | Length (4 bytes) |
+func (c *Commands) handleStringLength(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, _, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	length, err := kve.StringLength(key)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, length)
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: payload,
+	}, nil
+}
+
+// handleStringSubstring implements the server side of the protocol.ServerCommandStringSubstring command over TCP.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Start (4 bytes, signed) | End (4 bytes, signed) |
+// Response payload: | Substring (codex-encoded string) |
+func (c *Commands) handleStringSubstring(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if len(rest) < 8 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for range"),
+		}, nil
+	}
+
+	start := int(int32(binary.BigEndian.Uint32(rest[0:4])))
+	end := int(int32(binary.BigEndian.Uint32(rest[4:8])))
+
+	sub, err := kve.StringSubstring(key, start, end)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeString(sub),
+	}, nil
+}
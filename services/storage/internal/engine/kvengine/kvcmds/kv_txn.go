@@ -0,0 +1,116 @@
+package kvcmds
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/kvengine"
+)
+
+// handleTxn implements the server side of the protocol.ServerCommandKVTxn command over TCP.
+// Payload format: | Op Count (2 bytes) | Ops (variable) |
+// Op format: | Type (1 byte) | Key Length (2 bytes) | Key (variable) | Expected Revision (8 bytes) | Expires At (8 bytes) | Value Length (4 bytes) | Value (codex-encoded) |
+// Response payload: | Revision (8 bytes) per op | on success, | Op Index (2 bytes) | Current Revision (8 bytes) | on conflict
+func (c *Commands) handleTxn(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	kve, resp := c.keyValueEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	ops, err := decodeTxnOps(cmd.Payload)
+	if err != nil {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte(err.Error()),
+		}, nil
+	}
+
+	revisions, err := kve.Txn(ops)
+	if err != nil {
+		var conflict *kvengine.TxnConflictError
+		if !errors.As(err, &conflict) {
+			return errorResponse(err), nil
+		}
+
+		payload := binary.BigEndian.AppendUint16(nil, uint16(conflict.Op))
+		payload = binary.BigEndian.AppendUint64(payload, conflict.Revision)
+		return &protocol.Response{
+			Code:    protocol.StatusConflict,
+			Payload: payload,
+		}, nil
+	}
+
+	payload := make([]byte, 0, len(revisions)*8)
+	for _, revision := range revisions {
+		payload = binary.BigEndian.AppendUint64(payload, revision)
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: payload,
+	}, nil
+}
+
+func decodeTxnOps(data []byte) ([]kvengine.TxnOp, error) {
+	if len(data) < 2 {
+		return nil, errors.New("invalid payload length for op count")
+	}
+
+	count := int(binary.BigEndian.Uint16(data[0:2]))
+	if count > kvengine.MaxTxnOps {
+		return nil, fmt.Errorf("too many ops, at most %d are allowed", kvengine.MaxTxnOps)
+	}
+	data = data[2:]
+
+	ops := make([]kvengine.TxnOp, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < 3 {
+			return nil, fmt.Errorf("op %d: invalid payload length for type and key", i)
+		}
+		opType := kvengine.TxnOpType(data[0])
+		keyLen := int(binary.BigEndian.Uint16(data[1:3]))
+		data = data[3:]
+
+		if len(data) < keyLen+20 {
+			return nil, fmt.Errorf("op %d: invalid payload length for key", i)
+		}
+		op := kvengine.TxnOp{
+			Type:     opType,
+			Key:      string(data[:keyLen]),
+			Revision: binary.BigEndian.Uint64(data[keyLen : keyLen+8]),
+			Expires:  int64(binary.BigEndian.Uint64(data[keyLen+8 : keyLen+16])),
+		}
+		valueLen := int(binary.BigEndian.Uint32(data[keyLen+16 : keyLen+20]))
+		data = data[keyLen+20:]
+
+		if len(data) < valueLen {
+			return nil, fmt.Errorf("op %d: invalid payload length for value", i)
+		}
+		if valueLen > 0 {
+			value, err := codex.DecodeValue(data[:valueLen])
+			if err != nil {
+				return nil, fmt.Errorf("op %d: invalid value encoding: %w", i, err)
+			}
+			op.Value = value
+		}
+		data = data[valueLen:]
+
+		switch op.Type {
+		case kvengine.TxnOpSet, kvengine.TxnOpCompareAndSet:
+			if op.Value == nil {
+				return nil, fmt.Errorf("op %d: missing value", i)
+			}
+		case kvengine.TxnOpCheck, kvengine.TxnOpDelete, kvengine.TxnOpCompareAndDelete:
+		default:
+			return nil, fmt.Errorf("op %d: unknown op type %d", i, op.Type)
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
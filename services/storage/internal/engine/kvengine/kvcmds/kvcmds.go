@@ -2,6 +2,7 @@ package kvcmds
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -9,11 +10,13 @@ import (
 
 	"github.com/OliverSchlueter/goutils/problems"
 	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
 	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/auth"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/database"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/engine"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/kvengine"
 )
 
 type Commands struct {
@@ -38,21 +41,61 @@ func New(cfg Configuration) *Commands {
 
 func (c *Commands) Get() map[uint16]command.Handler {
 	return map[uint16]command.Handler{
-		protocol.ServerCommandKVSet:            c.handleSet,
-		protocol.ServerCommandKVSetTTL:         c.handleSetTTL,
-		protocol.ServerCommandKVDelete:         c.handleDelete,
-		protocol.ServerCommandKVDeleteMultiple: c.handleDelete,
-		protocol.ServerCommandKVDeleteAll:      c.handleDeleteAll,
-		protocol.ServerCommandKVExists:         c.handleExists,
-		protocol.ServerCommandKVGet:            c.handleGet,
-		protocol.ServerCommandKVGetMultiple:    c.handleGetMultiple,
-		protocol.ServerCommandKVGetAll:         c.handleGetAll,
-		protocol.ServerCommandKVGetTTL:         c.handleGetTTL,
-		protocol.ServerCommandKVGetMultipleTTL: c.handleGetMultipleTTL,
-		protocol.ServerCommandKVGetAllTTL:      c.handleGetAllTTL,
-		protocol.ServerCommandKVKeys:           c.handleKeys,
-		protocol.ServerCommandKVCount:          c.handleCountTCP,
-		protocol.ServerCommandKVSize:           c.handleSize,
+		protocol.ServerCommandKVSet:              c.handleSet,
+		protocol.ServerCommandKVSetTTL:           c.handleSetTTL,
+		protocol.ServerCommandKVCompareAndSet:    c.handleCompareAndSet,
+		protocol.ServerCommandKVDelete:           c.handleDelete,
+		protocol.ServerCommandKVDeleteMultiple:   c.handleDelete,
+		protocol.ServerCommandKVDeleteAll:        c.handleDeleteAll,
+		protocol.ServerCommandKVCompareAndDelete: c.handleCompareAndDelete,
+		protocol.ServerCommandKVExists:           c.handleExists,
+		protocol.ServerCommandKVGet:              c.handleGet,
+		protocol.ServerCommandKVGetMultiple:      c.handleGetMultiple,
+		protocol.ServerCommandKVGetAll:           c.handleGetAll,
+		protocol.ServerCommandKVGetTTL:           c.handleGetTTL,
+		protocol.ServerCommandKVGetMultipleTTL:   c.handleGetMultipleTTL,
+		protocol.ServerCommandKVGetAllTTL:        c.handleGetAllTTL,
+		protocol.ServerCommandKVKeys:             c.handleKeys,
+		protocol.ServerCommandKVCount:            c.handleCountTCP,
+		protocol.ServerCommandKVSize:             c.handleSize,
+		protocol.ServerCommandKVScan:             c.handleScan,
+		protocol.ServerCommandKVStats:            c.handleStats,
+		protocol.ServerCommandKVGetWithRevision:  c.handleGetWithRevision,
+		protocol.ServerCommandKVTxn:              c.handleTxn,
+
+		protocol.ServerCommandKVNumIncrement:  c.handleNumOp(kvengine.NumOpIncrement),
+		protocol.ServerCommandKVNumDecrement:  c.handleNumOp(kvengine.NumOpDecrement),
+		protocol.ServerCommandKVNumMultiply:   c.handleNumOp(kvengine.NumOpMultiply),
+		protocol.ServerCommandKVNumDivide:     c.handleNumOp(kvengine.NumOpDivide),
+		protocol.ServerCommandKVNumModulo:     c.handleNumOp(kvengine.NumOpModulo),
+		protocol.ServerCommandKVNumLeftShift:  c.handleNumOp(kvengine.NumOpLeftShift),
+		protocol.ServerCommandKVNumRightShift: c.handleNumOp(kvengine.NumOpRightShift),
+		protocol.ServerCommandKVNumBitwiseAnd: c.handleNumOp(kvengine.NumOpBitwiseAnd),
+		protocol.ServerCommandKVNumBitwiseOr:  c.handleNumOp(kvengine.NumOpBitwiseOr),
+		protocol.ServerCommandKVNumBitwiseXor: c.handleNumOp(kvengine.NumOpBitwiseXor),
+		protocol.ServerCommandKVNumBitwiseNot: c.handleNumOp(kvengine.NumOpBitwiseNot),
+
+		protocol.ServerCommandStringAppend:    c.handleStringConcat(false),
+		protocol.ServerCommandStringPrepend:   c.handleStringConcat(true),
+		protocol.ServerCommandStringLength:    c.handleStringLength,
+		protocol.ServerCommandStringSubstring: c.handleStringSubstring,
+
+		protocol.ServerCommandKVListLength:    c.handleListLength,
+		protocol.ServerCommandKVListGet:       c.handleListGet,
+		protocol.ServerCommandKVListSet:       c.handleListSet,
+		protocol.ServerCommandKVListRemove:    c.handleListRemove,
+		protocol.ServerCommandKVListLeftPush:  c.handleListPush(true),
+		protocol.ServerCommandKVListRightPush: c.handleListPush(false),
+		protocol.ServerCommandKVListLeftPop:   c.handleListPop(true),
+		protocol.ServerCommandKVListRightPop:  c.handleListPop(false),
+
+		protocol.ServerCommandKVMapLength: c.handleMapLength,
+		protocol.ServerCommandKVMapSet:    c.handleMapSet,
+		protocol.ServerCommandKVMapGet:    c.handleMapGet,
+		protocol.ServerCommandKVMapDelete: c.handleMapDelete,
+		protocol.ServerCommandKVMapExists: c.handleMapExists,
+		protocol.ServerCommandKVMapKeys:   c.handleMapKeys,
+		protocol.ServerCommandKVMapValues: c.handleMapValues,
 	}
 }
 
@@ -167,7 +210,97 @@ func (c *Commands) handleCommandHTTP(w http.ResponseWriter, r *http.Request) {
 		c.handleCountHTTP(w, r, db, coll, kve)
 	case protocol.ServerCommandKVSize:
 		c.handleSizeHTTP(w, r, db, coll, kve)
+	case protocol.ServerCommandKVStats:
+		c.handleStatsHTTP(w, r, db, coll, kve)
 	default:
 		problems.NotFound("Command", cmdIDStr).WriteToHTTP(w)
 	}
 }
+
+// errorResponse maps errors returned by the key-value engine to protocol responses.
+func errorResponse(err error) *protocol.Response {
+	switch {
+	case errors.Is(err, kvengine.ErrKeyNotFound):
+		return &protocol.Response{
+			Code:    protocol.StatusNotFound,
+			Payload: *commonresponses.EmptyPayload,
+		}
+	case errors.Is(err, kvengine.ErrTypeMismatch):
+		return &protocol.Response{
+			Code:    protocol.StatusTypeMismatch,
+			Payload: []byte(err.Error()),
+		}
+	case errors.Is(err, kvengine.ErrOverflow):
+		return &protocol.Response{
+			Code:    protocol.StatusOverflow,
+			Payload: []byte(err.Error()),
+		}
+	case errors.Is(err, kvengine.ErrOutOfRange):
+		return &protocol.Response{
+			Code:    protocol.StatusOutOfRange,
+			Payload: []byte(err.Error()),
+		}
+	case errors.Is(err, kvengine.ErrRevisionMismatch):
+		return &protocol.Response{
+			Code:    protocol.StatusConflict,
+			Payload: []byte(err.Error()),
+		}
+	case errors.Is(err, kvengine.ErrLimitExceeded):
+		return &protocol.Response{
+			Code:    protocol.StatusLimitExceeded,
+			Payload: []byte(err.Error()),
+		}
+	case errors.Is(err, kvengine.ErrDivisionByZero), errors.Is(err, kvengine.ErrInvalidOperand):
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte(err.Error()),
+		}
+	default:
+		slog.Error("Failed to execute key-value command", sloki.WrapError(err))
+		return commonresponses.InternalServerError
+	}
+}
+
+// keyValueEngine returns the key-value engine targeted by cmd, or the response to send if there is none.
+func (c *Commands) keyValueEngine(cmd *protocol.Command) (*kvengine.Engine, *protocol.Response) {
+	e, err := c.engineService.GetEngine(cmd.DatabaseName, cmd.CollectionName)
+	if err != nil {
+		if errors.Is(err, database.ErrCollectionNotFound) {
+			return nil, commonresponses.CollectionNotFound
+		}
+
+		slog.Error("Failed to get engine",
+			slog.String("database", cmd.DatabaseName),
+			slog.String("collection", cmd.CollectionName),
+			sloki.WrapError(err),
+		)
+		return nil, commonresponses.InternalServerError
+	}
+
+	if e.Type != database.EngineKeyValue {
+		return nil, commonresponses.CommandNotAllowed
+	}
+
+	return e.AsKeyValueEngine(), nil
+}
+
+// readKey reads a length-prefixed key (| Key Length (2 bytes) | Key (variable) |) from the start of data.
+// It returns the key and the rest of data, or the response to send if data is too short.
+func readKey(data []byte) (string, []byte, *protocol.Response) {
+	if len(data) < 2 {
+		return "", nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length"),
+		}
+	}
+
+	keyLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+keyLen {
+		return "", nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for key"),
+		}
+	}
+
+	return string(data[2 : 2+keyLen]), data[2+keyLen:], nil
+}
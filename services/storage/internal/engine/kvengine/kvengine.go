@@ -1,37 +1,128 @@
 package kvengine
 
 import (
+	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
 )
 
 type Engine struct {
-	shards     [ShardCount]shard
-	disableTTL bool
+	shards      [ShardCount]shard
+	disableTTL  bool
+	persistence *persistence
+	stop        chan struct{}
+	stopOnce    sync.Once
+	wg          sync.WaitGroup // Background jobs
+
+	// revision is the last revision assigned to an entry, see entry.revision.
+	revision atomic.Uint64
+
+	// memory and entries track the accounted size and the number of stored entries, see entrySize.
+	memory  atomic.Int64
+	entries atomic.Int64
+
+	maxMemory      int64
+	maxEntries     int64
+	evictionPolicy EvictionPolicy
+
+	evictions      atomic.Uint64
+	rejectedWrites atomic.Uint64
+	expirations    atomic.Uint64
+
+	onEvent       EventHandler
+	events        chan Event
+	droppedEvents atomic.Uint64
 }
 
 type Configuration struct {
 	DisableTTL bool
+
+	// Database and Collection are used to locate the persisted data, see NewPersistentEngine.
+	Database   string
+	Collection string
+
+	// SnapshotInterval is the interval in which all shards are snapshotted to disk, see NewPersistentEngine.
+	// Defaults to 5 minutes.
+	SnapshotInterval time.Duration
+
+	// MaxMemoryBytes limits the accounted size of all keys and values (0 = unlimited), see Engine.Size.
+	MaxMemoryBytes uint64
+	// MaxEntries limits the number of entries (0 = unlimited).
+	MaxEntries uint64
+	// EvictionPolicy decides what happens when a limit is reached. Defaults to EvictionReject.
+	EvictionPolicy EvictionPolicy
+
+	// OnEvent is called asynchronously for every change of a key (optional), see EventHandler.
+	// Changes restored from disk by NewPersistentEngine are not reported.
+	OnEvent EventHandler
 }
 
+// NewEngine creates a purely in-memory engine.
 func NewEngine(cfg Configuration) *Engine {
+	e := newEngine(cfg)
+	e.start()
+
+	return e
+}
+
+func newEngine(cfg Configuration) *Engine {
 	e := &Engine{
-		disableTTL: cfg.DisableTTL,
+		disableTTL:     cfg.DisableTTL,
+		stop:           make(chan struct{}),
+		maxMemory:      int64(min(cfg.MaxMemoryBytes, math.MaxInt64)),
+		maxEntries:     int64(min(cfg.MaxEntries, math.MaxInt64)),
+		evictionPolicy: cfg.EvictionPolicy,
+		onEvent:        cfg.OnEvent,
+	}
+	if e.evictionPolicy == "" {
+		e.evictionPolicy = EvictionReject
+	}
+	if e.onEvent != nil {
+		e.events = make(chan Event, eventQueueSize)
 	}
 
+	trackAccess := e.limited() && (e.evictionPolicy == EvictionLRU || e.evictionPolicy == EvictionLFU)
 	for i := 0; i < ShardCount; i++ {
 		e.shards[i] = shard{
-			index: i,
-			data:  make(map[string]*entry),
+			index:       i,
+			data:        make(map[string]*entry),
+			trackAccess: trackAccess,
 		}
 	}
 
+	return e
+}
+
+// start starts the background jobs of the engine.
+func (e *Engine) start() {
 	if !e.disableTTL {
-		e.startCleanup(1 * time.Second)
+		e.startCleanup(cleanupInterval)
+	}
+	if e.events != nil {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			e.dispatchEvents()
+		}()
 	}
+}
 
-	return e
+// Close stops the background jobs of the engine and waits for them to finish.
+// If persistence is enabled, the log is synced to disk and closed, later mutations are not persisted anymore.
+func (e *Engine) Close() error {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+	e.wg.Wait()
+
+	if e.persistence == nil {
+		return nil
+	}
+
+	return e.persistence.close()
 }
 
 // Keys returns a slice of all keys in the engine.
@@ -52,7 +143,8 @@ func (e *Engine) Keys() []string {
 // SetWithTTL stores a value for the given key with an optional expiration time (in unix nanoseconds).
 // If expires is 0, the key will not expire.
 // If expires is a positive value, it should be a unix timestamp in nanoseconds indicating when the key should expire.
-func (e *Engine) SetWithTTL(key string, value *codex.Value, expires int64) {
+// Returns ErrLimitExceeded if the value does not fit into the limits of the engine.
+func (e *Engine) SetWithTTL(key string, value *codex.Value, expires int64) error {
 	if e.disableTTL {
 		expires = 0
 	}
@@ -61,19 +153,18 @@ func (e *Engine) SetWithTTL(key string, value *codex.Value, expires int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.data[key] = &entry{
-		value:   value,
-		expires: expires,
-	}
+	_, err := e.replace(s, key, value, expires)
+	return err
 }
 
-func (e *Engine) Set(key string, value *codex.Value) {
-	e.SetWithTTL(key, value, 0)
+func (e *Engine) Set(key string, value *codex.Value) error {
+	return e.SetWithTTL(key, value, 0)
 }
 
 // SetMultipleTTL allows setting multiple key-value pairs at once with the same expiration time.
 // This is more efficient than calling Set multiple times, as it minimizes locking overhead by grouping updates by shard.
-func (e *Engine) SetMultipleTTL(entries map[string]codex.Value, expires int64) {
+// Pairs that do not fit into the limits of the engine are skipped and ErrLimitExceeded is returned after all others are stored.
+func (e *Engine) SetMultipleTTL(entries map[string]codex.Value, expires int64) error {
 	if e.disableTTL {
 		expires = 0
 	}
@@ -89,26 +180,28 @@ func (e *Engine) SetMultipleTTL(entries map[string]codex.Value, expires int64) {
 	}
 
 	// update each shard
+	var firstErr error
 	for shardIndex, entries := range shardEntries {
 		s := &e.shards[shardIndex]
 		s.mu.Lock()
 		for key, value := range entries {
-			s.data[key] = &entry{
-				value:   &value,
-				expires: expires,
+			if _, err := e.replace(s, key, &value, expires); err != nil && firstErr == nil {
+				firstErr = err
 			}
 		}
 		s.mu.Unlock()
 	}
+
+	return firstErr
 }
 
 // SetMultiple allows setting multiple key-value pairs at once without expiration.
-func (e *Engine) SetMultiple(entries map[string]codex.Value) {
-	e.SetMultipleTTL(entries, 0)
+func (e *Engine) SetMultiple(entries map[string]codex.Value) error {
+	return e.SetMultipleTTL(entries, 0)
 }
 
 // SetIfExistsTTL updates the value for the given key only if it already exists and has not expired.
-// Returns true if the key was updated, false otherwise.
+// Returns true if the key was updated, false otherwise (also if the value does not fit into the limits of the engine).
 func (e *Engine) SetIfExistsTTL(key string, value codex.Value, expires int64) bool {
 	if e.disableTTL {
 		expires = 0
@@ -123,11 +216,8 @@ func (e *Engine) SetIfExistsTTL(key string, value codex.Value, expires int64) bo
 		return false
 	}
 
-	s.data[key] = &entry{
-		value:   &value,
-		expires: expires,
-	}
-	return true
+	_, err := e.replace(s, key, &value, expires)
+	return err == nil
 }
 
 // SetIfExists updates the value for the given key only if it already exists and has not expired.
@@ -137,7 +227,7 @@ func (e *Engine) SetIfExists(key string, value codex.Value) bool {
 }
 
 // SetIfNotExistsTTL sets the value for the given key only if it does not already exist or has expired.
-// Returns true if the key was set, false otherwise.
+// Returns true if the key was set, false otherwise (also if the value does not fit into the limits of the engine).
 func (e *Engine) SetIfNotExistsTTL(key string, value codex.Value, expires int64) bool {
 	if e.disableTTL {
 		expires = 0
@@ -152,11 +242,8 @@ func (e *Engine) SetIfNotExistsTTL(key string, value codex.Value, expires int64)
 		return false
 	}
 
-	s.data[key] = &entry{
-		value:   &value,
-		expires: expires,
-	}
-	return true
+	_, err := e.replace(s, key, &value, expires)
+	return err == nil
 }
 
 func (e *Engine) SetIfNotExists(key string, value codex.Value) bool {
@@ -169,8 +256,8 @@ func (e *Engine) Get(key string) *codex.Value {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	entry, exists := s.data[key]
-	if !exists || (entry.expires > 0 && time.Now().UnixNano() > entry.expires) {
+	entry, ok := s.live(key)
+	if !ok {
 		return nil
 	}
 
@@ -187,13 +274,11 @@ func (e *Engine) GetMultiple(keys []string) map[string]*codex.Value {
 	}
 
 	results := make(map[string]*codex.Value, len(keys))
-	now := time.Now().UnixNano()
 	for shardIndex, keys := range shardKeys {
 		s := &e.shards[shardIndex]
 		s.mu.RLock()
 		for _, key := range keys {
-			entry, exists := s.data[key]
-			if exists && (entry.expires == 0 || now <= entry.expires) {
+			if entry, ok := s.live(key); ok {
 				results[key] = entry.value
 			} else {
 				results[key] = codex.EmptyValue // indicate missing/expired keys with a null value
@@ -295,42 +380,61 @@ func (e *Engine) Exists(key string) bool {
 }
 
 // Delete removes a key from the engine.
-func (e *Engine) Delete(key string) {
+// Returns an error if the deletion could not be persisted, the key is kept in that case.
+func (e *Engine) Delete(key string) error {
 	s := e.shardFor(key)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	delete(s.data, key)
+	return e.remove(s, key, EventDelete)
 }
 
 // DeleteMultiple removes multiple keys from the engine at once.
 // This is more efficient than calling Delete multiple times, as it minimizes locking overhead by grouping deletions by shard.
-func (e *Engine) DeleteMultiple(keys []string) {
+// Keys whose deletion could not be persisted are kept and the first error is returned after all others are removed.
+func (e *Engine) DeleteMultiple(keys []string) error {
 	shardKeys := make(map[int][]string)
 	for _, key := range keys {
 		s := e.shardFor(key)
 		shardKeys[s.index] = append(shardKeys[s.index], key)
 	}
 
+	var firstErr error
 	for shardIndex, keys := range shardKeys {
 		s := &e.shards[shardIndex]
 		s.mu.Lock()
 		for _, key := range keys {
-			delete(s.data, key)
+			if err := e.remove(s, key, EventDelete); err != nil && firstErr == nil {
+				firstErr = err
+			}
 		}
 		s.mu.Unlock()
 	}
+
+	return firstErr
 }
 
 // DeleteAll removes all keys from the engine.
 // This is a heavy operation and should be used with caution, as it will block all operations while it runs.
-func (e *Engine) DeleteAll() {
+// Returns an error if clearing a shard could not be persisted, that shard and the ones after it are kept in that case.
+func (e *Engine) DeleteAll() error {
 	for i := 0; i < ShardCount; i++ {
 		s := &e.shards[i]
 		s.mu.Lock()
-		s.data = make(map[string]*entry)
+		if err := e.logClearShard(i); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		if e.onEvent != nil {
+			for key, en := range s.data {
+				e.notify(EventDelete, key, en)
+			}
+		}
+		e.dropAll(s)
 		s.mu.Unlock()
 	}
+
+	return nil
 }
 
 // Count returns the total number of keys currently stored in the engine across all shards.
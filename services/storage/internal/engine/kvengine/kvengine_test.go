@@ -1,6 +1,12 @@
 package kvengine
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -138,3 +144,1075 @@ func TestSetIfExistsSetIfNotExistsWithExpiry(t *testing.T) {
 		t.Fatalf("expected SetIfExistsTTL to succeed for unexpired key")
 	}
 }
+
+func newPersistentTestEngine(t *testing.T) *Engine {
+	e, err := NewPersistentEngine(Configuration{
+		Database:   "testdb",
+		Collection: "persistent",
+	})
+	if err != nil {
+		t.Fatalf("NewPersistentEngine error: %v", err)
+	}
+	return e
+}
+
+func TestPersistentEngineRestoresAfterRestart(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(filepath.Join("data", "testdb")) })
+
+	e := newPersistentTestEngine(t)
+
+	a, _ := codex.NewValue("a")
+	b, _ := codex.NewValue(int64(42))
+	e.Set("a", a)
+	e.Set("b", b)
+	e.Set("deleted", a)
+	e.Delete("deleted")
+	e.SetWithTTL("expiring", a, time.Now().Add(50*time.Millisecond).UnixNano())
+	e.SetWithTTL("long", a, time.Now().Add(time.Hour).UnixNano())
+
+	// snapshot in between, so the restore has to combine the snapshot and the log
+	if err := e.snapshot(); err != nil {
+		t.Fatalf("snapshot error: %v", err)
+	}
+	e.Set("after-snapshot", b)
+
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond) // let "expiring" expire while the engine is down
+
+	e = newPersistentTestEngine(t)
+	defer e.Close()
+
+	if got := e.Get("a"); got == nil || got.AsString() != "a" {
+		t.Fatalf("expected a to be restored, got %v", got)
+	}
+	if got := e.Get("b"); got == nil || got.AsInt64() != 42 {
+		t.Fatalf("expected b to be restored, got %v", got)
+	}
+	if got := e.Get("after-snapshot"); got == nil || got.AsInt64() != 42 {
+		t.Fatalf("expected after-snapshot to be restored, got %v", got)
+	}
+	if e.Exists("deleted") {
+		t.Fatalf("expected deleted to stay deleted")
+	}
+	if e.Exists("expiring") {
+		t.Fatalf("expected expiring to be expired after restart")
+	}
+	if e.GetTTL("long") <= 0 {
+		t.Fatalf("expected long to keep its TTL")
+	}
+	if e.Count() != 4 {
+		t.Fatalf("expected 4 keys after restore, got %d", e.Count())
+	}
+
+	e.DeleteAll()
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	e = newPersistentTestEngine(t)
+	defer e.Close()
+
+	if e.Count() != 0 {
+		t.Fatalf("expected no keys after DeleteAll and restart, got %d", e.Count())
+	}
+}
+
+func TestPersistentEngineTruncatesTornLog(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(filepath.Join("data", "testdb")) })
+
+	e := newPersistentTestEngine(t)
+
+	v, _ := codex.NewValue("value")
+	e.Set("k1", v)
+	e.Set("k2", v)
+
+	logPath := e.persistence.logPath(e.persistence.gen)
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	// simulate a crash in the middle of writing the last record
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	if err := os.Truncate(logPath, info.Size()-3); err != nil {
+		t.Fatalf("Truncate error: %v", err)
+	}
+
+	e = newPersistentTestEngine(t)
+	defer e.Close()
+
+	if !e.Exists("k1") {
+		t.Fatalf("expected k1 to survive the torn log")
+	}
+	if e.Exists("k2") {
+		t.Fatalf("expected the torn k2 record to be dropped")
+	}
+}
+
+func TestPersistentEngineRejectsFailedLogWrites(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(filepath.Join("data", "testdb")) })
+
+	e := newPersistentTestEngine(t)
+
+	v, _ := codex.NewValue("value")
+	if err := e.Set("k1", v); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+
+	// replace the log with a read-only handle, so every write to it fails
+	p := e.persistence
+	writable := p.log
+	defer writable.Close()
+	readOnly, err := os.Open(p.logPath(p.gen))
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	p.mu.Lock()
+	p.log = readOnly
+	p.mu.Unlock()
+
+	if err := e.Set("k2", v); err == nil {
+		t.Fatalf("expected Set to fail when the log cannot be written")
+	}
+	if e.Exists("k2") {
+		t.Fatalf("expected k2 not to be stored after the failed write")
+	}
+	if err := e.Delete("k1"); err == nil {
+		t.Fatalf("expected Delete to fail when the log cannot be written")
+	}
+	if !e.Exists("k1") {
+		t.Fatalf("expected k1 to be kept after the failed delete")
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	e = newPersistentTestEngine(t)
+	defer e.Close()
+
+	if !e.Exists("k1") || e.Exists("k2") {
+		t.Fatalf("expected only k1 to be restored")
+	}
+}
+
+func TestPersistentEngineSnapshotAfterClose(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(filepath.Join("data", "testdb")) })
+
+	e := newPersistentTestEngine(t)
+	gen := e.persistence.gen
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	// a snapshot that was already running must not open a new log after the engine is closed
+	if err := e.snapshot(); !errors.Is(err, errLogClosed) {
+		t.Fatalf("snapshot error = %v, want %v", err, errLogClosed)
+	}
+	if _, err := os.Stat(e.persistence.logPath(gen + 1)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no log to be opened after Close, got %v", err)
+	}
+}
+
+func TestPersistentEngineFailsOnCorruptLog(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(filepath.Join("data", "testdb")) })
+
+	e := newPersistentTestEngine(t)
+
+	v, _ := codex.NewValue("value")
+	e.Set("k1", v)
+	e.Set("k2", v)
+
+	logPath := e.persistence.logPath(e.persistence.gen)
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	// flip a byte in the payload of the first record, the record after it is intact
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	data[recordHeaderSize+1] ^= 0xFF
+	if err := os.WriteFile(logPath, data, 0644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	if _, err := NewPersistentEngine(Configuration{Database: "testdb", Collection: "persistent"}); !errors.Is(err, errCorruptRecord) {
+		t.Fatalf("expected the restore to fail with errCorruptRecord, got %v", err)
+	}
+
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	if info.Size() != int64(len(data)) {
+		t.Fatalf("expected the corrupt log to be left untouched, size %d instead of %d", info.Size(), len(data))
+	}
+}
+
+func mustValue(t *testing.T, v any) *codex.Value {
+	t.Helper()
+	val, err := codex.NewValue(v)
+	if err != nil {
+		t.Fatalf("NewValue(%v): %v", v, err)
+	}
+	return val
+}
+
+func TestApplyNumOp(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial any
+		op      NumOp
+		operand any
+		want    any
+		wantErr error
+	}{
+		{name: "increment int64", initial: int64(5), op: NumOpIncrement, operand: int64(3), want: int64(8)},
+		{name: "increment int64 by int32", initial: int64(5), op: NumOpIncrement, operand: int32(-7), want: int64(-2)},
+		{name: "increment int16 overflow", initial: int16(math.MaxInt16), op: NumOpIncrement, operand: int16(1), wantErr: ErrOverflow},
+		{name: "increment int64 overflow", initial: int64(math.MaxInt64), op: NumOpIncrement, operand: int64(1), wantErr: ErrOverflow},
+		{name: "decrement uint32 underflow", initial: uint32(1), op: NumOpDecrement, operand: uint32(2), wantErr: ErrOverflow},
+		{name: "decrement int32", initial: int32(1), op: NumOpDecrement, operand: int32(2), want: int32(-1)},
+		{name: "increment byte overflow", initial: byte(250), op: NumOpIncrement, operand: byte(6), wantErr: ErrOverflow},
+		{name: "operand out of range", initial: int16(0), op: NumOpIncrement, operand: int32(math.MaxInt16 + 1), wantErr: ErrOverflow},
+		{name: "negative operand for unsigned", initial: uint64(5), op: NumOpIncrement, operand: int32(-1), wantErr: ErrOverflow},
+		{name: "multiply uint64 overflow", initial: uint64(math.MaxUint64 / 2), op: NumOpMultiply, operand: uint64(3), wantErr: ErrOverflow},
+		{name: "multiply int64 min by -1", initial: int64(math.MinInt64), op: NumOpMultiply, operand: int64(-1), wantErr: ErrOverflow},
+		{name: "multiply int32", initial: int32(-6), op: NumOpMultiply, operand: int32(7), want: int32(-42)},
+		{name: "divide int16 min by -1", initial: int16(math.MinInt16), op: NumOpDivide, operand: int16(-1), wantErr: ErrOverflow},
+		{name: "divide by zero", initial: int64(1), op: NumOpDivide, operand: int64(0), wantErr: ErrDivisionByZero},
+		{name: "modulo", initial: uint16(17), op: NumOpModulo, operand: uint16(5), want: uint16(2)},
+		{name: "modulo by zero", initial: uint16(17), op: NumOpModulo, operand: uint16(0), wantErr: ErrDivisionByZero},
+		{name: "left shift byte", initial: byte(0x81), op: NumOpLeftShift, operand: byte(1), want: byte(0x02)},
+		{name: "left shift int16 sign", initial: int16(0x4000), op: NumOpLeftShift, operand: int32(1), want: int16(math.MinInt16)},
+		{name: "shift out of range", initial: int32(1), op: NumOpLeftShift, operand: int32(32), wantErr: ErrInvalidOperand},
+		{name: "right shift negative", initial: int32(-8), op: NumOpRightShift, operand: int32(2), want: int32(-2)},
+		{name: "bitwise and", initial: uint32(0xF0F0), op: NumOpBitwiseAnd, operand: uint32(0x0FF0), want: uint32(0x00F0)},
+		{name: "bitwise or", initial: int64(0x0F), op: NumOpBitwiseOr, operand: int64(0xF0), want: int64(0xFF)},
+		{name: "bitwise xor", initial: uint16(0xFFFF), op: NumOpBitwiseXor, operand: uint16(0x00FF), want: uint16(0xFF00)},
+		{name: "bitwise not unsigned", initial: uint16(0x00FF), op: NumOpBitwiseNot, want: uint16(0xFF00)},
+		{name: "bitwise not signed", initial: int32(0), op: NumOpBitwiseNot, want: int32(-1)},
+		{name: "increment float64 by int", initial: float64(1.5), op: NumOpIncrement, operand: int32(2), want: float64(3.5)},
+		{name: "multiply float32 overflow", initial: float32(math.MaxFloat32), op: NumOpMultiply, operand: float32(2), wantErr: ErrOverflow},
+		{name: "modulo float64", initial: float64(7.5), op: NumOpModulo, operand: float64(2), want: float64(1.5)},
+		{name: "float bitwise", initial: float64(1), op: NumOpBitwiseAnd, operand: int64(1), wantErr: ErrTypeMismatch},
+		{name: "integer with float operand", initial: int64(1), op: NumOpIncrement, operand: float64(1), wantErr: ErrTypeMismatch},
+		{name: "string value", initial: "abc", op: NumOpIncrement, operand: int64(1), wantErr: ErrTypeMismatch},
+		{name: "non-numeric operand", initial: int64(1), op: NumOpIncrement, operand: "1", wantErr: ErrInvalidOperand},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngine(Configuration{DisableTTL: true})
+			initial := mustValue(t, tt.initial)
+			e.Set("key", initial)
+
+			var operand *codex.Value
+			if tt.operand != nil {
+				operand = mustValue(t, tt.operand)
+			}
+
+			got, err := e.ApplyNumOp("key", tt.op, operand)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+				}
+				if stored := e.Get("key"); stored != initial {
+					t.Fatalf("expected stored value to be unchanged after error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := mustValue(t, tt.want)
+			if got.Type != want.Type {
+				t.Fatalf("expected type %v, got %v", want.Type, got.Type)
+			}
+			gotAny, _ := got.ToAny()
+			wantAny, _ := want.ToAny()
+			if gotAny != wantAny {
+				t.Fatalf("expected %v, got %v", wantAny, gotAny)
+			}
+			if stored := e.Get("key"); stored != got {
+				t.Fatalf("expected stored value to be the returned value")
+			}
+		})
+	}
+}
+
+func TestApplyNumOpMissingKeyAndTTL(t *testing.T) {
+	e := NewEngine(Configuration{})
+	defer e.Close()
+
+	got, err := e.ApplyNumOp("counter", NumOpIncrement, mustValue(t, int64(2)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Type != codex.TypeInt64 || got.AsInt64() != 2 {
+		t.Fatalf("expected int64 2, got %v", got)
+	}
+
+	if _, err := e.ApplyNumOp("missing", NumOpBitwiseNot, nil); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).UnixNano()
+	e.SetWithTTL("ttl", mustValue(t, int32(1)), expiresAt)
+	if _, err := e.ApplyNumOp("ttl", NumOpIncrement, mustValue(t, int32(1))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ttl := e.GetTTL("ttl"); ttl != expiresAt {
+		t.Fatalf("expected expiration to be kept, got %d", ttl)
+	}
+}
+
+func TestApplyNumOpConcurrent(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true})
+
+	const workers = 8
+	const perWorker = 1000
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				if _, err := e.ApplyNumOp("counter", NumOpIncrement, mustValue(t, int64(1))); err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := e.Get("counter").AsInt64(); got != workers*perWorker {
+		t.Fatalf("expected %d, got %d", workers*perWorker, got)
+	}
+}
+
+func TestListOperations(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true})
+
+	if n, err := e.ListLength("list"); err != nil || n != 0 {
+		t.Fatalf("expected empty list, got %d, %v", n, err)
+	}
+
+	if n, err := e.ListRightPush("list", []*codex.Value{mustValue(t, "b"), mustValue(t, "c")}); err != nil || n != 2 {
+		t.Fatalf("expected length 2, got %d, %v", n, err)
+	}
+	if n, err := e.ListLeftPush("list", []*codex.Value{mustValue(t, "x"), mustValue(t, "a")}); err != nil || n != 4 {
+		t.Fatalf("expected length 4, got %d, %v", n, err)
+	}
+
+	// list is now [a, x, b, c]
+	if v, err := e.ListGet("list", 0); err != nil || v.AsString() != "a" {
+		t.Fatalf("expected a at index 0, got %v, %v", v, err)
+	}
+	if v, err := e.ListGet("list", -1); err != nil || v.AsString() != "c" {
+		t.Fatalf("expected c at index -1, got %v, %v", v, err)
+	}
+	if _, err := e.ListGet("list", 4); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("expected ErrOutOfRange, got %v", err)
+	}
+
+	before := e.Get("list")
+	if err := e.ListSet("list", 1, mustValue(t, "y")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if before.AsList()[1].AsString() != "x" {
+		t.Fatalf("expected previously returned value to be unchanged")
+	}
+
+	if v, err := e.ListRemove("list", 1); err != nil || v.AsString() != "y" {
+		t.Fatalf("expected to remove y, got %v, %v", v, err)
+	}
+
+	// list is now [a, b, c]
+	if v, err := e.ListRightPop("list"); err != nil || v.AsString() != "c" {
+		t.Fatalf("expected to pop c, got %v, %v", v, err)
+	}
+	afterPop := e.Get("list")
+	if _, err := e.ListRightPush("list", []*codex.Value{mustValue(t, "d")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := afterPop.AsList(); len(got) != 2 || got[1].AsString() != "b" {
+		t.Fatalf("expected previously returned list to be unchanged, got %v", got)
+	}
+
+	for _, want := range []string{"a", "b", "d"} {
+		v, err := e.ListLeftPop("list")
+		if err != nil || v.AsString() != want {
+			t.Fatalf("expected to pop %s, got %v, %v", want, v, err)
+		}
+	}
+
+	if e.Exists("list") {
+		t.Fatalf("expected empty list to be deleted")
+	}
+	if _, err := e.ListLeftPop("list"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	e.Set("str", mustValue(t, "abc"))
+	if _, err := e.ListRightPush("str", []*codex.Value{mustValue(t, "d")}); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected ErrTypeMismatch, got %v", err)
+	}
+}
+
+func TestMapOperations(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true})
+
+	if err := e.MapSet("player", "name", mustValue(t, "steve")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := e.MapSet("player", "level", mustValue(t, int32(3))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n, err := e.MapLength("player"); err != nil || n != 2 {
+		t.Fatalf("expected length 2, got %d, %v", n, err)
+	}
+	if v, err := e.MapGet("player", "level"); err != nil || v.AsInt32() != 3 {
+		t.Fatalf("expected level 3, got %v, %v", v, err)
+	}
+	if _, err := e.MapGet("player", "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	if ok, err := e.MapExists("player", "name"); err != nil || !ok {
+		t.Fatalf("expected name to exist, got %v, %v", ok, err)
+	}
+
+	keys, err := e.MapKeys("player")
+	if err != nil || len(keys) != 2 || !contains(keys, "name") || !contains(keys, "level") {
+		t.Fatalf("unexpected keys %v, %v", keys, err)
+	}
+	values, err := e.MapValues("player")
+	if err != nil || len(values) != 2 {
+		t.Fatalf("unexpected values %v, %v", values, err)
+	}
+
+	if ok, err := e.MapDelete("player", "name"); err != nil || !ok {
+		t.Fatalf("expected name to be deleted, got %v, %v", ok, err)
+	}
+	if ok, err := e.MapDelete("player", "name"); err != nil || ok {
+		t.Fatalf("expected second delete to report false, got %v, %v", ok, err)
+	}
+	if _, err := e.MapDelete("player", "level"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.Exists("player") {
+		t.Fatalf("expected empty map to be deleted")
+	}
+
+	e.Set("list", mustValue(t, []any{"a"}))
+	if _, err := e.MapKeys("list"); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected ErrTypeMismatch, got %v", err)
+	}
+}
+
+func TestStringOperations(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true})
+
+	if n, err := e.StringAppend("log", "world"); err != nil || n != 5 {
+		t.Fatalf("expected length 5, got %d, %v", n, err)
+	}
+	if n, err := e.StringPrepend("log", "hello "); err != nil || n != 11 {
+		t.Fatalf("expected length 11, got %d, %v", n, err)
+	}
+	if got := e.Get("log").AsString(); got != "hello world" {
+		t.Fatalf("expected 'hello world', got %q", got)
+	}
+	if n, err := e.StringLength("log"); err != nil || n != 11 {
+		t.Fatalf("expected length 11, got %d, %v", n, err)
+	}
+	if n, err := e.StringLength("missing"); err != nil || n != 0 {
+		t.Fatalf("expected length 0, got %d, %v", n, err)
+	}
+
+	substrings := []struct {
+		start, end int
+		want       string
+	}{
+		{0, 5, "hello"},
+		{6, 100, "world"},
+		{-5, 11, "world"},
+		{-100, 2, "he"},
+		{5, 2, ""},
+	}
+	for _, tt := range substrings {
+		got, err := e.StringSubstring("log", tt.start, tt.end)
+		if err != nil || got != tt.want {
+			t.Fatalf("StringSubstring(%d, %d): expected %q, got %q, %v", tt.start, tt.end, tt.want, got, err)
+		}
+	}
+
+	if _, err := e.StringSubstring("missing", 0, 1); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	e.Set("num", mustValue(t, int32(1)))
+	if _, err := e.StringAppend("num", "x"); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected ErrTypeMismatch, got %v", err)
+	}
+	if _, err := e.StringLength("num"); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected ErrTypeMismatch, got %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true})
+
+	const total = 1000
+	for i := 0; i < total; i++ {
+		e.Set(fmt.Sprintf("player:%d", i), mustValue(t, int32(i)))
+	}
+	e.Set("other", mustValue(t, "x"))
+
+	seen := make(map[string]int)
+	cursor := ScanCursor{}
+	pages := 0
+	for {
+		keys, next := e.Scan(cursor, 64, "player:*")
+		if len(keys) > 64 {
+			t.Fatalf("expected at most 64 keys per page, got %d", len(keys))
+		}
+		for _, k := range keys {
+			seen[k]++
+		}
+		pages++
+
+		// mutations during the scan must not cause keys to be skipped or duplicated
+		e.Set(fmt.Sprintf("new:%d", pages), mustValue(t, int32(pages)))
+
+		if next.Done() {
+			break
+		}
+		cursor = next
+	}
+
+	if len(seen) != total {
+		t.Fatalf("expected %d keys, got %d", total, len(seen))
+	}
+	for k, n := range seen {
+		if n != 1 {
+			t.Fatalf("expected %s to be returned once, got %d", k, n)
+		}
+	}
+	if pages < total/64 {
+		t.Fatalf("expected at least %d pages, got %d", total/64, pages)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"*", "anything", true},
+		{"player:*", "player:1", true},
+		{"player:*", "players:1", false},
+		{"user:?", "user:1", true},
+		{"user:?", "user:12", false},
+		{"*:score", "player:1:score", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"[a-c]x", "bx", true},
+		{"[a-c]x", "dx", false},
+		{"[!a-c]x", "dx", true},
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestCompareAndSetAndDelete(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true})
+
+	// revision 0 means the key must not exist
+	rev1, err := e.CompareAndSet("k", 0, mustValue(t, "a"))
+	if err != nil || rev1 == 0 {
+		t.Fatalf("expected create to succeed, got %d, %v", rev1, err)
+	}
+	if current, err := e.CompareAndSet("k", 0, mustValue(t, "b")); !errors.Is(err, ErrRevisionMismatch) || current != rev1 {
+		t.Fatalf("expected conflict with current revision %d, got %d, %v", rev1, current, err)
+	}
+
+	val, rev := e.GetWithRevision("k")
+	if val.AsString() != "a" || rev != rev1 {
+		t.Fatalf("expected a at revision %d, got %v at %d", rev1, val, rev)
+	}
+
+	rev2, err := e.CompareAndSet("k", rev1, mustValue(t, "b"))
+	if err != nil || rev2 <= rev1 {
+		t.Fatalf("expected update to a higher revision, got %d, %v", rev2, err)
+	}
+	if _, err := e.CompareAndSet("k", rev1, mustValue(t, "c")); !errors.Is(err, ErrRevisionMismatch) {
+		t.Fatalf("expected stale revision to conflict, got %v", err)
+	}
+
+	// plain writes also advance the revision
+	e.Set("k", mustValue(t, "d"))
+	if _, rev3 := e.GetWithRevision("k"); rev3 <= rev2 {
+		t.Fatalf("expected Set to advance the revision, got %d after %d", rev3, rev2)
+	}
+	if _, err := e.CompareAndDelete("k", rev2); !errors.Is(err, ErrRevisionMismatch) {
+		t.Fatalf("expected stale delete to conflict, got %v", err)
+	}
+
+	_, rev3 := e.GetWithRevision("k")
+	if _, err := e.CompareAndDelete("k", rev3); err != nil {
+		t.Fatalf("expected delete to succeed, got %v", err)
+	}
+	if e.Exists("k") {
+		t.Fatalf("expected k to be deleted")
+	}
+
+	// revisions are never reused after a delete
+	rev4, err := e.CompareAndSet("k", 0, mustValue(t, "e"))
+	if err != nil || rev4 <= rev3 {
+		t.Fatalf("expected recreated key to get a higher revision, got %d, %v", rev4, err)
+	}
+}
+
+func TestPersistentEngineRestoresRevisions(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(filepath.Join("data", "testdb")) })
+
+	e := newPersistentTestEngine(t)
+	e.Set("snapshotted", mustValue(t, "a"))
+	if err := e.snapshot(); err != nil {
+		t.Fatalf("snapshot error: %v", err)
+	}
+	rev, err := e.CompareAndSet("logged", 0, mustValue(t, "b"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, snapshotRev := e.GetWithRevision("snapshotted")
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	e = newPersistentTestEngine(t)
+	defer e.Close()
+
+	if _, got := e.GetWithRevision("snapshotted"); got != snapshotRev {
+		t.Fatalf("expected revision %d from snapshot, got %d", snapshotRev, got)
+	}
+	if _, got := e.GetWithRevision("logged"); got != rev {
+		t.Fatalf("expected revision %d from log, got %d", rev, got)
+	}
+
+	next, err := e.CompareAndSet("logged", rev, mustValue(t, "c"))
+	if err != nil || next <= rev {
+		t.Fatalf("expected new revision above %d after restart, got %d, %v", rev, next, err)
+	}
+}
+
+func TestTxn(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true})
+
+	e.Set("a", mustValue(t, int64(1)))
+	_, revA := e.GetWithRevision("a")
+
+	revisions, err := e.Txn([]TxnOp{
+		{Type: TxnOpCheck, Key: "a", Revision: revA},
+		{Type: TxnOpSet, Key: "b", Value: mustValue(t, "b")},
+		{Type: TxnOpCompareAndSet, Key: "c", Revision: 0, Value: mustValue(t, "c")},
+		{Type: TxnOpDelete, Key: "a"},
+	})
+	if err != nil {
+		t.Fatalf("expected txn to succeed, got %v", err)
+	}
+	if revisions[0] != 0 || revisions[1] <= revA || revisions[2] <= revisions[1] || revisions[3] != 0 {
+		t.Fatalf("unexpected revisions %v", revisions)
+	}
+	if e.Exists("a") || e.Get("b").AsString() != "b" || e.Get("c").AsString() != "c" {
+		t.Fatalf("expected all writes to be applied")
+	}
+
+	// a failing condition discards all writes
+	_, err = e.Txn([]TxnOp{
+		{Type: TxnOpSet, Key: "d", Value: mustValue(t, "d")},
+		{Type: TxnOpCompareAndDelete, Key: "b", Revision: revisions[1]},
+		{Type: TxnOpCheck, Key: "c", Revision: revisions[1]},
+	})
+	var conflict *TxnConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrRevisionMismatch) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if conflict.Op != 2 || conflict.Revision != revisions[2] {
+		t.Fatalf("expected conflict on op 2 at revision %d, got %+v", revisions[2], conflict)
+	}
+	if e.Exists("d") || !e.Exists("b") {
+		t.Fatalf("expected no writes of the failed txn to be applied")
+	}
+
+	if _, err := e.Txn([]TxnOp{{Type: TxnOpSet, Key: "e"}}); !errors.Is(err, ErrInvalidOperand) {
+		t.Fatalf("expected set without value to fail, got %v", err)
+	}
+	if _, err := e.Txn([]TxnOp{{Type: 42, Key: "e"}}); !errors.Is(err, ErrInvalidOperand) {
+		t.Fatalf("expected unknown op type to fail, got %v", err)
+	}
+}
+
+func TestPersistentEngineTxnTornBatch(t *testing.T) {
+	t.Cleanup(func() { os.RemoveAll(filepath.Join("data", "testdb")) })
+
+	e := newPersistentTestEngine(t)
+
+	if _, err := e.Txn([]TxnOp{
+		{Type: TxnOpSet, Key: "a", Value: mustValue(t, "a")},
+		{Type: TxnOpSet, Key: "b", Value: mustValue(t, "b")},
+	}); err != nil {
+		t.Fatalf("Txn error: %v", err)
+	}
+	if _, err := e.Txn([]TxnOp{
+		{Type: TxnOpDelete, Key: "a"},
+		{Type: TxnOpSet, Key: "c", Value: mustValue(t, "c")},
+		{Type: TxnOpSet, Key: "d", Value: mustValue(t, "d")},
+	}); err != nil {
+		t.Fatalf("Txn error: %v", err)
+	}
+
+	logPath := e.persistence.logPath(e.persistence.gen)
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	// simulate a crash in the middle of writing the second transaction
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	if err := os.Truncate(logPath, info.Size()-3); err != nil {
+		t.Fatalf("Truncate error: %v", err)
+	}
+
+	e = newPersistentTestEngine(t)
+	defer e.Close()
+
+	if !e.Exists("a") || !e.Exists("b") {
+		t.Fatalf("expected the first transaction to be restored")
+	}
+	if e.Exists("c") || e.Exists("d") {
+		t.Fatalf("expected no write of the torn transaction to be restored")
+	}
+}
+
+func TestTxnConcurrentTransfers(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true})
+
+	const accounts = 16
+	const total = accounts * 100
+	for i := 0; i < accounts; i++ {
+		e.Set(fmt.Sprintf("account:%d", i), mustValue(t, int64(100)))
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				from := fmt.Sprintf("account:%d", (w+i)%accounts)
+				to := fmt.Sprintf("account:%d", (w*3+i*7+1)%accounts)
+				if from == to {
+					continue
+				}
+
+				fromVal, fromRev := e.GetWithRevision(from)
+				toVal, toRev := e.GetWithRevision(to)
+				_, _ = e.Txn([]TxnOp{
+					{Type: TxnOpCompareAndSet, Key: from, Revision: fromRev, Value: mustValue(t, fromVal.AsInt64()-1)},
+					{Type: TxnOpCompareAndSet, Key: to, Revision: toRev, Value: mustValue(t, toVal.AsInt64()+1)},
+				})
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	sum := int64(0)
+	for i := 0; i < accounts; i++ {
+		sum += e.Get(fmt.Sprintf("account:%d", i)).AsInt64()
+	}
+	if sum != total {
+		t.Fatalf("expected total of %d to be preserved, got %d", total, sum)
+	}
+}
+
+func TestLimitReject(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true, MaxEntries: 2})
+
+	if err := e.Set("a", mustValue(t, "a")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := e.Set("b", mustValue(t, "b")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := e.Set("c", mustValue(t, "c")); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+
+	// overwriting an existing key does not add an entry
+	if err := e.Set("a", mustValue(t, "aa")); err != nil {
+		t.Fatalf("expected overwrite to succeed, got %v", err)
+	}
+	if _, err := e.Txn([]TxnOp{
+		{Type: TxnOpDelete, Key: "a"},
+		{Type: TxnOpSet, Key: "c", Value: mustValue(t, "c")},
+	}); err != nil {
+		t.Fatalf("expected txn that frees an entry first to succeed, got %v", err)
+	}
+	if _, err := e.Txn([]TxnOp{{Type: TxnOpSet, Key: "d", Value: mustValue(t, "d")}}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected txn to be rejected, got %v", err)
+	}
+
+	stats := e.Stats()
+	if stats.Entries != 2 || stats.RejectedWrites != 2 || stats.Evictions != 0 || stats.EvictionPolicy != string(EvictionReject) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLimitMemoryAccounting(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true})
+
+	e.Set("a", mustValue(t, "hello"))
+	e.Set("b", mustValue(t, int64(1)))
+	_, _ = e.ListRightPush("c", []*codex.Value{mustValue(t, "x"), mustValue(t, "y")})
+	e.Set("a", mustValue(t, "hello world"))
+	e.Delete("b")
+
+	stats := e.Stats()
+	if stats.MemoryBytes != e.Size() || stats.Entries != uint64(e.Count()) {
+		t.Fatalf("expected stats to match Size %d and Count %d, got %+v", e.Size(), e.Count(), stats)
+	}
+
+	e.DeleteAll()
+	if stats := e.Stats(); stats.MemoryBytes != 0 || stats.Entries != 0 {
+		t.Fatalf("expected empty stats after DeleteAll, got %+v", stats)
+	}
+}
+
+func TestEvictionLRU(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true, MaxEntries: 10, EvictionPolicy: EvictionLRU})
+
+	for i := 0; i < 10; i++ {
+		e.Set(fmt.Sprintf("key%d", i), mustValue(t, int64(i)))
+		time.Sleep(time.Millisecond)
+	}
+	// key0 becomes the most recently used key
+	e.Get("key0")
+
+	for i := 10; i < 15; i++ {
+		if err := e.Set(fmt.Sprintf("key%d", i), mustValue(t, int64(i))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	stats := e.Stats()
+	if stats.Entries != 10 || stats.Evictions != 5 {
+		t.Fatalf("expected 10 entries after 5 evictions, got %+v", stats)
+	}
+	if !e.Exists("key0") || !e.Exists("key14") {
+		t.Fatalf("expected recently used keys to survive")
+	}
+}
+
+func TestEvictionVolatileTTL(t *testing.T) {
+	e := NewEngine(Configuration{MaxEntries: 4, EvictionPolicy: EvictionVolatileTTL})
+	defer e.Close()
+
+	expires := time.Now().Add(time.Hour).UnixNano()
+	e.Set("permanent1", mustValue(t, "p"))
+	e.Set("permanent2", mustValue(t, "p"))
+	e.SetWithTTL("volatile1", mustValue(t, "v"), expires)
+	e.SetWithTTL("volatile2", mustValue(t, "v"), expires+1)
+
+	e.Set("new1", mustValue(t, "n"))
+	e.Set("new2", mustValue(t, "n"))
+
+	if e.Exists("volatile1") || e.Exists("volatile2") {
+		t.Fatalf("expected entries with expiration to be evicted first")
+	}
+	if !e.Exists("permanent1") || !e.Exists("permanent2") || !e.Exists("new1") || !e.Exists("new2") {
+		t.Fatalf("expected entries without expiration to survive")
+	}
+}
+
+func TestEvictionMemoryLimit(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true, MaxMemoryBytes: 1024, EvictionPolicy: EvictionRandom})
+
+	for i := 0; i < 1000; i++ {
+		if err := e.Set(fmt.Sprintf("key%d", i), mustValue(t, "0123456789")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	stats := e.Stats()
+	if stats.MemoryBytes > 1024 || stats.Evictions == 0 {
+		t.Fatalf("expected memory to stay within the limit by evicting, got %+v", stats)
+	}
+
+	// values that can never fit are rejected with every policy
+	big := make([]byte, 2048)
+	if err := e.Set("big", mustValue(t, big)); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded for an oversized value, got %v", err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	var mu sync.Mutex
+	var events []Event
+	e := NewEngine(Configuration{
+		MaxEntries:     2,
+		EvictionPolicy: EvictionRandom,
+		OnEvent: func(event Event) {
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+		},
+	})
+	defer e.Close()
+
+	e.Set("a", mustValue(t, "a"))
+	_, _ = e.ListRightPush("b", []*codex.Value{mustValue(t, "x")})
+	e.Delete("a")
+	e.Delete("missing")
+	e.Set("c", mustValue(t, "c"))
+	e.SetWithTTL("d", mustValue(t, "d"), time.Now().Add(time.Millisecond).UnixNano()) // evicts b or c
+
+	// expired entries are reported by the cleanup job
+	time.Sleep(2 * time.Millisecond)
+	e.cleanup()
+
+	// events are delivered asynchronously
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n >= 7 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var types []EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	expected := []EventType{EventSet, EventSet, EventDelete, EventSet, EventSet, EventEvict, EventExpire}
+	if fmt.Sprint(types) != fmt.Sprint(expected) {
+		t.Fatalf("expected events %v, got %v", expected, types)
+	}
+
+	if events[0].Key != "a" || events[0].Value.AsString() != "a" || events[0].Revision == 0 {
+		t.Fatalf("unexpected set event %+v", events[0])
+	}
+	if events[2].Key != "a" || events[2].Value != nil {
+		t.Fatalf("unexpected delete event %+v", events[2])
+	}
+	if events[6].Key != "d" {
+		t.Fatalf("unexpected expire event %+v", events[6])
+	}
+}
+
+func TestEventsDroppedWhenHandlerIsSlow(t *testing.T) {
+	release := make(chan struct{})
+	e := NewEngine(Configuration{
+		OnEvent: func(event Event) {
+			<-release
+		},
+	})
+	defer e.Close()
+	defer close(release)
+
+	// writes must not block on the handler
+	for i := 0; i < eventQueueSize+10; i++ {
+		if err := e.Set(fmt.Sprintf("key%d", i), mustValue(t, int64(i))); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+	}
+
+	if dropped := e.Stats().DroppedEvents; dropped == 0 {
+		t.Fatalf("expected dropped events, got %d", dropped)
+	}
+}
+
+func TestCleanupExpiryIndex(t *testing.T) {
+	e := NewEngine(Configuration{})
+	defer e.Close()
+
+	past := time.Now().Add(time.Millisecond).UnixNano()
+	future := time.Now().Add(time.Hour).UnixNano()
+	for i := 0; i < 1000; i++ {
+		e.SetWithTTL(fmt.Sprintf("key%d", i), mustValue(t, int64(i)), past)
+	}
+	// overwritten and deleted keys leave stale items in the index, which must not remove the new entries
+	for i := 0; i < 100; i++ {
+		e.Set(fmt.Sprintf("key%d", i), mustValue(t, int64(i)))
+	}
+	for i := 100; i < 200; i++ {
+		e.SetWithTTL(fmt.Sprintf("key%d", i), mustValue(t, int64(i)), future)
+	}
+	for i := 200; i < 300; i++ {
+		e.Delete(fmt.Sprintf("key%d", i))
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	e.cleanup()
+
+	if count := e.Count(); count != 200 {
+		t.Fatalf("expected 200 entries to remain, got %d", count)
+	}
+	if stats := e.Stats(); stats.Expirations != 700 {
+		t.Fatalf("expected 700 expirations, got %d", stats.Expirations)
+	}
+	for i := 0; i < 200; i++ {
+		if !e.Exists(fmt.Sprintf("key%d", i)) {
+			t.Fatalf("expected key%d to survive", i)
+		}
+	}
+}
+
+func TestCleanupBoundedPerShard(t *testing.T) {
+	e := NewEngine(Configuration{DisableTTL: true})
+	s := &e.shards[0]
+
+	s.mu.Lock()
+	now := time.Now().UnixNano()
+	for i := 0; i < maxExpirationsPerShard*2+1; i++ {
+		key := fmt.Sprintf("key%d", i)
+		e.store(s, key, &entry{value: mustValue(t, "v"), expires: now - 1, size: entrySize(key, mustValue(t, "v"))})
+	}
+
+	e.expireShard(s, now)
+	if len(s.data) != maxExpirationsPerShard+1 {
+		t.Fatalf("expected one tick to expire %d entries, %d remain", maxExpirationsPerShard, len(s.data))
+	}
+	e.expireShard(s, now)
+	e.expireShard(s, now)
+	if len(s.data) != 0 || s.nextExpiry.Load() != 0 {
+		t.Fatalf("expected all entries to expire after three ticks, %d remain", len(s.data))
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,243 @@
+package kvengine
+
+import (
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+)
+
+// listOf returns the list stored in en, or ErrTypeMismatch if en does not hold a list.
+func listOf(en *entry) ([]*codex.Value, error) {
+	if en.value.Type != codex.TypeList {
+		return nil, ErrTypeMismatch
+	}
+
+	return en.value.AsList(), nil
+}
+
+// resolveIndex converts a possibly negative index (counting from the end) into a position in a list of the given length.
+func resolveIndex(index, length int) (int, error) {
+	if index < 0 {
+		index += length
+	}
+	if index < 0 || index >= length {
+		return 0, ErrOutOfRange
+	}
+
+	return index, nil
+}
+
+// ListLength returns the number of items in the list stored for key.
+// Missing keys are treated as empty lists.
+func (e *Engine) ListLength(key string) (uint32, error) {
+	s := e.shardFor(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	en, ok := s.live(key)
+	if !ok {
+		return 0, nil
+	}
+
+	list, err := listOf(en)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(len(list)), nil
+}
+
+// ListGet returns the item at index in the list stored for key.
+// Negative indexes count from the end of the list (-1 is the last item).
+func (e *Engine) ListGet(key string, index int) (*codex.Value, error) {
+	s := e.shardFor(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	en, ok := s.live(key)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	list, err := listOf(en)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := resolveIndex(index, len(list))
+	if err != nil {
+		return nil, err
+	}
+
+	return list[i], nil
+}
+
+// ListSet replaces the item at index in the list stored for key.
+// Negative indexes count from the end of the list (-1 is the last item).
+func (e *Engine) ListSet(key string, index int, value *codex.Value) error {
+	s := e.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	en, ok := s.live(key)
+	if !ok {
+		return ErrKeyNotFound
+	}
+
+	list, err := listOf(en)
+	if err != nil {
+		return err
+	}
+
+	i, err := resolveIndex(index, len(list))
+	if err != nil {
+		return err
+	}
+
+	updated := make([]*codex.Value, len(list))
+	copy(updated, list)
+	updated[i] = value
+
+	_, err = e.replace(s, key, codex.NewListValue(updated), en.expires)
+	return err
+}
+
+// ListRemove removes the item at index from the list stored for key and returns it.
+// Negative indexes count from the end of the list (-1 is the last item).
+// The key is deleted when the list becomes empty.
+func (e *Engine) ListRemove(key string, index int) (*codex.Value, error) {
+	s := e.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	en, ok := s.live(key)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	list, err := listOf(en)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := resolveIndex(index, len(list))
+	if err != nil {
+		return nil, err
+	}
+
+	removed := list[i]
+	if len(list) == 1 {
+		if err := e.remove(s, key, EventDelete); err != nil {
+			return nil, err
+		}
+		return removed, nil
+	}
+
+	updated := make([]*codex.Value, 0, len(list)-1)
+	updated = append(updated, list[:i]...)
+	updated = append(updated, list[i+1:]...)
+
+	if _, err := e.replace(s, key, codex.NewListValue(updated), en.expires); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// ListLeftPush inserts values at the head of the list stored for key and returns the new length of the list.
+// The values are inserted one after another, so the last value ends up first.
+// Missing keys are created as empty lists without expiration.
+func (e *Engine) ListLeftPush(key string, values []*codex.Value) (uint32, error) {
+	return e.listPush(key, values, true)
+}
+
+// ListRightPush appends values to the tail of the list stored for key and returns the new length of the list.
+// Missing keys are created as empty lists without expiration.
+func (e *Engine) ListRightPush(key string, values []*codex.Value) (uint32, error) {
+	return e.listPush(key, values, false)
+}
+
+func (e *Engine) listPush(key string, values []*codex.Value, left bool) (uint32, error) {
+	s := e.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*codex.Value
+	var expires int64
+	if en, ok := s.live(key); ok {
+		var err error
+		list, err = listOf(en)
+		if err != nil {
+			return 0, err
+		}
+		expires = en.expires
+	}
+
+	var updated []*codex.Value
+	if left {
+		updated = make([]*codex.Value, 0, len(values)+len(list))
+		for i := len(values) - 1; i >= 0; i-- {
+			updated = append(updated, values[i])
+		}
+		updated = append(updated, list...)
+	} else {
+		// appending never touches the items visible through the old list, so the backing array can be shared
+		updated = append(list, values...)
+	}
+
+	if _, err := e.replace(s, key, codex.NewListValue(updated), expires); err != nil {
+		return 0, err
+	}
+	return uint32(len(updated)), nil
+}
+
+// ListLeftPop removes and returns the first item of the list stored for key.
+// The key is deleted when the list becomes empty.
+func (e *Engine) ListLeftPop(key string) (*codex.Value, error) {
+	return e.listPop(key, true)
+}
+
+// ListRightPop removes and returns the last item of the list stored for key.
+// The key is deleted when the list becomes empty.
+func (e *Engine) ListRightPop(key string) (*codex.Value, error) {
+	return e.listPop(key, false)
+}
+
+func (e *Engine) listPop(key string, left bool) (*codex.Value, error) {
+	s := e.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	en, ok := s.live(key)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	list, err := listOf(en)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	var popped *codex.Value
+	var updated []*codex.Value
+	if left {
+		popped = list[0]
+		updated = list[1:]
+	} else {
+		popped = list[len(list)-1]
+		// cap the capacity so that a later push cannot overwrite the popped slot of the old list
+		updated = list[: len(list)-1 : len(list)-1]
+	}
+
+	if len(updated) == 0 {
+		if err := e.remove(s, key, EventDelete); err != nil {
+			return nil, err
+		}
+		return popped, nil
+	}
+
+	if _, err := e.replace(s, key, codex.NewListValue(updated), en.expires); err != nil {
+		return nil, err
+	}
+	return popped, nil
+}
@@ -0,0 +1,167 @@
+package kvengine
+
+import (
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+)
+
+// mapOf returns the map stored in en, or ErrTypeMismatch if en does not hold a map.
+func mapOf(en *entry) (map[string]*codex.Value, error) {
+	if en.value.Type != codex.TypeMap {
+		return nil, ErrTypeMismatch
+	}
+
+	return en.value.AsMap(), nil
+}
+
+// readMap returns the map stored for key, or nil if the key does not exist.
+func (e *Engine) readMap(key string) (map[string]*codex.Value, error) {
+	s := e.shardFor(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	en, ok := s.live(key)
+	if !ok {
+		return nil, nil
+	}
+
+	return mapOf(en)
+}
+
+// MapLength returns the number of fields in the map stored for key.
+// Missing keys are treated as empty maps.
+func (e *Engine) MapLength(key string) (uint32, error) {
+	m, err := e.readMap(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(len(m)), nil
+}
+
+// MapSet sets field to value in the map stored for key.
+// Missing keys are created as empty maps without expiration.
+func (e *Engine) MapSet(key, field string, value *codex.Value) error {
+	s := e.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var m map[string]*codex.Value
+	var expires int64
+	if en, ok := s.live(key); ok {
+		var err error
+		m, err = mapOf(en)
+		if err != nil {
+			return err
+		}
+		expires = en.expires
+	}
+
+	updated := make(map[string]*codex.Value, len(m)+1)
+	for k, v := range m {
+		updated[k] = v
+	}
+	updated[field] = value
+
+	_, err := e.replace(s, key, codex.NewMapValue(updated), expires)
+	return err
+}
+
+// MapGet returns the value of field in the map stored for key.
+// Returns ErrKeyNotFound if either the key or the field does not exist.
+func (e *Engine) MapGet(key, field string) (*codex.Value, error) {
+	m, err := e.readMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	val, ok := m[field]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return val, nil
+}
+
+// MapDelete removes field from the map stored for key and reports whether it existed.
+// The key is deleted when the map becomes empty.
+func (e *Engine) MapDelete(key, field string) (bool, error) {
+	s := e.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	en, ok := s.live(key)
+	if !ok {
+		return false, nil
+	}
+
+	m, err := mapOf(en)
+	if err != nil {
+		return false, err
+	}
+
+	if _, exists := m[field]; !exists {
+		return false, nil
+	}
+
+	if len(m) == 1 {
+		if err := e.remove(s, key, EventDelete); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	updated := make(map[string]*codex.Value, len(m)-1)
+	for k, v := range m {
+		if k != field {
+			updated[k] = v
+		}
+	}
+
+	if _, err := e.replace(s, key, codex.NewMapValue(updated), en.expires); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MapExists reports whether field exists in the map stored for key.
+func (e *Engine) MapExists(key, field string) (bool, error) {
+	m, err := e.readMap(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := m[field]
+	return ok, nil
+}
+
+// MapKeys returns the fields of the map stored for key.
+// Missing keys are treated as empty maps.
+func (e *Engine) MapKeys(key string) ([]string, error) {
+	m, err := e.readMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// MapValues returns the values of the map stored for key.
+// Missing keys are treated as empty maps.
+func (e *Engine) MapValues(key string) ([]*codex.Value, error) {
+	m, err := e.readMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]*codex.Value, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+
+	return values, nil
+}
@@ -2,6 +2,7 @@ package kvengine
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
 )
@@ -11,10 +12,32 @@ const ShardCount = 256
 type entry struct {
 	value   *codex.Value
 	expires int64 // unix nanos, 0 means no expiration
+
+	// revision is assigned from a counter shared by all shards of the engine whenever the entry is written.
+	// It increases with every write and is never reused, not even after the key is deleted and created again.
+	revision uint64
+
+	// size is the number of bytes accounted for the entry, see entrySize.
+	size int64
+
+	// lastAccess (unix nanos) and hits are only maintained if the eviction policy of the engine needs them.
+	lastAccess atomic.Int64
+	hits       atomic.Uint32
 }
 
 type shard struct {
 	index int
 	mu    sync.RWMutex
 	data  map[string]*entry
+
+	// size is the sum of the sizes of all entries in data.
+	size int64
+
+	// trackAccess enables updating entry.lastAccess and entry.hits on every access.
+	trackAccess bool
+
+	// expiry indexes the entries with an expiration time, see expiryHeap.
+	expiry expiryHeap
+	// nextExpiry is the earliest expiration time in expiry (0 if empty), it can be read without holding the lock.
+	nextExpiry atomic.Int64
 }
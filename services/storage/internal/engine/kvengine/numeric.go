@@ -0,0 +1,427 @@
+package kvengine
+
+import (
+	"math"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+)
+
+// NumOp is an atomic read-modify-write operation on a numeric value.
+type NumOp uint8
+
+const (
+	NumOpIncrement NumOp = iota + 1
+	NumOpDecrement
+	NumOpMultiply
+	NumOpDivide
+	NumOpModulo
+	NumOpLeftShift
+	NumOpRightShift
+	NumOpBitwiseAnd
+	NumOpBitwiseOr
+	NumOpBitwiseXor
+	NumOpBitwiseNot
+)
+
+// ApplyNumOp atomically applies op with the given operand to the numeric value stored for key and returns the new value.
+// The new value keeps the type and the expiration time of the stored value.
+//
+// If the key does not exist or has expired, the stored value is treated as zero of the operand's type.
+// NumOpBitwiseNot takes no operand (nil) and returns ErrKeyNotFound for missing keys.
+//
+// Integer values support all operations, float values only the arithmetic ones.
+// The operand must be representable in the type of the stored value, integers cannot be combined with float operands.
+// Arithmetic results that do not fit into the type of the stored value return ErrOverflow, shifts and bitwise operations work on the bits of the type.
+func (e *Engine) ApplyNumOp(key string, op NumOp, operand *codex.Value) (*codex.Value, error) {
+	if op != NumOpBitwiseNot && (operand == nil || !operand.IsNumeric()) {
+		return nil, ErrInvalidOperand
+	}
+
+	s := e.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current *codex.Value
+	var expires int64
+	if en, ok := s.live(key); ok {
+		current = en.value
+		expires = en.expires
+	}
+
+	if current == nil {
+		if op == NumOpBitwiseNot {
+			return nil, ErrKeyNotFound
+		}
+
+		zero, err := zeroOf(operand.Type)
+		if err != nil {
+			return nil, err
+		}
+		current = zero
+	}
+
+	result, err := applyNumOp(current, op, operand)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := e.replace(s, key, result, expires); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func applyNumOp(current *codex.Value, op NumOp, operand *codex.Value) (*codex.Value, error) {
+	switch current.Type {
+	case codex.TypeInt16, codex.TypeInt32, codex.TypeInt64:
+		return applySignedNumOp(current, op, operand)
+	case codex.TypeByte, codex.TypeUint16, codex.TypeUint32, codex.TypeUint64:
+		return applyUnsignedNumOp(current, op, operand)
+	case codex.TypeFloat32, codex.TypeFloat64:
+		return applyFloatNumOp(current, op, operand)
+	default:
+		return nil, ErrTypeMismatch
+	}
+}
+
+func applySignedNumOp(current *codex.Value, op NumOp, operand *codex.Value) (*codex.Value, error) {
+	bits := bitSize(current.Type)
+	minVal := int64(-1) << (bits - 1)
+	maxVal := -(minVal + 1)
+
+	a := signedOf(current)
+
+	var b int64
+	if op != NumOpBitwiseNot {
+		var err error
+		b, err = signedOperand(operand, minVal, maxVal)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var r int64
+	switch op {
+	case NumOpIncrement:
+		r = a + b
+		if (r > a) != (b > 0) {
+			return nil, ErrOverflow
+		}
+	case NumOpDecrement:
+		r = a - b
+		if (r < a) != (b > 0) {
+			return nil, ErrOverflow
+		}
+	case NumOpMultiply:
+		if a != 0 && b != 0 {
+			r = a * b
+			if r/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+				return nil, ErrOverflow
+			}
+		}
+	case NumOpDivide:
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+		if a == math.MinInt64 && b == -1 {
+			return nil, ErrOverflow
+		}
+		r = a / b
+	case NumOpModulo:
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+		r = a % b
+	case NumOpLeftShift:
+		if b < 0 || b >= int64(bits) {
+			return nil, ErrInvalidOperand
+		}
+		// shift within the width of the type and sign-extend the result
+		r = (a << (b + int64(64-bits))) >> (64 - bits)
+	case NumOpRightShift:
+		if b < 0 || b >= int64(bits) {
+			return nil, ErrInvalidOperand
+		}
+		r = a >> b
+	case NumOpBitwiseAnd:
+		r = a & b
+	case NumOpBitwiseOr:
+		r = a | b
+	case NumOpBitwiseXor:
+		r = a ^ b
+	case NumOpBitwiseNot:
+		r = ^a
+	default:
+		return nil, ErrInvalidOperand
+	}
+
+	if r < minVal || r > maxVal {
+		return nil, ErrOverflow
+	}
+
+	return signedValue(current.Type, r), nil
+}
+
+func applyUnsignedNumOp(current *codex.Value, op NumOp, operand *codex.Value) (*codex.Value, error) {
+	bits := bitSize(current.Type)
+	maxVal := uint64(math.MaxUint64) >> (64 - bits)
+
+	a := unsignedOf(current)
+
+	var b uint64
+	if op != NumOpBitwiseNot {
+		var err error
+		b, err = unsignedOperand(operand, maxVal)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var r uint64
+	switch op {
+	case NumOpIncrement:
+		r = a + b
+		if r < a {
+			return nil, ErrOverflow
+		}
+	case NumOpDecrement:
+		if b > a {
+			return nil, ErrOverflow
+		}
+		r = a - b
+	case NumOpMultiply:
+		if a != 0 && b != 0 {
+			r = a * b
+			if r/b != a {
+				return nil, ErrOverflow
+			}
+		}
+	case NumOpDivide:
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+		r = a / b
+	case NumOpModulo:
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+		r = a % b
+	case NumOpLeftShift:
+		if b >= uint64(bits) {
+			return nil, ErrInvalidOperand
+		}
+		r = (a << b) & maxVal
+	case NumOpRightShift:
+		if b >= uint64(bits) {
+			return nil, ErrInvalidOperand
+		}
+		r = a >> b
+	case NumOpBitwiseAnd:
+		r = a & b
+	case NumOpBitwiseOr:
+		r = a | b
+	case NumOpBitwiseXor:
+		r = a ^ b
+	case NumOpBitwiseNot:
+		r = ^a & maxVal
+	default:
+		return nil, ErrInvalidOperand
+	}
+
+	if r > maxVal {
+		return nil, ErrOverflow
+	}
+
+	return unsignedValue(current.Type, r), nil
+}
+
+func applyFloatNumOp(current *codex.Value, op NumOp, operand *codex.Value) (*codex.Value, error) {
+	var a float64
+	if current.Type == codex.TypeFloat32 {
+		a = float64(current.AsFloat32())
+	} else {
+		a = current.AsFloat64()
+	}
+
+	if op == NumOpBitwiseNot {
+		return nil, ErrTypeMismatch
+	}
+	b := floatOf(operand)
+
+	var r float64
+	switch op {
+	case NumOpIncrement:
+		r = a + b
+	case NumOpDecrement:
+		r = a - b
+	case NumOpMultiply:
+		r = a * b
+	case NumOpDivide:
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+		r = a / b
+	case NumOpModulo:
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+		r = math.Mod(a, b)
+	default:
+		return nil, ErrTypeMismatch
+	}
+
+	if current.Type == codex.TypeFloat32 {
+		if math.Abs(r) > math.MaxFloat32 && !math.IsInf(a, 0) && !math.IsInf(b, 0) {
+			return nil, ErrOverflow
+		}
+		v, _ := codex.NewValue(float32(r))
+		return v, nil
+	}
+
+	if math.IsInf(r, 0) && !math.IsInf(a, 0) && !math.IsInf(b, 0) {
+		return nil, ErrOverflow
+	}
+	v, _ := codex.NewValue(r)
+	return v, nil
+}
+
+// zeroOf returns the zero value of a numeric type.
+func zeroOf(t codex.ValueType) (*codex.Value, error) {
+	switch t {
+	case codex.TypeInt16, codex.TypeInt32, codex.TypeInt64:
+		return signedValue(t, 0), nil
+	case codex.TypeByte, codex.TypeUint16, codex.TypeUint32, codex.TypeUint64:
+		return unsignedValue(t, 0), nil
+	case codex.TypeFloat32:
+		return codex.NewValue(float32(0))
+	case codex.TypeFloat64:
+		return codex.NewValue(float64(0))
+	default:
+		return nil, ErrInvalidOperand
+	}
+}
+
+func bitSize(t codex.ValueType) uint {
+	switch t {
+	case codex.TypeByte:
+		return 8
+	case codex.TypeUint16, codex.TypeInt16:
+		return 16
+	case codex.TypeUint32, codex.TypeInt32:
+		return 32
+	default:
+		return 64
+	}
+}
+
+func signedOf(v *codex.Value) int64 {
+	switch v.Type {
+	case codex.TypeInt16:
+		return int64(v.AsInt16())
+	case codex.TypeInt32:
+		return int64(v.AsInt32())
+	default:
+		return v.AsInt64()
+	}
+}
+
+func unsignedOf(v *codex.Value) uint64 {
+	switch v.Type {
+	case codex.TypeByte:
+		return uint64(v.AsByte())
+	case codex.TypeUint16:
+		return uint64(v.AsUint16())
+	case codex.TypeUint32:
+		return uint64(v.AsUint32())
+	default:
+		return v.AsUint64()
+	}
+}
+
+func floatOf(v *codex.Value) float64 {
+	switch v.Type {
+	case codex.TypeFloat32:
+		return float64(v.AsFloat32())
+	case codex.TypeFloat64:
+		return v.AsFloat64()
+	case codex.TypeInt16, codex.TypeInt32, codex.TypeInt64:
+		return float64(signedOf(v))
+	default:
+		return float64(unsignedOf(v))
+	}
+}
+
+// signedOperand converts an integer operand to int64 and checks that it fits into [minVal, maxVal].
+func signedOperand(v *codex.Value, minVal, maxVal int64) (int64, error) {
+	var b int64
+	switch v.Type {
+	case codex.TypeInt16, codex.TypeInt32, codex.TypeInt64:
+		b = signedOf(v)
+	case codex.TypeByte, codex.TypeUint16, codex.TypeUint32, codex.TypeUint64:
+		u := unsignedOf(v)
+		if u > math.MaxInt64 {
+			return 0, ErrOverflow
+		}
+		b = int64(u)
+	default:
+		return 0, ErrTypeMismatch
+	}
+
+	if b < minVal || b > maxVal {
+		return 0, ErrOverflow
+	}
+
+	return b, nil
+}
+
+// unsignedOperand converts an integer operand to uint64 and checks that it fits into [0, maxVal].
+func unsignedOperand(v *codex.Value, maxVal uint64) (uint64, error) {
+	var b uint64
+	switch v.Type {
+	case codex.TypeInt16, codex.TypeInt32, codex.TypeInt64:
+		s := signedOf(v)
+		if s < 0 {
+			return 0, ErrOverflow
+		}
+		b = uint64(s)
+	case codex.TypeByte, codex.TypeUint16, codex.TypeUint32, codex.TypeUint64:
+		b = unsignedOf(v)
+	default:
+		return 0, ErrTypeMismatch
+	}
+
+	if b > maxVal {
+		return 0, ErrOverflow
+	}
+
+	return b, nil
+}
+
+func signedValue(t codex.ValueType, v int64) *codex.Value {
+	var val *codex.Value
+	switch t {
+	case codex.TypeInt16:
+		val, _ = codex.NewValue(int16(v))
+	case codex.TypeInt32:
+		val, _ = codex.NewValue(int32(v))
+	default:
+		val, _ = codex.NewValue(v)
+	}
+	return val
+}
+
+func unsignedValue(t codex.ValueType, v uint64) *codex.Value {
+	var val *codex.Value
+	switch t {
+	case codex.TypeByte:
+		val, _ = codex.NewValue(byte(v))
+	case codex.TypeUint16:
+		val, _ = codex.NewValue(uint16(v))
+	case codex.TypeUint32:
+		val, _ = codex.NewValue(uint32(v))
+	default:
+		val, _ = codex.NewValue(v)
+	}
+	return val
+}
@@ -0,0 +1,749 @@
+package kvengine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+)
+
+const (
+	snapshotFileName = "snapshot.bin"
+	logFilePrefix    = "aof_"
+	logFileSuffix    = ".log"
+
+	// recordHeaderSize is the size of the header in front of every record: | payload length (4 bytes) | crc32 of payload (4 bytes) |
+	recordHeaderSize = 4 + 4
+
+	// maxRecordSize limits the payload length of a single record, anything larger is treated as corruption.
+	maxRecordSize = 64 * 1024 * 1024
+
+	defaultSnapshotInterval = 5 * time.Minute
+	syncInterval            = 1 * time.Second
+)
+
+var (
+	errCorruptRecord = errors.New("corrupt record")
+	errLogClosed     = errors.New("key-value log is closed")
+)
+
+type logOp byte
+
+const (
+	logOpSet        logOp = 1
+	logOpDelete     logOp = 2
+	logOpClearShard logOp = 3
+	logOpBatch      logOp = 4
+)
+
+// logRecord is a single mutation read from the append-only log or a snapshot.
+type logRecord struct {
+	op       logOp
+	key      string
+	value    *codex.Value
+	expires  int64
+	revision uint64
+	shard    int
+	batch    []*logRecord // set and delete records of a batch, which are applied together
+}
+
+// batchWrite is a single write of a batch record, see Engine.logBatch.
+// en is nil for deletions.
+type batchWrite struct {
+	s   *shard
+	key string
+	en  *entry
+}
+
+// persistence writes every mutation of an engine to an append-only log and periodically replaces the log with a snapshot of all shards.
+// Path structure: data/{database}/{collection}/snapshot.bin and data/{database}/{collection}/aof_{generation}.log
+type persistence struct {
+	dir string
+
+	mu     sync.Mutex
+	log    *os.File
+	gen    uint64
+	size   int64  // size of the current log, guarded by mu
+	buf    []byte // scratch buffer for encoding records, guarded by mu
+	closed bool   // set by close, no new log is opened afterwards
+
+	snapshotMu sync.Mutex
+}
+
+// NewPersistentEngine creates an engine that writes every mutation to an append-only log and periodically snapshots all shards.
+// Existing data is restored from the snapshot and the logs before the engine is returned.
+// Entries that expired while the server was down are dropped during the restore.
+func NewPersistentEngine(cfg Configuration) (*Engine, error) {
+	e := newEngine(cfg)
+
+	dir := filepath.Join("data", cfg.Database, cfg.Collection)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	p := &persistence{
+		dir: dir,
+	}
+	e.persistence = p
+
+	if err := e.restore(); err != nil {
+		return nil, err
+	}
+
+	// Fold the restored state into a fresh snapshot, so the old logs can be removed
+	if err := e.snapshot(); err != nil {
+		return nil, err
+	}
+
+	interval := cfg.SnapshotInterval
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+	e.startPersistence(interval)
+	e.start()
+
+	return e, nil
+}
+
+// restore loads the snapshot and replays all logs in order of their generation.
+func (e *Engine) restore() error {
+	p := e.persistence
+
+	if err := e.replayFile(filepath.Join(p.dir, snapshotFileName), false); err != nil {
+		return err
+	}
+
+	gens, err := p.logGenerations()
+	if err != nil {
+		return err
+	}
+
+	for _, gen := range gens {
+		if err := e.replayFile(p.logPath(gen), true); err != nil {
+			return err
+		}
+		p.gen = gen
+	}
+
+	return nil
+}
+
+// replayFile applies all records of the given file to the shards.
+// If repair is true, a torn tail (e.g. after a crash) is truncated instead of failing the restore.
+// A record counts as torn tail if it reaches the end of the file, corrupt records in the middle of the file always fail the restore.
+func (e *Engine) replayFile(path string, repair bool) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	now := time.Now().UnixNano()
+
+	var offset int64
+	for {
+		rec, n, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !repair || !isTornTail(r, err, offset+int64(n), info.Size()) {
+				return fmt.Errorf("failed to read %s at offset %d: %w", path, offset, err)
+			}
+
+			slog.Warn("Truncating torn tail of key-value log",
+				slog.String("path", path),
+				slog.Int64("offset", offset),
+				sloki.WrapError(err),
+			)
+			if err := f.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+
+		offset += int64(n)
+		e.applyRecord(rec, now)
+	}
+
+	return nil
+}
+
+// isTornTail reports whether a record that failed to read with err is the torn tail of the file, which was not completely written before a crash.
+// That is the case if the record is incomplete, if it ends at the end of the file or if only zeros follow (e.g. blocks that were allocated but not written).
+// end is the offset the record claims to end at, or the offset it starts at if its length is unknown. r must be positioned behind the part of the record that was read.
+func isTornTail(r io.Reader, err error, end, size int64) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	if !errors.Is(err, errCorruptRecord) {
+		return false
+	}
+	if end >= size {
+		return true
+	}
+
+	var buf [4096]byte
+	for {
+		n, err := r.Read(buf[:])
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false
+			}
+		}
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// applyRecord applies a replayed record to the shards.
+// It does not take any locks, as it is only used while the engine is not shared yet.
+func (e *Engine) applyRecord(rec *logRecord, now int64) {
+	switch rec.op {
+	case logOpSet:
+		s := e.shardFor(rec.key)
+		if rec.expires > 0 && rec.expires <= now {
+			e.drop(s, rec.key) // expired while the server was down
+			return
+		}
+
+		if rec.revision > e.revision.Load() {
+			e.revision.Store(rec.revision)
+		}
+
+		e.store(s, rec.key, &entry{
+			value:    rec.value,
+			expires:  rec.expires,
+			revision: rec.revision,
+			size:     entrySize(rec.key, rec.value),
+		})
+	case logOpDelete:
+		s := e.shardFor(rec.key)
+		e.drop(s, rec.key)
+	case logOpClearShard:
+		if rec.shard >= 0 && rec.shard < ShardCount {
+			e.dropAll(&e.shards[rec.shard])
+		}
+	case logOpBatch:
+		for _, r := range rec.batch {
+			e.applyRecord(r, now)
+		}
+	}
+}
+
+// snapshot writes all entries to a new snapshot file and removes the logs that are covered by it.
+// The log is rotated before the shards are read, so every mutation that is not part of the snapshot is in the new log.
+// Replaying a log on top of a newer state is safe, because records always carry the resulting state and never a delta.
+func (e *Engine) snapshot() error {
+	p := e.persistence
+	p.snapshotMu.Lock()
+	defer p.snapshotMu.Unlock()
+
+	gen, err := p.rotate()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(p.dir, snapshotFileName+".tmp")
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	type snapshotItem struct {
+		key string
+		en  *entry
+	}
+
+	w := bufio.NewWriter(f)
+	now := time.Now().UnixNano()
+	items := make([]snapshotItem, 0)
+	var buf []byte
+	for i := 0; i < ShardCount; i++ {
+		s := &e.shards[i]
+
+		// Only copy the entries under the lock, encoding happens afterwards to keep the shard available
+		items = items[:0]
+		s.mu.RLock()
+		for key, en := range s.data {
+			if en.expires > 0 && en.expires <= now {
+				continue
+			}
+			items = append(items, snapshotItem{key: key, en: en})
+		}
+		s.mu.RUnlock()
+
+		for _, item := range items {
+			buf = encodeSetRecord(buf[:0], item.key, item.en)
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Atomic replace
+	if err := os.Rename(tmpPath, filepath.Join(p.dir, snapshotFileName)); err != nil {
+		return err
+	}
+
+	return p.removeLogsBefore(gen)
+}
+
+// startPersistence starts the background job that syncs the log to disk and takes snapshots.
+func (e *Engine) startPersistence(snapshotInterval time.Duration) {
+	syncTicker := time.NewTicker(syncInterval)
+	snapshotTicker := time.NewTicker(snapshotInterval)
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer syncTicker.Stop()
+		defer snapshotTicker.Stop()
+
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-syncTicker.C:
+				if err := e.persistence.sync(); err != nil {
+					slog.Error("Failed to sync key-value log", slog.String("dir", e.persistence.dir), sloki.WrapError(err))
+				}
+			case <-snapshotTicker.C:
+				if err := e.snapshot(); err != nil {
+					slog.Error("Failed to snapshot key-value engine", slog.String("dir", e.persistence.dir), sloki.WrapError(err))
+				}
+			}
+		}
+	}()
+}
+
+// logSet appends a set record to the log. Must be called while holding the lock of the key's shard.
+// If it returns an error, the record was not persisted and the entry must not be stored.
+func (e *Engine) logSet(key string, en *entry) error {
+	if e.persistence == nil {
+		return nil
+	}
+
+	p := e.persistence
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = encodeSetRecord(p.buf[:0], key, en)
+	return p.write()
+}
+
+// logDelete appends a delete record to the log. Must be called while holding the lock of the key's shard.
+// If it returns an error, the record was not persisted and the entry must not be removed.
+func (e *Engine) logDelete(key string) error {
+	if e.persistence == nil {
+		return nil
+	}
+
+	p := e.persistence
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = encodeDeleteRecord(p.buf[:0], key)
+	return p.write()
+}
+
+// logClearShard appends a record that removes all entries of a shard. Must be called while holding the lock of the shard.
+// If it returns an error, the record was not persisted and the shard must not be cleared.
+func (e *Engine) logClearShard(index int) error {
+	if e.persistence == nil {
+		return nil
+	}
+
+	p := e.persistence
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = encodeClearShardRecord(p.buf[:0], index)
+	return p.write()
+}
+
+// logBatch appends a single record with all writes to the log, so they are either all replayed or none.
+// Must be called while holding the locks of the shards of all keys.
+// If it returns an error, the record was not persisted and none of the writes must be applied.
+func (e *Engine) logBatch(writes []batchWrite) error {
+	if e.persistence == nil || len(writes) == 0 {
+		return nil
+	}
+
+	p := e.persistence
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = encodeBatchRecord(p.buf[:0], writes)
+	return p.write()
+}
+
+// write writes the encoded record in p.buf to the current log. Must be called while holding p.mu.
+// If the write fails, the log is truncated back to its previous size, so the next record is not appended behind a partial one.
+func (p *persistence) write() error {
+	if p.log == nil {
+		slog.Warn("Dropping key-value log record, log is closed", slog.String("dir", p.dir))
+		return nil
+	}
+
+	if _, err := p.log.Write(p.buf); err != nil {
+		if truncErr := p.log.Truncate(p.size); truncErr != nil {
+			return errors.Join(err, truncErr)
+		}
+		return err
+	}
+	p.size += int64(len(p.buf))
+
+	return nil
+}
+
+// rotate closes the current log and opens the log of the next generation.
+// It returns errLogClosed once the persistence is closed.
+func (p *persistence) rotate() (uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, errLogClosed
+	}
+
+	gen := p.gen + 1
+	f, err := os.OpenFile(p.logPath(gen), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+
+	if p.log != nil {
+		if err := p.log.Sync(); err != nil {
+			slog.Warn("Failed to sync key-value log before rotating", slog.String("dir", p.dir), sloki.WrapError(err))
+		}
+		if err := p.log.Close(); err != nil {
+			slog.Warn("Failed to close key-value log before rotating", slog.String("dir", p.dir), sloki.WrapError(err))
+		}
+	}
+
+	p.log = f
+	p.gen = gen
+	p.size = info.Size()
+
+	return gen, nil
+}
+
+// sync flushes the current log to disk.
+func (p *persistence) sync() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.log == nil {
+		return nil
+	}
+
+	return p.log.Sync()
+}
+
+// close syncs and closes the current log. Records written afterwards are dropped.
+func (p *persistence) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	if p.log == nil {
+		return nil
+	}
+
+	if err := p.log.Sync(); err != nil {
+		return err
+	}
+
+	err := p.log.Close()
+	p.log = nil
+
+	return err
+}
+
+func (p *persistence) logPath(gen uint64) string {
+	return filepath.Join(p.dir, logFilePrefix+strconv.FormatUint(gen, 10)+logFileSuffix)
+}
+
+// logGenerations returns the generations of all logs in the directory in ascending order.
+func (p *persistence) logGenerations() ([]uint64, error) {
+	files, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	gens := make([]uint64, 0)
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, logFilePrefix) || !strings.HasSuffix(name, logFileSuffix) {
+			continue
+		}
+
+		gen, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, logFilePrefix), logFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		gens = append(gens, gen)
+	}
+
+	sort.Slice(gens, func(i, j int) bool { return gens[i] < gens[j] })
+
+	return gens, nil
+}
+
+// removeLogsBefore removes all logs with a generation lower than gen.
+func (p *persistence) removeLogsBefore(gen uint64) error {
+	gens, err := p.logGenerations()
+	if err != nil {
+		return err
+	}
+
+	for _, g := range gens {
+		if g >= gen {
+			continue
+		}
+
+		if err := os.Remove(p.logPath(g)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeSetRecord appends a set record to dst, see appendSetPayload.
+func encodeSetRecord(dst []byte, key string, en *entry) []byte {
+	start := len(dst)
+	dst = append(dst, make([]byte, recordHeaderSize)...)
+	dst = appendSetPayload(dst, key, en)
+
+	return finishRecord(dst, start)
+}
+
+// appendSetPayload appends the payload of a set record to dst.
+// Payload format: | op (1 byte) | key length (2 bytes) | key (variable) | expires (8 bytes, unix nanos) | revision (8 bytes) | value (codex-encoded) |
+func appendSetPayload(dst []byte, key string, en *entry) []byte {
+	dst = append(dst, byte(logOpSet))
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(len(key)))
+	dst = append(dst, key...)
+	dst = binary.LittleEndian.AppendUint64(dst, uint64(en.expires))
+	dst = binary.LittleEndian.AppendUint64(dst, en.revision)
+
+	return append(dst, codex.EncodeValue(en.value)...)
+}
+
+// encodeDeleteRecord appends a delete record to dst, see appendDeletePayload.
+func encodeDeleteRecord(dst []byte, key string) []byte {
+	start := len(dst)
+	dst = append(dst, make([]byte, recordHeaderSize)...)
+	dst = appendDeletePayload(dst, key)
+
+	return finishRecord(dst, start)
+}
+
+// appendDeletePayload appends the payload of a delete record to dst.
+// Payload format: | op (1 byte) | key length (2 bytes) | key (variable) |
+func appendDeletePayload(dst []byte, key string) []byte {
+	dst = append(dst, byte(logOpDelete))
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(len(key)))
+
+	return append(dst, key...)
+}
+
+// encodeBatchRecord appends a batch record with the set and delete records of writes to dst.
+// Payload format: | op (1 byte) | count (2 bytes) | records (variable) |, each record as | length (4 bytes) | set or delete payload (variable) |
+func encodeBatchRecord(dst []byte, writes []batchWrite) []byte {
+	start := len(dst)
+	dst = append(dst, make([]byte, recordHeaderSize)...)
+
+	dst = append(dst, byte(logOpBatch))
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(len(writes)))
+	for _, w := range writes {
+		lengthAt := len(dst)
+		dst = append(dst, 0, 0, 0, 0)
+		if w.en != nil {
+			dst = appendSetPayload(dst, w.key, w.en)
+		} else {
+			dst = appendDeletePayload(dst, w.key)
+		}
+		binary.LittleEndian.PutUint32(dst[lengthAt:lengthAt+4], uint32(len(dst)-lengthAt-4))
+	}
+
+	return finishRecord(dst, start)
+}
+
+// encodeClearShardRecord appends a clear shard record to dst.
+// Payload format: | op (1 byte) | shard index (2 bytes) |
+func encodeClearShardRecord(dst []byte, index int) []byte {
+	start := len(dst)
+	dst = append(dst, make([]byte, recordHeaderSize)...)
+
+	dst = append(dst, byte(logOpClearShard))
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(index))
+
+	return finishRecord(dst, start)
+}
+
+// finishRecord fills in the header of the record starting at start.
+// Format: | payload length (4 bytes) | crc32 of payload (4 bytes) | payload (variable) |
+func finishRecord(dst []byte, start int) []byte {
+	payload := dst[start+recordHeaderSize:]
+	binary.LittleEndian.PutUint32(dst[start:start+4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(dst[start+4:start+8], crc32.ChecksumIEEE(payload))
+
+	return dst
+}
+
+// readRecord reads the next record from r and returns it together with the number of bytes consumed.
+// It returns io.EOF if r is exhausted at a record boundary, and io.ErrUnexpectedEOF or errCorruptRecord for torn or corrupt records.
+// For corrupt records with a valid length, the returned size is the size the record claims to have, so the caller can tell where it ends.
+func readRecord(r io.Reader) (*logRecord, int, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+	if length == 0 || length > maxRecordSize {
+		return nil, 0, errCorruptRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, recordHeaderSize + int(length), errCorruptRecord
+	}
+
+	rec, err := decodeRecord(payload)
+	if err != nil {
+		return nil, recordHeaderSize + int(length), err
+	}
+
+	return rec, recordHeaderSize + int(length), nil
+}
+
+// decodeRecord decodes the payload of a record, see encodeSetRecord, encodeDeleteRecord, encodeClearShardRecord and encodeBatchRecord.
+func decodeRecord(payload []byte) (*logRecord, error) {
+	rec := &logRecord{op: logOp(payload[0])}
+	data := payload[1:]
+
+	switch rec.op {
+	case logOpSet, logOpDelete:
+		if len(data) < 2 {
+			return nil, errCorruptRecord
+		}
+		keyLen := int(binary.LittleEndian.Uint16(data[0:2]))
+		if len(data) < 2+keyLen {
+			return nil, errCorruptRecord
+		}
+		rec.key = string(data[2 : 2+keyLen])
+		data = data[2+keyLen:]
+
+		if rec.op == logOpDelete {
+			return rec, nil
+		}
+
+		if len(data) < 16 {
+			return nil, errCorruptRecord
+		}
+		rec.expires = int64(binary.LittleEndian.Uint64(data[0:8]))
+		rec.revision = binary.LittleEndian.Uint64(data[8:16])
+		data = data[16:]
+
+		value, err := codex.DecodeValue(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errCorruptRecord, err)
+		}
+		rec.value = value
+	case logOpClearShard:
+		if len(data) < 2 {
+			return nil, errCorruptRecord
+		}
+		rec.shard = int(binary.LittleEndian.Uint16(data[0:2]))
+	case logOpBatch:
+		if len(data) < 2 {
+			return nil, errCorruptRecord
+		}
+		count := int(binary.LittleEndian.Uint16(data[0:2]))
+		data = data[2:]
+
+		rec.batch = make([]*logRecord, 0, count)
+		for range count {
+			if len(data) < 4 {
+				return nil, errCorruptRecord
+			}
+			n := int(binary.LittleEndian.Uint32(data[0:4]))
+			if n == 0 || len(data) < 4+n {
+				return nil, errCorruptRecord
+			}
+
+			// batches only contain set and delete records
+			if op := logOp(data[4]); op != logOpSet && op != logOpDelete {
+				return nil, errCorruptRecord
+			}
+			r, err := decodeRecord(data[4 : 4+n])
+			if err != nil {
+				return nil, err
+			}
+			rec.batch = append(rec.batch, r)
+			data = data[4+n:]
+		}
+		if len(data) != 0 {
+			return nil, errCorruptRecord
+		}
+	default:
+		return nil, errCorruptRecord
+	}
+
+	return rec, nil
+}
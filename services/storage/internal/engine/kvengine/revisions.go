@@ -0,0 +1,82 @@
+package kvengine
+
+import (
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+)
+
+// GetWithRevision retrieves the value and the revision for the given key.
+// Returns nil and 0 if the key does not exist or has expired.
+func (e *Engine) GetWithRevision(key string) (*codex.Value, uint64) {
+	s := e.shardFor(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	en, ok := s.live(key)
+	if !ok {
+		return nil, 0
+	}
+
+	return en.value, en.revision
+}
+
+// CompareAndSetTTL stores the value for the given key only if the current revision of the key equals expectedRevision.
+// An expectedRevision of 0 means that the key must not exist (or has expired).
+// Returns the new revision, or the current revision (0 for missing keys) together with ErrRevisionMismatch.
+func (e *Engine) CompareAndSetTTL(key string, expectedRevision uint64, value *codex.Value, expires int64) (uint64, error) {
+	if e.disableTTL {
+		expires = 0
+	}
+
+	s := e.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current := s.revisionOf(key); current != expectedRevision {
+		return current, ErrRevisionMismatch
+	}
+
+	en, err := e.replace(s, key, value, expires)
+	if err != nil {
+		return 0, err
+	}
+
+	return en.revision, nil
+}
+
+// CompareAndSet stores the value without expiration for the given key only if the current revision of the key equals expectedRevision.
+// See CompareAndSetTTL.
+func (e *Engine) CompareAndSet(key string, expectedRevision uint64, value *codex.Value) (uint64, error) {
+	return e.CompareAndSetTTL(key, expectedRevision, value, 0)
+}
+
+// CompareAndDelete deletes the given key only if its current revision equals expectedRevision.
+// Returns the current revision (0 for missing keys) together with ErrRevisionMismatch if the revisions differ.
+func (e *Engine) CompareAndDelete(key string, expectedRevision uint64) (uint64, error) {
+	s := e.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.revisionOf(key)
+	if current != expectedRevision {
+		return current, ErrRevisionMismatch
+	}
+
+	if current != 0 {
+		if err := e.remove(s, key, EventDelete); err != nil {
+			return current, err
+		}
+	}
+
+	return 0, nil
+}
+
+// revisionOf returns the revision of the live entry for key, or 0 if there is none.
+// The caller must hold the shard lock.
+func (s *shard) revisionOf(key string) uint64 {
+	en, ok := s.live(key)
+	if !ok {
+		return 0
+	}
+
+	return en.revision
+}
@@ -0,0 +1,227 @@
+package kvengine
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultScanCount is the page size used by Scan if no count is given.
+	DefaultScanCount = 100
+	// MaxScanCount is the maximum page size of Scan.
+	MaxScanCount = 10_000
+
+	// maxScanBytes limits the total key size of a single page, so that it always fits into one protocol frame.
+	maxScanBytes = 4 << 20
+)
+
+// ScanCursor is the position of a scan: the shard it is in and the last key returned from that shard.
+// Keys of a shard are visited in lexicographic order, so every key that exists for the whole duration of a scan is returned exactly once.
+type ScanCursor struct {
+	Shard   int
+	LastKey string
+	HasLast bool
+}
+
+// Done reports whether the scan has visited all shards.
+func (c ScanCursor) Done() bool {
+	return c.Shard >= ShardCount
+}
+
+// Scan returns up to count keys matching the glob pattern, starting at cursor, and the cursor to continue from.
+// An empty pattern matches every key. Only one shard is locked at a time, and only while its keys are collected.
+// The scan is finished when the returned cursor is done.
+func (e *Engine) Scan(cursor ScanCursor, count int, pattern string) ([]string, ScanCursor) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	count = min(count, MaxScanCount)
+
+	prefix := globPrefix(pattern)
+	keys := make([]string, 0, count)
+	size := 0
+
+	for shardIndex := max(cursor.Shard, 0); shardIndex < ShardCount; shardIndex++ {
+		after := ""
+		hasAfter := false
+		if shardIndex == cursor.Shard && cursor.HasLast {
+			after = cursor.LastKey
+			hasAfter = true
+		}
+
+		candidates := e.scanShard(&e.shards[shardIndex], after, hasAfter, prefix, pattern)
+		sort.Strings(candidates)
+
+		for i, key := range candidates {
+			if len(keys) == count || (len(keys) > 0 && size+len(key) > maxScanBytes) {
+				next := ScanCursor{Shard: shardIndex}
+				if i > 0 {
+					next.LastKey = candidates[i-1]
+					next.HasLast = true
+				}
+				return keys, next
+			}
+
+			keys = append(keys, key)
+			size += len(key)
+		}
+
+		if len(keys) == count {
+			return keys, ScanCursor{Shard: shardIndex + 1}
+		}
+	}
+
+	return keys, ScanCursor{Shard: ShardCount}
+}
+
+// scanShard returns the live keys of s that are greater than after (if hasAfter is set) and match pattern.
+func (e *Engine) scanShard(s *shard, after string, hasAfter bool, prefix, pattern string) []string {
+	now := time.Now().UnixNano()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for key, en := range s.data {
+		if en.expires > 0 && now > en.expires {
+			continue
+		}
+		if hasAfter && key <= after {
+			continue
+		}
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if pattern != "" && !matchGlob(pattern, key) {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// globPrefix returns the literal prefix of a glob pattern, which every matching key must start with.
+func globPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[':
+			return b.String()
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+		}
+		b.WriteByte(pattern[i])
+	}
+
+	return b.String()
+}
+
+// matchGlob reports whether s matches the glob pattern.
+// Supported syntax: '*' matches any sequence of bytes, '?' matches a single byte,
+// '[abc]', '[a-z]' and '[!a-z]' match byte classes and '\' escapes the next byte.
+func matchGlob(pattern, s string) bool {
+	p, i := 0, 0
+	starP, starI := -1, 0
+
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starI = p, i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if matched, next, ok := matchClass(pattern, p, s[i]); ok {
+					if matched {
+						p = next
+						i++
+						continue
+					}
+				} else if s[i] == '[' {
+					// unterminated class, treat '[' literally
+					p++
+					i++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == s[i] {
+					p += 2
+					i++
+					continue
+				}
+			default:
+				if pattern[p] == s[i] {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+
+		// mismatch, backtrack to the last star and let it consume one more byte
+		if starP < 0 {
+			return false
+		}
+		starI++
+		p, i = starP+1, starI
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+// matchClass matches c against the class starting at pattern[start] ('[').
+// It returns whether c matched, the index after the class and whether the class is terminated.
+func matchClass(pattern string, start int, c byte) (bool, int, bool) {
+	p := start + 1
+	negate := false
+	if p < len(pattern) && (pattern[p] == '!' || pattern[p] == '^') {
+		negate = true
+		p++
+	}
+
+	matched := false
+	first := true
+	for p < len(pattern) && (first || pattern[p] != ']') {
+		first = false
+
+		lo := pattern[p]
+		if lo == '\\' && p+1 < len(pattern) {
+			p++
+			lo = pattern[p]
+		}
+		p++
+
+		hi := lo
+		if p+1 < len(pattern) && pattern[p] == '-' && pattern[p+1] != ']' {
+			hi = pattern[p+1]
+			if hi == '\\' && p+2 < len(pattern) {
+				p++
+				hi = pattern[p+1]
+			}
+			p += 2
+		}
+
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+
+	if p >= len(pattern) {
+		return false, 0, false
+	}
+
+	return matched != negate, p + 1, true
+}
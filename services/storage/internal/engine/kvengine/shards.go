@@ -1,8 +1,167 @@
 package kvengine
 
-import "github.com/fancyinnovations/fancyspaces/storage/internal/hashing"
+import (
+	"math"
+	"time"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/hashing"
+)
 
 func (e *Engine) shardFor(key string) *shard {
 	h := hashing.FNV32a(key)
 	return &e.shards[int(h%uint32(ShardCount))]
 }
+
+// live returns the entry stored for key if it exists and has not expired.
+// The caller must hold the shard lock.
+func (s *shard) live(key string) (*entry, bool) {
+	en, exists := s.data[key]
+	if !exists {
+		return nil, false
+	}
+
+	now := time.Now().UnixNano()
+	if en.expires > 0 && now > en.expires {
+		return nil, false
+	}
+
+	if s.trackAccess {
+		en.touch(now)
+	}
+
+	return en, true
+}
+
+// touch records an access to the entry for the LRU and LFU eviction policies.
+func (en *entry) touch(now int64) {
+	en.lastAccess.Store(now)
+	if hits := en.hits.Load(); hits < math.MaxUint32 {
+		en.hits.CompareAndSwap(hits, hits+1)
+	}
+}
+
+// entrySize returns the number of bytes accounted for an entry, which is the size reported by Engine.Size.
+func entrySize(key string, value *codex.Value) int64 {
+	return int64(len(key)) + int64(codex.SizeOfValue(value))
+}
+
+// replace stores a new entry with the next revision for key in s and logs it.
+// Stored values are never modified in place, because readers may still hold them after releasing the shard lock.
+// Returns ErrLimitExceeded if the write does not fit into the limits of the engine, see Engine.admit, or the error of writing the log.
+// The caller must hold the shard write lock.
+func (e *Engine) replace(s *shard, key string, value *codex.Value, expires int64) (*entry, error) {
+	size := entrySize(key, value)
+
+	memoryDelta, entriesDelta := size, int64(1)
+	if old, exists := s.data[key]; exists {
+		memoryDelta -= old.size
+		entriesDelta = 0
+	}
+
+	if err := e.admit(size, memoryDelta, entriesDelta); err != nil {
+		return nil, err
+	}
+
+	en, err := e.put(s, key, value, expires, size)
+	if err != nil {
+		return nil, err
+	}
+	e.evict(s, key)
+
+	return en, nil
+}
+
+// put stores a new entry with the next revision for key in s and logs it, without checking the limits of the engine.
+// The entry is only stored once it is logged, if logging fails the error is returned and s is left unchanged.
+// The caller must hold the shard write lock.
+func (e *Engine) put(s *shard, key string, value *codex.Value, expires int64, size int64) (*entry, error) {
+	en := e.newEntry(s, key, value, expires, size)
+	if err := e.logSet(key, en); err != nil {
+		return nil, err
+	}
+	e.store(s, key, en)
+	e.notify(EventSet, key, en)
+
+	return en, nil
+}
+
+// newEntry creates the entry that replaces key in s with the next revision, without storing it.
+// The access statistics of a replaced entry are carried over.
+// The caller must hold the shard write lock.
+func (e *Engine) newEntry(s *shard, key string, value *codex.Value, expires int64, size int64) *entry {
+	en := &entry{
+		value:    value,
+		expires:  expires,
+		revision: e.revision.Add(1),
+		size:     size,
+	}
+
+	if s.trackAccess {
+		if old, exists := s.data[key]; exists {
+			en.hits.Store(old.hits.Load())
+		}
+		en.touch(time.Now().UnixNano())
+	}
+
+	return en
+}
+
+// remove deletes key from s, logs it and emits eventType.
+// The key is only deleted once the deletion is logged, if logging fails the error is returned and s is left unchanged.
+// The caller must hold the shard write lock.
+func (e *Engine) remove(s *shard, key string, eventType EventType) error {
+	en, exists := s.data[key]
+	if !exists {
+		return nil
+	}
+
+	if err := e.logDelete(key); err != nil {
+		return err
+	}
+	e.drop(s, key)
+	e.notify(eventType, key, en)
+
+	return nil
+}
+
+// store puts en into s and updates the memory accounting, it neither checks limits nor logs.
+// The caller must hold the shard write lock.
+func (e *Engine) store(s *shard, key string, en *entry) {
+	memoryDelta := en.size
+	if old, exists := s.data[key]; exists {
+		memoryDelta -= old.size
+	} else {
+		e.entries.Add(1)
+	}
+
+	s.data[key] = en
+	s.size += memoryDelta
+	e.memory.Add(memoryDelta)
+	s.trackExpiry(key, en)
+}
+
+// drop deletes key from s and updates the memory accounting, it does not log.
+// The caller must hold the shard write lock.
+func (e *Engine) drop(s *shard, key string) {
+	old, exists := s.data[key]
+	if !exists {
+		return
+	}
+
+	delete(s.data, key)
+	s.size -= old.size
+	e.memory.Add(-old.size)
+	e.entries.Add(-1)
+}
+
+// dropAll deletes all keys from s and updates the memory accounting, it does not log.
+// The caller must hold the shard write lock.
+func (e *Engine) dropAll(s *shard) {
+	e.memory.Add(-s.size)
+	e.entries.Add(-int64(len(s.data)))
+
+	s.data = make(map[string]*entry)
+	s.size = 0
+	s.resetExpiry()
+}
@@ -0,0 +1,99 @@
+package kvengine
+
+import (
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+)
+
+// StringAppend atomically appends suffix to the string stored for key and returns the new length in bytes.
+// Missing keys are created as strings without expiration.
+func (e *Engine) StringAppend(key, suffix string) (uint32, error) {
+	return e.stringConcat(key, suffix, false)
+}
+
+// StringPrepend atomically prepends prefix to the string stored for key and returns the new length in bytes.
+// Missing keys are created as strings without expiration.
+func (e *Engine) StringPrepend(key, prefix string) (uint32, error) {
+	return e.stringConcat(key, prefix, true)
+}
+
+func (e *Engine) stringConcat(key, part string, prepend bool) (uint32, error) {
+	s := e.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current string
+	var expires int64
+	if en, ok := s.live(key); ok {
+		if en.value.Type != codex.TypeString {
+			return 0, ErrTypeMismatch
+		}
+		current = en.value.AsString()
+		expires = en.expires
+	}
+
+	var updated string
+	if prepend {
+		updated = part + current
+	} else {
+		updated = current + part
+	}
+
+	val, _ := codex.NewValue(updated)
+	if _, err := e.replace(s, key, val, expires); err != nil {
+		return 0, err
+	}
+
+	return uint32(len(updated)), nil
+}
+
+// StringLength returns the length in bytes of the string stored for key.
+// Missing keys are treated as empty strings.
+func (e *Engine) StringLength(key string) (uint32, error) {
+	s := e.shardFor(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	en, ok := s.live(key)
+	if !ok {
+		return 0, nil
+	}
+	if en.value.Type != codex.TypeString {
+		return 0, ErrTypeMismatch
+	}
+
+	return uint32(len(en.value.AsString())), nil
+}
+
+// StringSubstring returns the bytes [start, end) of the string stored for key.
+// Negative offsets count from the end of the string, offsets outside the string are clamped to its bounds.
+func (e *Engine) StringSubstring(key string, start, end int) (string, error) {
+	s := e.shardFor(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	en, ok := s.live(key)
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+	if en.value.Type != codex.TypeString {
+		return "", ErrTypeMismatch
+	}
+
+	str := en.value.AsString()
+	start = clampOffset(start, len(str))
+	end = clampOffset(end, len(str))
+	if start >= end {
+		return "", nil
+	}
+
+	return str[start:end], nil
+}
+
+// clampOffset converts a possibly negative offset (counting from the end) into a position within [0, length].
+func clampOffset(offset, length int) int {
+	if offset < 0 {
+		offset += length
+	}
+
+	return max(0, min(offset, length))
+}
@@ -1,28 +1,152 @@
 package kvengine
 
-import "time"
+import (
+	"container/heap"
+	"time"
+)
 
+const (
+	// cleanupInterval is the interval of the cleanup job.
+	cleanupInterval = 100 * time.Millisecond
+
+	// maxExpirationsPerShard bounds the number of entries a shard removes per cleanup tick, so the shard lock is only held briefly.
+	// Entries that are left over are removed in the next ticks, reads already treat them as missing.
+	maxExpirationsPerShard = 128
+)
+
+// expiryItem is an entry with an expiration time in the expiry index of a shard.
+type expiryItem struct {
+	expires int64
+	key     string
+	entry   *entry
+}
+
+// expiryHeap is a min-heap of entries ordered by their expiration time.
+// Items are not removed when their entry is replaced or deleted, they are skipped when they are popped instead (see shard.stale).
+type expiryHeap []expiryItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expires < h[j].expires }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap) Push(x any) {
+	*h = append(*h, x.(expiryItem))
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = expiryItem{}
+	*h = old[:len(old)-1]
+	return item
+}
+
+// stale reports whether item no longer refers to the entry stored for its key.
+// The caller must hold the shard lock.
+func (s *shard) stale(item expiryItem) bool {
+	return s.data[item.key] != item.entry
+}
+
+// trackExpiry adds en to the expiry index of s if it has an expiration time.
+// The caller must hold the shard write lock.
+func (s *shard) trackExpiry(key string, en *entry) {
+	if en.expires <= 0 {
+		return
+	}
+
+	// drop stale items once they make up most of the index, so that overwriting keys with long TTLs cannot grow it without bound
+	if len(s.expiry) >= 64 && len(s.expiry) > 2*len(s.data) {
+		s.compactExpiry()
+	}
+
+	heap.Push(&s.expiry, expiryItem{expires: en.expires, key: key, entry: en})
+	s.updateNextExpiry()
+}
+
+// compactExpiry removes all stale items from the expiry index of s.
+// The caller must hold the shard write lock.
+func (s *shard) compactExpiry() {
+	live := s.expiry[:0]
+	for _, item := range s.expiry {
+		if !s.stale(item) {
+			live = append(live, item)
+		}
+	}
+	clear(s.expiry[len(live):])
+
+	s.expiry = live
+	heap.Init(&s.expiry)
+	s.updateNextExpiry()
+}
+
+// resetExpiry clears the expiry index of s.
+// The caller must hold the shard write lock.
+func (s *shard) resetExpiry() {
+	s.expiry = nil
+	s.nextExpiry.Store(0)
+}
+
+// updateNextExpiry publishes the earliest expiration time of the index, so that the cleanup job can skip shards without locking them.
+// The caller must hold the shard write lock.
+func (s *shard) updateNextExpiry() {
+	if len(s.expiry) == 0 {
+		s.nextExpiry.Store(0)
+		return
+	}
+
+	s.nextExpiry.Store(s.expiry[0].expires)
+}
+
+// cleanup removes expired entries.
+// Only shards with due entries are locked, and only the due entries are visited (at most maxExpirationsPerShard per shard).
 func (e *Engine) cleanup() {
+	now := time.Now().UnixNano()
+
 	for i := 0; i < ShardCount; i++ {
 		s := &e.shards[i]
-		s.mu.Lock()
+		if next := s.nextExpiry.Load(); next == 0 || next > now {
+			continue
+		}
 
-		now := time.Now().UnixNano()
+		s.mu.Lock()
+		e.expireShard(s, now)
+		s.mu.Unlock()
+	}
+}
 
-		for key, entry := range s.data {
-			if entry.expires > 0 && entry.expires <= now {
-				delete(s.data, key)
-			}
+// expireShard removes up to maxExpirationsPerShard entries of s that expired at now.
+// The caller must hold the shard write lock.
+func (e *Engine) expireShard(s *shard, now int64) {
+	removed := 0
+	for len(s.expiry) > 0 && s.expiry[0].expires <= now && removed < maxExpirationsPerShard {
+		item := heap.Pop(&s.expiry).(expiryItem)
+		if s.stale(item) {
+			continue
 		}
-		s.mu.Unlock()
+
+		e.drop(s, item.key)
+		e.expirations.Add(1)
+		e.notify(EventExpire, item.key, item.entry)
+		removed++
 	}
+
+	s.updateNextExpiry()
 }
 
 func (e *Engine) startCleanup(interval time.Duration) {
 	ticker := time.NewTicker(interval)
+	e.wg.Add(1)
 	go func() {
-		for range ticker.C {
-			e.cleanup()
+		defer e.wg.Done()
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				e.cleanup()
+			}
 		}
 	}()
 }
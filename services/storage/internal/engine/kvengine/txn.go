@@ -0,0 +1,201 @@
+package kvengine
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+)
+
+// MaxTxnOps is the maximum number of operations in a single transaction.
+const MaxTxnOps = 1024
+
+// TxnOpType is the kind of operation in a transaction.
+type TxnOpType uint8
+
+const (
+	// TxnOpCheck requires that the key has the expected revision, without writing it.
+	TxnOpCheck TxnOpType = iota + 1
+	// TxnOpSet stores the value for the key.
+	TxnOpSet
+	// TxnOpDelete deletes the key.
+	TxnOpDelete
+	// TxnOpCompareAndSet stores the value for the key if it has the expected revision.
+	TxnOpCompareAndSet
+	// TxnOpCompareAndDelete deletes the key if it has the expected revision.
+	TxnOpCompareAndDelete
+)
+
+// TxnOp is a single operation of a transaction.
+// Revision is the expected revision of conditional operations (0 means the key must not exist),
+// Value and Expires are only used by set operations.
+type TxnOp struct {
+	Type     TxnOpType
+	Key      string
+	Revision uint64
+	Value    *codex.Value
+	Expires  int64
+}
+
+// TxnConflictError is returned by Txn if a condition of the transaction does not hold.
+// It wraps ErrRevisionMismatch.
+type TxnConflictError struct {
+	// Op is the index of the first operation whose condition failed.
+	Op int
+	// Revision is the current revision of the key of that operation (0 if it does not exist).
+	Revision uint64
+}
+
+func (e *TxnConflictError) Error() string {
+	return fmt.Sprintf("transaction operation %d: %s (current revision %d)", e.Op, ErrRevisionMismatch, e.Revision)
+}
+
+func (e *TxnConflictError) Unwrap() error {
+	return ErrRevisionMismatch
+}
+
+// conditional reports whether the operation checks the revision of its key.
+func (op TxnOp) conditional() bool {
+	return op.Type == TxnOpCheck || op.Type == TxnOpCompareAndSet || op.Type == TxnOpCompareAndDelete
+}
+
+// Txn atomically applies ops: either all operations are applied or none.
+// The shards of all involved keys are locked in ascending order, so concurrent transactions cannot deadlock
+// and other readers and writers never observe a partially applied transaction.
+//
+// All conditions are evaluated against the state before the transaction, the writes are then applied in order.
+// On success, it returns the new revision of every set operation (0 for the other operations).
+// If a condition does not hold, nothing is written and it returns a *TxnConflictError.
+// If the writes do not fit into the limits of the engine, nothing is written and it returns ErrLimitExceeded.
+// With persistence, all writes are logged in a single record, so they are also restored together or not at all.
+func (e *Engine) Txn(ops []TxnOp) ([]uint64, error) {
+	if len(ops) > MaxTxnOps {
+		return nil, ErrInvalidOperand
+	}
+
+	shardIndexes := make([]int, 0, len(ops))
+	for _, op := range ops {
+		switch op.Type {
+		case TxnOpSet, TxnOpCompareAndSet:
+			if op.Value == nil {
+				return nil, ErrInvalidOperand
+			}
+		case TxnOpCheck, TxnOpDelete, TxnOpCompareAndDelete:
+		default:
+			return nil, ErrInvalidOperand
+		}
+
+		shardIndexes = append(shardIndexes, e.shardFor(op.Key).index)
+	}
+
+	slices.Sort(shardIndexes)
+	shardIndexes = slices.Compact(shardIndexes)
+
+	for _, i := range shardIndexes {
+		e.shards[i].mu.Lock()
+	}
+	defer func() {
+		for _, i := range slices.Backward(shardIndexes) {
+			e.shards[i].mu.Unlock()
+		}
+	}()
+
+	for i, op := range ops {
+		if !op.conditional() {
+			continue
+		}
+
+		if current := e.shardFor(op.Key).revisionOf(op.Key); current != op.Revision {
+			return nil, &TxnConflictError{Op: i, Revision: current}
+		}
+	}
+
+	if err := e.admitTxn(ops); err != nil {
+		return nil, err
+	}
+
+	revisions := make([]uint64, len(ops))
+	writes := make([]batchWrite, 0, len(ops))
+	for i, op := range ops {
+		s := e.shardFor(op.Key)
+
+		switch op.Type {
+		case TxnOpSet, TxnOpCompareAndSet:
+			expires := op.Expires
+			if e.disableTTL {
+				expires = 0
+			}
+			en := e.newEntry(s, op.Key, op.Value, expires, entrySize(op.Key, op.Value))
+			writes = append(writes, batchWrite{s: s, key: op.Key, en: en})
+			revisions[i] = en.revision
+		case TxnOpDelete, TxnOpCompareAndDelete:
+			writes = append(writes, batchWrite{s: s, key: op.Key})
+		}
+	}
+
+	// all writes are logged in a single record, so a crash cannot leave a partially applied transaction on disk
+	if err := e.logBatch(writes); err != nil {
+		return nil, err
+	}
+
+	for _, w := range writes {
+		if w.en != nil {
+			e.store(w.s, w.key, w.en)
+			e.notify(EventSet, w.key, w.en)
+		} else if en, exists := w.s.data[w.key]; exists {
+			e.drop(w.s, w.key)
+			e.notify(EventDelete, w.key, en)
+		}
+	}
+
+	// the shards of the transaction are still locked, so none of its writes can be evicted here
+	e.evict(nil, "")
+
+	return revisions, nil
+}
+
+// admitTxn checks whether the writes of ops fit into the limits of the engine as a whole, see Engine.admit.
+// The caller must hold the locks of all involved shards.
+func (e *Engine) admitTxn(ops []TxnOp) error {
+	if !e.limited() {
+		return nil
+	}
+
+	// sizes holds the size of every written key after the ops applied so far, -1 for deleted keys
+	sizes := make(map[string]int64)
+	sizeOf := func(key string) int64 {
+		if size, ok := sizes[key]; ok {
+			return size
+		}
+		if en, exists := e.shardFor(key).data[key]; exists {
+			return en.size
+		}
+		return -1
+	}
+
+	var maxSize, memoryDelta, entriesDelta int64
+	for _, op := range ops {
+		current := sizeOf(op.Key)
+
+		switch op.Type {
+		case TxnOpSet, TxnOpCompareAndSet:
+			size := entrySize(op.Key, op.Value)
+			maxSize = max(maxSize, size)
+			if current < 0 {
+				memoryDelta += size
+				entriesDelta++
+			} else {
+				memoryDelta += size - current
+			}
+			sizes[op.Key] = size
+		case TxnOpDelete, TxnOpCompareAndDelete:
+			if current >= 0 {
+				memoryDelta -= current
+				entriesDelta--
+			}
+			sizes[op.Key] = -1
+		}
+	}
+
+	return e.admit(maxSize, memoryDelta, entriesDelta)
+}
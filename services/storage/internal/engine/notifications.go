@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"encoding/binary"
+	"log/slog"
+	"strings"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/kvengine"
+)
+
+// kvEventHandler returns an event handler that publishes the changes of the key-value collection db/coll
+// to the broker collection brokerColl of the same database.
+//
+// Subjects have the form kv.{db}.{coll}.{event}.{key}, so they can be watched with the broker wildcards (e.g. kv.db.coll.*.user:1 or kv.db.coll.>).
+// The key is escaped with escapeSubjectToken, so it is always a single token.
+// The payload of set events is | Revision (8 bytes) | Value (codex-encoded) |, the payload of the other events is empty.
+func (s *Service) kvEventHandler(db, coll, brokerColl string) kvengine.EventHandler {
+	prefix := "kv." + db + "." + coll + "."
+
+	return func(event kvengine.Event) {
+		// the broker is looked up for every event, because it may be loaded after the key-value collection
+		entry, err := s.GetEngine(db, brokerColl)
+		if err != nil {
+			return
+		}
+		broker := entry.AsBrokerEngine()
+		if broker == nil {
+			return
+		}
+
+		var payload []byte
+		if event.Type == kvengine.EventSet {
+			payload = binary.BigEndian.AppendUint64(nil, event.Revision)
+			payload = append(payload, codex.EncodeValue(event.Value)...)
+		}
+
+		if _, err := broker.Publish(prefix+string(event.Type)+"."+escapeSubjectToken(event.Key), payload); err != nil {
+			slog.Error("Failed to publish keyspace notification",
+				slog.String("database", db),
+				slog.String("collection", brokerColl),
+				sloki.WrapError(err),
+			)
+		}
+	}
+}
+
+// subjectTokenEscaper percent-encodes the token separator, and the escape character itself so the escaping can be reversed.
+var subjectTokenEscaper = strings.NewReplacer("%", "%25", ".", "%2E")
+
+// escapeSubjectToken escapes s so it can be used as a single subject token, e.g. user.1 becomes user%2E1.
+func escapeSubjectToken(s string) string {
+	return subjectTokenEscaper.Replace(s)
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fancyinnovations/fancyspaces/storage/internal/database"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/brokerengine"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/kvengine"
+)
+
+func TestEscapeSubjectToken(t *testing.T) {
+	tests := map[string]string{
+		"user:1":   "user:1",
+		"user.1":   "user%2E1",
+		"a.b.c":    "a%2Eb%2Ec",
+		"100%":     "100%25",
+		"user%2E1": "user%252E1",
+	}
+
+	for key, want := range tests {
+		if got := escapeSubjectToken(key); got != want {
+			t.Errorf("escapeSubjectToken(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestKVEventHandlerKeyWithDots(t *testing.T) {
+	received := make(chan string, 1)
+
+	broker := brokerengine.NewBroker(brokerengine.Configuration{
+		BatchSize: 1,
+		HeaderCallback: func(sub *brokerengine.Subscriber, subject string, msgs []brokerengine.Message) {
+			received <- msgs[0].Subject
+		},
+	})
+	defer broker.Close()
+
+	broker.Subscribe("kv.app.sessions.delete.*", &brokerengine.Subscriber{ID: "watcher", WithHeader: true})
+
+	s := &Service{
+		engines: map[string]*Entry{
+			toKey("app", "events"): {Database: "app", Collection: "events", Type: database.EngineBroker, engine: broker},
+		},
+	}
+	handler := s.kvEventHandler("app", "sessions", "events")
+
+	handler(kvengine.Event{Type: kvengine.EventDelete, Key: "user.1"})
+
+	select {
+	case subject := <-received:
+		if subject != "kv.app.sessions.delete.user%2E1" {
+			t.Fatalf("subject = %q, want %q", subject, "kv.app.sessions.delete.user%2E1")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the single token wildcard to match a key containing dots")
+	}
+}
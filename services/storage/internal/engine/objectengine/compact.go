@@ -1,147 +1,393 @@
 package objectengine
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
-	"hash/crc32"
 	"io"
 	"log/slog"
 	"os"
+	"slices"
 	"time"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+)
+
+const (
+	// defaultCompactionInterval is the default interval in which shards are checked for compaction.
+	defaultCompactionInterval = 5 * time.Minute
+
+	// defaultCompactionGarbageRatio is the default share of a shard file that must be garbage before the shard is compacted.
+	defaultCompactionGarbageRatio = 0.5
 )
 
+var errBucketClosed = errors.New("bucket closed")
+
+// Stats is a snapshot of the disk usage and the compaction counters of a bucket.
+type Stats struct {
+	Objects uint64 `json:"objects"`
+	// Versions is the number of older versions and delete markers kept by versioning.
+	Versions uint64 `json:"versions"`
+
+	// FileBytes is the size of all shard files.
+	FileBytes uint64 `json:"file_bytes"`
+	// GarbageBytes is the part of FileBytes taken up by replaced and deleted objects, which is reclaimed by compaction.
+	GarbageBytes uint64 `json:"garbage_bytes"`
+
+	// Compactions is the number of compacted shards.
+	Compactions uint64 `json:"compactions"`
+	// ReclaimedBytes is the number of bytes removed from the shard files by compaction.
+	ReclaimedBytes uint64 `json:"reclaimed_bytes"`
+	// LastCompaction is the time (in unix milliseconds) the last shard was compacted, 0 if none was compacted yet.
+	LastCompaction int64 `json:"last_compaction"`
+	// Compacting reports whether a shard is being compacted right now.
+	Compacting bool `json:"compacting"`
+
+	// Expirations is the number of objects deleted by the lifecycle rules.
+	Expirations uint64 `json:"expirations"`
+}
+
+// Stats returns the current disk usage, the compaction counters and the number of expired objects of the bucket.
+func (b *Bucket) Stats() Stats {
+	stats := Stats{
+		Compactions:    b.compactions.Load(),
+		ReclaimedBytes: b.reclaimedBytes.Load(),
+		LastCompaction: b.lastCompaction.Load(),
+		Compacting:     b.compacting.Load(),
+		Expirations:    b.lifecycleExpirations.Load(),
+	}
+
+	for _, s := range b.shards {
+		s.RLock()
+		stats.Objects += uint64(len(s.index))
+		for _, versions := range s.history {
+			stats.Versions += uint64(len(versions))
+		}
+		stats.FileBytes += uint64(s.size)
+		stats.GarbageBytes += uint64(s.garbage)
+		s.RUnlock()
+	}
+
+	return stats
+}
+
+// CompactNow schedules the compaction of all shards with garbage, regardless of the garbage ratio of the bucket.
+// It returns immediately, the progress can be observed with Stats.
+func (b *Bucket) CompactNow() {
+	select {
+	case b.compactNow <- struct{}{}:
+	default: // a compaction is already requested
+	}
+}
+
 func (b *Bucket) startCompactSchedule() {
+	b.wg.Add(1)
 	go func() {
-		for {
-			slog.Info("Starting scheduled compaction for bucket", slog.String("database", b.database), slog.String("collection", b.collection))
+		defer b.wg.Done()
 
-			time.Sleep(10 * time.Second)
+		ticker := time.NewTicker(b.compactionInterval)
+		defer ticker.Stop()
 
-			for i, s := range b.shards {
-				if !s.dirty {
-					continue
-				}
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				b.compact(b.compactionGarbageRatio)
+			case <-b.compactNow:
+				b.compact(0)
+			}
+		}
+	}()
+}
 
-				slog.Debug("Compacting shard", slog.Int("shard_index", i))
-				if err := b.compactShard(s); err != nil {
-					slog.Error("Failed to compact shard", slog.Int("shard_index", i), slog.Any("error", err))
-				}
+// compact compacts all shards of which at least the given share is garbage, after removing the versions that exceed the version retention.
+func (b *Bucket) compact(ratio float64) {
+	for i, s := range b.shards {
+		b.expireVersions(s, time.Now())
 
-				time.Sleep(10 * time.Second) // Sleep between shard compactions to reduce load
-			}
+		if !s.needsCompaction(ratio) {
+			continue
+		}
 
-			time.Sleep(5 * time.Minute)
+		reclaimed, err := b.compactShard(s)
+		if errors.Is(err, errBucketClosed) {
+			return
 		}
-	}()
+		if err != nil {
+			slog.Error("Failed to compact shard",
+				slog.String("database", b.database),
+				slog.String("collection", b.collection),
+				slog.Int("shard_index", i),
+				sloki.WrapError(err),
+			)
+			continue
+		}
+
+		slog.Info("Compacted shard",
+			slog.String("database", b.database),
+			slog.String("collection", b.collection),
+			slog.Int("shard_index", i),
+			slog.Int64("reclaimed_bytes", reclaimed),
+		)
+	}
+}
+
+// needsCompaction reports whether the shard has garbage and at least the given share of its file is garbage.
+func (s *shard) needsCompaction(ratio float64) bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.garbage > 0 && float64(s.garbage) >= ratio*float64(s.size)
 }
 
-func (b *Bucket) compactShard(s *shard) error {
+// expireVersions removes the older versions of the shard that exceed MaxVersions or VersionRetention, so that the next compaction of the shard drops them.
+// A version is replaced at the modification time of the next newer version.
+func (b *Bucket) expireVersions(s *shard, now time.Time) {
+	if b.maxVersions <= 0 && b.versionRetention <= 0 {
+		return
+	}
+	cutoff := now.Add(-b.versionRetention).UnixMilli()
+
+	s.appendMu.Lock()
+	defer s.appendMu.Unlock()
 	s.Lock()
 	defer s.Unlock()
 
-	if !s.dirty {
-		return nil
+	for key, versions := range s.history {
+		current, ok := s.index[key]
+		older := versions
+		if !ok {
+			// The newest delete marker is the current version
+			current = versions[len(versions)-1]
+			older = versions[:len(versions)-1]
+		}
+
+		kept := make([]ObjectMeta, 0, len(older))
+		for i, meta := range older {
+			replacedAt := current.ModifiedAt
+			if i+1 < len(versions) {
+				replacedAt = versions[i+1].ModifiedAt
+			}
+
+			newer := len(older) - 1 - i
+			if (b.maxVersions > 0 && newer >= b.maxVersions) || (b.versionRetention > 0 && replacedAt < cutoff) {
+				s.garbage += meta.entrySize()
+				continue
+			}
+			kept = append(kept, meta)
+		}
+
+		if len(kept) == len(older) {
+			continue
+		}
+		if !ok {
+			kept = append(kept, current)
+		}
+		s.history[key] = kept
+		s.tidyHistory(key)
 	}
+}
+
+// compactShard rewrites the shard file with only the versions of the index and history and returns the number of reclaimed bytes.
+// The entries are copied from a snapshot of the index without holding any lock of the shard, at the rate limit of the bucket.
+// Entries that are appended meanwhile are copied afterward while holding the append lock, readers are only blocked while the files are swapped.
+func (b *Bucket) compactShard(s *shard) (int64, error) {
+	b.compactMu.Lock()
+	defer b.compactMu.Unlock()
+
+	b.compacting.Store(true)
+	defer b.compacting.Store(false)
 
-	// Rewind old file
-	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
-		return err
+	// Only the versions of the keys are kept, replaced or removed versions and purge entries are dropped.
+	// They are copied in the order of the old file, so that it is read sequentially and the versions of a key keep their order.
+	s.RLock()
+	snapshotEnd := s.size
+	entries := make([]ObjectMeta, 0, len(s.index))
+	for _, meta := range s.index {
+		entries = append(entries, meta)
 	}
+	for _, versions := range s.history {
+		entries = append(entries, versions...)
+	}
+	s.RUnlock()
+
+	slices.SortFunc(entries, func(a, b ObjectMeta) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
 
 	shardPath := s.file.Name()
 	tmpPath := s.file.Name() + ".tmp"
 	tmpFile, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer tmpFile.Close()
+	defer os.Remove(tmpPath) // fails once the file replaced the shard file
 
-	newIndex := make(map[string]ObjectMeta)
-
-	for {
-		entryOffset, _ := s.file.Seek(0, io.SeekCurrent)
+	// The file is only replaced by compaction, so it can be read without holding a lock of the shard
+	oldFile := s.file
+	limit := newThrottle(b.compactionRateLimit, b.stop)
+	copied := make(map[int64]ObjectMeta, len(entries)) // by offset in the old file
+	var newOffset int64
 
-		meta, _, err := readEntry(s.file, true)
-		if err == io.EOF {
-			break
-		}
+	// Write all valid entries to the new shard file, they are rewritten in the current record format
+	for _, meta := range entries {
+		entry := meta
+		entry.Offset = newOffset
+		data := &throttledReader{r: io.NewSectionReader(oldFile, meta.dataOffset(), int64(meta.Size)), limit: limit}
+		checksum, err := writeEntryFrom(tmpFile, &entry, data)
 		if err != nil {
-			return err
+			return 0, err
 		}
 
-		// Only keep the version with the latest offset (most recent) for each key
-		current, ok := s.index[meta.Key]
-		if ok {
-			// If the current index entry has a higher offset than the one we're reading, it means the current entry is more recent and we should skip the older one.
-			if current.Offset > entryOffset {
-				slog.Debug("Skipping older entry during compaction", slog.String("key", meta.Key), slog.Int64("current_offset", current.Offset), slog.Int64("entry_offset", entryOffset))
-				continue
-			}
+		// Verify checksum, delete markers of older record formats kept the checksum of the deleted object
+		if checksum != meta.Checksum && !meta.DeleteMarker {
+			return 0, fmt.Errorf("data checksum mismatch for key %q", meta.Key)
 		}
 
-		newIndex[meta.Key] = ObjectMeta{
-			Key:        meta.Key,
-			Offset:     entryOffset,
-			Size:       meta.Size,
-			Checksum:   meta.Checksum,
-			CreatedAt:  meta.CreatedAt,
-			ModifiedAt: meta.ModifiedAt,
-		}
+		copied[meta.Offset] = entry
+		newOffset += entry.entrySize()
 	}
 
-	// Write all valid entries to the new shard file
-	for _, meta := range newIndex {
-		fmt.Printf("Compacting entry: key=%s, offset=%d, size=%d\n", meta.Key, meta.Offset, meta.Size)
+	s.appendMu.Lock()
+	defer s.appendMu.Unlock()
 
-		// Seek to the offset and read the entry
-		if _, err := s.file.Seek(meta.Offset, io.SeekStart); err != nil {
-			return err
-		}
+	// Copy the entries that were appended since the snapshot as they are, their offsets only shift
+	end := s.size
+	tail := io.NewSectionReader(oldFile, snapshotEnd, end-snapshotEnd)
+	if _, err := io.Copy(tmpFile, tail); err != nil {
+		return 0, err
+	}
+	shift := newOffset - snapshotEnd
+	newSize := newOffset + end - snapshotEnd
 
-		// Read the entry and verify the key matches
-		readMeta, data, err := readEntry(s.file, false)
-		if err != nil {
-			return err
-		}
-		if readMeta.Key != meta.Key {
-			return errors.New("key mismatch")
+	// Entries in front of the snapshot end were part of the snapshot, as new entries are only appended and versions never return once removed
+	var live int64
+	relocate := func(meta ObjectMeta) (ObjectMeta, error) {
+		if meta.Offset >= snapshotEnd {
+			meta.Offset += shift
+		} else {
+			entry, ok := copied[meta.Offset]
+			if !ok {
+				return ObjectMeta{}, fmt.Errorf("entry of key %q is missing from the compacted shard", meta.Key)
+			}
+			meta = entry
 		}
 
-		// Verify checksum
-		if crc32.ChecksumIEEE(data) != meta.Checksum {
-			return errors.New("data checksum mismatch")
+		live += meta.entrySize()
+		return meta, nil
+	}
+
+	newIndex := make(map[string]ObjectMeta, len(s.index))
+	for key, meta := range s.index {
+		if newIndex[key], err = relocate(meta); err != nil {
+			return 0, err
 		}
+	}
 
-		if err := writeEntry(tmpFile, readMeta.Key, readMeta.Checksum, readMeta.CreatedAt, readMeta.ModifiedAt, data); err != nil {
-			return err
+	newHistory := make(map[string][]ObjectMeta, len(s.history))
+	for key, versions := range s.history {
+		relocated := make([]ObjectMeta, len(versions))
+		for i, meta := range versions {
+			if relocated[i], err = relocate(meta); err != nil {
+				return 0, err
+			}
 		}
+		newHistory[key] = relocated
 	}
 
 	// Ensure data is durable
 	if err := tmpFile.Sync(); err != nil {
-		return err
+		return 0, err
 	}
 
+	s.Lock()
+	defer s.Unlock()
+
 	// Close old shard before replacing
-	oldFile := s.file
 	if err := oldFile.Close(); err != nil {
-		return err
+		return 0, err
 	}
 
 	// Atomic replace
 	if err := os.Rename(tmpPath, shardPath); err != nil {
-		return err
+		return 0, err
 	}
 
 	// Reopen shard file
 	f, err := os.OpenFile(shardPath, os.O_RDWR, 0644)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	s.file = f
 	s.index = newIndex
-	s.dirty = false
+	s.history = newHistory
+	s.size = newSize
+	s.garbage = newSize - live
+
+	reclaimed := end - newSize
+	b.compactions.Add(1)
+	b.reclaimedBytes.Add(uint64(max(reclaimed, 0)))
+	b.lastCompaction.Store(time.Now().UnixMilli())
+
+	return reclaimed, nil
+}
+
+// throttle limits the rate at which compaction copies data, it is aborted once stop is closed.
+type throttle struct {
+	rate   uint64 // bytes per second, 0 = unlimited
+	stop   <-chan struct{}
+	start  time.Time
+	copied uint64
+}
+
+func newThrottle(rate uint64, stop <-chan struct{}) *throttle {
+	return &throttle{rate: rate, stop: stop, start: time.Now()}
+}
+
+// wait records that n bytes were copied and sleeps until copying them does not exceed the rate.
+func (t *throttle) wait(n int) error {
+	select {
+	case <-t.stop:
+		return errBucketClosed
+	default:
+	}
+
+	if t.rate == 0 {
+		return nil
+	}
+
+	t.copied += uint64(n)
+	due := time.Duration(float64(t.copied) / float64(t.rate) * float64(time.Second))
+	delay := due - time.Since(t.start)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-t.stop:
+		return errBucketClosed
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader is a reader that is limited by a throttle.
+type throttledReader struct {
+	r     io.Reader
+	limit *throttle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if waitErr := r.limit.wait(n); waitErr != nil {
+		return n, waitErr
+	}
 
-	return nil
+	return n, err
 }
@@ -0,0 +1,113 @@
+package objectengine
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// Copy copies the object stored under srcKey to dstKey in dst, which may be b itself or another bucket.
+// The copy keeps the checksum, creation time, content type and metadata of the source and becomes a new version of dstKey.
+func (b *Bucket) Copy(srcKey string, dst *Bucket, dstKey string) error {
+	return b.transfer(srcKey, dst, dstKey, false)
+}
+
+// Move moves the object stored under srcKey to dstKey in dst, which may be b itself or another bucket.
+// Like Copy, but the source object is deleted in the same step: the copy and the delete marker of the source are both written
+// before either is published, and they are published together, so no reader observes both or neither object.
+func (b *Bucket) Move(srcKey string, dst *Bucket, dstKey string) error {
+	return b.transfer(srcKey, dst, dstKey, true)
+}
+
+// Rename moves the object stored under oldKey to newKey within the bucket.
+func (b *Bucket) Rename(oldKey, newKey string) error {
+	return b.Move(oldKey, b, newKey)
+}
+
+// transfer copies the entry of srcKey into the shard of dstKey without loading the object into memory.
+// The append locks of both shards are held during the transfer, so readers of the shards are not blocked.
+// The source entry is deleted if move is set, if the delete marker cannot be written the copy is removed again.
+func (b *Bucket) transfer(srcKey string, dst *Bucket, dstKey string, move bool) error {
+	src := b.shardForKey(srcKey)
+	target := dst.shardForKey(dstKey)
+
+	unlock := lockShards(src, target)
+	defer unlock()
+
+	meta, ok := src.index[srcKey]
+	if !ok {
+		return ErrKeyNotFound
+	}
+
+	// Copying or moving an object onto itself leaves it unchanged
+	if b == dst && srcKey == dstKey {
+		return nil
+	}
+
+	offset, err := target.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	// Index entries are never modified, so the copy can share the metadata map of the source
+	copied := ObjectMeta{
+		Key:         dstKey,
+		Offset:      offset,
+		Size:        meta.Size,
+		Checksum:    meta.Checksum,
+		CreatedAt:   meta.CreatedAt,
+		ModifiedAt:  time.Now().UnixMilli(),
+		ContentType: meta.ContentType,
+		Metadata:    meta.Metadata,
+		VersionID:   dst.newVersionID(),
+	}
+
+	// The source data lies before the end of the target file even if both are the same shard, so it can be read while appending
+	data := io.NewSectionReader(src.file, meta.dataOffset(), int64(meta.Size))
+	checksum, err := writeEntryFrom(target.file, &copied, data)
+	if err != nil {
+		return truncateShard(target, offset, err)
+	}
+
+	// Verify checksum
+	if checksum != meta.Checksum {
+		return truncateShard(target, offset, errors.New("data checksum mismatch"))
+	}
+
+	if err := target.flush(); err != nil {
+		return truncateShard(target, offset, err)
+	}
+
+	if !move {
+		target.setEntry(copied)
+		return nil
+	}
+
+	marker, err := src.writeMarker(b.deleteMarker(meta))
+	if err != nil {
+		// The copy was not published yet, so it is removed by truncating the target (together with a partial marker if both are the same shard)
+		return truncateShard(target, offset, err)
+	}
+
+	// Publish the copy and the delete marker of the source together
+	if src == target {
+		target.Lock()
+		target.apply(copied)
+		target.apply(marker)
+		target.Unlock()
+		return nil
+	}
+
+	first, second := src, target
+	if first.id > second.id {
+		first, second = second, first
+	}
+	first.Lock()
+	second.Lock()
+	target.apply(copied)
+	src.apply(marker)
+	second.Unlock()
+	first.Unlock()
+
+	return nil
+}
@@ -3,5 +3,10 @@ package objectengine
 import "errors"
 
 var (
-	ErrKeyNotFound = errors.New("key not found")
+	ErrKeyNotFound      = errors.New("key not found")
+	ErrVersionNotFound  = errors.New("version not found")
+	ErrUploadNotFound   = errors.New("upload not found")
+	ErrObjectTooLarge   = errors.New("object exceeds the maximum object size")
+	ErrMetadataTooLarge = errors.New("content type and metadata exceed the maximum metadata size")
+	ErrObjectChanged    = errors.New("object changed while it was read")
 )
@@ -0,0 +1,182 @@
+package objectengine
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+)
+
+// LifecycleBasis is the timestamp of an object that the age of a lifecycle rule is measured from.
+type LifecycleBasis string
+
+const (
+	// LifecycleModified measures the age of an object from the time it was last stored. This is the default.
+	LifecycleModified LifecycleBasis = "modified"
+	// LifecycleCreated measures the age of an object from the time its key was first stored, replacing the object does not reset it.
+	LifecycleCreated LifecycleBasis = "created"
+)
+
+// lifecycleInterval is the interval in which the lifecycle rules are evaluated.
+const lifecycleInterval = 1 * time.Minute
+
+// ParseLifecycleBasis parses the name of a lifecycle basis. An empty name is LifecycleModified.
+func ParseLifecycleBasis(name string) (LifecycleBasis, error) {
+	switch basis := LifecycleBasis(name); basis {
+	case "":
+		return LifecycleModified, nil
+	case LifecycleModified, LifecycleCreated:
+		return basis, nil
+	default:
+		return "", fmt.Errorf("unknown lifecycle basis %q", name)
+	}
+}
+
+// LifecycleRule expires the objects whose keys start with Prefix once they are older than MaxAge.
+type LifecycleRule struct {
+	// Prefix restricts the rule to keys that start with it, an empty prefix matches all keys.
+	Prefix string
+	// MaxAge is the age after which an object expires, rules without a positive MaxAge are ignored.
+	MaxAge time.Duration
+	// Basis is the timestamp the age is measured from. Defaults to LifecycleModified.
+	Basis LifecycleBasis
+}
+
+// expired reports whether the rule expires the object described by meta at now.
+func (r LifecycleRule) expired(meta ObjectMeta, now int64) bool {
+	if r.MaxAge <= 0 || !strings.HasPrefix(meta.Key, r.Prefix) {
+		return false
+	}
+
+	since := meta.ModifiedAt
+	if r.Basis == LifecycleCreated {
+		since = meta.CreatedAt
+	}
+
+	return now-since > r.MaxAge.Milliseconds()
+}
+
+// LifecycleReport describes the objects that the lifecycle rules of a bucket expire.
+type LifecycleReport struct {
+	// Objects is the number of expired objects.
+	Objects uint64 `json:"objects"`
+	// Bytes is the total size of the expired objects.
+	Bytes uint64 `json:"bytes"`
+	// Keys are the keys of the expired objects in lexicographic order, at most MaxListKeys.
+	Keys []string `json:"keys"`
+	// Truncated reports whether more objects expire than Keys contains.
+	Truncated bool `json:"truncated"`
+}
+
+// LifecycleReport returns the objects that the lifecycle rules expire right now, without removing them.
+func (b *Bucket) LifecycleReport() LifecycleReport {
+	now := time.Now().UnixMilli()
+	report := LifecycleReport{Keys: []string{}}
+
+	for _, s := range b.shards {
+		for _, meta := range b.expiredObjects(s, now) {
+			report.Objects++
+			report.Bytes += uint64(meta.Size)
+			report.Keys = append(report.Keys, meta.Key)
+		}
+	}
+
+	slices.Sort(report.Keys)
+	if len(report.Keys) > MaxListKeys {
+		report.Keys = report.Keys[:MaxListKeys]
+		report.Truncated = true
+	}
+
+	return report
+}
+
+// expiredObjects returns the objects of the shard that one of the lifecycle rules expires at now.
+func (b *Bucket) expiredObjects(s *shard, now int64) []ObjectMeta {
+	s.RLock()
+	defer s.RUnlock()
+
+	var expired []ObjectMeta
+	for _, meta := range s.index {
+		for _, rule := range b.lifecycleRules {
+			if rule.expired(meta, now) {
+				expired = append(expired, meta)
+				break
+			}
+		}
+	}
+
+	return expired
+}
+
+func (b *Bucket) startLifecycleSchedule() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(lifecycleInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				b.applyLifecycle(time.Now().UnixMilli())
+			}
+		}
+	}()
+}
+
+// applyLifecycle deletes the objects that the lifecycle rules expire at now, like Delete does.
+// The space of the deleted objects is reclaimed by the next compaction, in a versioned bucket only once the version retention removes them.
+func (b *Bucket) applyLifecycle(now int64) {
+	for i, s := range b.shards {
+		expired := b.expiredObjects(s, now)
+		if len(expired) == 0 {
+			continue
+		}
+
+		n, err := b.expireObjects(s, expired)
+		b.lifecycleExpirations.Add(uint64(n))
+		if err != nil {
+			slog.Error("Failed to expire objects",
+				slog.String("database", b.database),
+				slog.String("collection", b.collection),
+				slog.Int("shard_index", i),
+				sloki.WrapError(err),
+			)
+			continue
+		}
+
+		slog.Debug("Expired objects",
+			slog.String("database", b.database),
+			slog.String("collection", b.collection),
+			slog.Int("shard_index", i),
+			slog.Int("objects", n),
+		)
+	}
+}
+
+// expireObjects deletes the given objects of the shard and returns the number of deleted objects.
+// Objects that were replaced or deleted since they were found to be expired are skipped.
+func (b *Bucket) expireObjects(s *shard, expired []ObjectMeta) (int, error) {
+	s.appendMu.Lock()
+	defer s.appendMu.Unlock()
+
+	n := 0
+	for _, meta := range expired {
+		if current, ok := s.index[meta.Key]; !ok || current.Offset != meta.Offset {
+			continue
+		}
+
+		if err := b.deleteEntry(s, meta); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
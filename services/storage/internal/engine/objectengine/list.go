@@ -0,0 +1,177 @@
+package objectengine
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxListKeys is the maximum (and default) number of objects and common prefixes returned by a single List call
+	MaxListKeys = 1000
+
+	// maxListBytes limits the total size of the keys and common prefixes of a single page, so that it always fits into one protocol frame
+	maxListBytes = 4 << 20
+)
+
+// ListOptions controls which objects List returns
+type ListOptions struct {
+	// Prefix restricts the listing to keys that start with it
+	Prefix string
+
+	// Delimiter groups keys that contain it after the prefix into a single common prefix, which ends with the first occurrence of the delimiter.
+	// With "/" as delimiter, keys behave like paths and common prefixes like directories.
+	Delimiter string
+
+	// Token continues a listing after the last object or common prefix of the previous page, see ListResult.NextToken
+	Token string
+
+	// MaxKeys is the maximum number of objects and common prefixes to return, capped at MaxListKeys (0 means MaxListKeys)
+	MaxKeys int
+}
+
+// ListResult is a page of a listing
+type ListResult struct {
+	// Objects are the metadata of the listed objects in lexicographic order of their keys
+	Objects []ObjectMeta
+
+	// CommonPrefixes are the common prefixes of the keys grouped by the delimiter in lexicographic order
+	CommonPrefixes []string
+
+	// Truncated reports whether there are more objects or common prefixes, which can be listed by passing NextToken as ListOptions.Token
+	Truncated bool
+	NextToken string
+}
+
+// List returns the objects whose keys start with a prefix, ordered lexicographically across all shards.
+// Objects and common prefixes count towards the page size together, a listing is continued with the token of the previous page.
+// A page also ends early once its keys and common prefixes exceed maxListBytes, so that it always fits into one protocol frame.
+// Each shard is read consistently, but objects written to other shards during the listing may or may not be included.
+func (b *Bucket) List(opts ListOptions) ListResult {
+	maxKeys := opts.MaxKeys
+	if maxKeys <= 0 || maxKeys > MaxListKeys {
+		maxKeys = MaxListKeys
+	}
+
+	// Every element of the page is among the first elements of its shard, so it is enough to collect those and merge them
+	var candidates []listCandidate
+	more := false
+	for _, s := range b.shards {
+		shardCandidates, shardMore := s.listCandidates(opts, maxKeys+1)
+		candidates = append(candidates, shardCandidates...)
+		more = more || shardMore
+	}
+
+	slices.SortFunc(candidates, func(a, b listCandidate) int {
+		return strings.Compare(a.element, b.element)
+	})
+
+	var res ListResult
+	count := 0
+	size := 0
+	for _, c := range candidates {
+		if c.isPrefix && c.element == res.NextToken {
+			continue // the common prefix was already added from another shard
+		}
+
+		if count == maxKeys || (count > 0 && size+len(c.element) > maxListBytes) {
+			res.Truncated = true
+			return res
+		}
+
+		if c.isPrefix {
+			res.CommonPrefixes = append(res.CommonPrefixes, c.element)
+		} else {
+			res.Objects = append(res.Objects, c.meta)
+		}
+		res.NextToken = c.element
+		count++
+		size += len(c.element)
+	}
+
+	if more {
+		res.Truncated = true
+		return res
+	}
+
+	res.NextToken = ""
+	return res
+}
+
+// listCandidate is an element of a listing collected from a single shard
+type listCandidate struct {
+	element  string
+	isPrefix bool
+	meta     ObjectMeta
+}
+
+// listCandidates returns up to limit elements of the shard that follow the token of the listing in lexicographic order.
+// The elements are also limited to maxListBytes, more reports whether the shard has further elements.
+// Keys grouped into a common prefix are skipped with a binary search, so only the returned elements are visited.
+func (s *shard) listCandidates(opts ListOptions, limit int) ([]listCandidate, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	i, _ := slices.BinarySearch(s.keys, max(opts.Prefix, opts.Token))
+	var candidates []listCandidate
+	size := 0
+	for i < len(s.keys) {
+		key := s.keys[i]
+		if !strings.HasPrefix(key, opts.Prefix) {
+			break
+		}
+
+		element, isPrefix := listElement(key, opts)
+		if element <= opts.Token {
+			if isPrefix {
+				// the key is grouped into the common prefix of the token
+				i = skipPrefix(s.keys, i, element)
+			} else {
+				i++
+			}
+			continue
+		}
+
+		if len(candidates) == limit || size > maxListBytes {
+			return candidates, true
+		}
+
+		candidates = append(candidates, listCandidate{
+			element:  element,
+			isPrefix: isPrefix,
+			meta:     s.index[key],
+		})
+		size += len(element)
+
+		if isPrefix {
+			i = skipPrefix(s.keys, i, element)
+		} else {
+			i++
+		}
+	}
+
+	return candidates, false
+}
+
+// skipPrefix returns the index of the first key after keys[i] that does not start with prefix.
+// The keys must be sorted, so that all keys with the prefix are adjacent.
+func skipPrefix(keys []string, i int, prefix string) int {
+	return i + sort.Search(len(keys)-i, func(j int) bool {
+		return !strings.HasPrefix(keys[i+j], prefix)
+	})
+}
+
+// listElement returns the element a key is listed as: the key itself, or its common prefix if it contains the delimiter after the prefix.
+// Elements are ordered like their keys, because a common prefix is a prefix of all keys it groups and these keys are adjacent in lexicographic order.
+func listElement(key string, opts ListOptions) (string, bool) {
+	if opts.Delimiter == "" {
+		return key, false
+	}
+
+	rest := key[len(opts.Prefix):]
+	if i := strings.Index(rest, opts.Delimiter); i >= 0 {
+		return key[:len(opts.Prefix)+i+len(opts.Delimiter)], true
+	}
+
+	return key, false
+}
@@ -1,11 +1,22 @@
 package objectengine
 
+import (
+	"maps"
+	"math"
+)
+
+// MaxKeyLength is the maximum length of an object key in bytes, the protocol encodes keys with a 2-byte length
+const MaxKeyLength = math.MaxUint16
+
+// MaxMetadataSize is the maximum combined length in bytes of the content type and the names and values of the user metadata of an object
+const MaxMetadataSize = 8 * 1024
+
 // ObjectMeta stores object metadata including checksum
 type ObjectMeta struct {
 	// Key is the unique identifier for the object
 	Key string
 
-	// Offset is the byte offset in the shard file where the object data starts
+	// Offset is the byte offset in the shard file where the entry of the object starts
 	Offset int64
 
 	// Size is the length of the object data in bytes
@@ -19,4 +30,60 @@ type ObjectMeta struct {
 
 	// ModifiedAt is the timestamp (in unix milliseconds) when the object was last modified
 	ModifiedAt int64
+
+	// ContentType is the media type of the object (e.g. "image/png"), empty if unknown
+	ContentType string
+
+	// Metadata is user-defined metadata of the object, nil if there is none
+	Metadata map[string]string
+
+	// VersionID identifies the version of the object, it is 0 for objects that were stored while versioning was disabled (the null version).
+	// Version IDs increase with every version stored in the bucket.
+	VersionID uint64
+
+	// DeleteMarker is set for the versions created by deleting an object in a versioned bucket, they have no data.
+	DeleteMarker bool
+
+	// purge is set for entries that permanently remove the version VersionID of the key, they never become a version themselves
+	purge bool
+
+	// headerSize is the length of the entry in front of the object data, the data starts at Offset + headerSize
+	headerSize int64
+}
+
+// dataOffset returns the byte offset in the shard file where the object data starts
+func (m *ObjectMeta) dataOffset() int64 {
+	return m.Offset + m.headerSize
+}
+
+// entrySize returns the number of bytes the entry of the object takes up in the shard file
+func (m *ObjectMeta) entrySize() int64 {
+	return m.headerSize + int64(m.Size)
+}
+
+// metadataSize returns the number of bytes the metadata entries take up in the shard file
+func metadataSize(metadata map[string]string) int {
+	size := 0
+	for name, value := range metadata {
+		size += 2 + len(name) + 2 + len(value)
+	}
+	return size
+}
+
+// checkAttributes validates the content type and user metadata of an object and returns a copy of the metadata,
+// so that callers cannot modify the metadata stored in the index
+func checkAttributes(contentType string, metadata map[string]string) (map[string]string, error) {
+	size := len(contentType)
+	for name, value := range metadata {
+		size += len(name) + len(value)
+	}
+	if size > MaxMetadataSize {
+		return nil, ErrMetadataTooLarge
+	}
+
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	return maps.Clone(metadata), nil
 }
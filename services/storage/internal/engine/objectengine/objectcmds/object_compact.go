@@ -0,0 +1,49 @@
+package objectcmds
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/database"
+)
+
+// handleCompact schedules the compaction of all shards of an object engine that contain replaced or deleted objects.
+// Only admins of the database may compact its collections.
+// Payload format: empty
+// The response is sent once the compaction is scheduled, its progress can be observed with the stats command.
+func (c *Commands) handleCompact(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	u := c.userFromCtx(ctx.Ctx)
+	if u == nil || !u.Verified || !u.IsActive {
+		return commonresponses.Unauthorized, nil
+	}
+
+	db, err := c.dbStore.GetDatabase(ctx.Ctx, cmd.DatabaseName)
+	if err != nil {
+		if errors.Is(err, database.ErrDatabaseNotFound) {
+			return commonresponses.DatabaseNotFound, nil
+		}
+
+		slog.Error("Failed to get database",
+			slog.String("database", cmd.DatabaseName),
+			sloki.WrapError(err),
+		)
+		return commonresponses.InternalServerError, nil
+	}
+
+	if !u.IsAdmin() && !db.HasPermission(u.ID, database.PermissionLevelAdmin) {
+		return commonresponses.Forbidden, nil
+	}
+
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	obje.CompactNow()
+
+	return commonresponses.OK, nil
+}
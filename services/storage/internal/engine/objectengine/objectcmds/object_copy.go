@@ -0,0 +1,89 @@
+package objectcmds
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/objectengine"
+)
+
+// handleCopy processes a copy command for an object engine, which copies an object to another key, possibly in another object collection of the same database.
+// Payload format: | Source Key Length (2 bytes) | Source Key (variable) | Target Collection Length (2 bytes) | Target Collection (variable) | Target Key Length (2 bytes) | Target Key (variable) |
+// An empty target collection refers to the collection of the command.
+// If the source key is not found, a protocol.StatusNotFound response will be returned with an empty payload.
+func (c *Commands) handleCopy(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	return c.handleTransfer(cmd, false)
+}
+
+// handleMove processes a move command for an object engine, which moves an object to another key, possibly in another object collection of the same database.
+// Same payload format and responses as handleCopy.
+func (c *Commands) handleMove(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	return c.handleTransfer(cmd, true)
+}
+
+func (c *Commands) handleTransfer(cmd *protocol.Command, move bool) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	srcKey, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	dstCollection, rest, resp := readKey(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	dstKey, _, resp := readKey(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	dst := obje
+	if dstCollection != "" && dstCollection != cmd.CollectionName {
+		dst, resp = c.objectEngine(&protocol.Command{
+			DatabaseName:   cmd.DatabaseName,
+			CollectionName: dstCollection,
+		})
+		if resp != nil {
+			return resp, nil
+		}
+	}
+
+	if move {
+		err := obje.Move(srcKey, dst, dstKey)
+		return transferResponse(cmd, "Failed to move object", srcKey, err), nil
+	}
+
+	err := obje.Copy(srcKey, dst, dstKey)
+	return transferResponse(cmd, "Failed to copy object", srcKey, err), nil
+}
+
+// transferResponse maps the result of a copy, move or rename to a response, unexpected errors are logged with msg.
+func transferResponse(cmd *protocol.Command, msg, key string, err error) *protocol.Response {
+	if err == nil {
+		return commonresponses.OK
+	}
+
+	if errors.Is(err, objectengine.ErrKeyNotFound) {
+		return &protocol.Response{
+			Code:    protocol.StatusNotFound,
+			Payload: *commonresponses.EmptyPayload,
+		}
+	}
+
+	slog.Error(msg,
+		slog.String("database", cmd.DatabaseName),
+		slog.String("collection", cmd.CollectionName),
+		slog.String("key", key),
+		sloki.WrapError(err),
+	)
+	return commonresponses.InternalServerError
+}
@@ -13,8 +13,10 @@ import (
 	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/objectengine"
 )
 
-// handlePut processes a delete command for an object engine.
-// Payload format: | Key Length (2 bytes) | Key (variable) |
+// handleDelete processes a delete command for an object engine.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Version ID (8 bytes, optional) |
+// Without version ID, the object is deleted, which creates a delete marker in versioned collections.
+// With version ID, the version is removed permanently.
 func (c *Commands) handleDelete(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
 	e, err := c.engineService.GetEngine(cmd.DatabaseName, cmd.CollectionName)
 	if err != nil {
@@ -53,8 +55,18 @@ func (c *Commands) handleDelete(ctx *command.ConnCtx, _ *protocol.Message, cmd *
 
 	key := string(data[2 : 2+keyLen])
 
-	if err := obje.Delete(key); err != nil {
-		if errors.Is(err, objectengine.ErrKeyNotFound) {
+	versionID, versioned, resp := readVersionID(data[2+keyLen:])
+	if resp != nil {
+		return resp, nil
+	}
+
+	if versioned {
+		err = obje.DeleteVersion(key, versionID)
+	} else {
+		err = obje.Delete(key)
+	}
+	if err != nil {
+		if errors.Is(err, objectengine.ErrKeyNotFound) || errors.Is(err, objectengine.ErrVersionNotFound) {
 			return &protocol.Response{
 				Code:    protocol.StatusNotFound,
 				Payload: *commonresponses.EmptyPayload,
@@ -0,0 +1,31 @@
+package objectcmds
+
+import (
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// handleExists processes an exists command for an object engine. The payload is expected to be in the format:
+// Payload format: | Key Length (2 bytes) | Key (variable) |
+// A protocol.StatusOK response is returned if the object exists, a protocol.StatusNotFound response otherwise.
+func (c *Commands) handleExists(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, _, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if !obje.Exists(key) {
+		return &protocol.Response{
+			Code:    protocol.StatusNotFound,
+			Payload: *commonresponses.EmptyPayload,
+		}, nil
+	}
+
+	return commonresponses.OK, nil
+}
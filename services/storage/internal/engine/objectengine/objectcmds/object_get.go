@@ -15,9 +15,10 @@ import (
 )
 
 // handleGet processes a get command for an object engine. The payload is expected to be in the format:
-// Payload format: | Key Length (2 bytes) | Key (variable) |
+// Payload format: | Key Length (2 bytes) | Key (variable) | Version ID (8 bytes, optional) |
 // Response payload will be the binary data associated with the key, encoded using codex.TypeBinary.
-// If the key is not found, a protocol.StatusNotFound response will be returned with an empty payload.
+// Without version ID, the current version is returned.
+// If the key or version is not found or the version is a delete marker, a protocol.StatusNotFound response will be returned with an empty payload.
 func (c *Commands) handleGet(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
 	e, err := c.engineService.GetEngine(cmd.DatabaseName, cmd.CollectionName)
 	if err != nil {
@@ -56,9 +57,19 @@ func (c *Commands) handleGet(ctx *command.ConnCtx, _ *protocol.Message, cmd *pro
 
 	key := string(data[2 : 2+keyLen])
 
-	binData, err := obje.Get(key)
+	versionID, versioned, resp := readVersionID(data[2+keyLen:])
+	if resp != nil {
+		return resp, nil
+	}
+
+	var binData []byte
+	if versioned {
+		binData, err = obje.GetVersion(key, versionID)
+	} else {
+		binData, err = obje.Get(key)
+	}
 	if err != nil {
-		if errors.Is(err, objectengine.ErrKeyNotFound) {
+		if errors.Is(err, objectengine.ErrKeyNotFound) || errors.Is(err, objectengine.ErrVersionNotFound) {
 			return &protocol.Response{
 				Code:    protocol.StatusNotFound,
 				Payload: *commonresponses.EmptyPayload,
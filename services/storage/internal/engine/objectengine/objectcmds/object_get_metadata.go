@@ -14,8 +14,10 @@ import (
 )
 
 // handleGetMetadata processes a get metadata command for an object engine.
-// Payload format: | Key Length (2 bytes) | Key (variable) |
-// Response payload format: | Size (8 bytes) | CRC32 (4 bytes) | CreatedAt (8 bytes) | ModifiedAt (8 bytes) |
+// Payload format: | Key Length (2 bytes) | Key (variable) | Version ID (8 bytes, optional) |
+// Response payload format: | Size (8 bytes) | CRC32 (4 bytes) | CreatedAt (8 bytes) | ModifiedAt (8 bytes) | Content Type and Metadata (see readAttributes) |
+// | Version ID (8 bytes) | Delete Marker (1 byte) |
+// Without version ID, the metadata of the current version is returned. Only versions requested by their ID can be delete markers.
 func (c *Commands) handleGetMetadata(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
 	e, err := c.engineService.GetEngine(cmd.DatabaseName, cmd.CollectionName)
 	if err != nil {
@@ -54,9 +56,19 @@ func (c *Commands) handleGetMetadata(ctx *command.ConnCtx, _ *protocol.Message,
 
 	key := string(data[2 : 2+keyLen])
 
-	omd, err := obje.GetMeta(key)
+	versionID, versioned, resp := readVersionID(data[2+keyLen:])
+	if resp != nil {
+		return resp, nil
+	}
+
+	var omd *objectengine.ObjectMeta
+	if versioned {
+		omd, err = obje.GetMetaVersion(key, versionID)
+	} else {
+		omd, err = obje.GetMeta(key)
+	}
 	if err != nil {
-		if errors.Is(err, objectengine.ErrKeyNotFound) {
+		if errors.Is(err, objectengine.ErrKeyNotFound) || errors.Is(err, objectengine.ErrVersionNotFound) {
 			return &protocol.Response{
 				Code:    protocol.StatusNotFound,
 				Payload: *commonresponses.EmptyPayload,
@@ -87,6 +99,17 @@ func (c *Commands) handleGetMetadata(ctx *command.ConnCtx, _ *protocol.Message,
 	// ModifiedAt
 	binary.BigEndian.PutUint64(payload[20:28], uint64(omd.ModifiedAt))
 
+	// Content type and user metadata
+	payload = appendAttributes(payload, omd.ContentType, omd.Metadata)
+
+	// Version
+	payload = binary.BigEndian.AppendUint64(payload, omd.VersionID)
+	if omd.DeleteMarker {
+		payload = append(payload, 1)
+	} else {
+		payload = append(payload, 0)
+	}
+
 	return &protocol.Response{
 		Code:    protocol.StatusOK,
 		Payload: payload,
@@ -0,0 +1,68 @@
+package objectcmds
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/objectengine"
+)
+
+// maxRangeLength is the maximum number of bytes returned by a ranged read, so that the response fits into a protocol frame.
+const maxRangeLength = 8 * 1024 * 1024
+
+// handleGetRange processes a ranged get command for an object engine. The payload is expected to be in the format:
+// Payload format: | Key Length (2 bytes) | Key (variable) | Offset (8 bytes) | Length (4 bytes) |
+// Response payload will be up to Length bytes of the object starting at Offset, encoded using codex.TypeBinary.
+// Length is capped at 8 MB, the data is shorter if the object ends before and empty if Offset lies behind its end.
+// If the key is not found, a protocol.StatusNotFound response will be returned with an empty payload.
+func (c *Commands) handleGetRange(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if len(rest) != 8+4 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for range"),
+		}, nil
+	}
+
+	// offsets behind the maximum object size lie behind the end of every object
+	offset := min(binary.BigEndian.Uint64(rest[0:8]), objectengine.MaxObjectSize)
+	length := min(binary.BigEndian.Uint32(rest[8:12]), maxRangeLength)
+
+	data, err := obje.GetRange(key, uint32(offset), length)
+	if err != nil {
+		if errors.Is(err, objectengine.ErrKeyNotFound) {
+			return &protocol.Response{
+				Code:    protocol.StatusNotFound,
+				Payload: *commonresponses.EmptyPayload,
+			}, nil
+		}
+
+		slog.Error("Failed to get object range",
+			slog.String("database", cmd.DatabaseName),
+			slog.String("collection", cmd.CollectionName),
+			slog.String("key", key),
+			sloki.WrapError(err),
+		)
+		return commonresponses.InternalServerError, nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeBinary(data),
+	}, nil
+}
@@ -0,0 +1,294 @@
+package objectcmds
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OliverSchlueter/goutils/problems"
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/database"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/objectengine"
+)
+
+// metadataHeaderPrefix is the prefix of the HTTP headers that carry the user-defined metadata of an object.
+// Header names are case-insensitive, so metadata names are lower-cased when an object is stored over HTTP.
+const metadataHeaderPrefix = "X-Object-Meta-"
+
+// versionHeader is the HTTP header that carries the version ID of an object.
+const versionHeader = "X-Object-Version-Id"
+
+// versionQueryParam is the query parameter that addresses a version of an object.
+const versionQueryParam = "versionId"
+
+// httpChunkSize is the size of the chunks request bodies are streamed into the shard files with.
+const httpChunkSize = 1024 * 1024
+
+// RegisterHTTP registers the HTTP gateway for object collections.
+// Objects are addressed by their key, which may contain slashes: {prefix}/databases/{db_id}/collections/{coll_name}/objects/{key...}
+func (c *Commands) RegisterHTTP(prefix string, mux *http.ServeMux) {
+	mux.HandleFunc(prefix+"/databases/{db_id}/collections/{coll_name}/objects/{key...}", c.handleObjectHTTP)
+}
+
+func (c *Commands) handleObjectHTTP(w http.ResponseWriter, r *http.Request) {
+	var requiredLevel database.PermissionLevel
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		requiredLevel = database.PermissionLevelReadOnly
+	case http.MethodPut, http.MethodDelete:
+		requiredLevel = database.PermissionLevelReadWrite
+	default:
+		problems.MethodNotAllowed(r.Method, []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete}).WriteToHTTP(w)
+		return
+	}
+
+	u := c.userFromCtx(r.Context())
+	if u == nil || !u.Verified || !u.IsActive {
+		problems.Unauthorized().WriteToHTTP(w)
+		return
+	}
+
+	dbid := r.PathValue("db_id")
+	if dbid == "" {
+		problems.ValidationError("db_id", "Database ID is required").WriteToHTTP(w)
+		return
+	}
+
+	db, err := c.dbStore.GetDatabase(r.Context(), dbid)
+	if err != nil {
+		if errors.Is(err, database.ErrDatabaseNotFound) {
+			problems.NotFound("Database", dbid).WriteToHTTP(w)
+			return
+		}
+
+		slog.Error("Failed to get database by id", sloki.WrapError(err))
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
+	if !db.HasPermission(u.ID, requiredLevel) && !u.IsAdmin() {
+		problems.Forbidden().WriteToHTTP(w)
+		return
+	}
+
+	collname := r.PathValue("coll_name")
+	if collname == "" {
+		problems.ValidationError("coll_name", "Collection name is required").WriteToHTTP(w)
+		return
+	}
+
+	coll, err := c.dbStore.GetCollection(r.Context(), db, collname)
+	if err != nil {
+		if errors.Is(err, database.ErrCollectionNotFound) {
+			problems.NotFound("Collection", collname).WriteToHTTP(w)
+			return
+		}
+
+		slog.Error("Failed to get collection by name", sloki.WrapError(err))
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
+
+	e, err := c.engineService.GetEngine(db.Name, coll.Name)
+	if err != nil {
+		if errors.Is(err, database.ErrCollectionNotFound) {
+			problems.NotFound("Collection", collname).WriteToHTTP(w)
+			return
+		}
+
+		slog.Error("Failed to get engine",
+			slog.String("database", db.Name),
+			slog.String("collection", coll.Name),
+			sloki.WrapError(err),
+		)
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
+
+	if e.Type != database.EngineObject {
+		problems.ValidationError("Engine", "Collection is not an object collection").WriteToHTTP(w)
+		return
+	}
+
+	obje := e.AsObjectEngine()
+
+	key := r.PathValue("key")
+	if key == "" {
+		problems.ValidationError("key", "Key is required").WriteToHTTP(w)
+		return
+	}
+	if len(key) > objectengine.MaxKeyLength {
+		problems.ValidationError("key", "Key is too long").WriteToHTTP(w)
+		return
+	}
+
+	var versionID *uint64
+	if value := r.URL.Query().Get(versionQueryParam); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			problems.ValidationError(versionQueryParam, "Version ID must be an unsigned integer").WriteToHTTP(w)
+			return
+		}
+		versionID = &id
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		c.handleGetObjectHTTP(w, r, obje, key, versionID)
+	case http.MethodPut:
+		c.handlePutObjectHTTP(w, r, obje, key)
+	case http.MethodDelete:
+		c.handleDeleteObjectHTTP(w, r, obje, key, versionID)
+	}
+}
+
+// handleGetObjectHTTP serves an object, or the version with the given ID, for GET and HEAD requests.
+// Range requests and the conditional headers If-Match, If-None-Match, If-Modified-Since and If-Unmodified-Since are supported,
+// with the CRC32 checksum as ETag and the modification time as Last-Modified.
+func (c *Commands) handleGetObjectHTTP(w http.ResponseWriter, r *http.Request, obje *objectengine.Bucket, key string, versionID *uint64) {
+	var reader *objectengine.Reader
+	var err error
+	if versionID != nil {
+		reader, err = obje.NewVersionReader(key, *versionID)
+	} else {
+		reader, err = obje.NewReader(key)
+	}
+	if err != nil {
+		if errors.Is(err, objectengine.ErrKeyNotFound) {
+			problems.NotFound("Object", key).WriteToHTTP(w)
+			return
+		}
+		if errors.Is(err, objectengine.ErrVersionNotFound) {
+			problems.NotFound("Version", strconv.FormatUint(*versionID, 10)).WriteToHTTP(w)
+			return
+		}
+
+		slog.Error("Failed to open object", slog.String("key", key), sloki.WrapError(err))
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
+
+	meta := reader.Meta()
+
+	contentType := meta.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag(meta))
+	w.Header().Set(versionHeader, strconv.FormatUint(meta.VersionID, 10))
+	for name, value := range meta.Metadata {
+		w.Header().Set(metadataHeaderPrefix+name, value)
+	}
+
+	// ServeContent evaluates the conditional headers against ETag and the modification time and handles range requests
+	http.ServeContent(w, r, "", time.UnixMilli(meta.ModifiedAt), io.NewSectionReader(reader, 0, reader.Size()))
+}
+
+// handlePutObjectHTTP stores the request body as a new version of the object, its version ID is returned in the X-Object-Version-Id header.
+// The body is streamed into the bucket as a chunked upload, the Content-Type header and the X-Object-Meta-* headers are stored with the object.
+func (c *Commands) handlePutObjectHTTP(w http.ResponseWriter, r *http.Request, obje *objectengine.Bucket, key string) {
+	var metadata map[string]string
+	for name, values := range r.Header {
+		if !strings.HasPrefix(name, metadataHeaderPrefix) || len(values) == 0 {
+			continue
+		}
+
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[strings.ToLower(strings.TrimPrefix(name, metadataHeaderPrefix))] = values[0]
+	}
+
+	id, err := obje.BeginUpload(key, r.Header.Get("Content-Type"), metadata)
+	if err != nil {
+		if errors.Is(err, objectengine.ErrMetadataTooLarge) {
+			problems.ValidationError("headers", err.Error()).WriteToHTTP(w)
+			return
+		}
+
+		slog.Error("Failed to begin object upload", slog.String("key", key), sloki.WrapError(err))
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
+
+	if err := appendBody(obje, id, r.Body); err != nil {
+		_ = obje.AbortUpload(id)
+
+		if errors.Is(err, objectengine.ErrObjectTooLarge) {
+			problems.ValidationError("body", err.Error()).WriteToHTTP(w)
+			return
+		}
+
+		slog.Error("Failed to upload object", slog.String("key", key), sloki.WrapError(err))
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
+
+	meta, err := obje.CommitUpload(id)
+	if err != nil {
+		slog.Error("Failed to commit object upload", slog.String("key", key), sloki.WrapError(err))
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
+
+	w.Header().Set("ETag", etag(*meta))
+	w.Header().Set(versionHeader, strconv.FormatUint(meta.VersionID, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteObjectHTTP deletes an object, or removes the version with the given ID permanently.
+func (c *Commands) handleDeleteObjectHTTP(w http.ResponseWriter, _ *http.Request, obje *objectengine.Bucket, key string, versionID *uint64) {
+	var err error
+	if versionID != nil {
+		err = obje.DeleteVersion(key, *versionID)
+	} else {
+		err = obje.Delete(key)
+	}
+	if err != nil {
+		if errors.Is(err, objectengine.ErrKeyNotFound) {
+			problems.NotFound("Object", key).WriteToHTTP(w)
+			return
+		}
+		if errors.Is(err, objectengine.ErrVersionNotFound) {
+			problems.NotFound("Version", strconv.FormatUint(*versionID, 10)).WriteToHTTP(w)
+			return
+		}
+
+		slog.Error("Failed to delete object", slog.String("key", key), sloki.WrapError(err))
+		problems.InternalServerError("").WriteToHTTP(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// appendBody appends everything read from body to the upload with the given ID.
+func appendBody(obje *objectengine.Bucket, id uint64, body io.Reader) error {
+	buf := make([]byte, httpChunkSize)
+	for {
+		n, err := io.ReadFull(body, buf)
+		if n > 0 {
+			if err := obje.AppendUpload(id, buf[:n]); err != nil {
+				return err
+			}
+		}
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// etag returns the entity tag of an object, which is derived from its checksum.
+func etag(meta objectengine.ObjectMeta) string {
+	return fmt.Sprintf("\"%08x\"", meta.Checksum)
+}
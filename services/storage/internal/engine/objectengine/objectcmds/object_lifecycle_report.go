@@ -0,0 +1,36 @@
+package objectcmds
+
+import (
+	"log/slog"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// handleLifecycleReport returns the objects that the lifecycle rules of an object engine expire right now, without deleting them.
+// Payload format: empty
+// Response payload: codex-encoded binary value containing the marshaled objectengine.LifecycleReport
+func (c *Commands) handleLifecycleReport(_ *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	data, err := codex.Marshal(obje.LifecycleReport())
+	if err != nil {
+		slog.Error("Failed to marshal lifecycle report",
+			slog.String("database", cmd.DatabaseName),
+			slog.String("collection", cmd.CollectionName),
+			sloki.WrapError(err),
+		)
+		return commonresponses.InternalServerError, nil
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: codex.EncodeBinary(data),
+	}, nil
+}
@@ -0,0 +1,77 @@
+package objectcmds
+
+import (
+	"encoding/binary"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/objectengine"
+)
+
+// handleList processes a list command for an object engine, which lists objects in lexicographic order of their keys.
+// Payload format: | Prefix Length (2 bytes) | Prefix (variable) | Delimiter Length (2 bytes) | Delimiter (variable) | Token Length (2 bytes) | Token (variable) | Max Keys (2 bytes) |
+// Response payload: | Truncated (1 byte) | Next Token Length (2 bytes) | Next Token (variable) | Object Count (2 bytes) | Objects | Prefix Count (2 bytes) | Common Prefixes |
+// Each object is encoded as | Key Length (2 bytes) | Key (variable) | Size (8 bytes) | Checksum (4 bytes) | Created At (8 bytes) | Modified At (8 bytes) |,
+// each common prefix as | Prefix Length (2 bytes) | Prefix (variable) |.
+func (c *Commands) handleList(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	prefix, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	delimiter, rest, resp := readKey(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	token, rest, resp := readKey(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if len(rest) != 2 {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for max keys"),
+		}, nil
+	}
+
+	res := obje.List(objectengine.ListOptions{
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		Token:     token,
+		MaxKeys:   int(binary.BigEndian.Uint16(rest[0:2])),
+	})
+
+	var payload []byte
+	if res.Truncated {
+		payload = append(payload, 1)
+	} else {
+		payload = append(payload, 0)
+	}
+	payload = appendString(payload, res.NextToken)
+
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(res.Objects)))
+	for _, meta := range res.Objects {
+		payload = appendString(payload, meta.Key)
+		payload = binary.BigEndian.AppendUint64(payload, uint64(meta.Size))
+		payload = binary.BigEndian.AppendUint32(payload, meta.Checksum)
+		payload = binary.BigEndian.AppendUint64(payload, uint64(meta.CreatedAt))
+		payload = binary.BigEndian.AppendUint64(payload, uint64(meta.ModifiedAt))
+	}
+
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(res.CommonPrefixes)))
+	for _, p := range res.CommonPrefixes {
+		payload = appendString(payload, p)
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: payload,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package objectcmds
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/objectengine"
+)
+
+// handleListVersions processes a list versions command for an object engine, which lists the versions of an object newest first.
+// Payload format: | Key Length (2 bytes) | Key (variable) |
+// Response payload: | Version Count (2 bytes) | Versions |, with at most objectengine.MaxListKeys versions.
+// Each version is encoded as | Version ID (8 bytes) | Delete Marker (1 byte) | Size (8 bytes) | Checksum (4 bytes) | Created At (8 bytes) | Modified At (8 bytes) |
+// If the key has no versions, a protocol.StatusNotFound response is returned.
+func (c *Commands) handleListVersions(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, _, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	versions, err := obje.ListVersions(key)
+	if errors.Is(err, objectengine.ErrKeyNotFound) {
+		return &protocol.Response{
+			Code:    protocol.StatusNotFound,
+			Payload: *commonresponses.EmptyPayload,
+		}, nil
+	}
+
+	versions = versions[:min(len(versions), objectengine.MaxListKeys)]
+
+	payload := binary.BigEndian.AppendUint16(nil, uint16(len(versions)))
+	for _, meta := range versions {
+		payload = binary.BigEndian.AppendUint64(payload, meta.VersionID)
+		if meta.DeleteMarker {
+			payload = append(payload, 1)
+		} else {
+			payload = append(payload, 0)
+		}
+		payload = binary.BigEndian.AppendUint64(payload, uint64(meta.Size))
+		payload = binary.BigEndian.AppendUint32(payload, meta.Checksum)
+		payload = binary.BigEndian.AppendUint64(payload, uint64(meta.CreatedAt))
+		payload = binary.BigEndian.AppendUint64(payload, uint64(meta.ModifiedAt))
+	}
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: payload,
+	}, nil
+}
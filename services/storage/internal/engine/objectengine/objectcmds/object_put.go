@@ -11,10 +11,12 @@ import (
 	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/database"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/objectengine"
 )
 
 // handlePut processes a put command for an object engine. The payload is expected to be in the format:
-// Payload format: | Key Length (2 bytes) | Key (variable) | Data (codex.TypeBinary) |
+// Payload format: | Key Length (2 bytes) | Key (variable) | Data (codex.TypeBinary) | Content Type and Metadata (optional, see readAttributes) |
+// Response payload format: | Version ID (8 bytes) |, the version ID is 0 if versioning is disabled.
 func (c *Commands) handlePut(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
 	e, err := c.engineService.GetEngine(cmd.DatabaseName, cmd.CollectionName)
 	if err != nil {
@@ -61,7 +63,17 @@ func (c *Commands) handlePut(ctx *command.ConnCtx, _ *protocol.Message, cmd *pro
 		}, nil
 	}
 
-	if err := obje.Put(key, binVal); err != nil {
+	contentType, metadata, resp := readAttributes(data[2+int(keyLen)+5+len(binVal):])
+	if resp != nil {
+		return resp, nil
+	}
+
+	meta, err := obje.PutWithMetadata(key, binVal, contentType, metadata)
+	if err != nil {
+		if errors.Is(err, objectengine.ErrMetadataTooLarge) {
+			return metadataTooLarge, nil
+		}
+
 		slog.Error("Failed to put object",
 			slog.String("database", cmd.DatabaseName),
 			slog.String("collection", cmd.CollectionName),
@@ -72,5 +84,5 @@ func (c *Commands) handlePut(ctx *command.ConnCtx, _ *protocol.Message, cmd *pro
 		return commonresponses.InternalServerError, nil
 	}
 
-	return commonresponses.OK, nil
+	return versionResponse(meta), nil
 }
@@ -0,0 +1,29 @@
+package objectcmds
+
+import (
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+)
+
+// handleRename processes a rename command for an object engine, which moves an object to another key within the collection.
+// Payload format: | Old Key Length (2 bytes) | Old Key (variable) | New Key Length (2 bytes) | New Key (variable) |
+// If the old key is not found, a protocol.StatusNotFound response will be returned with an empty payload.
+func (c *Commands) handleRename(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	oldKey, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	newKey, _, resp := readKey(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	err := obje.Rename(oldKey, newKey)
+	return transferResponse(cmd, "Failed to rename object", oldKey, err), nil
+}
@@ -0,0 +1,154 @@
+package objectcmds
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/codex"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/objectengine"
+)
+
+// handleUploadBegin processes an upload begin command for an object engine, which starts a chunked upload of an object.
+// Payload format: | Key Length (2 bytes) | Key (variable) | Content Type and Metadata (optional, see readAttributes) |
+// Response payload: | Upload ID (8 bytes) |
+func (c *Commands) handleUploadBegin(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	key, rest, resp := readKey(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	contentType, metadata, resp := readAttributes(rest)
+	if resp != nil {
+		return resp, nil
+	}
+
+	id, err := obje.BeginUpload(key, contentType, metadata)
+	if err != nil {
+		if errors.Is(err, objectengine.ErrMetadataTooLarge) {
+			return metadataTooLarge, nil
+		}
+
+		slog.Error("Failed to begin object upload",
+			slog.String("database", cmd.DatabaseName),
+			slog.String("collection", cmd.CollectionName),
+			slog.String("key", key),
+			sloki.WrapError(err),
+		)
+		return commonresponses.InternalServerError, nil
+	}
+
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, id)
+
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: payload,
+	}, nil
+}
+
+// handleUploadAppend processes an upload append command for an object engine, which appends a chunk to an upload.
+// Payload format: | Upload ID (8 bytes) | Chunk (codex.TypeBinary) |
+// If the upload does not exist, a protocol.StatusNotFound response is returned.
+// If the object would exceed objectengine.MaxObjectSize, a protocol.StatusLimitExceeded response is returned.
+func (c *Commands) handleUploadAppend(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	id, rest, resp := readUploadID(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	chunk, err := codex.DecodeBinary(rest)
+	if err != nil {
+		return &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid binary value encoding: " + err.Error()),
+		}, nil
+	}
+
+	if err := obje.AppendUpload(id, chunk); err != nil {
+		return uploadErrorResponse(cmd, "Failed to append to object upload", err), nil
+	}
+
+	return commonresponses.OK, nil
+}
+
+// handleUploadCommit processes an upload commit command for an object engine, which stores the uploaded object.
+// Payload format: | Upload ID (8 bytes) |
+// Response payload format: | Version ID (8 bytes) |, the version ID is 0 if versioning is disabled.
+// If the upload does not exist, a protocol.StatusNotFound response is returned.
+func (c *Commands) handleUploadCommit(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	id, _, resp := readUploadID(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	meta, err := obje.CommitUpload(id)
+	if err != nil {
+		return uploadErrorResponse(cmd, "Failed to commit object upload", err), nil
+	}
+
+	return versionResponse(meta), nil
+}
+
+// handleUploadAbort processes an upload abort command for an object engine, which discards an upload.
+// Payload format: | Upload ID (8 bytes) |
+// If the upload does not exist, a protocol.StatusNotFound response is returned.
+func (c *Commands) handleUploadAbort(ctx *command.ConnCtx, _ *protocol.Message, cmd *protocol.Command) (*protocol.Response, error) {
+	obje, resp := c.objectEngine(cmd)
+	if resp != nil {
+		return resp, nil
+	}
+
+	id, _, resp := readUploadID(cmd.Payload)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if err := obje.AbortUpload(id); err != nil {
+		return uploadErrorResponse(cmd, "Failed to abort object upload", err), nil
+	}
+
+	return commonresponses.OK, nil
+}
+
+// uploadErrorResponse maps an error of an upload operation to a response, unexpected errors are logged with msg.
+func uploadErrorResponse(cmd *protocol.Command, msg string, err error) *protocol.Response {
+	switch {
+	case errors.Is(err, objectengine.ErrUploadNotFound):
+		return &protocol.Response{
+			Code:    protocol.StatusNotFound,
+			Payload: *commonresponses.EmptyPayload,
+		}
+	case errors.Is(err, objectengine.ErrObjectTooLarge):
+		return &protocol.Response{
+			Code:    protocol.StatusLimitExceeded,
+			Payload: []byte(err.Error()),
+		}
+	}
+
+	slog.Error(msg,
+		slog.String("database", cmd.DatabaseName),
+		slog.String("collection", cmd.CollectionName),
+		sloki.WrapError(err),
+	)
+	return commonresponses.InternalServerError
+}
@@ -1,36 +1,207 @@
 package objectcmds
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/commonresponses"
 	"github.com/fancyinnovations/fancyspaces/integrations/storage-go-sdk/protocol"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/auth"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/database"
 	"github.com/fancyinnovations/fancyspaces/storage/internal/engine"
+	"github.com/fancyinnovations/fancyspaces/storage/internal/engine/objectengine"
 )
 
 type Commands struct {
 	dbStore       *database.Store
 	engineService *engine.Service
+	userFromCtx   func(ctx context.Context) *auth.User
 }
 
 type Configuration struct {
 	DatabaseStore *database.Store
 	EngineService *engine.Service
+	UserFromCtx   func(ctx context.Context) *auth.User
 }
 
 func New(cfg Configuration) *Commands {
 	return &Commands{
 		dbStore:       cfg.DatabaseStore,
 		engineService: cfg.EngineService,
+		userFromCtx:   cfg.UserFromCtx,
 	}
 }
 
 func (c *Commands) Get() map[uint16]command.Handler {
 	return map[uint16]command.Handler{
-		protocol.ServerCommandObjectPut:         c.handlePut,
-		protocol.ServerCommandObjectGet:         c.handleGet,
-		protocol.ServerCommandObjectGetMetadata: c.handleGetMetadata,
-		protocol.ServerCommandObjectDelete:      c.handleDelete,
-		protocol.ServerCommandObjectCount:       c.handleCount,
-		protocol.ServerCommandObjectSize:        c.handleSize,
+		protocol.ServerCommandObjectPut:             c.handlePut,
+		protocol.ServerCommandObjectGet:             c.handleGet,
+		protocol.ServerCommandObjectGetMetadata:     c.handleGetMetadata,
+		protocol.ServerCommandObjectDelete:          c.handleDelete,
+		protocol.ServerCommandObjectExists:          c.handleExists,
+		protocol.ServerCommandObjectList:            c.handleList,
+		protocol.ServerCommandObjectCopy:            c.handleCopy,
+		protocol.ServerCommandObjectMove:            c.handleMove,
+		protocol.ServerCommandObjectRename:          c.handleRename,
+		protocol.ServerCommandObjectCount:           c.handleCount,
+		protocol.ServerCommandObjectSize:            c.handleSize,
+		protocol.ServerCommandObjectUploadBegin:     c.handleUploadBegin,
+		protocol.ServerCommandObjectUploadAppend:    c.handleUploadAppend,
+		protocol.ServerCommandObjectUploadCommit:    c.handleUploadCommit,
+		protocol.ServerCommandObjectUploadAbort:     c.handleUploadAbort,
+		protocol.ServerCommandObjectGetRange:        c.handleGetRange,
+		protocol.ServerCommandObjectStats:           c.handleStats,
+		protocol.ServerCommandObjectCompact:         c.handleCompact,
+		protocol.ServerCommandObjectListVersions:    c.handleListVersions,
+		protocol.ServerCommandObjectLifecycleReport: c.handleLifecycleReport,
+	}
+}
+
+// objectEngine returns the object engine of the collection addressed by cmd, or the response to send if there is none.
+func (c *Commands) objectEngine(cmd *protocol.Command) (*objectengine.Bucket, *protocol.Response) {
+	e, err := c.engineService.GetEngine(cmd.DatabaseName, cmd.CollectionName)
+	if err != nil {
+		if errors.Is(err, database.ErrCollectionNotFound) {
+			return nil, commonresponses.CollectionNotFound
+		}
+
+		slog.Error("Failed to get engine",
+			slog.String("database", cmd.DatabaseName),
+			slog.String("collection", cmd.CollectionName),
+			sloki.WrapError(err),
+		)
+		return nil, commonresponses.InternalServerError
+	}
+
+	if e.Type != database.EngineObject {
+		return nil, commonresponses.CommandNotAllowed
+	}
+
+	return e.AsObjectEngine(), nil
+}
+
+// readKey reads a length-prefixed key (| Key Length (2 bytes) | Key (variable) |) from the start of data.
+// It returns the key and the rest of data, or the response to send if data is too short.
+func readKey(data []byte) (string, []byte, *protocol.Response) {
+	if len(data) < 2 {
+		return "", nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length"),
+		}
+	}
+
+	keyLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+keyLen {
+		return "", nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for key"),
+		}
+	}
+
+	return string(data[2 : 2+keyLen]), data[2+keyLen:], nil
+}
+
+// readVersionID reads the optional version ID (8 bytes) that follows the key of a command in data.
+// It returns the version ID and whether one was given, or the response to send if data has an invalid length.
+func readVersionID(data []byte) (uint64, bool, *protocol.Response) {
+	switch len(data) {
+	case 0:
+		return 0, false, nil
+	case 8:
+		return binary.BigEndian.Uint64(data[0:8]), true, nil
+	default:
+		return 0, false, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for version id"),
+		}
+	}
+}
+
+// versionResponse is the response to commands that store a version of an object, its payload is | Version ID (8 bytes) |
+func versionResponse(meta *objectengine.ObjectMeta) *protocol.Response {
+	return &protocol.Response{
+		Code:    protocol.StatusOK,
+		Payload: binary.BigEndian.AppendUint64(nil, meta.VersionID),
+	}
+}
+
+// appendString appends s with a 2-byte length prefix to dst.
+func appendString(dst []byte, s string) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(s)))
+	return append(dst, s...)
+}
+
+// readUploadID reads the upload ID (8 bytes) from the start of data.
+// It returns the ID and the rest of data, or the response to send if data is too short.
+func readUploadID(data []byte) (uint64, []byte, *protocol.Response) {
+	if len(data) < 8 {
+		return 0, nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for upload id"),
+		}
 	}
+
+	return binary.BigEndian.Uint64(data[0:8]), data[8:], nil
+}
+
+// readAttributes reads the optional content type and user metadata of an object from data.
+// Format: | Content Type Length (2 bytes) | Content Type (variable) | Metadata Count (2 bytes) | Metadata Entries |
+// Each metadata entry is encoded as | Name Length (2 bytes) | Name (variable) | Value Length (2 bytes) | Value (variable) |
+// Empty data means no content type and no metadata. It returns the response to send if data is malformed.
+func readAttributes(data []byte) (string, map[string]string, *protocol.Response) {
+	if len(data) == 0 {
+		return "", nil, nil
+	}
+
+	contentType, rest, resp := readKey(data)
+	if resp != nil {
+		return "", nil, resp
+	}
+
+	if len(rest) < 2 {
+		return "", nil, &protocol.Response{
+			Code:    protocol.StatusBadRequest,
+			Payload: []byte("invalid payload length for metadata"),
+		}
+	}
+	count := int(binary.BigEndian.Uint16(rest[0:2]))
+	rest = rest[2:]
+
+	var metadata map[string]string
+	if count > 0 {
+		metadata = make(map[string]string, count)
+	}
+	for range count {
+		var name, value string
+		if name, rest, resp = readKey(rest); resp != nil {
+			return "", nil, resp
+		}
+		if value, rest, resp = readKey(rest); resp != nil {
+			return "", nil, resp
+		}
+		metadata[name] = value
+	}
+
+	return contentType, metadata, nil
+}
+
+// appendAttributes appends the content type and user metadata of an object to dst, in the format read by readAttributes.
+func appendAttributes(dst []byte, contentType string, metadata map[string]string) []byte {
+	dst = appendString(dst, contentType)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(metadata)))
+	for name, value := range metadata {
+		dst = appendString(dst, name)
+		dst = appendString(dst, value)
+	}
+	return dst
+}
+
+// metadataTooLarge is the response to objects whose content type and metadata exceed objectengine.MaxMetadataSize.
+var metadataTooLarge = &protocol.Response{
+	Code:    protocol.StatusLimitExceeded,
+	Payload: []byte(objectengine.ErrMetadataTooLarge.Error()),
 }
@@ -6,6 +6,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,27 +16,105 @@ import (
 type Bucket struct {
 	database   string
 	collection string
+	path       string
 	shards     [ShardCount]*shard
+	syncPolicy SyncPolicy
+
+	versioning       bool
+	maxVersions      int
+	versionRetention time.Duration
+	lastVersion      atomic.Uint64 // Last version ID handed out, see nextVersionID
+
+	lifecycleRules       []LifecycleRule
+	lifecycleExpirations atomic.Uint64
+
+	uploadsMu sync.Mutex
+	uploads   map[uint64]*upload
+
+	compactionInterval     time.Duration
+	compactionGarbageRatio float64
+	compactionRateLimit    uint64
+	compactMu              sync.Mutex // Ensures that only one shard is compacted at a time
+	compactNow             chan struct{}
+	compacting             atomic.Bool
+	compactions            atomic.Uint64
+	reclaimedBytes         atomic.Uint64
+	lastCompaction         atomic.Int64
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup // Background jobs
 }
 
 type Configuration struct {
 	Database   string
 	Collection string
+
+	// SyncPolicy decides when writes are flushed to disk. Defaults to SyncInterval.
+	SyncPolicy SyncPolicy
+
+	// Versioning keeps the previous versions of objects when they are replaced or deleted, deleting an object creates a delete marker instead.
+	// With versioning disabled, objects are stored as the null version (version ID 0), which replaces the previous null version of the key.
+	Versioning bool
+	// MaxVersions is the number of older versions (including delete markers) that are kept per key (0 = unlimited).
+	MaxVersions int
+	// VersionRetention is the time older versions are kept after they were replaced (0 = unlimited).
+	// Versions beyond MaxVersions or VersionRetention are removed by compaction.
+	VersionRetention time.Duration
+
+	// LifecycleRules expire objects by the prefix of their keys and their age. Expired objects are deleted like by Delete.
+	// The rules are evaluated every minute, an object expires once any of the rules matches it.
+	LifecycleRules []LifecycleRule
+
+	// CompactionInterval is the interval in which the shards are checked for compaction. Defaults to 5 minutes.
+	CompactionInterval time.Duration
+	// CompactionGarbageRatio is the share (0 - 1) of a shard file that must be taken up by replaced and deleted objects before the shard is compacted.
+	// Defaults to 0.5.
+	CompactionGarbageRatio float64
+	// CompactionRateLimit limits the number of bytes per second compaction copies (0 = unlimited).
+	CompactionRateLimit uint64
 }
 
 // NewBucket initializes a new bucket, creating necessary directories and shard files
 // Path structure: data/{database}/{collection}/shard_{i}.bin
+// Entries that were torn by a crash are removed from the end of the shard files, see readAllEntries.
 func NewBucket(cfg Configuration) (*Bucket, error) {
 	b := &Bucket{
-		database:   cfg.Database,
-		collection: cfg.Collection,
+		database:               cfg.Database,
+		collection:             cfg.Collection,
+		syncPolicy:             cfg.SyncPolicy,
+		versioning:             cfg.Versioning,
+		maxVersions:            cfg.MaxVersions,
+		versionRetention:       cfg.VersionRetention,
+		lifecycleRules:         cfg.LifecycleRules,
+		uploads:                make(map[uint64]*upload),
+		compactionInterval:     cfg.CompactionInterval,
+		compactionGarbageRatio: cfg.CompactionGarbageRatio,
+		compactionRateLimit:    cfg.CompactionRateLimit,
+		compactNow:             make(chan struct{}, 1),
+		stop:                   make(chan struct{}),
+	}
+	if b.syncPolicy == "" {
+		b.syncPolicy = SyncInterval
+	}
+	if b.compactionInterval <= 0 {
+		b.compactionInterval = defaultCompactionInterval
+	}
+	if b.compactionGarbageRatio <= 0 {
+		b.compactionGarbageRatio = defaultCompactionGarbageRatio
 	}
 	path := filepath.Join("data", b.database, b.collection)
+	b.path = path
 
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, err
 	}
 
+	// Uploads do not survive restarts, remove the parts of unfinished ones
+	if err := os.RemoveAll(b.uploadsPath()); err != nil {
+		return nil, err
+	}
+
 	// Initialize shards
 	for i := 0; i < ShardCount; i++ {
 		s, err := b.newShard(i, path)
@@ -42,56 +123,112 @@ func NewBucket(cfg Configuration) (*Bucket, error) {
 		}
 
 		b.shards[i] = s
+
+		for _, meta := range s.index {
+			b.observeVersionID(meta.VersionID)
+		}
+		for _, versions := range s.history {
+			for _, meta := range versions {
+				b.observeVersionID(meta.VersionID)
+			}
+		}
 	}
 
 	// Start background compaction
 	b.startCompactSchedule()
 
+	if b.syncPolicy == SyncInterval {
+		b.startSyncSchedule()
+	}
+
+	b.startUploadExpirySchedule()
+
+	if len(b.lifecycleRules) > 0 {
+		b.startLifecycleSchedule()
+	}
+
 	return b, nil
 }
 
+// Close stops the background jobs of the bucket, flushes the shard files to disk and closes them.
+// A running compaction is aborted. The bucket must not be used afterward.
+func (b *Bucket) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.stop)
+	})
+	b.wg.Wait()
+
+	var errs []error
+	for _, s := range b.shards {
+		s.appendMu.Lock()
+		s.Lock()
+		errs = append(errs, s.file.Sync(), s.file.Close())
+		s.Unlock()
+		s.appendMu.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
 // Put stores an object in the bucket
 func (b *Bucket) Put(key string, data []byte) error {
+	_, err := b.PutWithMetadata(key, data, "", nil)
+	return err
+}
+
+// PutWithMetadata stores an object with a content type and user-defined metadata in the bucket and returns its metadata, which includes its version ID.
+// Returns ErrMetadataTooLarge if they exceed MaxMetadataSize, and ErrObjectTooLarge if data exceeds MaxObjectSize.
+func (b *Bucket) PutWithMetadata(key string, data []byte, contentType string, metadata map[string]string) (*ObjectMeta, error) {
+	if int64(len(data)) > MaxObjectSize {
+		return nil, ErrObjectTooLarge
+	}
+
+	metadata, err := checkAttributes(contentType, metadata)
+	if err != nil {
+		return nil, err
+	}
+
 	s := b.shardForKey(key)
-	s.Lock()
-	defer s.Unlock()
+	s.appendMu.Lock()
+	defer s.appendMu.Unlock()
 
 	offset, err := s.file.Seek(0, io.SeekEnd)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Compute checksum
-	checksum := crc32.ChecksumIEEE(data)
+	meta := ObjectMeta{
+		Key:         key,
+		Offset:      offset,
+		Checksum:    crc32.ChecksumIEEE(data),
+		ModifiedAt:  time.Now().UnixMilli(),
+		ContentType: contentType,
+		Metadata:    metadata,
+		VersionID:   b.newVersionID(),
+	}
 
-	var createdAt int64
-	if meta, ok := s.index[key]; ok {
-		createdAt = meta.CreatedAt
+	if existing, ok := s.index[key]; ok {
+		meta.CreatedAt = existing.CreatedAt
 	} else {
-		createdAt = time.Now().UnixMilli()
+		meta.CreatedAt = meta.ModifiedAt
 	}
 
-	modifiedAt := time.Now().UnixMilli()
-
 	// Write length-prefixed key + value
-	if err := writeEntry(s.file, key, checksum, createdAt, modifiedAt, data); err != nil {
-		return err
+	if err := writeEntry(s.file, &meta, data); err != nil {
+		return nil, truncateShard(s, offset, err)
+	}
+	if err := s.flush(); err != nil {
+		return nil, truncateShard(s, offset, err)
 	}
 
 	// Update in-memory index
-	s.index[key] = ObjectMeta{
-		Key:        key,
-		Offset:     offset,
-		Size:       uint32(len(data)),
-		Checksum:   checksum,
-		CreatedAt:  createdAt,
-		ModifiedAt: modifiedAt,
-	}
+	s.setEntry(meta)
 
-	return nil
+	return &meta, nil
 }
 
 // Get retrieves an object by key
+// Any number of Get calls can read a shard concurrently, also while an object of the shard is written.
 func (b *Bucket) Get(key string) ([]byte, error) {
 	s := b.shardForKey(key)
 	s.RLock()
@@ -102,17 +239,47 @@ func (b *Bucket) Get(key string) ([]byte, error) {
 		return nil, ErrKeyNotFound
 	}
 
-	// Seek to the offset and read the entry
-	if _, err := s.file.Seek(meta.Offset, io.SeekStart); err != nil {
+	return s.read(meta)
+}
+
+// GetVersion retrieves a version of an object.
+// Returns ErrVersionNotFound if the key has no version with the ID and ErrKeyNotFound if the version is a delete marker.
+func (b *Bucket) GetVersion(key string, versionID uint64) ([]byte, error) {
+	s := b.shardForKey(key)
+	s.RLock()
+	defer s.RUnlock()
+
+	meta, err := s.objectVersion(key, versionID)
+	if err != nil {
 		return nil, err
 	}
 
-	// Read the entry and verify the key matches
-	readMeta, data, err := readEntry(s.file, false)
+	return s.read(meta)
+}
+
+// objectVersion returns the version of key with the given ID, which must not be a delete marker.
+// The caller must hold one of the locks.
+func (s *shard) objectVersion(key string, versionID uint64) (ObjectMeta, error) {
+	meta, ok := s.version(key, versionID)
+	if !ok {
+		return ObjectMeta{}, ErrVersionNotFound
+	}
+	if meta.DeleteMarker {
+		return ObjectMeta{}, ErrKeyNotFound
+	}
+
+	return meta, nil
+}
+
+// read reads the data of the entry described by meta and verifies its checksum.
+// The caller must hold one of the locks.
+func (s *shard) read(meta ObjectMeta) ([]byte, error) {
+	// Read the entry with positional reads, which do not move the file offset other readers rely on, and verify the key matches
+	readMeta, data, err := readEntry(io.NewSectionReader(s.file, meta.Offset, meta.entrySize()), false)
 	if err != nil {
 		return nil, err
 	}
-	if readMeta.Key != key {
+	if readMeta.Key != meta.Key {
 		return nil, errors.New("key mismatch")
 	}
 
@@ -124,27 +291,113 @@ func (b *Bucket) Get(key string) ([]byte, error) {
 	return data, nil
 }
 
-// Delete marks a key as deleted
+// GetRange retrieves up to length bytes of an object, starting at offset.
+// The result is shorter than length if the object ends before, and empty if offset lies behind its end.
+// Unlike Get, it cannot verify the checksum of the object, callers that read the whole object in ranges should do so.
+func (b *Bucket) GetRange(key string, offset, length uint32) ([]byte, error) {
+	s := b.shardForKey(key)
+	s.RLock()
+	defer s.RUnlock()
+
+	meta, ok := s.index[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	if offset >= meta.Size {
+		return []byte{}, nil
+	}
+	length = min(length, meta.Size-offset)
+
+	data := make([]byte, length)
+	if _, err := s.file.ReadAt(data, meta.dataOffset()+int64(offset)); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Delete deletes an object.
+// In a versioned bucket, a delete marker becomes the current version of the key and the previous versions are kept.
 func (b *Bucket) Delete(key string) error {
 	s := b.shardForKey(key)
-	s.Lock()
-	defer s.Unlock()
+	s.appendMu.Lock()
+	defer s.appendMu.Unlock()
 
 	meta, ok := s.index[key]
 	if !ok {
 		return ErrKeyNotFound
 	}
 
-	delete(s.index, key)
+	return b.deleteEntry(s, meta)
+}
+
+// deleteEntry appends a delete marker for the object described by meta to its shard.
+// The caller must hold the append lock.
+func (b *Bucket) deleteEntry(s *shard, meta ObjectMeta) error {
+	return s.appendMarker(b.deleteMarker(meta))
+}
+
+// deleteMarker returns the delete marker that deletes the object of meta.
+func (b *Bucket) deleteMarker(meta ObjectMeta) ObjectMeta {
+	return ObjectMeta{
+		Key:          meta.Key,
+		CreatedAt:    meta.CreatedAt,
+		VersionID:    b.newVersionID(),
+		DeleteMarker: true,
+	}
+}
+
+// DeleteVersion permanently removes a version of an object, which may be a delete marker.
+// If it is the current version, the previous version becomes current.
+func (b *Bucket) DeleteVersion(key string, versionID uint64) error {
+	s := b.shardForKey(key)
+	s.appendMu.Lock()
+	defer s.appendMu.Unlock()
+
+	meta, ok := s.version(key, versionID)
+	if !ok {
+		return ErrVersionNotFound
+	}
+
+	return s.appendMarker(ObjectMeta{
+		Key:       key,
+		CreatedAt: meta.CreatedAt,
+		VersionID: versionID,
+		purge:     true,
+	})
+}
+
+// ListVersions returns all versions of an object, including delete markers, newest first.
+// Returns ErrKeyNotFound if the key has no versions.
+func (b *Bucket) ListVersions(key string) ([]ObjectMeta, error) {
+	s := b.shardForKey(key)
+	s.RLock()
+	defer s.RUnlock()
+
+	var versions []ObjectMeta
+	if current, ok := s.index[key]; ok {
+		versions = append(versions, current)
+	}
+	for _, meta := range slices.Backward(s.history[key]) {
+		versions = append(versions, meta)
+	}
 
-	// Write a tombstone entry with zero size
-	if err := writeEntry(s.file, key, meta.Checksum, meta.CreatedAt, time.Now().UnixMilli(), []byte{}); err != nil {
-		return err
+	if len(versions) == 0 {
+		return nil, ErrKeyNotFound
 	}
 
-	s.dirty = true // Mark shard as dirty for compaction
+	return versions, nil
+}
+
+// Exists reports whether an object is stored under key
+func (b *Bucket) Exists(key string) bool {
+	s := b.shardForKey(key)
+	s.RLock()
+	defer s.RUnlock()
 
-	return nil
+	_, ok := s.index[key]
+	return ok
 }
 
 // GetMeta returns the ObjectMeta for a given key
@@ -161,6 +414,20 @@ func (b *Bucket) GetMeta(key string) (*ObjectMeta, error) {
 	return &meta, nil
 }
 
+// GetMetaVersion returns the ObjectMeta of a version of an object, which may be a delete marker.
+func (b *Bucket) GetMetaVersion(key string, versionID uint64) (*ObjectMeta, error) {
+	s := b.shardForKey(key)
+	s.RLock()
+	defer s.RUnlock()
+
+	meta, ok := s.version(key, versionID)
+	if !ok {
+		return nil, ErrVersionNotFound
+	}
+
+	return &meta, nil
+}
+
 // Count returns the total number of objects in the bucket
 func (b *Bucket) Count() uint32 {
 	total := 0
@@ -185,3 +452,34 @@ func (b *Bucket) Size() uint64 {
 	}
 	return total
 }
+
+// newVersionID returns the version ID of a new version, which is 0 (the null version) if versioning is disabled.
+func (b *Bucket) newVersionID() uint64 {
+	if !b.versioning {
+		return 0
+	}
+
+	return b.nextVersionID()
+}
+
+// nextVersionID returns a new version ID, which is greater than all version IDs handed out before.
+// Version IDs are derived from the current time in nanoseconds, so they also increase across restarts.
+func (b *Bucket) nextVersionID() uint64 {
+	for {
+		last := b.lastVersion.Load()
+		next := max(uint64(time.Now().UnixNano()), last+1)
+		if b.lastVersion.CompareAndSwap(last, next) {
+			return next
+		}
+	}
+}
+
+// observeVersionID makes sure that new version IDs are greater than versionID.
+func (b *Bucket) observeVersionID(versionID uint64) {
+	for {
+		last := b.lastVersion.Load()
+		if versionID <= last || b.lastVersion.CompareAndSwap(last, versionID) {
+			return
+		}
+	}
+}
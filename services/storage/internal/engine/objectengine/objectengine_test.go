@@ -2,9 +2,19 @@ package objectengine
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"hash/crc32"
+	"io"
+	"maps"
+	"math"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestPutGetGetMetaDelete_TableDriven(t *testing.T) {
@@ -91,14 +101,1314 @@ func TestPutGetGetMetaDelete_TableDriven(t *testing.T) {
 	deleteShardsForTest(t, b)
 }
 
-func deleteShardsForTest(t *testing.T, b *Bucket) {
+func TestUploadGetRange(t *testing.T) {
+	b, err := NewBucket(Configuration{
+		Database:   "testdb",
+		Collection: "testupload",
+	})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	if err := b.Put("obj", []byte("old")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	oldMeta, err := b.GetMeta("obj")
+	if err != nil {
+		t.Fatalf("GetMeta error: %v", err)
+	}
+
+	want := bytes.Repeat([]byte("0123456789"), 1000)
+
+	id, err := b.BeginUpload("obj", "", nil)
+	if err != nil {
+		t.Fatalf("BeginUpload error: %v", err)
+	}
+	for chunk := range slices.Chunk(want, 3000) {
+		if err := b.AppendUpload(id, chunk); err != nil {
+			t.Fatalf("AppendUpload error: %v", err)
+		}
+	}
+
+	// the object is only replaced on commit
+	if got, _ := b.Get("obj"); !bytes.Equal(got, []byte("old")) {
+		t.Fatalf("Get before commit = %q, want %q", got, "old")
+	}
+
+	if _, err := b.CommitUpload(id); err != nil {
+		t.Fatalf("CommitUpload error: %v", err)
+	}
+	if err := b.AppendUpload(id, []byte("x")); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("AppendUpload after commit error = %v, want %v", err, ErrUploadNotFound)
+	}
+
+	got, err := b.Get("obj")
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Get returned %d bytes, want the %d uploaded bytes", len(got), len(want))
+	}
+
+	meta, err := b.GetMeta("obj")
+	if err != nil {
+		t.Fatalf("GetMeta error: %v", err)
+	}
+	if meta.Checksum != crc32.ChecksumIEEE(want) {
+		t.Fatalf("checksum = %d, want %d", meta.Checksum, crc32.ChecksumIEEE(want))
+	}
+	if meta.CreatedAt != oldMeta.CreatedAt {
+		t.Fatalf("CreatedAt = %d, want the creation time of the replaced object %d", meta.CreatedAt, oldMeta.CreatedAt)
+	}
+
+	ranges := []struct {
+		offset, length uint32
+		want           []byte
+	}{
+		{offset: 0, length: 10, want: want[:10]},
+		{offset: 4995, length: 10, want: want[4995:5005]},
+		{offset: 9990, length: 100, want: want[9990:]},
+		{offset: 10000, length: 10, want: []byte{}},
+	}
+	for _, r := range ranges {
+		got, err := b.GetRange("obj", r.offset, r.length)
+		if err != nil {
+			t.Fatalf("GetRange(%d, %d) error: %v", r.offset, r.length, err)
+		}
+		if !bytes.Equal(got, r.want) {
+			t.Fatalf("GetRange(%d, %d) = %q, want %q", r.offset, r.length, got, r.want)
+		}
+	}
+
+	if _, err := b.GetRange("missing", 0, 10); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GetRange(missing) error = %v, want %v", err, ErrKeyNotFound)
+	}
+
+	// aborted uploads leave the object untouched
+	id, err = b.BeginUpload("obj", "", nil)
+	if err != nil {
+		t.Fatalf("BeginUpload error: %v", err)
+	}
+	if err := b.AppendUpload(id, []byte("discarded")); err != nil {
+		t.Fatalf("AppendUpload error: %v", err)
+	}
+	if err := b.AbortUpload(id); err != nil {
+		t.Fatalf("AbortUpload error: %v", err)
+	}
+	if _, err := b.CommitUpload(id); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("CommitUpload after abort error = %v, want %v", err, ErrUploadNotFound)
+	}
+	if got, _ := b.Get("obj"); !bytes.Equal(got, want) {
+		t.Fatalf("Get after abort returned %d bytes, want %d", len(got), len(want))
+	}
+
+	entries, err := os.ReadDir(b.uploadsPath())
+	if err != nil {
+		t.Fatalf("ReadDir error: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("%d part files left after commit and abort", len(entries))
+	}
+}
+
+func TestExpireUploads(t *testing.T) {
+	b, err := NewBucket(Configuration{
+		Database:   "testdb",
+		Collection: "testexpireuploads",
+	})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	stale, err := b.BeginUpload("stale", "", nil)
+	if err != nil {
+		t.Fatalf("BeginUpload error: %v", err)
+	}
+	active, err := b.BeginUpload("active", "", nil)
+	if err != nil {
+		t.Fatalf("BeginUpload error: %v", err)
+	}
+
+	b.uploadsMu.Lock()
+	b.uploads[stale].lastActive.Store(time.Now().Add(-2 * uploadTimeout).UnixNano())
+	b.uploadsMu.Unlock()
+
+	b.expireUploads()
+
+	if err := b.AppendUpload(stale, []byte("x")); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("AppendUpload to expired upload error = %v, want %v", err, ErrUploadNotFound)
+	}
+	if err := b.AppendUpload(active, []byte("x")); err != nil {
+		t.Fatalf("AppendUpload to active upload error: %v", err)
+	}
+	if err := b.AbortUpload(active); err != nil {
+		t.Fatalf("AbortUpload error: %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	b, err := NewBucket(Configuration{
+		Database:   "testdb",
+		Collection: "testlist",
+	})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	keys := []string{"z", "img/y", "docs/sub/c", "a.txt", "docs/b", "img/x", "docs/a"}
+	for _, key := range keys {
+		if err := b.Put(key, []byte(key)); err != nil {
+			t.Fatalf("Put(%s) error: %v", key, err)
+		}
+	}
+
+	cases := []struct {
+		name         string
+		opts         ListOptions
+		wantObjects  []string
+		wantPrefixes []string
+	}{
+		{
+			name:        "all",
+			opts:        ListOptions{},
+			wantObjects: []string{"a.txt", "docs/a", "docs/b", "docs/sub/c", "img/x", "img/y", "z"},
+		},
+		{
+			name:        "prefix",
+			opts:        ListOptions{Prefix: "docs/"},
+			wantObjects: []string{"docs/a", "docs/b", "docs/sub/c"},
+		},
+		{
+			name:         "delimiter",
+			opts:         ListOptions{Delimiter: "/"},
+			wantObjects:  []string{"a.txt", "z"},
+			wantPrefixes: []string{"docs/", "img/"},
+		},
+		{
+			name:         "prefix_and_delimiter",
+			opts:         ListOptions{Prefix: "docs/", Delimiter: "/"},
+			wantObjects:  []string{"docs/a", "docs/b"},
+			wantPrefixes: []string{"docs/sub/"},
+		},
+		{
+			name: "no_match",
+			opts: ListOptions{Prefix: "missing/"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := b.List(tc.opts)
+			if res.Truncated {
+				t.Fatalf("List(%+v) is truncated", tc.opts)
+			}
+
+			var gotObjects []string
+			for _, meta := range res.Objects {
+				gotObjects = append(gotObjects, meta.Key)
+				if meta.Size != uint32(len(meta.Key)) {
+					t.Fatalf("List(%+v) size of %s = %d, want %d", tc.opts, meta.Key, meta.Size, len(meta.Key))
+				}
+			}
+			if !slices.Equal(gotObjects, tc.wantObjects) {
+				t.Fatalf("List(%+v) objects = %v, want %v", tc.opts, gotObjects, tc.wantObjects)
+			}
+			if !slices.Equal(res.CommonPrefixes, tc.wantPrefixes) {
+				t.Fatalf("List(%+v) common prefixes = %v, want %v", tc.opts, res.CommonPrefixes, tc.wantPrefixes)
+			}
+		})
+	}
+
+	t.Run("pagination", func(t *testing.T) {
+		var got []string
+		opts := ListOptions{Delimiter: "/", MaxKeys: 1}
+		for pages := 0; ; pages++ {
+			if pages > 10 {
+				t.Fatalf("pagination does not terminate, got %v", got)
+			}
+
+			res := b.List(opts)
+			for _, meta := range res.Objects {
+				got = append(got, meta.Key)
+			}
+			got = append(got, res.CommonPrefixes...)
+
+			if !res.Truncated {
+				break
+			}
+			opts.Token = res.NextToken
+		}
+
+		want := []string{"a.txt", "docs/", "img/", "z"}
+		if !slices.Equal(got, want) {
+			t.Fatalf("paginated listing = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestListByteLimit(t *testing.T) {
+	cfg := Configuration{
+		Database:   "testdb",
+		Collection: "testlistbytes",
+	}
+	b, err := NewBucket(cfg)
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer func() { deleteShardsForTest(t, b) }()
+
+	// 100 keys of 60 KB exceed maxListBytes, so the listing takes several pages
+	var want []string
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("%03d", i) + strings.Repeat("k", 60_000)
+		if err := b.Put(key, nil); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+		want = append(want, key)
+	}
+
+	listAll := func(t *testing.T) []string {
+		t.Helper()
+
+		var got []string
+		opts := ListOptions{}
+		for pages := 1; ; pages++ {
+			if pages > 10 {
+				t.Fatalf("pagination does not terminate")
+			}
+
+			res := b.List(opts)
+			size := 0
+			for _, meta := range res.Objects {
+				got = append(got, meta.Key)
+				size += len(meta.Key)
+			}
+			if size > maxListBytes {
+				t.Fatalf("page has %d bytes of keys, want at most %d", size, maxListBytes)
+			}
+
+			if !res.Truncated {
+				if pages == 1 {
+					t.Fatalf("expected the listing to be split into several pages")
+				}
+				return got
+			}
+			opts.Token = res.NextToken
+		}
+	}
+
+	if got := listAll(t); !slices.Equal(got, want) {
+		t.Fatalf("listing returned %d keys, want %d in order", len(got), len(want))
+	}
+
+	// deleted keys are not listed, also after the index is loaded from disk
+	if err := b.Delete(want[50]); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	want = slices.Delete(want, 50, 51)
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if b, err = NewBucket(cfg); err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	if got := listAll(t); !slices.Equal(got, want) {
+		t.Fatalf("listing after reopen returned %d keys, want %d in order", len(got), len(want))
+	}
+}
+
+func TestCopyMoveRename(t *testing.T) {
+	src, err := NewBucket(Configuration{Database: "testdb", Collection: "testcopysrc"})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, src)
+
+	dst, err := NewBucket(Configuration{Database: "testdb", Collection: "testcopydst"})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, dst)
+
+	want := []byte("asset data")
+	if err := src.Put("a", want); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	orig, err := src.GetMeta("a")
+	if err != nil {
+		t.Fatalf("GetMeta error: %v", err)
+	}
+
+	assertObject := func(t *testing.T, b *Bucket, key string) {
+		t.Helper()
+
+		got, err := b.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%s) error: %v", key, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Get(%s) = %q, want %q", key, got, want)
+		}
+
+		meta, err := b.GetMeta(key)
+		if err != nil {
+			t.Fatalf("GetMeta(%s) error: %v", key, err)
+		}
+		if meta.CreatedAt != orig.CreatedAt || meta.Checksum != orig.Checksum {
+			t.Fatalf("GetMeta(%s) = %+v, want the creation time and checksum of %+v", key, meta, orig)
+		}
+	}
+
+	if err := src.Copy("a", src, "b"); err != nil {
+		t.Fatalf("Copy error: %v", err)
+	}
+	assertObject(t, src, "a")
+	assertObject(t, src, "b")
+
+	if err := src.Move("b", dst, "c"); err != nil {
+		t.Fatalf("Move error: %v", err)
+	}
+	if src.Exists("b") {
+		t.Fatalf("moved object still exists in the source bucket")
+	}
+	assertObject(t, dst, "c")
+
+	if err := dst.Rename("c", "d"); err != nil {
+		t.Fatalf("Rename error: %v", err)
+	}
+	if dst.Exists("c") {
+		t.Fatalf("renamed object still exists under its old key")
+	}
+	assertObject(t, dst, "d")
+
+	if err := src.Rename("a", "a"); err != nil {
+		t.Fatalf("Rename onto itself error: %v", err)
+	}
+	assertObject(t, src, "a")
+
+	if err := src.Copy("missing", dst, "x"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Copy(missing) error = %v, want %v", err, ErrKeyNotFound)
+	}
+
+	// tombstones hide moved objects after a reload, and compaction drops them
+	for _, b := range []*Bucket{src, dst} {
+		for _, s := range b.shards {
+			if err := s.loadIndex(); err != nil {
+				t.Fatalf("loadIndex error: %v", err)
+			}
+			if _, err := b.compactShard(s); err != nil {
+				t.Fatalf("compactShard error: %v", err)
+			}
+		}
+	}
+	if src.Exists("b") || dst.Exists("c") {
+		t.Fatalf("moved objects reappeared after reload and compaction")
+	}
+	assertObject(t, src, "a")
+	assertObject(t, dst, "d")
+	if n := src.Count() + dst.Count(); n != 2 {
+		t.Fatalf("Count after compaction = %d, want 2", n)
+	}
+}
+
+func TestMoveRemovesCopyWhenDeleteFails(t *testing.T) {
+	src, err := NewBucket(Configuration{Database: "testdb", Collection: "testmovesrc"})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, src)
+
+	dst, err := NewBucket(Configuration{Database: "testdb", Collection: "testmovedst"})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, dst)
+
+	if err := src.Put("a", []byte("asset data")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+
+	// replace the source shard file with a read-only handle, so the copy can be read but the delete marker cannot be written
+	s := src.shardForKey("a")
+	writable := s.file
+	readOnly, err := os.Open(writable.Name())
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	s.file = readOnly
+	defer func() {
+		readOnly.Close()
+		s.file = writable
+	}()
+
+	if err := src.Move("a", dst, "c"); err == nil {
+		t.Fatalf("expected Move to fail when the delete marker cannot be written")
+	}
+	if !src.Exists("a") {
+		t.Fatalf("expected the source object to be kept")
+	}
+	if dst.Exists("c") {
+		t.Fatalf("expected the copy not to be published")
+	}
+
+	// the copy was removed from the target shard file as well
+	target := dst.shardForKey("c")
+	if err := target.loadIndex(); err != nil {
+		t.Fatalf("loadIndex error: %v", err)
+	}
+	if dst.Exists("c") {
+		t.Fatalf("expected the copy to be removed from the shard file")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	b, err := NewBucket(Configuration{Database: "testdb", Collection: "testmetadata"})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	metadata := map[string]string{"author": "alice", "license": "MIT"}
+	if _, err := b.PutWithMetadata("logo.png", []byte("png"), "image/png", metadata); err != nil {
+		t.Fatalf("PutWithMetadata error: %v", err)
+	}
+	metadata["author"] = "mallory" // the stored metadata is a copy
+
+	assertAttributes := func(t *testing.T, key string) {
+		t.Helper()
+
+		meta, err := b.GetMeta(key)
+		if err != nil {
+			t.Fatalf("GetMeta(%s) error: %v", key, err)
+		}
+		if meta.ContentType != "image/png" {
+			t.Fatalf("GetMeta(%s) content type = %q, want %q", key, meta.ContentType, "image/png")
+		}
+		if !maps.Equal(meta.Metadata, map[string]string{"author": "alice", "license": "MIT"}) {
+			t.Fatalf("GetMeta(%s) metadata = %v", key, meta.Metadata)
+		}
+		if got, err := b.Get(key); err != nil || !bytes.Equal(got, []byte("png")) {
+			t.Fatalf("Get(%s) = %q, %v", key, got, err)
+		}
+		if got, err := b.GetRange(key, 1, 2); err != nil || !bytes.Equal(got, []byte("ng")) {
+			t.Fatalf("GetRange(%s) = %q, %v", key, got, err)
+		}
+	}
+
+	assertAttributes(t, "logo.png")
+
+	if err := b.Copy("logo.png", b, "copy.png"); err != nil {
+		t.Fatalf("Copy error: %v", err)
+	}
+	assertAttributes(t, "copy.png")
+
+	// the attributes are read back from the shard files
 	for _, s := range b.shards {
-		file := s.file
-		if err := file.Close(); err != nil {
-			t.Logf("error closing shard file: %v", err)
+		if err := s.loadIndex(); err != nil {
+			t.Fatalf("loadIndex error: %v", err)
+		}
+	}
+	assertAttributes(t, "logo.png")
+	assertAttributes(t, "copy.png")
+
+	tooLarge := map[string]string{"large": string(bytes.Repeat([]byte("x"), MaxMetadataSize))}
+	if _, err := b.PutWithMetadata("large", []byte("x"), "", tooLarge); !errors.Is(err, ErrMetadataTooLarge) {
+		t.Fatalf("PutWithMetadata with large metadata error = %v, want %v", err, ErrMetadataTooLarge)
+	}
+}
+
+func TestLegacyRecords(t *testing.T) {
+	b, err := NewBucket(Configuration{Database: "testdb", Collection: "testlegacy"})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	// entries written before record versions existed have no record header
+	s := b.shardForKey("old")
+	data := []byte("legacy data")
+	var entry []byte
+	entry = binary.LittleEndian.AppendUint32(entry, uint32(len("old")))
+	entry = append(entry, "old"...)
+	entry = binary.LittleEndian.AppendUint32(entry, crc32.ChecksumIEEE(data))
+	entry = binary.LittleEndian.AppendUint64(entry, 1000)
+	entry = binary.LittleEndian.AppendUint64(entry, 2000)
+	entry = binary.LittleEndian.AppendUint32(entry, uint32(len(data)))
+	entry = append(entry, data...)
+	if _, err := s.file.Write(entry); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	if _, err := b.PutWithMetadata("new", []byte("new data"), "text/plain", nil); err != nil {
+		t.Fatalf("PutWithMetadata error: %v", err)
+	}
+
+	for _, s := range b.shards {
+		if err := s.loadIndex(); err != nil {
+			t.Fatalf("loadIndex error: %v", err)
+		}
+	}
+
+	for _, compact := range []bool{false, true} {
+		if compact {
+			for _, s := range b.shards {
+				if _, err := b.compactShard(s); err != nil {
+					t.Fatalf("compactShard error: %v", err)
+				}
+			}
+		}
+
+		got, err := b.Get("old")
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("Get(old) = %q, %v (compacted: %v)", got, err, compact)
+		}
+		if got, err := b.GetRange("old", 7, 4); err != nil || !bytes.Equal(got, []byte("data")) {
+			t.Fatalf("GetRange(old) = %q, %v (compacted: %v)", got, err, compact)
+		}
+
+		meta, err := b.GetMeta("old")
+		if err != nil {
+			t.Fatalf("GetMeta(old) error: %v", err)
+		}
+		if meta.CreatedAt != 1000 || meta.ModifiedAt != 2000 || meta.ContentType != "" || meta.Metadata != nil {
+			t.Fatalf("GetMeta(old) = %+v (compacted: %v)", meta, compact)
+		}
+
+		if got, err := b.Get("new"); err != nil || !bytes.Equal(got, []byte("new data")) {
+			t.Fatalf("Get(new) = %q, %v (compacted: %v)", got, err, compact)
+		}
+	}
+}
+
+func TestMaxObjectSize(t *testing.T) {
+	// the largest header together with the largest object must fit into the payload length of the record frame
+	metadata := make(map[string]string)
+	size := 0
+	for i := 0; size+4 <= MaxMetadataSize; i++ {
+		name := fmt.Sprintf("%04x", i)
+		metadata[name] = ""
+		size += len(name)
+	}
+	meta := &ObjectMeta{
+		Key:      strings.Repeat("k", MaxKeyLength),
+		Metadata: metadata,
+		Size:     MaxObjectSize,
+	}
+
+	buf := encodeEntryHeader(meta)
+	if meta.headerSize > maxEntryHeaderSize {
+		t.Fatalf("header size = %d, want at most %d", meta.headerSize, maxEntryHeaderSize)
+	}
+	length := binary.LittleEndian.Uint32(buf[recordFrameSize-8:])
+	if want := uint64(meta.headerSize-recordFrameSize) + MaxObjectSize; uint64(length) != want {
+		t.Fatalf("payload length = %d, want %d", length, want)
+	}
+
+	// uploads are rejected before they exceed the limit
+	b, err := NewBucket(Configuration{Database: "testdb", Collection: "testmaxsize"})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	id, err := b.BeginUpload("obj", "", nil)
+	if err != nil {
+		t.Fatalf("BeginUpload error: %v", err)
+	}
+	b.uploads[id].size = MaxObjectSize - 1
+	if err := b.AppendUpload(id, []byte("xx")); !errors.Is(err, ErrObjectTooLarge) {
+		t.Fatalf("AppendUpload error = %v, want %v", err, ErrObjectTooLarge)
+	}
+	if err := b.AbortUpload(id); err != nil {
+		t.Fatalf("AbortUpload error: %v", err)
+	}
+}
+
+func TestReader(t *testing.T) {
+	b, err := NewBucket(Configuration{Database: "testdb", Collection: "testreader"})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	want := []byte("0123456789")
+	if _, err := b.PutWithMetadata("obj", want, "text/plain", nil); err != nil {
+		t.Fatalf("PutWithMetadata error: %v", err)
+	}
+
+	r, err := b.NewReader("obj")
+	if err != nil {
+		t.Fatalf("NewReader error: %v", err)
+	}
+	if r.Meta().ContentType != "text/plain" || r.Size() != int64(len(want)) {
+		t.Fatalf("Reader meta = %+v, size = %d", r.Meta(), r.Size())
+	}
+
+	got, err := io.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("ReadAll = %q, %v, want %q", got, err, want)
+	}
+
+	buf := make([]byte, 4)
+	if n, err := r.ReadAt(buf, 8); n != 2 || !errors.Is(err, io.EOF) || !bytes.Equal(buf[:n], []byte("89")) {
+		t.Fatalf("ReadAt(8) = %d, %v, %q", n, err, buf[:n])
+	}
+
+	// writes are not blocked by the reader, but the reader notices them
+	if err := b.Put("obj", []byte("replaced")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	if _, err := r.ReadAt(buf, 0); !errors.Is(err, ErrObjectChanged) {
+		t.Fatalf("ReadAt after replace error = %v, want %v", err, ErrObjectChanged)
+	}
+
+	if _, err := b.NewReader("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("NewReader(missing) error = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	b, err := NewBucket(Configuration{Database: "testdb", Collection: "testrecovery", SyncPolicy: SyncAlways})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	want := []byte("committed data")
+	if err := b.Put("obj", want); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+
+	s := b.shardForKey("obj")
+	info, err := s.file.Stat()
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	committed := info.Size()
+
+	var replacement bytes.Buffer
+	if err := writeEntry(&replacement, &ObjectMeta{Key: "obj"}, []byte("replacement data")); err != nil {
+		t.Fatalf("writeEntry error: %v", err)
+	}
+	corrupted := bytes.Clone(replacement.Bytes())
+	corrupted[len(corrupted)-1] ^= 0xFF
+
+	// legacy entries are never written after framed entries
+	var legacy []byte
+	legacy = binary.LittleEndian.AppendUint32(legacy, uint32(len("obj")))
+	legacy = append(legacy, "obj"...)
+	legacy = binary.LittleEndian.AppendUint32(legacy, crc32.ChecksumIEEE(nil))
+	legacy = binary.LittleEndian.AppendUint64(legacy, 1000)
+	legacy = binary.LittleEndian.AppendUint64(legacy, 2000)
+	legacy = binary.LittleEndian.AppendUint32(legacy, 0)
+
+	cases := []struct {
+		name string
+		tail []byte
+	}{
+		{name: "torn entry", tail: replacement.Bytes()[:replacement.Len()/2]},
+		{name: "torn frame", tail: replacement.Bytes()[:7]},
+		{name: "corrupted data", tail: corrupted},
+		{name: "zeroed tail", tail: make([]byte, 64)},
+		{name: "legacy entry after framed entry", tail: legacy},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := s.file.WriteAt(tc.tail, committed); err != nil {
+				t.Fatalf("WriteAt error: %v", err)
+			}
+
+			if err := s.loadIndex(); err != nil {
+				t.Fatalf("loadIndex error: %v", err)
+			}
+
+			if got, err := b.Get("obj"); err != nil || !bytes.Equal(got, want) {
+				t.Fatalf("Get(obj) = %q, %v", got, err)
+			}
+			if info, err := s.file.Stat(); err != nil || info.Size() != committed {
+				t.Fatalf("shard size after recovery = %d, %v, want %d", info.Size(), err, committed)
+			}
+		})
+	}
+
+	// corrupt entries in front of intact entries are not a torn tail, the shard must not be truncated
+	for _, middle := range [][]byte{corrupted, legacy, make([]byte, 64)} {
+		tail := append(bytes.Clone(middle), replacement.Bytes()...)
+		if _, err := s.file.WriteAt(tail, committed); err != nil {
+			t.Fatalf("WriteAt error: %v", err)
+		}
+		if err := s.loadIndex(); !errors.Is(err, errCorruptRecord) {
+			t.Fatalf("loadIndex with corruption in the middle error = %v, want %v", err, errCorruptRecord)
+		}
+		if info, err := s.file.Stat(); err != nil || info.Size() != committed+int64(len(tail)) {
+			t.Fatalf("shard was truncated despite intact entries after the corruption")
+		}
+		if err := s.file.Truncate(committed); err != nil {
+			t.Fatalf("Truncate error: %v", err)
+		}
+	}
+	if err := s.loadIndex(); err != nil {
+		t.Fatalf("loadIndex error: %v", err)
+	}
+
+	// entries written after the recovery are appended to the intact entries
+	if err := b.Put("obj", []byte("new data")); err != nil {
+		t.Fatalf("Put after recovery error: %v", err)
+	}
+	if err := s.loadIndex(); err != nil {
+		t.Fatalf("loadIndex error: %v", err)
+	}
+	if got, err := b.Get("obj"); err != nil || string(got) != "new data" {
+		t.Fatalf("Get(obj) after recovery = %q, %v", got, err)
+	}
+
+	// entries of other record versions are never truncated
+	info, err = s.file.Stat()
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	for _, version := range []byte{recordVersion - 1, recordVersion + 1} {
+		other := binary.LittleEndian.AppendUint32(nil, recordMarker)
+		other = append(other, version)
+		if _, err := s.file.WriteAt(other, info.Size()); err != nil {
+			t.Fatalf("WriteAt error: %v", err)
+		}
+		if err := s.loadIndex(); !errors.Is(err, errUnsupportedRecordVersion) {
+			t.Fatalf("loadIndex with record version %d error = %v, want %v", version, err, errUnsupportedRecordVersion)
+		}
+		if after, err := s.file.Stat(); err != nil || after.Size() != info.Size()+int64(len(other)) {
+			t.Fatalf("shard was truncated despite record version %d", version)
+		}
+		if err := s.file.Truncate(info.Size()); err != nil {
+			t.Fatalf("Truncate error: %v", err)
+		}
+	}
+
+	for _, name := range []string{"", "interval", "always", "never"} {
+		if _, err := ParseSyncPolicy(name); err != nil {
+			t.Fatalf("ParseSyncPolicy(%q) error: %v", name, err)
+		}
+	}
+	if _, err := ParseSyncPolicy("sometimes"); err == nil {
+		t.Fatalf("ParseSyncPolicy(sometimes) succeeded")
+	}
+}
+
+func TestConcurrentReads(t *testing.T) {
+	b, err := NewBucket(Configuration{Database: "testdb", Collection: "testconcurrent"})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	// every version of an object repeats its key and version, so readers can tell torn reads from valid ones
+	value := func(key string, version int) []byte {
+		return bytes.Repeat([]byte(fmt.Sprintf("%s@%d;", key, version)), 64)
+	}
+	check := func(key string, data []byte) {
+		var version int
+		if _, err := fmt.Sscanf(string(data), key+"@%d;", &version); err != nil || !bytes.Equal(data, value(key, version)) {
+			t.Errorf("read of %s returned data of no version: %.40q", key, data)
+		}
+	}
+
+	keys := make([]string, 32)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		if err := b.Put(keys[i], value(keys[i], 0)); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	// writers replace the objects and compact their shards while the readers are running
+	for w := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for version := 1; version <= 100; version++ {
+				key := keys[(version*7+w)%len(keys)]
+				if err := b.Put(key, value(key, version)); err != nil {
+					t.Errorf("Put error: %v", err)
+					return
+				}
+				if version%25 == 0 {
+					if _, err := b.compactShard(b.shardForKey(key)); err != nil {
+						t.Errorf("compactShard error: %v", err)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	var readers sync.WaitGroup
+	for r := range 8 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				key := keys[(i*3+r)%len(keys)]
+				switch i % 3 {
+				case 0:
+					data, err := b.Get(key)
+					if err != nil {
+						t.Errorf("Get(%s) error: %v", key, err)
+						return
+					}
+					check(key, data)
+				case 1:
+					data, err := b.GetRange(key, 0, math.MaxUint32)
+					if err != nil {
+						t.Errorf("GetRange(%s) error: %v", key, err)
+						return
+					}
+					check(key, data)
+				case 2:
+					reader, err := b.NewReader(key)
+					if err != nil {
+						t.Errorf("NewReader(%s) error: %v", key, err)
+						return
+					}
+					data, err := io.ReadAll(io.NewSectionReader(reader, 0, reader.Size()))
+					if errors.Is(err, ErrObjectChanged) {
+						continue
+					}
+					if err != nil {
+						t.Errorf("Reader(%s) error: %v", key, err)
+						return
+					}
+					check(key, data)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	bucket, err := NewBucket(Configuration{Database: "testdb", Collection: "benchget"})
+	if err != nil {
+		b.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(b, bucket)
+
+	data := bytes.Repeat([]byte("x"), 4096)
+	keys := make([]string, 256)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		if err := bucket.Put(keys[i], data); err != nil {
+			b.Fatalf("Put error: %v", err)
+		}
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := bucket.Get(keys[i%len(keys)]); err != nil {
+				b.Errorf("Get error: %v", err)
+				return
+			}
+			i++
+		}
+	})
+}
+
+func TestCompaction(t *testing.T) {
+	b, err := NewBucket(Configuration{Database: "testdb", Collection: "testcompaction", CompactionRateLimit: 1024 * 1024})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	data := bytes.Repeat([]byte("x"), 100*1024)
+	for range 3 {
+		if err := b.Put("obj", data); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+	}
+
+	s := b.shardForKey("obj")
+	if !s.needsCompaction(b.compactionGarbageRatio) {
+		t.Fatalf("shard with two replaced entries does not need compaction")
+	}
+	before := b.Stats()
+	if before.Objects != 1 || before.GarbageBytes != before.FileBytes*2/3 {
+		t.Fatalf("Stats before compaction = %+v", before)
+	}
+
+	// the rate limit of 1 MB/s stretches copying the object to about 100 ms
+	start := time.Now()
+	reclaimed, err := b.compactShard(s)
+	if err != nil {
+		t.Fatalf("compactShard error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("compaction took %v despite the rate limit", elapsed)
+	}
+	if reclaimed != int64(before.GarbageBytes) {
+		t.Fatalf("compactShard reclaimed %d bytes, want %d", reclaimed, before.GarbageBytes)
+	}
+
+	after := b.Stats()
+	if after.GarbageBytes != 0 || after.FileBytes != before.FileBytes-before.GarbageBytes ||
+		after.Compactions != 1 || after.ReclaimedBytes != uint64(reclaimed) || after.LastCompaction == 0 {
+		t.Fatalf("Stats after compaction = %+v", after)
+	}
+
+	// a manual compaction ignores the garbage ratio
+	if err := b.Put("deleted", []byte("deleted data")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	if err := b.Delete("deleted"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	b.CompactNow()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Stats().GarbageBytes != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("CompactNow did not compact: %+v", b.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// entries written while a shard is compacted are kept
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			key := fmt.Sprintf("key-%d", i%5)
+			if err := b.Put(key, []byte(fmt.Sprintf("%s@%d", key, i))); err != nil {
+				t.Errorf("Put error: %v", err)
+				return
+			}
+			if i%7 == 0 {
+				if err := b.Delete(key); err != nil {
+					t.Errorf("Delete error: %v", err)
+					return
+				}
+			}
+		}
+	}()
+	for range 10 {
+		for _, s := range b.shards {
+			if _, err := b.compactShard(s); err != nil {
+				t.Fatalf("compactShard error: %v", err)
+			}
+		}
+	}
+	wg.Wait()
+
+	want := make(map[string][]byte)
+	for _, s := range b.shards {
+		for key := range s.index {
+			got, err := b.Get(key)
+			if err != nil {
+				t.Fatalf("Get(%s) error: %v", key, err)
+			}
+			want[key] = got
+		}
+	}
+	wantStats := b.Stats()
+
+	// the compacted shard files contain the same objects as the index
+	for _, s := range b.shards {
+		if err := s.loadIndex(); err != nil {
+			t.Fatalf("loadIndex error: %v", err)
+		}
+		if info, err := s.file.Stat(); err != nil || info.Size() != s.size {
+			t.Fatalf("shard file size = %d, %v, want %d", info.Size(), err, s.size)
+		}
+	}
+	for key, data := range want {
+		if got, err := b.Get(key); err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("Get(%s) after reload = %q, %v, want %q", key, got, err, data)
+		}
+	}
+	if got := b.Stats(); got.Objects != uint64(len(want)) || got.FileBytes != wantStats.FileBytes || got.GarbageBytes != wantStats.GarbageBytes {
+		t.Fatalf("Stats after reload = %+v, want %+v", got, wantStats)
+	}
+}
+
+func TestVersioning(t *testing.T) {
+	cfg := Configuration{Database: "testdb", Collection: "testversioning"}
+	b, err := NewBucket(cfg)
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer func() { deleteShardsForTest(t, b) }()
+
+	// objects stored without versioning are the null version
+	if err := b.Put("obj", []byte("null")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	cfg.Versioning = true
+	if b, err = NewBucket(cfg); err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+
+	v1, err := b.PutWithMetadata("obj", []byte("v1"), "", nil)
+	if err != nil {
+		t.Fatalf("PutWithMetadata error: %v", err)
+	}
+	v2, err := b.PutWithMetadata("obj", []byte("v2"), "", nil)
+	if err != nil {
+		t.Fatalf("PutWithMetadata error: %v", err)
+	}
+	if v1.VersionID == 0 || v2.VersionID <= v1.VersionID {
+		t.Fatalf("version IDs = %d, %d, want increasing non-zero IDs", v1.VersionID, v2.VersionID)
+	}
+
+	if got, err := b.Get("obj"); err != nil || string(got) != "v2" {
+		t.Fatalf("Get = %q, %v, want v2", got, err)
+	}
+	for id, want := range map[uint64]string{0: "null", v1.VersionID: "v1", v2.VersionID: "v2"} {
+		if got, err := b.GetVersion("obj", id); err != nil || string(got) != want {
+			t.Fatalf("GetVersion(%d) = %q, %v, want %q", id, got, err, want)
+		}
+	}
+	if _, err := b.GetVersion("obj", 42); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatalf("GetVersion of unknown version error = %v, want ErrVersionNotFound", err)
+	}
+
+	// deleting creates a delete marker and keeps the versions
+	if err := b.Delete("obj"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := b.Get("obj"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Get after Delete error = %v, want ErrKeyNotFound", err)
+	}
+	versions, err := b.ListVersions("obj")
+	if err != nil || len(versions) != 4 || !versions[0].DeleteMarker || versions[1].VersionID != v2.VersionID || versions[3].VersionID != 0 {
+		t.Fatalf("ListVersions after Delete = %+v, %v", versions, err)
+	}
+	marker := versions[0].VersionID
+	if _, err := b.GetVersion("obj", marker); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("GetVersion of delete marker error = %v, want ErrKeyNotFound", err)
+	}
+	if meta, err := b.GetMetaVersion("obj", marker); err != nil || !meta.DeleteMarker {
+		t.Fatalf("GetMetaVersion of delete marker = %+v, %v", meta, err)
+	}
+
+	// removing the delete marker restores the object, removing the current version restores the previous one
+	if err := b.DeleteVersion("obj", marker); err != nil {
+		t.Fatalf("DeleteVersion error: %v", err)
+	}
+	if got, err := b.Get("obj"); err != nil || string(got) != "v2" {
+		t.Fatalf("Get after removing the delete marker = %q, %v, want v2", got, err)
+	}
+	if err := b.DeleteVersion("obj", v2.VersionID); err != nil {
+		t.Fatalf("DeleteVersion error: %v", err)
+	}
+	if got, err := b.Get("obj"); err != nil || string(got) != "v1" {
+		t.Fatalf("Get after removing the current version = %q, %v, want v1", got, err)
+	}
+	if err := b.DeleteVersion("obj", v2.VersionID); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatalf("DeleteVersion of removed version error = %v, want ErrVersionNotFound", err)
+	}
+
+	// empty objects are no delete markers
+	if err := b.Put("empty", nil); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+
+	// the versions survive compaction and reloading the shards
+	want := map[string][]ObjectMeta{}
+	for _, key := range []string{"obj", "empty"} {
+		if want[key], err = b.ListVersions(key); err != nil {
+			t.Fatalf("ListVersions(%s) error: %v", key, err)
+		}
+	}
+	stats := b.Stats()
+	for _, s := range b.shards {
+		if _, err := b.compactShard(s); err != nil {
+			t.Fatalf("compactShard error: %v", err)
+		}
+		if err := s.loadIndex(); err != nil {
+			t.Fatalf("loadIndex error: %v", err)
+		}
+	}
+	for key, versions := range want {
+		got, err := b.ListVersions(key)
+		if err != nil || len(got) != len(versions) {
+			t.Fatalf("ListVersions(%s) after reload = %+v, %v, want %+v", key, got, err, versions)
 		}
+		for i := range got {
+			if got[i].VersionID != versions[i].VersionID || got[i].DeleteMarker != versions[i].DeleteMarker || got[i].Checksum != versions[i].Checksum {
+				t.Fatalf("ListVersions(%s) after reload = %+v, want %+v", key, got, versions)
+			}
+		}
+	}
+	if got := b.Stats(); got.Objects != stats.Objects || got.Versions != stats.Versions || got.GarbageBytes != 0 {
+		t.Fatalf("Stats after reload = %+v, want %+v without garbage", got, stats)
+	}
+	if got, err := b.Get("empty"); err != nil || len(got) != 0 {
+		t.Fatalf("Get of empty object after reload = %q, %v", got, err)
+	}
+
+	// new version IDs are greater than the loaded ones
+	if v3, err := b.PutWithMetadata("obj", []byte("v3"), "", nil); err != nil || v3.VersionID <= v2.VersionID {
+		t.Fatalf("PutWithMetadata after reload = %+v, %v", v3, err)
+	}
+}
+
+func TestVersionRetention(t *testing.T) {
+	b, err := NewBucket(Configuration{Database: "testdb", Collection: "testretention", Versioning: true, MaxVersions: 2, VersionRetention: time.Hour})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	var ids []uint64
+	for i := range 4 {
+		meta, err := b.PutWithMetadata("obj", []byte(fmt.Sprintf("v%d", i)), "", nil)
+		if err != nil {
+			t.Fatalf("PutWithMetadata error: %v", err)
+		}
+		ids = append(ids, meta.VersionID)
+	}
+	if err := b.Put("deleted", []byte("deleted")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	if err := b.Delete("deleted"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+
+	versionIDs := func(key string) []uint64 {
+		versions, err := b.ListVersions(key)
+		if errors.Is(err, ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("ListVersions(%s) error: %v", key, err)
+		}
+
+		var ids []uint64
+		for _, meta := range versions {
+			ids = append(ids, meta.VersionID)
+		}
+		return ids
+	}
+
+	// only the newest two older versions are kept
+	b.compact(b.compactionGarbageRatio)
+	if got, want := versionIDs("obj"), []uint64{ids[3], ids[2], ids[1]}; !slices.Equal(got, want) {
+		t.Fatalf("versions after compaction = %v, want %v", got, want)
+	}
+	if got := versionIDs("deleted"); len(got) != 2 {
+		t.Fatalf("versions of deleted object = %v, want the delete marker and the object", got)
+	}
+
+	// once older versions expire, the current version is kept and a delete marker without older versions is removed
+	for _, s := range b.shards {
+		b.expireVersions(s, time.Now().Add(2*time.Hour))
+	}
+	if got, want := versionIDs("obj"), []uint64{ids[3]}; !slices.Equal(got, want) {
+		t.Fatalf("versions after expiration = %v, want %v", got, want)
+	}
+	if got := versionIDs("deleted"); got != nil {
+		t.Fatalf("versions of deleted object after expiration = %v, want none", got)
+	}
+	if stats := b.Stats(); stats.Objects != 1 || stats.Versions != 0 {
+		t.Fatalf("Stats after expiration = %+v", stats)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	b, err := NewBucket(Configuration{
+		Database:   "testdb",
+		Collection: "testlifecycle",
+		LifecycleRules: []LifecycleRule{
+			{Prefix: "tmp/", MaxAge: time.Hour},
+			{Prefix: "dumps/", MaxAge: 24 * time.Hour, Basis: LifecycleCreated},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewBucket error: %v", err)
+	}
+	defer deleteShardsForTest(t, b)
+
+	for _, key := range []string{"tmp/a", "tmp/b", "dumps/a", "keep"} {
+		if err := b.Put(key, []byte(key)); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+	}
+
+	// backdate the creation of dumps/a, replacing it keeps its creation time
+	s := b.shardForKey("dumps/a")
+	meta := s.index["dumps/a"]
+	meta.CreatedAt -= (48 * time.Hour).Milliseconds()
+	s.index["dumps/a"] = meta
+	if err := b.Put("dumps/a", []byte("replaced")); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+
+	report := b.LifecycleReport()
+	if report.Objects != 1 || !slices.Equal(report.Keys, []string{"dumps/a"}) || report.Bytes != uint64(len("replaced")) {
+		t.Fatalf("LifecycleReport = %+v, want only dumps/a", report)
+	}
+	if !b.Exists("dumps/a") {
+		t.Fatal("LifecycleReport deleted an object")
+	}
+
+	// two hours later, the objects below tmp/ expired as well
+	b.applyLifecycle(time.Now().Add(2 * time.Hour).UnixMilli())
+	for key, want := range map[string]bool{"tmp/a": false, "tmp/b": false, "dumps/a": false, "keep": true} {
+		if got := b.Exists(key); got != want {
+			t.Errorf("Exists(%s) after lifecycle = %v, want %v", key, got, want)
+		}
+	}
+
+	stats := b.Stats()
+	if stats.Expirations != 3 || stats.Objects != 1 || stats.GarbageBytes == 0 {
+		t.Fatalf("Stats after lifecycle = %+v", stats)
+	}
+
+	// the deleted objects are reclaimed by compaction and stay deleted after reloading the shards
+	b.compact(0)
+	for _, s := range b.shards {
+		if err := s.loadIndex(); err != nil {
+			t.Fatalf("loadIndex error: %v", err)
+		}
+	}
+	if stats := b.Stats(); stats.Objects != 1 || stats.GarbageBytes != 0 {
+		t.Fatalf("Stats after compaction = %+v", stats)
+	}
+}
+
+func deleteShardsForTest(t testing.TB, b *Bucket) {
+	if err := b.Close(); err != nil {
+		t.Logf("error closing bucket: %v", err)
+	}
+	for _, s := range b.shards {
+		file := s.file
 		if err := os.Remove(file.Name()); err != nil {
 			t.Logf("error deleting shard file: %v", err)
 		}
 	}
+	if err := os.RemoveAll(b.uploadsPath()); err != nil {
+		t.Logf("error deleting uploads: %v", err)
+	}
 }
@@ -0,0 +1,77 @@
+package objectengine
+
+import "io"
+
+// Reader reads the data of a version of an object in parts, e.g. to serve it to a slow client.
+// Unlike Get, it does not keep the shard locked while the object is read, so writers of the shard are not blocked.
+// Reads fail with ErrObjectChanged once the version is removed (e.g. a null version is replaced or deleted), or moved within the shard file by compaction.
+type Reader struct {
+	s    *shard
+	meta ObjectMeta
+}
+
+// NewReader returns a Reader for the object stored under key.
+func (b *Bucket) NewReader(key string) (*Reader, error) {
+	s := b.shardForKey(key)
+	s.RLock()
+	defer s.RUnlock()
+
+	meta, ok := s.index[key]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return &Reader{s: s, meta: meta}, nil
+}
+
+// NewVersionReader returns a Reader for a version of an object, see GetVersion.
+func (b *Bucket) NewVersionReader(key string, versionID uint64) (*Reader, error) {
+	s := b.shardForKey(key)
+	s.RLock()
+	defer s.RUnlock()
+
+	meta, err := s.objectVersion(key, versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{s: s, meta: meta}, nil
+}
+
+// Meta returns the metadata of the object at the time the Reader was created.
+func (r *Reader) Meta() ObjectMeta {
+	return r.meta
+}
+
+// Size returns the size of the object in bytes.
+func (r *Reader) Size() int64 {
+	return int64(r.meta.Size)
+}
+
+// ReadAt implements io.ReaderAt for the data of the object.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, io.EOF
+	}
+
+	r.s.RLock()
+	defer r.s.RUnlock()
+
+	if current, ok := r.s.version(r.meta.Key, r.meta.VersionID); !ok || current.Offset != r.meta.Offset {
+		return 0, ErrObjectChanged
+	}
+
+	if off >= r.Size() {
+		return 0, io.EOF
+	}
+
+	n := int(min(int64(len(p)), r.Size()-off))
+	if _, err := r.s.file.ReadAt(p[:n], r.meta.dataOffset()+off); err != nil {
+		return 0, err
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -2,9 +2,14 @@ package objectengine
 
 import (
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/fancyinnovations/fancyspaces/storage/internal/hashing"
 )
@@ -12,12 +17,29 @@ import (
 // ShardCount defines the number of shards for partitioning data
 const ShardCount = 16
 
+// shardSeq hands out the shard IDs, which order the locks of operations that span two shards
+var shardSeq atomic.Uint64
+
 // shard holds a file and an in-memory index
+// Entries are appended to the file while holding appendMu, the RWMutex is only taken to publish them in the index afterward.
+// Changing the index or replacing the file requires both locks, reading them requires one of them.
+// Readers only use positional reads (ReadAt), so any number of readers can read while a writer appends to the file.
+//
+// The newest version of a key is its current version: the entry in index if there is one, otherwise the last entry in history, which is then a delete marker.
+// The oldest version in history is never a delete marker, as it would mark nothing.
 type shard struct {
 	sync.RWMutex
-	file  *os.File
-	index map[string]ObjectMeta
-	dirty bool // Indicates if the shard has deleted entries and needs compaction
+	appendMu   sync.Mutex
+	id         uint64
+	file       *os.File
+	index      map[string]ObjectMeta   // Current version of every key that is not deleted
+	keys       []string                // Keys of the index in lexicographic order, used by List
+	loading    bool                    // Indicates that the index is being loaded, keys is only sorted once it is complete
+	history    map[string][]ObjectMeta // Older versions and delete markers of the keys, oldest first
+	size       int64                   // Length of the shard file up to the end of the last published entry
+	garbage    int64                   // Bytes of the shard file taken up by replaced or removed versions and purge entries, which compaction removes
+	syncPolicy SyncPolicy
+	unsynced   atomic.Bool // Indicates if the shard has writes that were not flushed to disk yet
 }
 
 // shardForKey determines which shard a key belongs to using FNV-1a hash
@@ -38,9 +60,9 @@ func (b *Bucket) newShard(idx int, basePath string) (*shard, error) {
 	}
 
 	s := &shard{
-		file:  f,
-		index: make(map[string]ObjectMeta),
-		dirty: true, // Mark as dirty to trigger initial compaction if there are deleted entries
+		id:         shardSeq.Add(1),
+		file:       f,
+		syncPolicy: b.syncPolicy,
 	}
 
 	if err := s.loadIndex(); err != nil {
@@ -51,34 +73,204 @@ func (b *Bucket) newShard(idx int, basePath string) (*shard, error) {
 }
 
 // loadIndex reads all entries from the shard file and builds the in-memory index.
-// It also marks the shard as dirty if it encounters any deleted entries (size == 0).
+// The entries are applied in the order they were written, so that the index and history are the same as before the shard was closed.
 func (s *shard) loadIndex() error {
-	idx := make(map[string]ObjectMeta)
-
 	entries, err := readAllEntries(s)
 	if err != nil {
 		return err
 	}
 
+	s.index = make(map[string]ObjectMeta)
+	s.history = make(map[string][]ObjectMeta)
+	s.size = 0
+	s.garbage = 0
+
+	s.loading = true
 	for _, e := range entries {
-		if e.Size == 0 { // Deleted entry, skip but mark shard as dirty
-			s.dirty = true
-			continue
+		s.apply(*e)
+	}
+	s.loading = false
+	s.keys = slices.Sorted(maps.Keys(s.index))
+
+	return nil
+}
+
+// setEntry publishes an entry that was appended to the shard file in the index.
+// The caller must hold the append lock.
+func (s *shard) setEntry(meta ObjectMeta) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.apply(meta)
+}
+
+// apply updates the index and the history with an entry that was appended to the shard file.
+// Everything that is no longer a version of its key is counted as garbage, including entries that never become one.
+// The caller must hold both locks.
+func (s *shard) apply(meta ObjectMeta) {
+	s.size += meta.entrySize()
+
+	if meta.purge {
+		s.removeVersion(meta.Key, meta.VersionID)
+		s.garbage += meta.entrySize()
+		return
+	}
+
+	// Null versions replace the previous null version of the key
+	if meta.VersionID == 0 {
+		s.removeVersion(meta.Key, 0)
+	}
+
+	// The current version becomes an older version
+	if current, ok := s.index[meta.Key]; ok {
+		s.history[meta.Key] = append(s.history[meta.Key], current)
+		if meta.DeleteMarker {
+			s.removeCurrent(meta.Key)
 		}
+	}
+
+	switch {
+	case !meta.DeleteMarker:
+		s.setCurrent(meta)
+	case len(s.history[meta.Key]) > 0:
+		s.history[meta.Key] = append(s.history[meta.Key], meta)
+	default:
+		// A delete marker of a key without versions marks nothing
+		s.garbage += meta.entrySize()
+	}
+}
+
+// setCurrent makes meta the current version of its key.
+// The caller must hold both locks.
+func (s *shard) setCurrent(meta ObjectMeta) {
+	if _, ok := s.index[meta.Key]; !ok && !s.loading {
+		i, _ := slices.BinarySearch(s.keys, meta.Key)
+		s.keys = slices.Insert(s.keys, i, meta.Key)
+	}
+	s.index[meta.Key] = meta
+}
+
+// removeCurrent removes the current version of key from the index, its older versions are kept.
+// The caller must hold both locks.
+func (s *shard) removeCurrent(key string) {
+	delete(s.index, key)
+	if i, ok := slices.BinarySearch(s.keys, key); ok && !s.loading {
+		s.keys = slices.Delete(s.keys, i, i+1)
+	}
+}
+
+// version returns the version of key with the given ID, which may be a delete marker.
+// The caller must hold one of the locks.
+func (s *shard) version(key string, versionID uint64) (ObjectMeta, bool) {
+	if current, ok := s.index[key]; ok && current.VersionID == versionID {
+		return current, true
+	}
+
+	for _, meta := range s.history[key] {
+		if meta.VersionID == versionID {
+			return meta, true
+		}
+	}
 
-		existing, exists := idx[e.Key]
-		if exists {
-			// If we encounter multiple entries for the same key, we keep the one with the highest offset (most recent)
-			if existing.Offset > e.Offset {
-				continue
-			}
+	return ObjectMeta{}, false
+}
+
+// removeVersion drops the version of key with the given ID, if it exists, and counts it as garbage.
+// The caller must hold both locks.
+func (s *shard) removeVersion(key string, versionID uint64) {
+	if current, ok := s.index[key]; ok && current.VersionID == versionID {
+		s.removeCurrent(key)
+		s.garbage += current.entrySize()
+	} else {
+		versions := s.history[key]
+		i := slices.IndexFunc(versions, func(meta ObjectMeta) bool {
+			return meta.VersionID == versionID
+		})
+		if i < 0 {
+			return
 		}
 
-		idx[e.Key] = *e
+		s.garbage += versions[i].entrySize()
+		s.history[key] = slices.Delete(versions, i, i+1)
+	}
+
+	s.tidyHistory(key)
+}
+
+// tidyHistory restores the order of the versions of key after versions were removed:
+// the newest version becomes current if it is an object, and delete markers that are the oldest versions are dropped as garbage.
+// The caller must hold both locks.
+func (s *shard) tidyHistory(key string) {
+	versions := s.history[key]
+
+	if _, ok := s.index[key]; !ok && len(versions) > 0 && !versions[len(versions)-1].DeleteMarker {
+		s.setCurrent(versions[len(versions)-1])
+		versions = versions[:len(versions)-1]
+	}
+
+	for len(versions) > 0 && versions[0].DeleteMarker {
+		s.garbage += versions[0].entrySize()
+		versions = versions[1:]
+	}
+
+	if len(versions) == 0 {
+		delete(s.history, key)
+		return
+	}
+	s.history[key] = versions
+}
+
+// appendMarker appends an entry without data, a delete marker or a purge entry, to the shard file and applies it.
+// The caller must hold the append lock.
+func (s *shard) appendMarker(marker ObjectMeta) error {
+	marker, err := s.writeMarker(marker)
+	if err != nil {
+		return err
 	}
 
-	// Swap in the rebuilt index
-	s.index = idx
+	s.setEntry(marker)
 
 	return nil
 }
+
+// writeMarker appends an entry without data to the shard file like appendMarker, but does not apply it.
+// It returns the marker with its offset and modification time set.
+// The caller must hold the append lock.
+func (s *shard) writeMarker(marker ObjectMeta) (ObjectMeta, error) {
+	// A failed append may have left the file offset behind the end, so position it at the end before appending
+	offset, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return ObjectMeta{}, err
+	}
+
+	marker.Offset = offset
+	marker.ModifiedAt = time.Now().UnixMilli()
+	if err := writeEntry(s.file, &marker, nil); err != nil {
+		return ObjectMeta{}, truncateShard(s, offset, err)
+	}
+	if err := s.flush(); err != nil {
+		return ObjectMeta{}, truncateShard(s, offset, err)
+	}
+
+	return marker, nil
+}
+
+// lockShards takes the append locks of two shards, which may be the same, in the order of their IDs so that concurrent callers cannot deadlock.
+// It returns a function that unlocks them.
+func lockShards(a, b *shard) func() {
+	if a == b {
+		a.appendMu.Lock()
+		return a.appendMu.Unlock
+	}
+
+	if a.id > b.id {
+		a, b = b, a
+	}
+	a.appendMu.Lock()
+	b.appendMu.Lock()
+
+	return func() {
+		b.appendMu.Unlock()
+		a.appendMu.Unlock()
+	}
+}
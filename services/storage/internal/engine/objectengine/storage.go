@@ -1,165 +1,426 @@
 package objectengine
 
 import (
+	"bufio"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
+	"log/slog"
+	"math"
+	"os"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+)
+
+const (
+	// recordMarker starts every entry that has a versioned record header.
+	// Entries written before record versions existed start with the key length instead, which is never this large.
+	recordMarker uint32 = 0xFFFFFFFF
+
+	// recordVersion is the version of the record format written by writeEntry, it is the only version that is read
+	recordVersion byte = 4
+
+	// recordFrameSize is the size of the record header in front of the payload of an entry:
+	// | marker (4 bytes) | version (1 byte) | payload length (4 bytes) | payload checksum (4 bytes) |
+	recordFrameSize = 4 + 1 + 4 + 4
+
+	// recordChecksumOffset is the position of the payload checksum in the record header
+	recordChecksumOffset = 4 + 1 + 4
+
+	// maxEntryHeaderSize is an upper bound of the size of everything of an entry except the data, see encodeEntryHeader.
+	// Metadata entries are bounded by their 2-byte count, each one adds two length prefixes to MaxMetadataSize.
+	maxEntryHeaderSize = recordFrameSize + 4 + MaxKeyLength + 4 + 8 + 8 + 8 + 1 + 2 + 2 + 4*math.MaxUint16 + MaxMetadataSize + 4
+)
+
+// Flags of an entry
+const (
+	recordFlagDeleteMarker byte = 1 << iota
+	recordFlagPurge
+)
+
+var (
+	errUnsupportedRecordVersion = errors.New("unsupported record version")
+	errCorruptRecord            = errors.New("corrupt record")
 )
 
-// writeEntry writes a key-value pair with length prefixes
-// Format: | key length (4 bytes) | key (variable) | checksum (4 bytes) | created timestamp (8 bytes) | modified timestamp (8 bytes) | data length (4 bytes) | data (variable) |
-func writeEntry(w io.Writer, key string, checksum uint32, created, modified int64, data []byte) error {
-	// Write key length and key
-	keyLen := uint32(len(key))
-	if err := binary.Write(w, binary.LittleEndian, keyLen); err != nil {
+// writeEntry writes an entry with the object described by meta and its data in the current record format
+// Format: | marker (4 bytes) | version (1 byte) | payload length (4 bytes) | payload checksum (4 bytes) | payload (variable) |
+// The payload is: | key length (4 bytes) | key (variable) | checksum (4 bytes) | created timestamp (8 bytes) | modified timestamp (8 bytes) |
+// | version id (8 bytes) | flags (1 byte) | content type length (2 bytes) | content type (variable) | metadata count (2 bytes) | metadata entries | data length (4 bytes) | data (variable) |
+// Each metadata entry is encoded as | name length (2 bytes) | name (variable) | value length (2 bytes) | value (variable) |
+// The payload checksum is the CRC32 of the whole payload, so that torn or corrupted entries are detected when the shard is loaded.
+// The size of meta is set to the length of data.
+func writeEntry(w io.Writer, meta *ObjectMeta, data []byte) error {
+	meta.Size = uint32(len(data))
+	buf := encodeEntryHeader(meta)
+
+	checksum := crc32.Update(crc32.ChecksumIEEE(buf[recordFrameSize:]), crc32.IEEETable, data)
+	binary.LittleEndian.PutUint32(buf[recordChecksumOffset:], checksum)
+
+	if _, err := w.Write(buf); err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte(key)); err != nil {
-		return err
+
+	_, err := w.Write(data)
+
+	return err
+}
+
+// writeEntryFrom writes an entry like writeEntry, but streams exactly meta.Size bytes of data from r instead of taking them from memory.
+// The entry must be written at meta.Offset of f, its payload checksum is filled in once all data is written.
+// Returns the CRC32 checksum of the data.
+func writeEntryFrom(f *os.File, meta *ObjectMeta, r io.Reader) (uint32, error) {
+	buf := encodeEntryHeader(meta)
+	if _, err := f.Write(buf); err != nil {
+		return 0, err
 	}
 
-	// Write checksum
-	if err := binary.Write(w, binary.LittleEndian, checksum); err != nil {
-		return err
+	record := crc32.NewIEEE()
+	record.Write(buf[recordFrameSize:])
+	data := crc32.NewIEEE()
+	if _, err := io.CopyN(f, io.TeeReader(r, io.MultiWriter(record, data)), int64(meta.Size)); err != nil {
+		return 0, err
 	}
 
-	// Write created timestamp
-	if err := binary.Write(w, binary.LittleEndian, created); err != nil {
-		return err
+	checksum := binary.LittleEndian.AppendUint32(nil, record.Sum32())
+	if _, err := f.WriteAt(checksum, meta.Offset+recordChecksumOffset); err != nil {
+		return 0, err
 	}
 
-	// Write modified timestamp
-	if err := binary.Write(w, binary.LittleEndian, modified); err != nil {
-		return err
+	return data.Sum32(), nil
+}
+
+// encodeEntryHeader encodes everything of an entry except the data, which must follow with exactly meta.Size bytes.
+// The payload checksum is left empty, the header size of meta is set to the length of the header.
+func encodeEntryHeader(meta *ObjectMeta) []byte {
+	headerSize := recordFrameSize + 4 + len(meta.Key) + 4 + 8 + 8 + 8 + 1 + 2 + len(meta.ContentType) + 2 + metadataSize(meta.Metadata) + 4
+	buf := make([]byte, 0, headerSize)
+
+	buf = binary.LittleEndian.AppendUint32(buf, recordMarker)
+	buf = append(buf, recordVersion)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(headerSize-recordFrameSize)+meta.Size)
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(meta.Key)))
+	buf = append(buf, meta.Key...)
+
+	buf = binary.LittleEndian.AppendUint32(buf, meta.Checksum)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(meta.CreatedAt))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(meta.ModifiedAt))
+
+	buf = binary.LittleEndian.AppendUint64(buf, meta.VersionID)
+	var flags byte
+	if meta.DeleteMarker {
+		flags |= recordFlagDeleteMarker
+	}
+	if meta.purge {
+		flags |= recordFlagPurge
 	}
+	buf = append(buf, flags)
 
-	// Write data length and data
-	dataLen := uint32(len(data))
-	if err := binary.Write(w, binary.LittleEndian, dataLen); err != nil {
-		return err
+	buf = appendString(buf, meta.ContentType)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(meta.Metadata)))
+	for name, value := range meta.Metadata {
+		buf = appendString(buf, name)
+		buf = appendString(buf, value)
 	}
-	_, err := w.Write(data)
 
-	return err
+	buf = binary.LittleEndian.AppendUint32(buf, meta.Size)
+	meta.headerSize = int64(len(buf))
+
+	return buf
 }
 
-// readEntry reads a key-value pair with length prefixes
-// Same format as writeEntry
-// Returns the key, data, and any error encountered
+// readEntry reads an entry written by writeEntry or an entry without record header, which was written before record versions existed
+// Legacy format: | key length (4 bytes) | key (variable) | checksum (4 bytes) | created timestamp (8 bytes) | modified timestamp (8 bytes) | data length (4 bytes) | data (variable) |
+// Entries of other record versions return errUnsupportedRecordVersion.
+// The payload checksum of framed entries is verified, errCorruptRecord is returned if it does not match.
+// io.EOF is only returned if r ends before the entry, an entry that ends early returns io.ErrUnexpectedEOF.
+// Returns the metadata, data, and any error encountered
 func readEntry(r io.Reader, skipData bool) (*ObjectMeta, []byte, error) {
-	// Read key length and key
+	// Read the marker of the record header, or the key length of a legacy entry
 	var keyLen uint32
 	if err := binary.Read(r, binary.LittleEndian, &keyLen); err != nil {
 		return nil, nil, err
 	}
 
-	keyBuf := make([]byte, keyLen)
-	if _, err := io.ReadFull(r, keyBuf); err != nil {
+	meta, data, err := readRecord(r, keyLen, skipData)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
 		return nil, nil, err
 	}
-	key := string(keyBuf)
 
-	// Read checksum
-	var checksum uint32
-	if err := binary.Read(r, binary.LittleEndian, &checksum); err != nil {
+	return meta, data, nil
+}
+
+// readRecord reads the rest of an entry whose first field was keyLen, which is either the record marker or the key length of a legacy entry.
+func readRecord(r io.Reader, keyLen uint32, skipData bool) (*ObjectMeta, []byte, error) {
+	meta := &ObjectMeta{headerSize: 4}
+	if keyLen != recordMarker {
+		data, err := readPayload(r, meta, keyLen, 0, skipData)
+		return meta, data, err
+	}
+
+	var version [1]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
 		return nil, nil, err
 	}
+	meta.headerSize += 1
 
-	// Read created timestamp
-	var created int64
-	if err := binary.Read(r, binary.LittleEndian, &created); err != nil {
+	if version[0] != recordVersion {
+		return nil, nil, errUnsupportedRecordVersion
+	}
+
+	var frame [8]byte
+	if _, err := io.ReadFull(r, frame[:]); err != nil {
 		return nil, nil, err
 	}
+	length := binary.LittleEndian.Uint32(frame[0:])
+	checksum := binary.LittleEndian.Uint32(frame[4:])
 
-	// Read modified timestamp
-	var modified int64
-	if err := binary.Read(r, binary.LittleEndian, &modified); err != nil {
+	// Everything that follows is part of the payload, hash it while parsing and never read past its end
+	hash := crc32.NewIEEE()
+	payload := io.TeeReader(io.LimitReader(r, int64(length)), hash)
+
+	if err := binary.Read(payload, binary.LittleEndian, &keyLen); err != nil {
 		return nil, nil, err
 	}
+	meta.headerSize += 8 + 4
 
-	// Read data length and data
-	var dataLen uint32
-	if err := binary.Read(r, binary.LittleEndian, &dataLen); err != nil {
+	data, err := readPayload(payload, meta, keyLen, recordVersion, skipData)
+	if err != nil {
 		return nil, nil, err
 	}
 
+	if meta.headerSize-recordFrameSize+int64(meta.Size) != int64(length) || hash.Sum32() != checksum {
+		return nil, nil, errCorruptRecord
+	}
+
+	return meta, data, nil
+}
+
+// readPayload reads the rest of an entry of the given record version (0 for entries without record header) after its key length into meta
+// The data is discarded if skipData is set.
+func readPayload(r io.Reader, meta *ObjectMeta, keyLen uint32, version byte, skipData bool) ([]byte, error) {
+	// Longer keys cannot be stored, so the entry is garbage
+	if keyLen > MaxKeyLength {
+		return nil, errCorruptRecord
+	}
+
+	// Read key, checksum, created and modified timestamp
+	fixed := make([]byte, int(keyLen)+4+8+8)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+	meta.headerSize += int64(len(fixed))
+
+	meta.Key = string(fixed[:keyLen])
+	meta.Checksum = binary.LittleEndian.Uint32(fixed[keyLen:])
+	meta.CreatedAt = int64(binary.LittleEndian.Uint64(fixed[keyLen+4:]))
+	meta.ModifiedAt = int64(binary.LittleEndian.Uint64(fixed[keyLen+12:]))
+
+	if version == recordVersion {
+		var fields [8 + 1]byte
+		if _, err := io.ReadFull(r, fields[:]); err != nil {
+			return nil, err
+		}
+		meta.headerSize += int64(len(fields))
+
+		meta.VersionID = binary.LittleEndian.Uint64(fields[0:8])
+		meta.DeleteMarker = fields[8]&recordFlagDeleteMarker != 0
+		meta.purge = fields[8]&recordFlagPurge != 0
+
+		if err := readAttributes(r, meta); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read data length and data
+	if err := binary.Read(r, binary.LittleEndian, &meta.Size); err != nil {
+		return nil, err
+	}
+	meta.headerSize += 4
+
+	// Entries without record header and without data are deletions
+	if version == 0 && meta.Size == 0 {
+		meta.DeleteMarker = true
+	}
+
 	if skipData {
-		// Skip the data by seeking forward
-		if seeker, ok := r.(io.Seeker); ok {
-			if _, err := seeker.Seek(int64(dataLen), io.SeekCurrent); err != nil {
-				return nil, nil, err
-			}
-			return &ObjectMeta{
-				Key:        key,
-				Offset:     0, // Offset is not relevant when skipping data
-				Size:       dataLen,
-				Checksum:   checksum,
-				CreatedAt:  created,
-				ModifiedAt: modified,
-			}, nil, nil
-		} else {
-			// If the reader doesn't support seeking, read and discard the data
-			if _, err := io.CopyN(io.Discard, r, int64(dataLen)); err != nil {
-				return nil, nil, err
-			}
-			return &ObjectMeta{
-				Key:        key,
-				Offset:     0, // Offset is not relevant when skipping data
-				Size:       dataLen,
-				Checksum:   checksum,
-				CreatedAt:  created,
-				ModifiedAt: modified,
-			}, nil, nil
+		if _, err := io.CopyN(io.Discard, r, int64(meta.Size)); err != nil {
+			return nil, err
 		}
+		return nil, nil
 	}
 
-	dataBuf := make([]byte, dataLen)
+	dataBuf := make([]byte, meta.Size)
 	if _, err := io.ReadFull(r, dataBuf); err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	meta := &ObjectMeta{
-		Key:        key,
-		Offset:     0,
-		Size:       dataLen,
-		Checksum:   checksum,
-		CreatedAt:  created,
-		ModifiedAt: modified,
+	return dataBuf, nil
+}
+
+// readAttributes reads the content type and the metadata entries of an entry into meta
+func readAttributes(r io.Reader, meta *ObjectMeta) error {
+	contentType, n, err := readString(r)
+	if err != nil {
+		return err
 	}
+	meta.ContentType = contentType
+	meta.headerSize += n
 
-	return meta, dataBuf, nil
+	var count uint16
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return err
+	}
+	meta.headerSize += 2
+
+	if count == 0 {
+		return nil
+	}
+
+	meta.Metadata = make(map[string]string, count)
+	for range count {
+		name, n, err := readString(r)
+		if err != nil {
+			return err
+		}
+		meta.headerSize += n
+
+		value, n, err := readString(r)
+		if err != nil {
+			return err
+		}
+		meta.headerSize += n
+
+		meta.Metadata[name] = value
+	}
+
+	return nil
 }
 
-func readAllEntries(s *shard) ([]*ObjectMeta, error) {
-	_, err := s.file.Seek(0, io.SeekStart)
-	if err != nil {
-		return nil, err
+// appendString appends s with a 2-byte length prefix to dst
+func appendString(dst []byte, s string) []byte {
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(len(s)))
+	return append(dst, s...)
+}
+
+// readString reads a string with a 2-byte length prefix and returns it with the number of bytes read
+func readString(r io.Reader) (string, int64, error) {
+	var n uint16
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", 0, err
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", 0, err
 	}
 
-	currentOffset, err := s.file.Seek(0, io.SeekCurrent)
+	return string(buf), 2 + int64(n), nil
+}
+
+// readAllEntries reads the metadata of all entries of the shard file and verifies their payload checksums.
+// A torn tail (e.g. because the server crashed while writing the last entry) is truncated and the loss is logged, see isTornTail.
+// Corrupt entries in front of intact data are never truncated, loading the shard fails instead.
+// Once a framed entry was read, all following entries must be framed as well, as only the framed record format is still written.
+// Entries of another record version are never truncated, loading the shard fails instead.
+func readAllEntries(s *shard) ([]*ObjectMeta, error) {
+	info, err := s.file.Stat()
 	if err != nil {
 		return nil, err
 	}
+	size := info.Size()
+
+	r := bufio.NewReader(io.NewSectionReader(s.file, 0, size))
 
 	var entries []*ObjectMeta
+	var offset int64
+	framed := false
 	for {
-		meta, _, err := readEntry(s.file, true)
+		head, _ := r.Peek(recordFrameSize)
+		if len(head) >= 5 && binary.LittleEndian.Uint32(head) == recordMarker && head[4] != recordVersion {
+			return nil, fmt.Errorf("%w %d at offset %d of %s", errUnsupportedRecordVersion, head[4], offset, s.file.Name())
+		}
+		isFramed := len(head) >= 5 && binary.LittleEndian.Uint32(head) == recordMarker
+
+		// The end of a framed entry is known from its payload length, even if the entry itself is corrupt
+		end := int64(-1)
+		if isFramed && len(head) == recordFrameSize {
+			end = offset + recordFrameSize + int64(binary.LittleEndian.Uint32(head[5:]))
+		}
+
+		meta, _, err := readEntry(r, true)
+		if err == io.EOF {
+			break
+		}
+		if err == nil && framed && !isFramed {
+			end = offset + meta.entrySize()
+			err = errCorruptRecord
+		}
 		if err != nil {
-			if err == io.EOF {
-				break
+			if !isTornTail(s.file, err, offset, end, size) {
+				return nil, fmt.Errorf("read entry at offset %d of %s: %w", offset, s.file.Name(), err)
 			}
 
-			return nil, err
+			slog.Warn("Truncating torn tail of object shard",
+				slog.String("path", s.file.Name()),
+				slog.Int64("offset", offset),
+				slog.Int64("discarded_bytes", size-offset),
+				sloki.WrapError(err),
+			)
+			if err := s.file.Truncate(offset); err != nil {
+				return nil, err
+			}
+			break
 		}
 
-		meta.Offset = currentOffset
+		framed = framed || isFramed
+		meta.Offset = offset
+		offset += meta.entrySize()
 
 		entries = append(entries, meta)
+	}
 
-		currentOffset, err = s.file.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return nil, err
+	return entries, nil
+}
+
+// isTornTail reports whether the entry at offset of f, which could not be read because of err, is the torn tail of the shard file.
+// This is the case if the entry runs past the end of the file, if a corrupt entry ends exactly at the end of the file, or if only zero bytes follow.
+// end is the end of the entry according to its record frame (or the legacy entry that was read in place of a framed one), or -1 if it is not known.
+func isTornTail(f *os.File, err error, offset, end, size int64) bool {
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		if end < 0 || end > size {
+			return true
 		}
+	case errors.Is(err, errCorruptRecord):
+		if end == size {
+			return true
+		}
+	default:
+		return false
 	}
 
-	return entries, nil
+	r := io.NewSectionReader(f, offset, size-offset)
+	var buf [4096]byte
+	for {
+		n, err := r.Read(buf[:])
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false
+			}
+		}
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
 }
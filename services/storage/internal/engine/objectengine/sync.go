@@ -0,0 +1,90 @@
+package objectengine
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/OliverSchlueter/goutils/sloki"
+)
+
+// SyncPolicy decides when writes to the shard files are flushed to disk.
+type SyncPolicy string
+
+const (
+	// SyncInterval flushes the shards with new writes every second, so at most the writes of the last second are lost on a crash. This is the default.
+	SyncInterval SyncPolicy = "interval"
+	// SyncAlways flushes every write before it returns, so acknowledged writes are never lost.
+	SyncAlways SyncPolicy = "always"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+// syncInterval is the interval in which shards are flushed with SyncInterval.
+const syncInterval = 1 * time.Second
+
+// ParseSyncPolicy parses the name of a sync policy. An empty name is SyncInterval.
+func ParseSyncPolicy(name string) (SyncPolicy, error) {
+	switch policy := SyncPolicy(name); policy {
+	case "":
+		return SyncInterval, nil
+	case SyncInterval, SyncAlways, SyncNever:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown sync policy %q", name)
+	}
+}
+
+// flush makes an entry that was just appended to the shard file durable according to the sync policy.
+// The caller must hold the append lock.
+func (s *shard) flush() error {
+	if s.syncPolicy == SyncAlways {
+		return s.file.Sync()
+	}
+
+	s.unsynced.Store(true)
+	return nil
+}
+
+func (b *Bucket) startSyncSchedule() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				b.syncShards()
+			}
+		}
+	}()
+}
+
+// syncShards flushes all shards that were written to since they were last flushed.
+func (b *Bucket) syncShards() {
+	for i, s := range b.shards {
+		if !s.unsynced.Swap(false) {
+			continue
+		}
+
+		// The read lock keeps compaction from replacing the file while it is flushed
+		s.RLock()
+		err := s.file.Sync()
+		s.RUnlock()
+
+		if err != nil {
+			s.unsynced.Store(true)
+			slog.Error("Failed to sync shard",
+				slog.String("database", b.database),
+				slog.String("collection", b.collection),
+				slog.Int("shard_index", i),
+				sloki.WrapError(err),
+			)
+		}
+	}
+}
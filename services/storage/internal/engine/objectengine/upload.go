@@ -0,0 +1,264 @@
+package objectengine
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// MaxObjectSize is the maximum size of an object in bytes, limited by the payload length field of the shard entries, which also covers the entry header
+	MaxObjectSize = math.MaxUint32 - maxEntryHeaderSize
+
+	// uploadTimeout is the time after which an upload without new chunks is aborted
+	uploadTimeout = 1 * time.Hour
+
+	// uploadExpiryInterval is the interval in which uploads are checked for the upload timeout
+	uploadExpiryInterval = 1 * time.Minute
+)
+
+// upload is an object that is uploaded in chunks.
+// The chunks are written to a part file, which is copied into the shard file when the upload is committed.
+type upload struct {
+	sync.Mutex
+	key         string
+	contentType string
+	metadata    map[string]string
+	file        *os.File
+	size        int64
+	checksum    uint32
+	done        bool         // Set once the upload was committed or aborted
+	lastActive  atomic.Int64 // Unix nanoseconds of the last chunk
+}
+
+func (b *Bucket) uploadsPath() string {
+	return filepath.Join(b.path, "uploads")
+}
+
+// BeginUpload starts a chunked upload of an object with a content type and user-defined metadata (see PutWithMetadata) and returns its upload ID.
+// Chunks are added with AppendUpload, the object only becomes visible once CommitUpload is called.
+// Uploads that receive no chunks for an hour are aborted.
+func (b *Bucket) BeginUpload(key string, contentType string, metadata map[string]string) (uint64, error) {
+	metadata, err := checkAttributes(contentType, metadata)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(b.uploadsPath(), 0755); err != nil {
+		return 0, err
+	}
+
+	b.uploadsMu.Lock()
+	defer b.uploadsMu.Unlock()
+
+	var id uint64
+	for id == 0 || b.uploads[id] != nil {
+		id = rand.Uint64()
+	}
+
+	partPath := filepath.Join(b.uploadsPath(), fmt.Sprintf("%d.part", id))
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	u := &upload{
+		key:         key,
+		contentType: contentType,
+		metadata:    metadata,
+		file:        f,
+	}
+	u.lastActive.Store(time.Now().UnixNano())
+	b.uploads[id] = u
+
+	return id, nil
+}
+
+// AppendUpload appends a chunk to the upload with the given ID.
+func (b *Bucket) AppendUpload(id uint64, chunk []byte) error {
+	b.uploadsMu.Lock()
+	u, ok := b.uploads[id]
+	b.uploadsMu.Unlock()
+	if !ok {
+		return ErrUploadNotFound
+	}
+
+	u.Lock()
+	defer u.Unlock()
+
+	if u.done {
+		return ErrUploadNotFound
+	}
+
+	if u.size+int64(len(chunk)) > MaxObjectSize {
+		return ErrObjectTooLarge
+	}
+
+	if _, err := u.file.Write(chunk); err != nil {
+		return err
+	}
+
+	u.size += int64(len(chunk))
+	u.checksum = crc32.Update(u.checksum, crc32.IEEETable, chunk)
+	u.lastActive.Store(time.Now().UnixNano())
+
+	return nil
+}
+
+// CommitUpload stores the uploaded object under the key of the upload as a new version and returns its metadata.
+// The upload is finished afterward, even if storing the object failed.
+func (b *Bucket) CommitUpload(id uint64) (*ObjectMeta, error) {
+	u, err := b.finishUpload(id)
+	if err != nil {
+		return nil, err
+	}
+	defer u.Unlock()
+	defer u.discard()
+
+	if _, err := u.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	s := b.shardForKey(u.key)
+	s.appendMu.Lock()
+	defer s.appendMu.Unlock()
+
+	offset, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := ObjectMeta{
+		Key:         u.key,
+		Offset:      offset,
+		Size:        uint32(u.size),
+		Checksum:    u.checksum,
+		ModifiedAt:  time.Now().UnixMilli(),
+		ContentType: u.contentType,
+		Metadata:    u.metadata,
+		VersionID:   b.newVersionID(),
+	}
+
+	if existing, ok := s.index[u.key]; ok {
+		meta.CreatedAt = existing.CreatedAt
+	} else {
+		meta.CreatedAt = meta.ModifiedAt
+	}
+
+	checksum, err := writeEntryFrom(s.file, &meta, u.file)
+	if err != nil {
+		return nil, truncateShard(s, offset, err)
+	}
+
+	// Verify checksum, the part file may have been damaged on disk
+	if checksum != u.checksum {
+		return nil, truncateShard(s, offset, errors.New("data checksum mismatch"))
+	}
+
+	if err := s.flush(); err != nil {
+		return nil, truncateShard(s, offset, err)
+	}
+
+	// Update in-memory index
+	s.setEntry(meta)
+
+	return &meta, nil
+}
+
+// AbortUpload discards the upload with the given ID.
+func (b *Bucket) AbortUpload(id uint64) error {
+	u, err := b.finishUpload(id)
+	if err != nil {
+		return err
+	}
+	defer u.Unlock()
+
+	return u.discard()
+}
+
+// finishUpload removes the upload with the given ID from the bucket and marks it as done.
+// The returned upload is locked.
+func (b *Bucket) finishUpload(id uint64) (*upload, error) {
+	b.uploadsMu.Lock()
+	u, ok := b.uploads[id]
+	delete(b.uploads, id)
+	b.uploadsMu.Unlock()
+	if !ok {
+		return nil, ErrUploadNotFound
+	}
+
+	u.Lock()
+	if u.done {
+		u.Unlock()
+		return nil, ErrUploadNotFound
+	}
+	u.done = true
+
+	return u, nil
+}
+
+func (b *Bucket) startUploadExpirySchedule() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(uploadExpiryInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				b.expireUploads()
+			}
+		}
+	}()
+}
+
+// expireUploads aborts all uploads that did not receive a chunk within the upload timeout.
+func (b *Bucket) expireUploads() {
+	deadline := time.Now().Add(-uploadTimeout).UnixNano()
+
+	b.uploadsMu.Lock()
+	var expired []uint64
+	for id, u := range b.uploads {
+		if u.lastActive.Load() < deadline {
+			expired = append(expired, id)
+		}
+	}
+	b.uploadsMu.Unlock()
+
+	for _, id := range expired {
+		_ = b.AbortUpload(id)
+	}
+}
+
+// discard closes and removes the part file of the upload.
+// The caller must hold the upload lock.
+func (u *upload) discard() error {
+	if err := u.file.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(u.file.Name())
+}
+
+// truncateShard removes a partially written entry from the end of the shard file, so that the file stays readable.
+// It returns cause, or the truncation error if the entry could not be removed.
+// The caller must hold the append lock.
+func truncateShard(s *shard, offset int64, cause error) error {
+	if err := s.file.Truncate(offset); err != nil {
+		return fmt.Errorf("%w (truncating shard failed: %v)", cause, err)
+	}
+
+	return cause
+}
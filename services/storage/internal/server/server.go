@@ -18,6 +18,9 @@ import (
 	"github.com/fancyinnovations/fancyspaces/storage/internal/command"
 )
 
+// slowConsumerWriteTimeout is how long writing a slow consumer notification may take, see SendSlowConsumer.
+const slowConsumerWriteTimeout = 1 * time.Second
+
 type Server struct {
 	addr          string
 	listener      net.Listener
@@ -254,6 +257,78 @@ func (s *Server) IsConnectionHealthy(connID string) bool {
 // SendBrokerMessage is called by the engine to send messages to subscribed clients.
 // Payload: | subject length (2 bytes) | subject (N bytes) | messages (list of codex.TypeBinary) |
 func (s *Server) SendBrokerMessage(db, coll, connID, subject string, msgs [][]byte) {
+	s.sendBrokerMessages(protocol.ClientCommandBrokerMessage, db, coll, connID, subject, msgs)
+}
+
+// SendStreamMessage is called by the engine to send messages of broker streams to clients with stream subscriptions.
+// The payload has the same format as the one of SendBrokerMessage, but every message is encoded with its sequence number, timestamp, subject, ID and header.
+func (s *Server) SendStreamMessage(db, coll, connID, subject string, msgs [][]byte) {
+	s.sendBrokerMessages(protocol.ClientCommandBrokerStreamMessage, db, coll, connID, subject, msgs)
+}
+
+// SendHeaderMessage is called by the engine to send messages together with their metadata to clients that subscribed with header.
+// The payload has the same format as the one of SendBrokerMessage, but every message is encoded with its ID, timestamp, sequence number, subject and header.
+func (s *Server) SendHeaderMessage(db, coll, connID, subject string, msgs [][]byte) {
+	s.sendBrokerMessages(protocol.ClientCommandBrokerHeaderMessage, db, coll, connID, subject, msgs)
+}
+
+// SendConsumerMessage is called by the engine to send messages of durable broker consumers to subscribed clients.
+// The payload has the same format as the one of SendBrokerMessage with the consumer name in place of the subject,
+// every message is encoded with its sequence number, timestamp, number of deliveries, subject, ID and header.
+func (s *Server) SendConsumerMessage(db, coll, connID, consumer string, msgs [][]byte) {
+	s.sendBrokerMessages(protocol.ClientCommandBrokerConsumerMessage, db, coll, connID, consumer, msgs)
+}
+
+// SendRequestMessage is called by the engine to send broker requests to subscribed clients.
+// The payload has the same format as the one of SendBrokerMessage, but every message is encoded with the subject to reply to.
+func (s *Server) SendRequestMessage(db, coll, connID, subject string, msgs [][]byte) {
+	s.sendBrokerMessages(protocol.ClientCommandBrokerRequestMessage, db, coll, connID, subject, msgs)
+}
+
+// SendSlowConsumer is called by the engine to notify a client that messages are dropped for one of its subscriptions, because it does not keep up with the publishers.
+// If disconnect is set, the connection is closed after the notification, so the client does not hold back the publishers any longer.
+// If the notification cannot be written within slowConsumerWriteTimeout, the client does not read at all and the connection is closed as well.
+func (s *Server) SendSlowConsumer(db, coll, connID string, notice []byte, disconnect bool) {
+	s.connectionsMu.Lock()
+	ctx, exists := s.connections[connID]
+	s.connectionsMu.Unlock()
+
+	if !exists {
+		return
+	}
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+
+		s.writeCommand(ctx.Conn, &protocol.Command{
+			ReqID:          0x4242, // dummy ReqID since this is a server-initiated message and not a response to a client command
+			ID:             protocol.ClientCommandBrokerSlowConsumer,
+			DatabaseName:   db,
+			CollectionName: coll,
+			Payload:        notice,
+		})
+	}()
+
+	timer := time.NewTimer(slowConsumerWriteTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-written:
+	case <-timer.C:
+		slog.Warn("Disconnecting stuck slow consumer", slog.String("ConnID", connID), slog.String("database", db), slog.String("collection", coll))
+		ctx.Conn.Close() // also unblocks the write
+		return
+	}
+
+	if disconnect {
+		slog.Warn("Disconnecting slow consumer", slog.String("ConnID", connID), slog.String("database", db), slog.String("collection", coll))
+		ctx.Conn.Close()
+	}
+}
+
+// sendBrokerMessages sends a command with the given ID that delivers messages of a subscription to a client.
+func (s *Server) sendBrokerMessages(cmdID uint16, db, coll, connID, subject string, msgs [][]byte) {
 	s.connectionsMu.Lock()
 	ctx, exists := s.connections[connID]
 	s.connectionsMu.Unlock()
@@ -301,7 +376,7 @@ func (s *Server) SendBrokerMessage(db, coll, connID, subject string, msgs [][]by
 
 	s.writeCommand(ctx.Conn, &protocol.Command{
 		ReqID:          0x4242, // dummy ReqID since this is a server-initiated message and not a response to a client command
-		ID:             protocol.ClientCommandBrokerMessage,
+		ID:             cmdID,
 		DatabaseName:   db,
 		CollectionName: coll,
 		Payload:        payloadBuf,